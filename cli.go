@@ -0,0 +1,391 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"tool-map/config"
+	"tool-map/models"
+	"tool-map/repositories"
+	"tool-map/services"
+
+	"gorm.io/gorm"
+)
+
+// command là một subcommand của CLI
+type command struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, args []string) error
+}
+
+// commands theo thứ tự hiển thị trong usage
+var commands = []command{
+	{"fetch", "tải relation từ OSM API, ghi file .osm hoặc JSON đã xử lý", runFetch},
+	{"build", "dựng polygon từ file .osm hoặc boundary store, không ghi DB", runBuild},
+	{"publish", "fetch + dựng polygon + lưu DB/Redis/MinIO (luồng import đầy đủ)", runPublish},
+	{"apply-osc", "áp dụng diff OsmChange vào boundary store và publish lại đơn vị bị ảnh hưởng", runApplyOsc},
+	{"centers", "tính tọa độ trung tâm cho xã/phường đã có polygon", runCenters},
+	{"export", "xuất tỉnh hoặc xã/phường từ DB ra GeoJSON, JSON hoặc CSV", runExport},
+	{"lookup", "tìm xã/phường chứa một tọa độ", runLookup},
+	{"geocode", "gắn MATT, MA_PHUONG_XA cho file CSV tọa độ bằng chỉ mục polygon offline, không cần DB", runGeocode},
+	{"validate", "dựng cây hành chính và đối chiếu với DM_PHUONG_XA", runValidate},
+	{"sync-polygons", "tải các file polygon từ MinIO về thư mục cục bộ", runSyncPolygons},
+	{"serve", "chạy HTTP server", runServe},
+	{"apikey", "tạo API key cho HTTP server", runAPIKey},
+	{"config", "in cấu hình đang dùng (secret được che)", runConfig},
+}
+
+// runCLI load cấu hình (-config <file> đứng trước lệnh), chọn subcommand theo args[0] và trả về exit code
+func runCLI(ctx context.Context, args []string) int {
+	configPath, args, err := splitConfigFlag(args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" || args[0] == "help" {
+		printUsage(os.Stderr)
+		if len(args) == 0 {
+			return 2
+		}
+		return 0
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != args[0] {
+			continue
+		}
+		cfg, err := config.Load(configPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		appConfig = cfg
+		redactor := cfg.Redactor()
+		setupLogging(cfg.Log, redactor)
+		services.SetConfig(cfg)
+
+		err = runCommand(ctx, cmd, args[1:])
+		switch {
+		case err == nil:
+			return 0
+		case errors.Is(err, flag.ErrHelp):
+			return 0
+		case errors.Is(err, errUsage):
+			return 2
+		default:
+			fmt.Fprintf(os.Stderr, "%s: %s\n", cmd.name, redactor.String(err.Error()))
+			return 1
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Không có lệnh '%s'\n\n", args[0])
+	printUsage(os.Stderr)
+	return 2
+}
+
+// splitConfigFlag tách flag toàn cục -config <file> / -config=<file> đứng trước tên lệnh
+func splitConfigFlag(args []string) (string, []string, error) {
+	if len(args) == 0 {
+		return "", args, nil
+	}
+	name, value, hasValue := strings.Cut(strings.TrimPrefix(args[0], "-"), "=")
+	if !strings.HasPrefix(args[0], "-") || (name != "config" && name != "-config") {
+		return "", args, nil
+	}
+	if hasValue {
+		return value, args[1:], nil
+	}
+	if len(args) < 2 {
+		return "", nil, fmt.Errorf("flag -config cần đường dẫn file")
+	}
+	return args[1], args[2:], nil
+}
+
+// runCommand chạy subcommand; panic được chuyển thành lỗi kèm stack trace để tiến trình
+// thoát với exit code khác 0 (các defer như lưu checkpoint vẫn chạy trước đó)
+func runCommand(ctx context.Context, cmd command, args []string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v\n%s", r, debug.Stack())
+		}
+	}()
+	return cmd.run(ctx, args)
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintf(w, "Cách dùng: tool-map <lệnh> [flags]\n\nCác lệnh:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-14s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(w, "\nChạy 'tool-map <lệnh> -h' để xem flags của từng lệnh.\n")
+	fmt.Fprintf(w, "Cấu hình đọc từ -config <file>, CONFIG_FILE hoặc %s; biến môi trường ghi đè giá trị trong file.\n", config.DefaultFile)
+}
+
+// errUsage báo flag/tham số sai; usage đã được in nên chỉ cần exit code 2
+var errUsage = errors.New("usage")
+
+// newFlagSet tạo FlagSet cho subcommand, lỗi parse trả về thay vì exit
+func newFlagSet(name, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Cách dùng: tool-map %s %s\n\n", name, usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseFlags parse args, quy đổi lỗi parse thành errUsage
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return errUsage
+	}
+	return nil
+}
+
+// app giữ các kết nối dùng chung của một lần chạy, chỉ mở khi lệnh cần tới
+type app struct {
+	db       *gorm.DB
+	dmTTRepo *repositories.DmTTRepository
+	osm      *services.OSMService
+	store    *services.BoundaryStore
+}
+
+// service trả về OSMService; withDB=true kết nối Oracle và Redis ở lần gọi đầu, lỗi kết nối được trả về
+// để lệnh kết thúc qua đường lỗi thông thường (các defer như saveStore vẫn chạy)
+func (a *app) service(withDB bool) (*services.OSMService, error) {
+	if withDB && a.db == nil {
+		// Khởi tạo Redis (nếu được cấu hình qua env)
+		services.InitRedis()
+
+		db, err := connectDB()
+		if err != nil {
+			return nil, err
+		}
+		a.db = db
+		slog.Info("Đã kết nối Oracle database")
+		a.dmTTRepo = repositories.NewDmTTRepository(a.db)
+		a.osm = services.NewOSMServiceWithDB(a.db)
+	}
+	if a.osm == nil {
+		a.osm = services.NewOSMService()
+	}
+	if a.store != nil {
+		a.osm.SetBoundaryStore(a.store)
+	}
+	return a.osm, nil
+}
+
+// useStore nạp boundary store; mọi relation fetch sau đó được lưu vào store
+func (a *app) useStore() (*services.BoundaryStore, error) {
+	if a.store == nil {
+		store, err := services.LoadBoundaryStore(boundaryStorePath())
+		if err != nil {
+			return nil, fmt.Errorf("lỗi khi đọc boundary store: %w", err)
+		}
+		a.store = store
+		if a.osm != nil {
+			a.osm.SetBoundaryStore(store)
+		}
+	}
+	return a.store, nil
+}
+
+// saveStore ghi boundary store nếu đã được nạp
+func (a *app) saveStore() {
+	if a.store == nil {
+		return
+	}
+	if err := a.store.Save(); err != nil {
+		slog.Error("Lỗi khi lưu boundary store", "error", err)
+		return
+	}
+	nodes, ways, relations := a.store.Len()
+	slog.Info("Đã lưu boundary store", "path", a.store.Path(), "nodes", nodes, "ways", ways, "relations", relations)
+}
+
+// relationSelector là bộ flag chọn relation dùng chung cho fetch, build, publish, validate
+type relationSelector struct {
+	relations string
+	idsFile   string
+	provinces string
+	discover  bool
+	area      int64
+}
+
+func (sel *relationSelector) register(fs *flag.FlagSet) {
+	fs.StringVar(&sel.relations, "relations", "", "danh sách relation ID, phân cách bằng dấu phẩy")
+	fs.StringVar(&sel.idsFile, "ids-file", "", "file chứa relation ID, mỗi dòng một ID")
+	fs.StringVar(&sel.provinces, "provinces", "", "danh sách MATT hoặc tên tỉnh (tra OSM_RELATION_ID trong DMTT)")
+	fs.BoolVar(&sel.discover, "discover", false, "tìm mọi tỉnh (admin_levels.province, mặc định 4) trong vùng -area qua Overpass")
+	fs.Int64Var(&sel.area, "area", 0, "relation vùng tìm kiếm cho -discover (mặc định osm.area_relation_id / OSM_AREA_RELATION_ID)")
+}
+
+// empty cho biết không có flag chọn relation nào được truyền
+func (sel *relationSelector) empty() bool {
+	return sel.relations == "" && sel.idsFile == "" && sel.provinces == "" && !sel.discover
+}
+
+// needsDB cho biết việc chọn relation có cần tra DMTT không
+func (sel *relationSelector) needsDB() bool {
+	return sel.provinces != ""
+}
+
+// resolve trả về danh sách relation theo thứ tự: -relations, -ids-file, -provinces, -discover (bỏ trùng)
+func (sel *relationSelector) resolve(ctx context.Context, a *app) ([]int64, error) {
+	var ids []int64
+	seen := make(map[int64]bool)
+	add := func(id int64) {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	for _, field := range splitList(sel.relations) {
+		id, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("relation ID không hợp lệ: %q", field)
+		}
+		add(id)
+	}
+
+	if sel.idsFile != "" {
+		fileIDs, err := readRelationIDsFile(sel.idsFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range fileIDs {
+			add(id)
+		}
+	}
+
+	if sel.provinces != "" {
+		osmService, err := a.service(true)
+		if err != nil {
+			return nil, err
+		}
+		for _, province := range splitList(sel.provinces) {
+			relationID, err := osmService.ResolveProvinceRelation(ctx, province)
+			if err != nil {
+				return nil, err
+			}
+			add(relationID)
+		}
+	}
+
+	if sel.discover {
+		osmService, err := a.service(false)
+		if err != nil {
+			return nil, err
+		}
+		discovered, err := osmService.DiscoverRelationIDs(ctx, sel.areaID(), models.OSMProvinceLevel)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range discovered {
+			add(id)
+		}
+	}
+
+	return ids, nil
+}
+
+// areaID là relation vùng cho -discover: flag -area, rồi osm.area_relation_id (mặc định Việt Nam)
+func (sel *relationSelector) areaID() int64 {
+	if sel.area != 0 {
+		return sel.area
+	}
+	return appConfig.OSM.AreaRelationID
+}
+
+// readRelationIDsFile đọc relation ID mỗi dòng một ID; file không tồn tại trả về danh sách rỗng
+func readRelationIDsFile(path string) ([]int64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("lỗi khi đọc file %s: %w", path, err)
+	}
+
+	var ids []int64
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		var id int64
+		if _, err := fmt.Sscanf(line, "%d", &id); err != nil {
+			slog.Warn("Bỏ qua dòng không hợp lệ", "file", path, "line", line)
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// splitList tách danh sách phân cách bằng dấu phẩy, bỏ phần tử rỗng
+func splitList(s string) []string {
+	var out []string
+	for _, field := range strings.Split(s, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			out = append(out, field)
+		}
+	}
+	return out
+}
+
+// createOutput mở file ghi kết quả; "-" là stdout
+func createOutput(path string) (io.WriteCloser, error) {
+	if path == "-" {
+		return nopWriteCloser{os.Stdout}, nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("không thể tạo file %s: %w", path, err)
+	}
+	return f, nil
+}
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+// writeOutput ghi kết quả vào path ("-" là stdout) qua hàm write
+func writeOutput(path string, write func(io.Writer) error) error {
+	out, err := createOutput(path)
+	if err != nil {
+		return err
+	}
+	if err := write(out); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if path != "-" {
+		slog.Info("Đã ghi file", "path", path)
+	}
+	return nil
+}
+
+// formatExt là phần mở rộng file mặc định của format xuất
+func formatExt(format string) string {
+	if format == services.FormatGeoJSON {
+		return "geojson"
+	}
+	return format
+}
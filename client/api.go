@@ -0,0 +1,174 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// MaxBatchPoints là số điểm tối đa của một request /lookup/batch; LookupBatch tự chia lô lớn hơn
+const MaxBatchPoints = 10000
+
+// ListProvinces trả về danh sách tỉnh/thành phố (GET /provinces)
+func (c *Client) ListProvinces(ctx context.Context) ([]Unit, error) {
+	var units []Unit
+	err := c.do(ctx, request{method: http.MethodGet, path: "/provinces", idempotent: true}, &units)
+	return units, err
+}
+
+// ListCommunes trả về các xã/phường của tỉnh maTT (GET /provinces/{matt}/communes)
+func (c *Client) ListCommunes(ctx context.Context, maTT string) ([]Unit, error) {
+	var units []Unit
+	path := "/provinces/" + maTT + "/communes"
+	err := c.do(ctx, request{method: http.MethodGet, path: path, idempotent: true}, &units)
+	return units, err
+}
+
+// Province trả về hình học của tỉnh; simplify là dung sai đơn giản hóa (mét), < 0 = mặc định của server
+func (c *Client) Province(ctx context.Context, maTT string, simplify float64) (*Feature, error) {
+	return c.feature(ctx, "/provinces/"+maTT, simplify)
+}
+
+// Commune trả về hình học của xã/phường; simplify như Province
+func (c *Client) Commune(ctx context.Context, maPhuongXa string, simplify float64) (*Feature, error) {
+	return c.feature(ctx, "/communes/"+maPhuongXa, simplify)
+}
+
+func (c *Client) feature(ctx context.Context, path string, simplify float64) (*Feature, error) {
+	query := url.Values{}
+	if simplify >= 0 {
+		query.Set("simplify", strconv.FormatFloat(simplify, 'f', -1, 64))
+	}
+	var feature Feature
+	if err := c.do(ctx, request{method: http.MethodGet, path: path, query: query, idempotent: true}, &feature); err != nil {
+		return nil, err
+	}
+	return &feature, nil
+}
+
+// Lookup tìm xã/phường và tỉnh chứa tọa độ (GET /lookup); province (MATT) khác rỗng thì chỉ tìm trong tỉnh đó
+func (c *Client) Lookup(ctx context.Context, lat, lon float64, province string) (*LookupResult, error) {
+	query := url.Values{}
+	query.Set("lat", strconv.FormatFloat(lat, 'f', -1, 64))
+	query.Set("lon", strconv.FormatFloat(lon, 'f', -1, 64))
+	if province != "" {
+		query.Set("province", province)
+	}
+	var result LookupResult
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/lookup", query: query, idempotent: true}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// LookupBatch tra cứu nhiều điểm qua POST /lookup/batch (NDJSON), chia lô theo MaxBatchPoints.
+// Kết quả theo đúng thứ tự points; lỗi của từng điểm nằm trong LookupResult.Error.
+func (c *Client) LookupBatch(ctx context.Context, points []Point, province string) ([]LookupResult, error) {
+	query := url.Values{}
+	if province != "" {
+		query.Set("province", province)
+	}
+	results := make([]LookupResult, 0, len(points))
+	for start := 0; start < len(points); start += MaxBatchPoints {
+		chunk := points[start:min(start+MaxBatchPoints, len(points))]
+		var body bytes.Buffer
+		encoder := json.NewEncoder(&body)
+		for _, point := range chunk {
+			if err := encoder.Encode(point); err != nil {
+				return nil, err
+			}
+		}
+
+		// Tra cứu chỉ đọc nên an toàn khi gửi lại
+		resp, err := c.send(ctx, request{
+			method:      http.MethodPost,
+			path:        "/lookup/batch",
+			query:       query,
+			body:        body.Bytes(),
+			contentType: "application/x-ndjson",
+			idempotent:  true,
+		})
+		if err != nil {
+			return nil, err
+		}
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			var result LookupResult
+			if err := json.Unmarshal(scanner.Bytes(), &result); err != nil {
+				resp.Body.Close()
+				return nil, fmt.Errorf("tool-map: response /lookup/batch không hợp lệ: %w", err)
+			}
+			results = append(results, result)
+		}
+		err = scanner.Err()
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(results) != len(points) {
+		return nil, fmt.Errorf("tool-map: /lookup/batch trả về %d kết quả cho %d điểm", len(results), len(points))
+	}
+	return results, nil
+}
+
+// SubmitImportJob tạo job import (POST /jobs/import, cần key scope admin).
+// Không thử lại khi lỗi mạng để tránh tạo trùng job.
+func (c *Client) SubmitImportJob(ctx context.Context, req ImportJobRequest) (*Job, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	var job Job
+	err = c.do(ctx, request{method: http.MethodPost, path: "/jobs/import", body: body, contentType: "application/json"}, &job)
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Job trả về trạng thái job (GET /jobs/{id})
+func (c *Client) Job(ctx context.Context, id string) (*Job, error) {
+	var job Job
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/jobs/" + id, idempotent: true}, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// CancelJob hủy job (POST /jobs/{id}/cancel); job đã kết thúc trả về APIError 409
+func (c *Client) CancelJob(ctx context.Context, id string) (*Job, error) {
+	var job Job
+	err := c.do(ctx, request{method: http.MethodPost, path: "/jobs/" + id + "/cancel", idempotent: true}, &job)
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// WaitJob hỏi trạng thái job mỗi interval cho tới khi job kết thúc hoặc ctx bị hủy
+func (c *Client) WaitJob(ctx context.Context, id string, interval time.Duration) (*Job, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		job, err := c.Job(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if job.Finished() {
+			return job, nil
+		}
+		select {
+		case <-ctx.Done():
+			return job, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
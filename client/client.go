@@ -0,0 +1,240 @@
+// Package client là client Go của HTTP API tool-map (xem server/openapi.json, phục vụ tại /openapi.json).
+// Các service khác tra cứu ranh giới và tọa độ qua client này thay vì gọi trực tiếp package services
+// hay đọc hash Redis geo_polygon:*. Package chỉ dùng thư viện chuẩn.
+//
+//	c, err := client.New("http://tool-map:8080", client.WithAPIKey(os.Getenv("TOOL_MAP_API_KEY")))
+//	result, err := c.Lookup(ctx, 21.0285, 105.8542, "")
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTimeout     = 30 * time.Second
+	defaultMaxAttempts = 4
+	defaultBaseDelay   = 200 * time.Millisecond
+	defaultMaxDelay    = 10 * time.Second
+	userAgent          = "tool-map-client/1"
+)
+
+// APIError là response lỗi ({"error": message}) của server
+type APIError struct {
+	StatusCode int
+	Message    string
+	RetryAfter time.Duration // theo header Retry-After (429), 0 nếu không có
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("tool-map: %d %s", e.StatusCode, e.Message)
+}
+
+// IsNotFound cho biết err là lỗi 404 (không có đơn vị hoặc job)
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// IsRateLimited cho biết err là lỗi 429 sau khi đã hết số lần thử lại
+func IsRateLimited(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusTooManyRequests
+}
+
+// Client gọi HTTP API tool-map; an toàn khi dùng đồng thời
+type Client struct {
+	baseURL     *url.URL
+	httpClient  *http.Client
+	apiKey      string
+	userAgent   string
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+}
+
+// Option cấu hình Client
+type Option func(*Client)
+
+// WithAPIKey đặt API key gửi trong header Authorization
+func WithAPIKey(key string) Option {
+	return func(c *Client) { c.apiKey = key }
+}
+
+// WithHTTPClient thay http.Client mặc định (timeout 30 giây)
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) { c.httpClient = httpClient }
+}
+
+// WithUserAgent đặt User-Agent, nên chứa tên service gọi để dễ đọc audit log
+func WithUserAgent(ua string) Option {
+	return func(c *Client) { c.userAgent = ua }
+}
+
+// WithRetry đặt số lần gọi tối đa (1 = không thử lại) và khoảng chờ backoff ban đầu/tối đa
+func WithRetry(maxAttempts int, baseDelay, maxDelay time.Duration) Option {
+	return func(c *Client) {
+		c.maxAttempts = max(maxAttempts, 1)
+		c.baseDelay = baseDelay
+		c.maxDelay = maxDelay
+	}
+}
+
+// New tạo client cho server tại baseURL (ví dụ http://tool-map:8080)
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimRight(baseURL, "/"))
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("tool-map: base URL không hợp lệ: %q", baseURL)
+	}
+	c := &Client{
+		baseURL:     u,
+		httpClient:  &http.Client{Timeout: defaultTimeout},
+		userAgent:   userAgent,
+		maxAttempts: defaultMaxAttempts,
+		baseDelay:   defaultBaseDelay,
+		maxDelay:    defaultMaxDelay,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// request là một lời gọi API; body được giữ dạng byte để gửi lại khi thử lại
+type request struct {
+	method      string
+	path        string
+	query       url.Values
+	body        []byte
+	contentType string
+	// idempotent = true thì được thử lại khi lỗi mạng và 502/503/504; 429 luôn được thử lại
+	// vì server từ chối trước khi xử lý
+	idempotent bool
+}
+
+// do gửi request, thử lại theo backoff, và giải mã response 2xx JSON vào out (nếu khác nil)
+func (c *Client) do(ctx context.Context, req request, out any) error {
+	resp, err := c.send(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("tool-map: response %s %s không hợp lệ: %w", req.method, req.path, err)
+	}
+	return nil
+}
+
+// send gửi request với retry; trả về response 2xx (caller đóng body) hoặc lỗi
+func (c *Client) send(ctx context.Context, req request) (*http.Response, error) {
+	u := *c.baseURL
+	u.Path += req.path
+	u.RawQuery = req.query.Encode()
+
+	var lastErr error
+	for attempt := 1; ; attempt++ {
+		resp, err := c.attempt(ctx, u.String(), req)
+		if err == nil {
+			return resp, nil
+		}
+		lastErr = err
+		if attempt >= c.maxAttempts || !c.retryable(req, err) {
+			return nil, lastErr
+		}
+
+		delay := c.backoff(attempt)
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+			delay = min(apiErr.RetryAfter, c.maxDelay)
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, errors.Join(lastErr, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+func (c *Client) attempt(ctx context.Context, target string, req request) (*http.Response, error) {
+	var body io.Reader
+	if req.body != nil {
+		body = bytes.NewReader(req.body)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Accept", "application/json")
+	httpReq.Header.Set("User-Agent", c.userAgent)
+	if req.contentType != "" {
+		httpReq.Header.Set("Content-Type", req.contentType)
+	}
+	if c.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	apiErr := &APIError{StatusCode: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
+	var payload struct {
+		Error string `json:"error"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if json.Unmarshal(data, &payload) == nil && payload.Error != "" {
+		apiErr.Message = payload.Error
+	}
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		apiErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	return nil, apiErr
+}
+
+// retryable cho biết lỗi có nên thử lại không; lỗi do ctx của caller thì không
+func (c *Client) retryable(req request, err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return req.idempotent // lỗi mạng
+	}
+	switch apiErr.StatusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return req.idempotent
+	}
+	return false
+}
+
+// backoff là thời gian chờ trước lần thử thứ attempt+1: lũy thừa 2 có jitter, tối đa maxDelay
+func (c *Client) backoff(attempt int) time.Duration {
+	delay := c.baseDelay << (attempt - 1)
+	if delay <= 0 || delay > c.maxDelay {
+		delay = c.maxDelay
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
@@ -0,0 +1,130 @@
+package client
+
+import "time"
+
+// Cấp đơn vị hành chính (admin_level OSM mặc định)
+const (
+	LevelProvince = 4
+	LevelCommune  = 6
+)
+
+// Trạng thái job import
+const (
+	JobStatusQueued    = "queued"
+	JobStatusRunning   = "running"
+	JobStatusSucceeded = "succeeded"
+	JobStatusFailed    = "failed"
+	JobStatusCanceled  = "canceled"
+)
+
+// Unit là một tỉnh hoặc xã/phường không kèm hình học (schema Unit)
+type Unit struct {
+	Level      int       `json:"level"`
+	Code       string    `json:"code"` // MATT hoặc MA_PHUONG_XA
+	Name       string    `json:"name"`
+	NameEn     string    `json:"nameEn,omitempty"`
+	MaTT       string    `json:"maTT,omitempty"`
+	RelationID *int64    `json:"osmRelationId,omitempty"`
+	LatCenter  *float64  `json:"latCenter,omitempty"`
+	LonCenter  *float64  `json:"lonCenter,omitempty"`
+	BBox       []float64 `json:"bbox,omitempty"` // [minLon, minLat, maxLon, maxLat]
+}
+
+// Feature là hình học GeoJSON của một đơn vị (schema Feature)
+type Feature struct {
+	Type       string            `json:"type"`
+	Properties FeatureProperties `json:"properties"`
+	Geometry   MultiPolygon      `json:"geometry"`
+}
+
+// FeatureProperties là thuộc tính của Feature
+type FeatureProperties struct {
+	Level          int      `json:"level"`
+	Code           string   `json:"code"`
+	Name           string   `json:"name"`
+	MaTT           string   `json:"maTT,omitempty"`
+	RelationID     *int64   `json:"osmRelationId,omitempty"`
+	LatCenter      *float64 `json:"latCenter,omitempty"`
+	LonCenter      *float64 `json:"lonCenter,omitempty"`
+	SimplifyMeters float64  `json:"simplifyMeters,omitempty"`
+}
+
+// MultiPolygon là hình học GeoJSON, tọa độ [lon, lat]
+type MultiPolygon struct {
+	Type        string           `json:"type"`
+	Coordinates [][][][2]float64 `json:"coordinates"`
+}
+
+// UnitRef là mã và tên đơn vị trong kết quả tra cứu
+type UnitRef struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
+
+// Point là một điểm gửi lên /lookup/batch
+type Point struct {
+	ID  string  `json:"id,omitempty"`
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+// LookupResult là kết quả tra cứu một tọa độ (schema LookupResult). Khi Found, DistanceToBoundaryMeters
+// là khoảng cách tới ranh giới xã chứa điểm; ngược lại Nearest là xã gần nhất trong ~1 km, nếu có.
+type LookupResult struct {
+	ID                       string   `json:"id,omitempty"`
+	Lat                      float64  `json:"lat"`
+	Lon                      float64  `json:"lon"`
+	Found                    bool     `json:"found"`
+	Commune                  *UnitRef `json:"commune,omitempty"`
+	Province                 *UnitRef `json:"province,omitempty"`
+	Nearest                  *UnitRef `json:"nearest,omitempty"`
+	DistanceToBoundaryMeters *float64 `json:"distanceToBoundaryMeters,omitempty"`
+	Error                    string   `json:"error,omitempty"` // lỗi của điểm trong lô
+}
+
+// ImportJobRequest là yêu cầu import lại các tỉnh theo relation ID và/hoặc MATT/tên tỉnh
+type ImportJobRequest struct {
+	RelationIDs []int64  `json:"relationIds,omitempty"`
+	Provinces   []string `json:"provinces,omitempty"`
+	Communes    *bool    `json:"communes,omitempty"` // mặc định true
+}
+
+// Job là trạng thái một job import (schema Job)
+type Job struct {
+	ID              string           `json:"id"`
+	Status          string           `json:"status"`
+	Request         ImportJobRequest `json:"request"`
+	Instance        string           `json:"instance"`
+	CreatedAt       time.Time        `json:"createdAt"`
+	StartedAt       *time.Time       `json:"startedAt,omitempty"`
+	FinishedAt      *time.Time       `json:"finishedAt,omitempty"`
+	CancelRequested bool             `json:"cancelRequested,omitempty"`
+	Error           string           `json:"error,omitempty"`
+	Provinces       []JobProvince    `json:"provinces"`
+}
+
+// Finished cho biết job đã kết thúc
+func (j *Job) Finished() bool {
+	return j.Status == JobStatusSucceeded || j.Status == JobStatusFailed || j.Status == JobStatusCanceled
+}
+
+// JobProvince là tiến độ một relation tỉnh của job
+type JobProvince struct {
+	RelationID    int64        `json:"relationId"`
+	Name          string       `json:"name,omitempty"`
+	MaTT          string       `json:"maTT,omitempty"`
+	Status        string       `json:"status"`
+	Error         string       `json:"error,omitempty"`
+	CommunesTotal int          `json:"communesTotal"`
+	CommunesDone  int          `json:"communesDone"`
+	Communes      []JobCommune `json:"communes,omitempty"`
+}
+
+// JobCommune là kết quả một xã/phường của job
+type JobCommune struct {
+	RelationID int64  `json:"relationId"`
+	Name       string `json:"name"`
+	MaPhuongXa string `json:"maPhuongXa,omitempty"`
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+}
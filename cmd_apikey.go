@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"tool-map/services"
+)
+
+// runAPIKey xử lý các lệnh con của apikey; hiện chỉ có new
+func runAPIKey(ctx context.Context, args []string) error {
+	if len(args) == 0 || args[0] != "new" {
+		fmt.Fprintf(os.Stderr, "Cách dùng: tool-map apikey new -client <tên> [-scope lookup|admin] [-rate-limit N]\n")
+		return errUsage
+	}
+
+	fs := newFlagSet("apikey new", "-client <tên> [-scope lookup|admin] [-rate-limit N]")
+	client := fs.String("client", "", "tên client (team/hệ thống), dùng trong audit log và metric")
+	scope := fs.String("scope", services.ScopeLookup, "quyền: lookup (chỉ đọc) hoặc admin")
+	rateLimit := fs.Int("rate-limit", -1, "số request mỗi auth.rate_window, -1 = dùng auth.rate_limit, 0 = không giới hạn")
+	if err := parseFlags(fs, args[1:]); err != nil {
+		return err
+	}
+	if *client == "" || (*scope != services.ScopeLookup && *scope != services.ScopeAdmin) {
+		fs.Usage()
+		return errUsage
+	}
+
+	key, err := services.NewAPIKey()
+	if err != nil {
+		return err
+	}
+	entry := services.APIKey{Client: *client, KeySHA256: services.HashAPIKey(key), Scope: *scope}
+	rateLimitSQL := "NULL"
+	if *rateLimit >= 0 {
+		entry.RateLimit = rateLimit
+		rateLimitSQL = fmt.Sprint(*rateLimit)
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	// Key chỉ được in một lần ra stdout; cấu hình chỉ lưu SHA-256
+	fmt.Println(key)
+	fmt.Fprintf(os.Stderr, "\nThêm vào auth.keys_file:\n  %s\n", data)
+	fmt.Fprintf(os.Stderr, "\nhoặc bảng API_KEY:\n  INSERT INTO API_KEY (KEY_HASH, CLIENT, SCOPE, RATE_LIMIT, TRANG_THAI, NGAY_TAO) VALUES ('%s', '%s', '%s', %s, 1, SYSDATE);\n",
+		entry.KeySHA256, entry.Client, entry.Scope, rateLimitSQL)
+	return nil
+}
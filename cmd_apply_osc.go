@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"tool-map/models"
+	"tool-map/services"
+)
+
+// runApplyOsc: tool-map apply-osc <file.osc[.gz]>... cập nhật tăng dần từ diff thay vì fetch lại toàn bộ
+func runApplyOsc(ctx context.Context, args []string) error {
+	fs := newFlagSet("apply-osc", "<file.osc[.gz]>...")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errUsage
+	}
+
+	a := &app{}
+	if _, err := a.useStore(); err != nil {
+		return err
+	}
+	osmService, err := a.service(true)
+	if err != nil {
+		return err
+	}
+	return runApplyOsmChange(ctx, osmService, fs.Args())
+}
+
+// runApplyOsmChange áp dụng lần lượt các file .osc vào boundary store rồi dựng lại và publish lại những
+// tỉnh/xã có way hoặc node bị thay đổi. Store chỉ được lưu sau khi mọi relation của một file đã publish xong;
+// khi có lỗi, store trên đĩa giữ nguyên trạng thái trước file đó để chạy lại cùng diff sẽ dựng lại các đơn vị.
+func runApplyOsmChange(ctx context.Context, osmService *services.OSMService, files []string) error {
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		slog.Info("Đang áp dụng OsmChange", "file", file)
+
+		change, err := models.ParseOsmChangeFromFile(file)
+		if err != nil {
+			return fmt.Errorf("lỗi khi đọc OsmChange %s: %w", file, err)
+		}
+
+		summary, err := osmService.ApplyOsmChange(change)
+		if err != nil {
+			return fmt.Errorf("lỗi khi áp dụng OsmChange %s: %w", file, err)
+		}
+		slog.Info("Đã áp dụng OsmChange",
+			"file", file,
+			"nodes", summary.Nodes,
+			"ways", summary.Ways,
+			"relations", summary.Relations,
+			"affected", len(summary.Affected))
+		for _, relationID := range summary.Deleted {
+			slog.Warn("Relation đã bị xóa trên OSM, cần kiểm tra thủ công", "relation", relationID)
+		}
+
+		// Publish hết các relation rồi mới báo lỗi, để một đơn vị lỗi không chặn các đơn vị khác
+		var failed []int64
+		for _, relationID := range summary.Affected {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := republishRelation(ctx, osmService, relationID, summary.IsIncomplete(relationID)); err != nil {
+				slog.Error("Lỗi khi publish lại relation", "relation", relationID, "error", err)
+				failed = append(failed, relationID)
+			}
+		}
+		if len(failed) > 0 {
+			return fmt.Errorf("%s: %d relation publish lỗi %v, boundary store không được lưu", file, len(failed), failed)
+		}
+
+		if err := osmService.BoundaryStore().Save(); err != nil {
+			return fmt.Errorf("lỗi khi lưu boundary store: %w", err)
+		}
+	}
+	return nil
+}
+
+// republishRelation dựng lại polygon của relation từ boundary store (hoặc fetch lại /full khi store
+// thiếu way/node) và lưu lại vào DB/Redis/MinIO nếu hình học thực sự thay đổi
+func republishRelation(ctx context.Context, osmService *services.OSMService, relationID int64, refetch bool) error {
+	logger := slog.With("relation", relationID)
+	unit, err := osmService.FindImportedUnit(ctx, relationID)
+	if err != nil {
+		return fmt.Errorf("lỗi khi tìm đơn vị của relation: %w", err)
+	}
+	if unit == nil {
+		logger.Info("Relation chưa được import vào DM, bỏ qua")
+		return nil
+	}
+	logger = logger.With("name", unit.Name, "level", unit.Level)
+	logger.Info("Dựng lại đơn vị")
+
+	var result *models.OSMProcessingResult
+	if refetch {
+		logger.Info("Boundary store thiếu dữ liệu, fetch lại từ OSM API")
+		result, err = osmService.FetchAndProcessRelation(ctx, relationID)
+	} else {
+		result, err = osmService.ProcessStoredRelation(relationID)
+	}
+	if err != nil {
+		return fmt.Errorf("lỗi khi xử lý relation: %w", err)
+	}
+
+	change, err := osmService.DetectChange(ctx, unit.Code, unit.Name, unit.Level, result)
+	if err != nil {
+		logger.Warn("Không xác định được thay đổi", "error", err)
+	} else {
+		logger.Info("Trạng thái thay đổi", "status", change.Status)
+	}
+
+	if change == nil || change.NeedsReprocess() {
+		switch unit.Level {
+		case 4:
+			err = osmService.PublishProvince(ctx, relationID, unit.Code, unit.Name, unit.Level, result)
+		case 6:
+			err = osmService.PublishCommune(ctx, unit.Code, unit.Name, unit.Level, unit.MaTT, result)
+		}
+		if err != nil {
+			return fmt.Errorf("lỗi khi lưu đơn vị: %w", err)
+		}
+	}
+	if err := osmService.RecordImport(ctx, change, result, changelogPath()); err != nil {
+		return fmt.Errorf("lỗi khi ghi nhận import: %w", err)
+	}
+	return nil
+}
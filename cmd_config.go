@@ -0,0 +1,24 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// runConfig xử lý các lệnh con của config; hiện chỉ có print
+func runConfig(ctx context.Context, args []string) error {
+	if len(args) == 0 || args[0] != "print" {
+		fmt.Fprintf(os.Stderr, "Cách dùng: tool-map config print [-format toml|json]\n")
+		return errUsage
+	}
+
+	fs := newFlagSet("config print", "[-format toml|json]")
+	format := fs.String("format", "toml", "định dạng in ra: toml hoặc json")
+	if err := parseFlags(fs, args[1:]); err != nil {
+		return err
+	}
+
+	// Cấu hình đã gộp file + biến môi trường; mật khẩu và secret key được che
+	return appConfig.Print(os.Stdout, *format)
+}
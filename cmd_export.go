@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"tool-map/models"
+	"tool-map/services"
+)
+
+// runExport xuất hình học tỉnh (level 4) hoặc xã/phường (level 6) đã lưu trong DB
+func runExport(ctx context.Context, args []string) error {
+	fs := newFlagSet("export", "[-level 4|6] [-provinces <matt,...>] [-format geojson|json|csv|index] [-out file]")
+	level := fs.Int("level", 4, "cấp đơn vị: 4 = tỉnh/thành phố, 6 = xã/phường")
+	provinces := fs.String("provinces", "", "danh sách MATT, phân cách bằng dấu phẩy (bỏ trống = tất cả)")
+	format := fs.String("format", services.FormatGeoJSON, "định dạng xuất: geojson, json, csv hoặc index (snapshot nhị phân cho geocode)")
+	out := fs.String("out", "", "file kết quả, \"-\" là stdout (mặc định export_<level>.<format>)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *level != 4 && *level != 6 {
+		return fmt.Errorf("level phải là 4 hoặc 6")
+	}
+	if *out == "" {
+		*out = fmt.Sprintf("export_%d.%s", *level, formatExt(*format))
+	}
+
+	a := &app{}
+	osmService, err := a.service(true)
+	if err != nil {
+		return err
+	}
+	maTTs := splitList(*provinces)
+
+	var units []services.UnitGeometry
+	if *level == 4 {
+		provinceUnits, err := osmService.ProvinceGeometries(ctx, maTTs)
+		if err != nil {
+			return err
+		}
+		units = provinceUnits
+	} else {
+		if len(maTTs) == 0 {
+			all, err := a.dmTTRepo.GetAll(ctx)
+			if err != nil {
+				return err
+			}
+			for _, tt := range all {
+				maTTs = append(maTTs, tt.MaTT)
+			}
+		}
+		for _, maTT := range maTTs {
+			communeUnits, err := osmService.CommuneGeometries(ctx, maTT)
+			if err != nil {
+				return err
+			}
+			units = append(units, communeUnits...)
+		}
+	}
+	slog.Info("Xuất đơn vị hành chính", "level", *level, "units", len(units))
+
+	return writeOutput(*out, func(w io.Writer) error {
+		return services.WriteGeometries(w, *format, units)
+	})
+}
+
+// runCenters tính tọa độ trung tâm cho các xã/phường đã có polygon nhưng chưa có tâm
+func runCenters(ctx context.Context, args []string) error {
+	fs := newFlagSet("centers", "")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	a := &app{}
+	osmService, err := a.service(true)
+	if err != nil {
+		return err
+	}
+	return updateCenters(ctx, osmService)
+}
+
+func updateCenters(ctx context.Context, osmService *services.OSMService) error {
+	slog.Info("Đang cập nhật tọa độ trung tâm của xã/phường")
+	if err := osmService.UpdateLatLonCenterForPhuongXa(ctx); err != nil {
+		return fmt.Errorf("lỗi khi cập nhật tọa độ trung tâm của xã/phường: %w", err)
+	}
+	slog.Info("Đã cập nhật tọa độ trung tâm của xã/phường")
+	return nil
+}
+
+// runLookup tìm xã/phường chứa một tọa độ bằng point-in-polygon, có thể giới hạn trong một tỉnh
+func runLookup(ctx context.Context, args []string) error {
+	fs := newFlagSet("lookup", "-lat <lat> -lon <lon> [-province <matt>] [-format text|json]")
+	lat := fs.Float64("lat", 0, "vĩ độ")
+	lon := fs.Float64("lon", 0, "kinh độ")
+	province := fs.String("province", "", "chỉ tìm trong tỉnh có MATT này")
+	format := fs.String("format", "text", "định dạng kết quả: text hoặc json")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if !flagPassed(fs, "lat") || !flagPassed(fs, "lon") {
+		fs.Usage()
+		return errUsage
+	}
+
+	a := &app{}
+	osmService, err := a.service(true)
+	if err != nil {
+		return err
+	}
+	result, err := osmService.NewPointLookup().Lookup(ctx, *province, *lat, *lon)
+	if err != nil {
+		return err
+	}
+
+	if *format == services.FormatJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(result)
+	}
+	switch {
+	case result.Found:
+		fmt.Printf("%s (%s), %s (%s), cách ranh giới %.0f m\n", result.Commune.Name, result.Commune.Code,
+			result.Province.Name, result.Province.Code, *result.DistanceToBoundaryMeters)
+	case result.Nearest != nil:
+		fmt.Printf("Không có xã/phường chứa (%f, %f); gần nhất là %s (%s), cách %.0f m\n", *lat, *lon,
+			result.Nearest.Name, result.Nearest.Code, *result.DistanceToBoundaryMeters)
+	default:
+		fmt.Printf("Không tìm thấy xã/phường chứa (%f, %f)\n", *lat, *lon)
+	}
+	return nil
+}
+
+// flagPassed cho biết flag có được truyền trên dòng lệnh không (phân biệt với giá trị 0)
+func flagPassed(fs *flag.FlagSet, name string) bool {
+	passed := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			passed = true
+		}
+	})
+	return passed
+}
+
+// validateReport là kết quả validate một relation tỉnh
+type validateReport struct {
+	RelationID int64                       `json:"relationId"`
+	Name       string                      `json:"name"`
+	Communes   int                         `json:"communes"`
+	Pending    int                         `json:"pendingSubareas"`
+	Coverage   *services.AdminTreeCoverage `json:"coverage,omitempty"`
+	Error      string                      `json:"error,omitempty"`
+}
+
+// runValidate dựng cây hành chính của từng tỉnh và đối chiếu xã/phường với DM_PHUONG_XA
+func runValidate(ctx context.Context, args []string) error {
+	fs := newFlagSet("validate", "-relations <id,...> | -provinces <matt,...> | -discover [flags]")
+	var sel relationSelector
+	sel.register(fs)
+	coverage := fs.Bool("coverage", true, "đối chiếu với DM_PHUONG_XA (cần database)")
+	format := fs.String("format", "text", "định dạng kết quả: text hoặc json")
+	out := fs.String("out", "-", "file kết quả, \"-\" là stdout")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if sel.empty() {
+		fs.Usage()
+		return errUsage
+	}
+
+	a := &app{}
+	relationIDs, err := sel.resolve(ctx, a)
+	if err != nil {
+		return err
+	}
+	osmService, err := a.service(*coverage || sel.needsDB())
+	if err != nil {
+		return err
+	}
+
+	reports := make([]validateReport, 0, len(relationIDs))
+	for _, relationID := range relationIDs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		report := validateReport{RelationID: relationID}
+		tree, err := osmService.BuildAdminTree(ctx, relationID)
+		if err != nil {
+			report.Error = err.Error()
+			reports = append(reports, report)
+			continue
+		}
+		report.Name = tree.Name
+		report.Communes = len(tree.Communes())
+		report.Pending = countPending(tree)
+		if _, err := services.SaveAdminTree(tree, adminTreeDir()); err != nil {
+			slog.Error("Lỗi khi lưu cây hành chính", "relation", relationID, "error", err)
+		}
+
+		if *coverage {
+			tt, err := a.dmTTRepo.GetByOsmRelationID(ctx, relationID)
+			if err == nil && tt == nil {
+				tt, err = a.dmTTRepo.GetByName(ctx, tree.Name)
+			}
+			switch {
+			case err != nil:
+				report.Error = err.Error()
+			case tt == nil:
+				report.Error = fmt.Sprintf("không tìm thấy tỉnh '%s' trong DMTT", tree.Name)
+			default:
+				result, err := osmService.CheckTreeCoverage(ctx, tree, tt.MaTT)
+				if err != nil {
+					report.Error = err.Error()
+				} else {
+					report.Coverage = result
+					if _, err := services.SaveAdminTreeCoverage(result, adminTreeDir()); err != nil {
+						slog.Error("Lỗi khi lưu kết quả đối chiếu", "relation", relationID, "error", err)
+					}
+				}
+			}
+		}
+		reports = append(reports, report)
+	}
+
+	return writeOutput(*out, func(w io.Writer) error {
+		if *format == services.FormatJSON {
+			encoder := json.NewEncoder(w)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(reports)
+		}
+		for _, r := range reports {
+			if r.Error != "" && r.Name == "" {
+				fmt.Fprintf(w, "%d: lỗi - %s\n", r.RelationID, r.Error)
+				continue
+			}
+			fmt.Fprintf(w, "%s (%d): %d xã/phường, %d subarea chưa xác định\n", r.Name, r.RelationID, r.Communes, r.Pending)
+			if r.Coverage != nil {
+				fmt.Fprintf(w, "  DM_PHUONG_XA: khớp %d, thiếu trên OSM %d, thiếu trong DB %d\n",
+					len(r.Coverage.Matched), len(r.Coverage.MissingInOSM), len(r.Coverage.MissingInDB))
+			}
+			if r.Error != "" {
+				fmt.Fprintf(w, "  lỗi: %s\n", r.Error)
+			}
+		}
+		return nil
+	})
+}
+
+// countPending đếm số subarea chưa xác định được trong cây
+func countPending(node *models.AdminTreeNode) int {
+	count := len(node.PendingSubareas())
+	for _, child := range node.Children {
+		count += countPending(child)
+	}
+	return count
+}
+
+// runSyncPolygons tải các file polygon từ bucket MinIO về thư mục cục bộ
+func runSyncPolygons(ctx context.Context, args []string) error {
+	fs := newFlagSet("sync-polygons", "[-out dir]")
+	outDir := fs.String("out", appConfig.Storage.PolygonDir, "thư mục lưu file polygon (storage.polygon_dir)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	a := &app{}
+	osmService, err := a.service(false)
+	if err != nil {
+		return err
+	}
+	_, err = osmService.DownloadPolygonFilesTo(ctx, *outDir)
+	return err
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"tool-map/models"
+	"tool-map/services"
+)
+
+// runFetch tải relation /full từ OSM API và ghi ra file, không đụng tới DB
+func runFetch(ctx context.Context, args []string) error {
+	fs := newFlagSet("fetch", "-relations <id,...> | -ids-file <file> | -provinces <matt,...> | -discover [flags]")
+	var sel relationSelector
+	sel.register(fs)
+	outDir := fs.String("out", "osm", "thư mục ghi file")
+	format := fs.String("format", "osm", "định dạng file: osm (XML gốc) hoặc json (kết quả đã xử lý)")
+	withCommunes := fs.Bool("with-communes", false, "fetch cả các xã/phường con (theo member subarea)")
+	useStore := fs.Bool("store", true, "lưu dữ liệu fetch vào boundary store để build/apply-osc dùng lại")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if sel.empty() {
+		fs.Usage()
+		return errUsage
+	}
+	if *format != "osm" && *format != services.FormatJSON {
+		return fmt.Errorf("format '%s' không được hỗ trợ (osm, json)", *format)
+	}
+
+	a := &app{}
+	if *useStore {
+		if _, err := a.useStore(); err != nil {
+			return err
+		}
+		defer a.saveStore()
+	}
+	relationIDs, err := sel.resolve(ctx, a)
+	if err != nil {
+		return err
+	}
+	osmService, err := a.service(sel.needsDB())
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(*outDir, 0755); err != nil {
+		return fmt.Errorf("không thể tạo thư mục %s: %w", *outDir, err)
+	}
+
+	for _, relationID := range relationIDs {
+		if err := fetchRelationToFile(ctx, osmService, relationID, *outDir, *format); err != nil {
+			return err
+		}
+		if !*withCommunes {
+			continue
+		}
+
+		tree, err := osmService.BuildAdminTree(ctx, relationID)
+		if err != nil {
+			return fmt.Errorf("lỗi khi dựng cây hành chính cho relation %d: %w", relationID, err)
+		}
+		for _, commune := range tree.Communes() {
+			if err := fetchRelationToFile(ctx, osmService, commune.RelationID, *outDir, *format); err != nil {
+				slog.Warn("Bỏ qua xã/phường", "relation", relationID, "commune", commune.Name, "commune_relation", commune.RelationID, "error", err)
+			}
+		}
+	}
+	return nil
+}
+
+// fetchRelationToFile ghi relation_<id>.osm hoặc relation_<id>.json vào dir
+func fetchRelationToFile(ctx context.Context, osmService *services.OSMService, relationID int64, dir, format string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	osm, err := osmService.FetchRelationOSM(ctx, relationID)
+	if err != nil {
+		return fmt.Errorf("lỗi khi lấy dữ liệu OSM (ID %d): %w", relationID, err)
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("relation_%d.%s", relationID, format))
+	if format == "osm" {
+		return writeOutput(path, func(w io.Writer) error { return writeOSM(w, osm) })
+	}
+
+	result, err := osmService.ProcessOSM(osm, relationID)
+	if err != nil {
+		return err
+	}
+	return writeOutput(path, func(w io.Writer) error {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(result)
+	})
+}
+
+// writeOSM ghi tài liệu OSM dạng XML, đọc lại được bằng models.ParseOSMFromFile
+func writeOSM(w io.Writer, osm *models.OSM) error {
+	data, err := xml.MarshalIndent(osm, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode OSM XML: %w", err)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// runBuild dựng polygon từ file .osm (hoặc boundary store) và xuất ra GeoJSON/JSON/CSV, không ghi DB
+func runBuild(ctx context.Context, args []string) error {
+	fs := newFlagSet("build", "[-in a.osm,b.osm] [-relations <id,...>] [flags]")
+	var sel relationSelector
+	sel.register(fs)
+	in := fs.String("in", "", "các file .osm đầu vào, phân cách bằng dấu phẩy (bỏ trống = boundary store)")
+	format := fs.String("format", services.FormatGeoJSON, "định dạng xuất: geojson, json hoặc csv")
+	out := fs.String("out", "", "file kết quả, \"-\" là stdout (mặc định build.<format>)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *out == "" {
+		*out = "build." + formatExt(*format)
+	}
+
+	a := &app{}
+	var relationIDs []int64
+	if !sel.empty() {
+		ids, err := sel.resolve(ctx, a)
+		if err != nil {
+			return err
+		}
+		relationIDs = ids
+	}
+
+	// Gom dữ liệu vào một store (tạm thời với -in) để tách được từng relation kèm way/node của nó
+	var source *services.BoundaryStore
+	if files := splitList(*in); len(files) > 0 {
+		source = services.NewBoundaryStore("")
+		for _, file := range files {
+			osm, err := models.ParseOSMFromFile(file)
+			if err != nil {
+				return fmt.Errorf("lỗi khi đọc %s: %w", file, err)
+			}
+			source.Merge(osm)
+			if sel.empty() {
+				_, relations := osm.GetAdministrativeBoundaries()
+				for _, relation := range relations {
+					relationIDs = append(relationIDs, relation.ID)
+				}
+			}
+		}
+	} else {
+		if sel.empty() {
+			fs.Usage()
+			return errUsage
+		}
+		store, err := a.useStore()
+		if err != nil {
+			return err
+		}
+		source = store
+	}
+
+	osmService, err := a.service(false)
+	if err != nil {
+		return err
+	}
+	units := make([]services.UnitGeometry, 0, len(relationIDs))
+	for _, relationID := range relationIDs {
+		osm, ok := source.RelationOSM(relationID)
+		if !ok {
+			slog.Warn("Bỏ qua relation không có trong dữ liệu đầu vào", "relation", relationID)
+			continue
+		}
+		unit, err := osmService.BuildUnitGeometry(osm, relationID)
+		if err != nil {
+			slog.Warn("Bỏ qua relation", "relation", relationID, "error", err)
+			continue
+		}
+		units = append(units, *unit)
+	}
+	slog.Info("Đã dựng relation", "built", len(units), "total", len(relationIDs))
+
+	return writeOutput(*out, func(w io.Writer) error {
+		return services.WriteGeometries(w, *format, units)
+	})
+}
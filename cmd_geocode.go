@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"tool-map/services"
+)
+
+// runGeocode gắn MATT, MA_PHUONG_XA cho từng dòng (id, lat, lon) của file CSV bằng chỉ mục polygon
+// trong bộ nhớ, không cần DB
+func runGeocode(ctx context.Context, args []string) error {
+	fs := newFlagSet("geocode", "-index <communes.geojson|snapshot> [-in points.csv] [-out tagged.csv] [flags]")
+	indexPath := fs.String("index", "", "chỉ mục polygon: GeoJSON hoặc snapshot nhị phân từ export -format geojson|index, nhiều file phân cách bằng dấu phẩy")
+	in := fs.String("in", "-", "file CSV/TSV đầu vào có header, \"-\" là stdin")
+	out := fs.String("out", "-", "file kết quả, \"-\" là stdout")
+	workers := fs.Int("workers", 0, "số worker tra cứu (mặc định số CPU)")
+	latColumn := fs.String("lat-col", "lat", "tên cột vĩ độ")
+	lonColumn := fs.String("lon-col", "lon", "tên cột kinh độ")
+	delimiter := fs.String("delimiter", "", "ký tự phân cách (mặc định tab cho .tsv, còn lại dấu phẩy)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *indexPath == "" {
+		fs.Usage()
+		return errUsage
+	}
+
+	comma := ','
+	switch {
+	case *delimiter == `\t`:
+		comma = '\t'
+	case *delimiter != "":
+		runes := []rune(*delimiter)
+		if len(runes) != 1 {
+			return fmt.Errorf("delimiter phải là một ký tự")
+		}
+		comma = runes[0]
+	case strings.EqualFold(filepath.Ext(*in), ".tsv"):
+		comma = '\t'
+	}
+
+	started := time.Now()
+	index, err := services.LoadPolygonIndex(splitList(*indexPath)...)
+	if err != nil {
+		return err
+	}
+	if index.Len() == 0 {
+		return fmt.Errorf("chỉ mục %s không có đơn vị nào có polygon", *indexPath)
+	}
+	slog.Info("Đã nạp chỉ mục polygon", "path", *indexPath, "units", index.Len(), "duration", time.Since(started))
+
+	input := io.Reader(os.Stdin)
+	if *in != "-" {
+		file, err := os.Open(*in)
+		if err != nil {
+			return fmt.Errorf("không mở được file %s: %w", *in, err)
+		}
+		defer file.Close()
+		input = file
+	}
+
+	started = time.Now()
+	var stats services.GeocodeStats
+	err = writeOutput(*out, func(w io.Writer) error {
+		buffered := bufio.NewWriterSize(w, 1<<20)
+		stats, err = services.GeocodeCSV(ctx, index, bufio.NewReaderSize(input, 1<<20), buffered, services.GeocodeConfig{
+			Workers:   *workers,
+			Comma:     comma,
+			LatColumn: *latColumn,
+			LonColumn: *lonColumn,
+		})
+		if err != nil {
+			return err
+		}
+		return buffered.Flush()
+	})
+	if err != nil {
+		return err
+	}
+	slog.Info("Hoàn thành geocode",
+		"rows", stats.Rows,
+		"matched", stats.Matched,
+		"province_only", stats.Province,
+		"unmatched", stats.Unmatched,
+		"invalid", stats.Invalid,
+		"duration", time.Since(started))
+	return nil
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+	"tool-map/metrics"
+	"tool-map/services"
+)
+
+// runPublish là luồng import đầy đủ: fetch từng tỉnh, dựng polygon, lưu DB/Redis/MinIO,
+// xử lý xã/phường con và cuối cùng cập nhật tọa độ trung tâm
+func runPublish(ctx context.Context, args []string) error {
+	fs := newFlagSet("publish", "[flags]")
+	var sel relationSelector
+	sel.register(fs)
+	communes := fs.Bool("communes", true, "xử lý cả các xã/phường con của mỗi tỉnh")
+	centers := fs.Bool("centers", true, "cập nhật tọa độ trung tâm xã/phường sau khi import")
+	timeout := fs.Duration("timeout", appConfig.OSM.RelationTimeout, "thời gian tối đa cho mỗi relation tỉnh (osm.relation_timeout)")
+	pipelineCfg := communePipelineConfig()
+	fs.IntVar(&pipelineCfg.FetchWorkers, "fetch-workers", pipelineCfg.FetchWorkers, "số worker fetch xã/phường (concurrency.fetch_workers)")
+	fs.IntVar(&pipelineCfg.WriteWorkers, "write-workers", pipelineCfg.WriteWorkers, "số worker ghi DB/Redis (concurrency.write_workers)")
+	dryRun := fs.Bool("dry-run", false, "chạy toàn bộ fetch/đối chiếu/dựng polygon nhưng không ghi Oracle, Redis, MinIO; in kế hoạch thay đổi")
+	planPath := fs.String("plan", "-", "file kế hoạch thay đổi khi -dry-run (.json ghi JSON, còn lại ghi văn bản; \"-\" là stdout)")
+	checkpointMode := fs.String("checkpoint", appConfig.Storage.CheckpointBackend, "nơi lưu tiến độ: file, redis hoặc none (storage.checkpoint_backend)")
+	checkpointFile := fs.String("checkpoint-file", appConfig.Storage.CheckpointFile, "file tiến độ khi -checkpoint=file (storage.checkpoint_file)")
+	retryFailed := fs.Bool("retry-failed", false, "chạy lại cả relation/xã/phường đã lỗi ở lần trước")
+	fresh := fs.Bool("fresh", false, "bỏ checkpoint cũ, chạy lại từ đầu")
+	reportBase := fs.String("report", appConfig.Storage.RunReport, "ghi báo cáo lần chạy ra <report>.json và <report>.html; rỗng để tắt (storage.run_report)")
+	metricsFile := fs.String("metrics-file", appConfig.Metrics.Textfile, "ghi metric Prometheus ra file cho node_exporter textfile collector; rỗng để tắt (metrics.textfile)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	a := &app{}
+	if _, err := a.useStore(); err != nil {
+		return err
+	}
+	osmService, err := a.service(true)
+	if err != nil {
+		return err
+	}
+
+	var plan *services.ChangePlan
+	if *dryRun {
+		plan = services.NewChangePlan()
+		osmService.SetDryRun(plan)
+		slog.Info("Chế độ dry-run: không ghi Oracle, Redis, MinIO")
+	}
+	var report *services.RunReport
+	if *reportBase != "" {
+		report = services.NewRunReport(*dryRun)
+		osmService.SetRunReport(report)
+	}
+
+	// Giữ thời điểm thành công của các tỉnh không chạy lần này; dry-run không ghi metric
+	if *dryRun {
+		*metricsFile = ""
+	}
+	if *metricsFile != "" {
+		if err := metrics.LoadLastSuccess(*metricsFile); err != nil {
+			slog.Warn("Không đọc được file metric của lần chạy trước", "error", err)
+		}
+		defer saveMetrics(*metricsFile)
+	}
+
+	// Không chọn relation: đọc id.txt, file trống thì tìm mọi tỉnh trong vùng qua Overpass
+	if sel.empty() {
+		sel.idsFile = "id.txt"
+	}
+	relationIDs, err := sel.resolve(ctx, a)
+	if err == nil && len(relationIDs) == 0 && !sel.discover {
+		sel = relationSelector{discover: true}
+		relationIDs, err = sel.resolve(ctx, a)
+	}
+	if err != nil {
+		return fmt.Errorf("lỗi khi lấy danh sách relation: %w", err)
+	}
+
+	// Dry-run không ghi gì nên cũng không đọc/ghi checkpoint
+	var checkpoint *services.Checkpoint
+	if !*dryRun {
+		checkpoint, err = openRunCheckpoint(ctx, *checkpointMode, *checkpointFile, *fresh)
+		if err != nil {
+			return err
+		}
+	}
+	pipelineCfg.Checkpoint = checkpoint
+	pipelineCfg.RetryFailed = *retryFailed
+	for _, relationID := range relationIDs {
+		if _, ok := checkpoint.Relation(relationID); !ok {
+			checkpoint.MarkRelation(ctx, relationID, "", services.StepPending, nil)
+		}
+	}
+
+	// Khóa relation sống lâu hơn timeout một chút để không hết hạn khi tiến trình vẫn đang chạy
+	lockTTL := *timeout + 5*time.Minute
+	failedRelations := 0
+	for _, relationID := range relationIDs {
+		if ctx.Err() != nil {
+			slog.Warn("Đã hủy, dừng trước relation", "relation", relationID, "error", ctx.Err())
+			break
+		}
+		if checkpoint.ShouldSkipRelation(relationID, *retryFailed) {
+			entry, _ := checkpoint.Relation(relationID)
+			slog.Info("Bỏ qua relation theo checkpoint", "relation", relationID, "province", entry.Name, "status", entry.Status)
+			report.SetProvince(relationID, entry.Name, "")
+			if entry.Status == services.StepFailed {
+				finishProvince(report, relationID, services.ReportStatusFailed, fmt.Errorf("lỗi ở lần chạy trước: %s", entry.Error))
+				failedRelations++
+			} else {
+				finishProvince(report, relationID, services.ReportStatusSkipped, nil)
+			}
+			continue
+		}
+
+		release := func() {}
+		if !*dryRun {
+			unlock, ok, err := services.AcquireRelationLock(ctx, relationID, lockTTL)
+			if err != nil {
+				slog.Error("Lỗi khi khóa relation", "relation", relationID, "error", err)
+				finishProvince(report, relationID, services.ReportStatusFailed, err)
+				failedRelations++
+				continue
+			}
+			if !ok {
+				slog.Warn("Relation đang được tiến trình khác xử lý, bỏ qua", "relation", relationID)
+				finishProvince(report, relationID, services.ReportStatusSkipped, fmt.Errorf("đang được tiến trình khác xử lý"))
+				continue
+			}
+			release = unlock
+		}
+
+		report.StartProvince(relationID)
+		relationCtx, cancel := context.WithTimeout(ctx, *timeout)
+		provinceName, err := osmService.ImportRelation(relationCtx, relationID, *communes, pipelineCfg.WithDefaults())
+		cancel()
+		release()
+
+		switch {
+		case ctx.Err() != nil:
+			// Bị ngắt giữa chừng: giữ trạng thái hiện tại để lần chạy sau làm tiếp
+			finishProvince(report, relationID, services.ReportStatusInterrupted, ctx.Err())
+		case err != nil:
+			slog.Error("Relation lỗi", "relation", relationID, "error", err)
+			checkpoint.MarkRelation(ctx, relationID, "", services.StepFailed, err)
+			finishProvince(report, relationID, services.ReportStatusFailed, err)
+			failedRelations++
+		default:
+			checkpoint.MarkRelation(ctx, relationID, "", services.StepPublished, nil)
+			finishProvince(report, relationID, services.ReportStatusPublished, nil)
+			if !*dryRun {
+				metrics.ProvinceSucceeded(relationID, provinceName, time.Now())
+			}
+		}
+	}
+	checkpoint.Flush(context.WithoutCancel(ctx))
+
+	if !osmService.DryRun() {
+		a.saveStore()
+	}
+	saveRunReport(report, *reportBase)
+
+	if plan != nil {
+		// Tọa độ trung tâm được tính từ polygon đã lưu nên không có gì để làm khi dry-run
+		return writeOutput(*planPath, func(w io.Writer) error {
+			if strings.HasSuffix(*planPath, ".json") {
+				return plan.WriteJSON(w)
+			}
+			return plan.WriteText(w)
+		})
+	}
+
+	if *centers && ctx.Err() == nil {
+		if err := updateCenters(ctx, osmService); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case ctx.Err() != nil:
+		slog.Warn("Đã dừng, chạy lại cùng lệnh để tiếp tục", "checkpoint", checkpoint.String())
+		return ctx.Err()
+	case failedRelations > 0:
+		slog.Warn("Chạy lại với -retry-failed để thử lại phần bị lỗi", "checkpoint", checkpoint.String())
+		return fmt.Errorf("%d/%d relation không publish được", failedRelations, len(relationIDs))
+	}
+
+	// Lượt chạy hoàn tất: xóa checkpoint để lần import sau bắt đầu từ đầu
+	if err := checkpoint.Reset(ctx); err != nil {
+		slog.Error("Lỗi khi xóa checkpoint", "error", err)
+	}
+	slog.Info("Hoàn thành publish", "relations", len(relationIDs))
+	return nil
+}
+
+// finishProvince chốt trạng thái relation trong báo cáo và metric
+func finishProvince(report *services.RunReport, relationID int64, status string, err error) {
+	report.FinishProvince(relationID, status, err)
+	metrics.Province(status)
+}
+
+// saveMetrics ghi metric của lần chạy ra textfile; lỗi ghi không làm hỏng kết quả import
+func saveMetrics(path string) {
+	if err := metrics.WriteTextfile(path); err != nil {
+		slog.Error("Lỗi khi ghi file metric", "error", err)
+		return
+	}
+	slog.Info("Đã ghi file metric", "path", path)
+}
+
+// saveRunReport chốt và ghi báo cáo lần chạy; lỗi ghi báo cáo không làm hỏng kết quả import
+func saveRunReport(report *services.RunReport, base string) {
+	if report == nil {
+		return
+	}
+	report.Finish()
+	jsonPath, htmlPath, err := report.Save(base)
+	if err != nil {
+		slog.Error("Lỗi khi ghi báo cáo lần chạy", "error", err)
+		return
+	}
+	slog.Info("Đã ghi báo cáo lần chạy", "json", jsonPath, "html", htmlPath,
+		"matched", report.Totals.Matched,
+		"unmatched", report.Totals.Unmatched,
+		"failed", report.Totals.Failed,
+		"approximated", report.Totals.Approximated)
+}
+
+// openRunCheckpoint mở checkpoint theo backend (file, redis, none); fresh xóa tiến độ cũ
+func openRunCheckpoint(ctx context.Context, mode, path string, fresh bool) (*services.Checkpoint, error) {
+	var checkpoint *services.Checkpoint
+	var err error
+	switch mode {
+	case "none", "":
+		return nil, nil
+	case "file":
+		checkpoint, err = services.OpenFileCheckpoint(ctx, path)
+	case "redis":
+		checkpoint, err = services.OpenRedisCheckpoint(ctx)
+	default:
+		return nil, fmt.Errorf("checkpoint '%s' không được hỗ trợ (file, redis, none)", mode)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if fresh {
+		if err := checkpoint.Reset(ctx); err != nil {
+			return nil, fmt.Errorf("không thể xóa checkpoint %s: %w", checkpoint, err)
+		}
+		return checkpoint, nil
+	}
+
+	relations, communes := checkpoint.Counts()
+	if len(relations) > 0 {
+		slog.Info("Tiếp tục từ checkpoint", "checkpoint", checkpoint.String(), "relations", relations, "communes", communes)
+	}
+	return checkpoint, nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"tool-map/metrics"
+	"tool-map/server"
+)
+
+// runServe chạy HTTP server (và gRPC LookupService nếu có địa chỉ) cho tới khi nhận Ctrl+C/SIGTERM
+func runServe(ctx context.Context, args []string) error {
+	fs := newFlagSet("serve", "[-addr host:port] [-grpc-addr host:port]")
+	addr := fs.String("addr", appConfig.HTTP.Addr, "địa chỉ lắng nghe (http.addr)")
+	grpcAddr := fs.String("grpc-addr", appConfig.HTTP.GRPCAddr, "địa chỉ gRPC LookupService, rỗng để tắt (http.grpc_addr)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	// Lấy lại thời điểm import thành công gần nhất từ textfile của lệnh publish theo lịch
+	if path := appConfig.Metrics.Textfile; path != "" {
+		if err := metrics.LoadLastSuccess(path); err != nil {
+			slog.Warn("Không đọc được file metric", "error", err)
+		}
+	}
+
+	a := &app{}
+	service, err := a.service(true)
+	if err != nil {
+		return err
+	}
+	// Viewer debug đọc way của relation từ boundary store, relation chưa có trong store được fetch từ OSM API
+	if _, err := a.useStore(); err != nil {
+		slog.Warn("Không dùng được boundary store cho viewer", "error", err)
+	}
+	access, err := service.NewAccessControl(ctx)
+	if err != nil {
+		return err
+	}
+	return server.New(service, access).ListenAndServe(ctx, *addr, *grpcAddr)
+}
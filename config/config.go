@@ -0,0 +1,485 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultFile là file cấu hình được đọc khi không chỉ định -config / CONFIG_FILE
+const DefaultFile = "config.toml"
+
+// DefaultBucket là bucket MinIO chứa polygon tỉnh (ghi khi publish, đọc khi export/sync)
+const DefaultBucket = "osm-data"
+
+// Config là cấu hình của tool-map. Thứ tự ưu tiên: giá trị mặc định < file TOML < biến môi trường.
+// Tag toml là tên khóa trong file, env là biến môi trường ghi đè, secret đánh dấu giá trị bị che khi in.
+type Config struct {
+	Oracle      OracleConfig      `toml:"oracle"`
+	Redis       RedisConfig       `toml:"redis"`
+	MinIO       MinIOConfig       `toml:"minio"`
+	OSM         OSMConfig         `toml:"osm"`
+	AdminLevels AdminLevelConfig  `toml:"admin_levels"`
+	Concurrency ConcurrencyConfig `toml:"concurrency"`
+	Simplify    SimplifyConfig    `toml:"simplify"`
+	Storage     StorageConfig     `toml:"storage"`
+	Log         LogConfig         `toml:"log"`
+	HTTP        HTTPConfig        `toml:"http"`
+	Metrics     MetricsConfig     `toml:"metrics"`
+	Tiles       TilesConfig       `toml:"tiles"`
+	Auth        AuthConfig        `toml:"auth"`
+}
+
+// OracleConfig là kết nối Oracle chứa DMTT và DM_PHUONG_XA cùng giới hạn connection pool
+type OracleConfig struct {
+	Host         string `toml:"host" env:"ORACLE_HOST"`
+	Port         int    `toml:"port" env:"ORACLE_PORT"`
+	Service      string `toml:"service" env:"ORACLE_SERVICE"`
+	User         string `toml:"user" env:"ORACLE_USER"`
+	Password     string `toml:"password" env:"ORACLE_PASSWORD" secret:"true"`
+	PasswordFile string `toml:"password_file" env:"ORACLE_PASSWORD_FILE"` // đọc password từ file (Docker/Kubernetes secret)
+
+	MaxOpenConns    int           `toml:"max_open_conns" env:"ORACLE_MAX_OPEN_CONNS"` // 0 = không giới hạn
+	MaxIdleConns    int           `toml:"max_idle_conns" env:"ORACLE_MAX_IDLE_CONNS"`
+	ConnMaxLifetime time.Duration `toml:"conn_max_lifetime" env:"ORACLE_CONN_MAX_LIFETIME"` // 0 = không giới hạn
+	ConnMaxIdleTime time.Duration `toml:"conn_max_idle_time" env:"ORACLE_CONN_MAX_IDLE_TIME"`
+}
+
+// RedisConfig là kết nối Redis; Cluster có giá trị thì dùng cluster thay cho Address
+type RedisConfig struct {
+	Address      string   `toml:"address" env:"REDIS_ADDRESS"`
+	Cluster      []string `toml:"cluster" env:"REDIS_CLUSTER"`
+	Password     string   `toml:"password" env:"REDIS_PASSWORD" secret:"true"`
+	PasswordFile string   `toml:"password_file" env:"REDIS_PASSWORD_FILE"`
+	Prefix       string   `toml:"prefix" env:"REDIS_PREFIX"`
+
+	PoolSize        int           `toml:"pool_size" env:"REDIS_POOL_SIZE"` // mỗi node; 0 = mặc định go-redis (10 * GOMAXPROCS)
+	MinIdleConns    int           `toml:"min_idle_conns" env:"REDIS_MIN_IDLE_CONNS"`
+	ConnMaxIdleTime time.Duration `toml:"conn_max_idle_time" env:"REDIS_CONN_MAX_IDLE_TIME"` // 0 = mặc định go-redis
+	DialTimeout     time.Duration `toml:"dial_timeout" env:"REDIS_DIAL_TIMEOUT"`
+}
+
+// MinIOConfig là kết nối MinIO và bucket lưu polygon
+type MinIOConfig struct {
+	Endpoint            string `toml:"endpoint" env:"MINIO_ENDPOINT"`
+	AccessKeyID         string `toml:"access_key_id" env:"MINIO_ACCESS_KEY_ID"`
+	AccessKeyIDFile     string `toml:"access_key_id_file" env:"MINIO_ACCESS_KEY_ID_FILE"`
+	SecretAccessKey     string `toml:"secret_access_key" env:"MINIO_SECRET_ACCESS_KEY" secret:"true"`
+	SecretAccessKeyFile string `toml:"secret_access_key_file" env:"MINIO_SECRET_ACCESS_KEY_FILE"`
+	UseSSL              bool   `toml:"use_ssl" env:"MINIO_USE_SSL"`
+	Bucket              string `toml:"bucket" env:"MINIO_BUCKET_NAME"`
+	ReturnURL           string `toml:"return_url" env:"MINIO_RETURN_URL"` // tiền tố URL lưu vào DB, mặc định http://<endpoint>
+
+	MaxIdleConnsPerHost int `toml:"max_idle_conns_per_host" env:"MINIO_MAX_IDLE_CONNS_PER_HOST"`
+}
+
+// OSMConfig là endpoint OSM/Overpass và giới hạn khi gọi
+type OSMConfig struct {
+	APIURL          string        `toml:"api_url" env:"OSM_API_URL"`       // rỗng = api.openstreetmap.org
+	OverpassURL     string        `toml:"overpass_url" env:"OVERPASS_URL"` // rỗng = overpass-api.de
+	RateLimit       float64       `toml:"rate_limit" env:"OSM_RATE_LIMIT"` // request/giây tới OSM API, <= 0 để tắt
+	AreaRelationID  int64         `toml:"area_relation_id" env:"OSM_AREA_RELATION_ID"`
+	RelationTimeout time.Duration `toml:"relation_timeout" env:"RELATION_TIMEOUT"` // thời gian tối đa cho mỗi relation tỉnh
+}
+
+// AdminLevelConfig là admin_level trên OSM tương ứng với tỉnh và xã/phường
+type AdminLevelConfig struct {
+	Province int `toml:"province" env:"OSM_PROVINCE_ADMIN_LEVEL"`
+	Commune  int `toml:"commune" env:"OSM_COMMUNE_ADMIN_LEVEL"`
+}
+
+// ConcurrencyConfig là số worker của pipeline xã/phường (0 = mặc định)
+type ConcurrencyConfig struct {
+	FetchWorkers int `toml:"fetch_workers" env:"COMMUNE_FETCH_WORKERS"`
+	WriteWorkers int `toml:"write_workers" env:"COMMUNE_WRITE_WORKERS"`
+	QueueSize    int `toml:"queue_size" env:"COMMUNE_QUEUE_SIZE"`
+}
+
+// SimplifyConfig là dung sai đơn giản hóa polygon (mét) khi trả hình học qua API
+type SimplifyConfig struct {
+	DefaultToleranceMeters float64 `toml:"default_tolerance_m" env:"SIMPLIFY_DEFAULT_TOLERANCE_M"`
+	MaxToleranceMeters     float64 `toml:"max_tolerance_m" env:"SIMPLIFY_MAX_TOLERANCE_M"`
+}
+
+// StorageConfig là các file/thư mục cục bộ mà tool đọc ghi
+type StorageConfig struct {
+	BoundaryStoreFile string `toml:"boundary_store_file" env:"BOUNDARY_STORE_FILE"`
+	AdminTreeDir      string `toml:"admin_tree_dir" env:"ADMIN_TREE_DIR"`
+	ChangelogFile     string `toml:"changelog_file" env:"CHANGELOG_FILE"`
+	PolygonDir        string `toml:"polygon_dir" env:"POLYGON_DIR"`
+	CheckpointBackend string `toml:"checkpoint_backend" env:"CHECKPOINT_BACKEND"` // file, redis hoặc none
+	CheckpointFile    string `toml:"checkpoint_file" env:"CHECKPOINT_FILE"`
+	RunReport         string `toml:"run_report" env:"RUN_REPORT"` // tiền tố file báo cáo, rỗng để tắt
+}
+
+// LogConfig là mức và định dạng log
+type LogConfig struct {
+	Level  string `toml:"level" env:"LOG_LEVEL"`   // debug, info, warn, error
+	Format string `toml:"format" env:"LOG_FORMAT"` // text hoặc json
+}
+
+// HTTPConfig là cấu hình lệnh serve. AdminToken là bearer token của các endpoint quản trị (/jobs);
+// rỗng thì các endpoint này bị tắt. GRPCAddr là địa chỉ của gRPC LookupService, rỗng để tắt.
+type HTTPConfig struct {
+	Addr           string `toml:"addr" env:"HTTP_ADDR"`
+	GRPCAddr       string `toml:"grpc_addr" env:"GRPC_ADDR"`
+	AdminToken     string `toml:"admin_token" env:"HTTP_ADMIN_TOKEN" secret:"true"`
+	AdminTokenFile string `toml:"admin_token_file" env:"HTTP_ADMIN_TOKEN_FILE"`
+}
+
+// MetricsConfig là cấu hình metric Prometheus. Textfile là file metric publish ghi ra sau mỗi lần
+// chạy (cho node_exporter textfile collector) và serve đọc lại khi khởi động; rỗng để tắt.
+type MetricsConfig struct {
+	Textfile string `toml:"textfile" env:"METRICS_FILE"`
+}
+
+// TilesConfig là cấu hình vector tile của serve. Index là các file chỉ mục polygon (như geocode -index);
+// rỗng thì chỉ mục được dựng từ DB ở request tile đầu tiên. MinIOPrefix khác rỗng thì tile dựng sẵn
+// <prefix>/<layer>/<z>/<x>/<y>.mvt trong bucket MinIO được dùng trước khi tự dựng.
+type TilesConfig struct {
+	Index          []string      `toml:"index" env:"TILES_INDEX"`
+	MinIOPrefix    string        `toml:"minio_prefix" env:"TILES_MINIO_PREFIX"`
+	CacheSize      int           `toml:"cache_size" env:"TILES_CACHE_SIZE"` // số tile giữ trong LRU, 0 để tắt
+	MaxAge         time.Duration `toml:"max_age" env:"TILES_MAX_AGE"`       // Cache-Control max-age
+	MaxZoom        int           `toml:"max_zoom" env:"TILES_MAX_ZOOM"`
+	CommuneMinZoom int           `toml:"commune_min_zoom" env:"TILES_COMMUNE_MIN_ZOOM"` // zoom nhỏ hơn trả tile rỗng
+}
+
+// AuthConfig là API key, rate limit và audit log của HTTP server
+type AuthConfig struct {
+	Required           bool          `toml:"required" env:"AUTH_REQUIRED"`   // API đọc cũng cần key; false thì request không key được xem là anonymous
+	KeysFile           string        `toml:"keys_file" env:"AUTH_KEYS_FILE"` // file JSON danh sách key (lưu SHA-256)
+	Oracle             bool          `toml:"oracle" env:"AUTH_ORACLE"`       // đọc thêm key từ bảng API_KEY
+	ReloadInterval     time.Duration `toml:"reload_interval" env:"AUTH_RELOAD_INTERVAL"`
+	RateLimit          int           `toml:"rate_limit" env:"AUTH_RATE_LIMIT"`                     // số request mỗi rate_window của một key, 0 = không giới hạn
+	AnonymousRateLimit int           `toml:"anonymous_rate_limit" env:"AUTH_ANONYMOUS_RATE_LIMIT"` // theo IP, cho request không key
+	RateWindow         time.Duration `toml:"rate_window" env:"AUTH_RATE_WINDOW"`
+	AuditLog           string        `toml:"audit_log" env:"AUTH_AUDIT_LOG"` // file JSONL, rỗng = ghi qua log chung
+}
+
+// Default trả về cấu hình mặc định
+func Default() *Config {
+	return &Config{
+		Oracle: OracleConfig{
+			Port:            1521,
+			MaxOpenConns:    10,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
+		},
+		Redis: RedisConfig{DialTimeout: 5 * time.Second},
+		MinIO: MinIOConfig{Bucket: DefaultBucket, MaxIdleConnsPerHost: 16},
+		OSM: OSMConfig{
+			RateLimit:       2,
+			AreaRelationID:  49915, // Việt Nam
+			RelationTimeout: 30 * time.Minute,
+		},
+		AdminLevels: AdminLevelConfig{Province: 4, Commune: 6},
+		Concurrency: ConcurrencyConfig{FetchWorkers: 4, WriteWorkers: 2},
+		Simplify:    SimplifyConfig{DefaultToleranceMeters: 0, MaxToleranceMeters: 1000},
+		Storage: StorageConfig{
+			BoundaryStoreFile: "boundary_store.osm",
+			AdminTreeDir:      "admin_tree",
+			ChangelogFile:     "changelog.jsonl",
+			PolygonDir:        "polygon",
+			CheckpointBackend: "file",
+			CheckpointFile:    "checkpoint.json",
+			RunReport:         "run_report",
+		},
+		Log:  LogConfig{Level: "info", Format: "text"},
+		HTTP: HTTPConfig{Addr: ":8080"},
+		Tiles: TilesConfig{
+			CacheSize:      4096,
+			MaxAge:         time.Hour,
+			MaxZoom:        16,
+			CommuneMinZoom: 8,
+		},
+		Auth: AuthConfig{
+			ReloadInterval:     time.Minute,
+			RateLimit:          600,
+			AnonymousRateLimit: 60,
+			RateWindow:         time.Minute,
+		},
+	}
+}
+
+// Load đọc cấu hình: mặc định, rồi file path (rỗng = CONFIG_FILE hoặc config.toml nếu có), rồi biến
+// môi trường, rồi các file secret (*_file); cuối cùng kiểm tra hợp lệ.
+// File chỉ định tường minh mà không tồn tại là lỗi.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+
+	explicit := path != ""
+	if !explicit {
+		path = os.Getenv("CONFIG_FILE")
+		explicit = path != ""
+	}
+	if !explicit {
+		path = DefaultFile
+	}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := decodeTOML(string(data), cfg); err != nil {
+			return nil, fmt.Errorf("lỗi khi đọc cấu hình %s: %w", path, err)
+		}
+	case errors.Is(err, os.ErrNotExist) && !explicit:
+		// Không có file cấu hình: chỉ dùng mặc định và biến môi trường
+	default:
+		return nil, fmt.Errorf("không thể đọc file cấu hình %s: %w", path, err)
+	}
+
+	if err := applyEnv(reflect.ValueOf(cfg).Elem()); err != nil {
+		return nil, err
+	}
+	if err := cfg.loadSecretFiles(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate kiểm tra giá trị cấu hình, gom mọi lỗi vào một thông báo
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Oracle.Port > 0 && c.Oracle.Port <= 65535, "oracle.port không hợp lệ: %d", c.Oracle.Port)
+	check(c.Oracle.MaxOpenConns >= 0, "oracle.max_open_conns không được âm")
+	check(c.Oracle.MaxIdleConns >= 0, "oracle.max_idle_conns không được âm")
+	check(c.Oracle.MaxOpenConns == 0 || c.Oracle.MaxIdleConns <= c.Oracle.MaxOpenConns, "oracle.max_idle_conns (%d) không được lớn hơn oracle.max_open_conns (%d)", c.Oracle.MaxIdleConns, c.Oracle.MaxOpenConns)
+	check(c.Oracle.ConnMaxLifetime >= 0 && c.Oracle.ConnMaxIdleTime >= 0, "oracle.conn_max_lifetime/conn_max_idle_time không được âm")
+	check(c.Redis.PoolSize >= 0 && c.Redis.MinIdleConns >= 0, "redis.pool_size/min_idle_conns không được âm")
+	check(c.Redis.ConnMaxIdleTime >= 0 && c.Redis.DialTimeout >= 0, "redis.conn_max_idle_time/dial_timeout không được âm")
+	check(c.MinIO.Bucket != "", "minio.bucket không được để trống")
+	check(c.MinIO.MaxIdleConnsPerHost >= 0, "minio.max_idle_conns_per_host không được âm")
+	check(c.OSM.RelationTimeout > 0, "osm.relation_timeout phải lớn hơn 0")
+	check(c.OSM.AreaRelationID > 0, "osm.area_relation_id không hợp lệ: %d", c.OSM.AreaRelationID)
+	check(c.AdminLevels.Province >= 1 && c.AdminLevels.Province <= 12, "admin_levels.province không hợp lệ: %d", c.AdminLevels.Province)
+	check(c.AdminLevels.Commune >= 1 && c.AdminLevels.Commune <= 12, "admin_levels.commune không hợp lệ: %d", c.AdminLevels.Commune)
+	check(c.AdminLevels.Province < c.AdminLevels.Commune, "admin_levels.province (%d) phải nhỏ hơn admin_levels.commune (%d)", c.AdminLevels.Province, c.AdminLevels.Commune)
+	check(c.Concurrency.FetchWorkers >= 0, "concurrency.fetch_workers không được âm")
+	check(c.Concurrency.WriteWorkers >= 0, "concurrency.write_workers không được âm")
+	check(c.Concurrency.QueueSize >= 0, "concurrency.queue_size không được âm")
+	check(c.Simplify.DefaultToleranceMeters >= 0, "simplify.default_tolerance_m không được âm")
+	check(c.Simplify.MaxToleranceMeters >= c.Simplify.DefaultToleranceMeters, "simplify.max_tolerance_m phải >= simplify.default_tolerance_m")
+	check(oneOf(c.Storage.CheckpointBackend, "file", "redis", "none"), "storage.checkpoint_backend '%s' không hợp lệ (file, redis, none)", c.Storage.CheckpointBackend)
+	check(c.Storage.CheckpointBackend != "file" || c.Storage.CheckpointFile != "", "storage.checkpoint_file không được để trống khi checkpoint_backend = file")
+	check(c.Storage.BoundaryStoreFile != "", "storage.boundary_store_file không được để trống")
+	check(c.Storage.AdminTreeDir != "", "storage.admin_tree_dir không được để trống")
+	check(c.Storage.ChangelogFile != "", "storage.changelog_file không được để trống")
+	check(c.Storage.PolygonDir != "", "storage.polygon_dir không được để trống")
+	var level slog.Level
+	check(level.UnmarshalText([]byte(c.Log.Level)) == nil, "log.level '%s' không hợp lệ (debug, info, warn, error)", c.Log.Level)
+	check(oneOf(strings.ToLower(c.Log.Format), "text", "json"), "log.format '%s' không hợp lệ (text, json)", c.Log.Format)
+	check(c.HTTP.Addr != "", "http.addr không được để trống")
+	check(c.HTTP.GRPCAddr == "" || c.HTTP.GRPCAddr != c.HTTP.Addr, "http.grpc_addr phải khác http.addr")
+	check(c.Tiles.CacheSize >= 0, "tiles.cache_size không được âm")
+	check(c.Tiles.MaxAge >= 0, "tiles.max_age không được âm")
+	check(c.Tiles.MaxZoom >= 0 && c.Tiles.MaxZoom <= 22, "tiles.max_zoom không hợp lệ: %d", c.Tiles.MaxZoom)
+	check(c.Tiles.CommuneMinZoom >= 0 && c.Tiles.CommuneMinZoom <= c.Tiles.MaxZoom, "tiles.commune_min_zoom phải trong khoảng 0..tiles.max_zoom")
+	check(c.Auth.ReloadInterval >= 0, "auth.reload_interval không được âm")
+	check(c.Auth.RateLimit >= 0 && c.Auth.AnonymousRateLimit >= 0, "auth.rate_limit/anonymous_rate_limit không được âm")
+	check(c.Auth.RateWindow > 0, "auth.rate_window phải lớn hơn 0")
+
+	if len(errs) > 0 {
+		return fmt.Errorf("cấu hình không hợp lệ: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// loadSecretFiles đọc secret từ các file *_file (ví dụ /run/secrets/oracle_password).
+// File được chỉ định thì ghi đè giá trị trong file cấu hình/biến môi trường; xuống dòng cuối bị bỏ.
+func (c *Config) loadSecretFiles() error {
+	for _, secret := range []struct {
+		name   string
+		path   string
+		target *string
+	}{
+		{"oracle.password_file", c.Oracle.PasswordFile, &c.Oracle.Password},
+		{"redis.password_file", c.Redis.PasswordFile, &c.Redis.Password},
+		{"minio.access_key_id_file", c.MinIO.AccessKeyIDFile, &c.MinIO.AccessKeyID},
+		{"minio.secret_access_key_file", c.MinIO.SecretAccessKeyFile, &c.MinIO.SecretAccessKey},
+		{"http.admin_token_file", c.HTTP.AdminTokenFile, &c.HTTP.AdminToken},
+	} {
+		if secret.path == "" {
+			continue
+		}
+		data, err := os.ReadFile(secret.path)
+		if err != nil {
+			return fmt.Errorf("không thể đọc %s: %w", secret.name, err)
+		}
+		*secret.target = strings.TrimRight(string(data), "\r\n")
+	}
+	return nil
+}
+
+// RequireOracle kiểm tra đủ thông tin kết nối Oracle; chỉ gọi với lệnh cần DB
+func (c *Config) RequireOracle() error {
+	var missing []string
+	if c.Oracle.Host == "" {
+		missing = append(missing, "oracle.host (ORACLE_HOST)")
+	}
+	if c.Oracle.Service == "" {
+		missing = append(missing, "oracle.service (ORACLE_SERVICE)")
+	}
+	if c.Oracle.User == "" {
+		missing = append(missing, "oracle.user (ORACLE_USER)")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("thiếu cấu hình Oracle: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+func oneOf(v string, values ...string) bool {
+	for _, value := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Masked trả về bản sao với các giá trị secret được che
+func (c *Config) Masked() *Config {
+	masked := *c
+	masked.Redis.Cluster = append([]string(nil), c.Redis.Cluster...)
+	maskSecrets(reflect.ValueOf(&masked).Elem())
+	return &masked
+}
+
+const maskedValue = "****"
+
+func maskSecrets(v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := v.Field(i)
+		switch {
+		case field.Kind() == reflect.Struct:
+			maskSecrets(field)
+		case t.Field(i).Tag.Get("secret") == "true" && field.Kind() == reflect.String && field.String() != "":
+			field.SetString(maskedValue)
+		}
+	}
+}
+
+// Print ghi cấu hình đã che secret theo format toml hoặc json
+func (c *Config) Print(w io.Writer, format string) error {
+	masked := c.Masked()
+	switch format {
+	case "toml", "":
+		return encodeTOML(w, masked)
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(toMap(reflect.ValueOf(masked).Elem()))
+	default:
+		return fmt.Errorf("format '%s' không được hỗ trợ (toml, json)", format)
+	}
+}
+
+// toMap đổi cấu hình sang map theo tên khóa TOML để bản JSON khớp với file cấu hình
+func toMap(v reflect.Value) map[string]any {
+	t := v.Type()
+	m := make(map[string]any, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := v.Field(i)
+		key := t.Field(i).Tag.Get("toml")
+		switch {
+		case field.Type() == durationType:
+			m[key] = time.Duration(field.Int()).String()
+		case field.Kind() == reflect.Struct:
+			m[key] = toMap(field)
+		default:
+			m[key] = field.Interface()
+		}
+	}
+	return m
+}
+
+// applyEnv ghi đè các trường có tag env bằng biến môi trường khác rỗng
+func applyEnv(v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := v.Field(i)
+		if field.Kind() == reflect.Struct {
+			if err := applyEnv(field); err != nil {
+				return err
+			}
+			continue
+		}
+		key := t.Field(i).Tag.Get("env")
+		if key == "" {
+			continue
+		}
+		value := os.Getenv(key)
+		if value == "" {
+			continue
+		}
+		if err := setFromString(field, value); err != nil {
+			return fmt.Errorf("%s không hợp lệ: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// setFromString gán giá trị dạng chuỗi (biến môi trường) vào trường; []string tách theo dấu phẩy
+func setFromString(field reflect.Value, value string) error {
+	value = strings.TrimSpace(value)
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		if field.Type() == reflect.TypeOf(time.Duration(0)) {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return err
+			}
+			field.SetInt(int64(d))
+			return nil
+		}
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("kiểu %s không được hỗ trợ", field.Type())
+	}
+	return nil
+}
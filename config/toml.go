@@ -0,0 +1,39 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// File cấu hình được đọc/ghi bằng github.com/BurntSushi/toml theo tag toml của Config; duration viết
+// dạng chuỗi ("30m"). Khóa hoặc bảng không có trong Config là lỗi để phát hiện gõ sai sớm.
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// decodeTOML ghi đè các trường của cfg bằng giá trị có trong file
+func decodeTOML(data string, cfg *Config) error {
+	md, err := toml.Decode(data, cfg)
+	if err != nil {
+		return err
+	}
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		keys := make([]string, len(undecoded))
+		for i, key := range undecoded {
+			keys[i] = key.String()
+		}
+		return fmt.Errorf("khóa không xác định: %s", strings.Join(keys, ", "))
+	}
+	return nil
+}
+
+// encodeTOML ghi cfg ra dạng TOML mà decodeTOML đọc lại được
+func encodeTOML(w io.Writer, cfg *Config) error {
+	encoder := toml.NewEncoder(w)
+	encoder.Indent = ""
+	return encoder.Encode(cfg)
+}
@@ -0,0 +1,103 @@
+package config
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecodeTOML(t *testing.T) {
+	data := `
+# Cấu hình thử
+[oracle]
+host = "db.local" # chú thích cuối dòng
+port = 1_522
+conn_max_lifetime = "1h30m"
+
+[redis]
+cluster = [
+  "10.0.0.1:6379",
+  "10.0.0.2:6379", # nhiều dòng, dấu phẩy cuối
+]
+password = "p#ss \"quoted\""
+
+[osm]
+rate_limit = 0.5
+area_relation_id = 49915
+
+[minio]
+use_ssl = true
+`
+	cfg := Default()
+	if err := decodeTOML(data, cfg); err != nil {
+		t.Fatalf("decodeTOML: %v", err)
+	}
+
+	want := Default()
+	want.Oracle.Host = "db.local"
+	want.Oracle.Port = 1522
+	want.Oracle.ConnMaxLifetime = 90 * time.Minute
+	want.Redis.Cluster = []string{"10.0.0.1:6379", "10.0.0.2:6379"}
+	want.Redis.Password = `p#ss "quoted"`
+	want.OSM.RateLimit = 0.5
+	want.MinIO.UseSSL = true
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("decoded config differs from expected\n got: %+v\nwant: %+v", cfg, want)
+	}
+}
+
+func TestDecodeTOMLErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"unknown key", "[oracle]\nhots = \"x\"\n", "oracle.hots"},
+		{"unknown table", "[oracl]\nhost = \"x\"\n", "oracl"},
+		{"key outside table", "host = \"x\"\n", "host"},
+		{"string for int", "[oracle]\nport = \"1521\"\n", "oracle.port"},
+		{"int for string", "[oracle]\nhost = 1\n", "oracle.host"},
+		{"bad duration", "[oracle]\nconn_max_lifetime = \"30 minutes\"\n", "30 minutes"},
+		{"syntax", "[oracle]\nport = \n", "line 2"},
+		{"unterminated table", "[oracle\nport = 1\n", "table name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := decodeTOML(tt.data, Default())
+			if err == nil {
+				t.Fatal("err = nil")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %q, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeTOMLRoundTrip(t *testing.T) {
+	cfg := Default()
+	cfg.Redis.Cluster = []string{"a:6379", "b:6379"}
+	cfg.Tiles.Index = []string{"index.geojson"}
+	cfg.OSM.RateLimit = 1
+	cfg.Auth.RateWindow = 90 * time.Second
+
+	var buf bytes.Buffer
+	if err := encodeTOML(&buf, cfg); err != nil {
+		t.Fatalf("encodeTOML: %v", err)
+	}
+	for _, line := range []string{`rate_window = "1m30s"`, "rate_limit = 1.0", `cluster = ["a:6379", "b:6379"]`} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("output missing %q:\n%s", line, buf.String())
+		}
+	}
+
+	decoded := &Config{}
+	if err := decodeTOML(buf.String(), decoded); err != nil {
+		t.Fatalf("decodeTOML(encodeTOML): %v\n%s", err, buf.String())
+	}
+	if !reflect.DeepEqual(decoded, cfg) {
+		t.Errorf("round trip differs\n got: %+v\nwant: %+v", decoded, cfg)
+	}
+}
@@ -0,0 +1,19 @@
+package entities
+
+import "time"
+
+// ApiKey là API key của một client gọi HTTP API; chỉ lưu SHA-256 (hex) của key
+type ApiKey struct {
+	KeyHash   string     `json:"keyHash" gorm:"column:KEY_HASH;primarykey"`
+	Client    string     `json:"client" gorm:"column:CLIENT"`
+	Scope     string     `json:"scope" gorm:"column:SCOPE"`            // lookup hoặc admin
+	RateLimit *int       `json:"rateLimit" gorm:"column:RATE_LIMIT"`   // request mỗi auth.rate_window, NULL = mặc định
+	TrangThai *int       `json:"trangThai" gorm:"column:TRANG_THAI"`   // 1 = đang dùng
+	ExpiresAt *time.Time `json:"expiresAt" gorm:"column:NGAY_HET_HAN"` // NULL = không hết hạn
+	RegDate   *time.Time `json:"-" gorm:"column:NGAY_TAO;<-:create"`
+	RegBy     string     `json:"regBy" gorm:"column:NGUOI_TAO"`
+}
+
+func (ApiKey) TableName() string {
+	return "API_KEY"
+}
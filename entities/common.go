@@ -23,6 +23,13 @@ type AddressBase struct {
 	MinLon *float64 `json:"minLon" gorm:"column:MIN_LON"`
 	MaxLat *float64 `json:"maxLat" gorm:"column:MAX_LAT"`
 	MinLat *float64 `json:"minLat" gorm:"column:MIN_LAT"`
+
+	// Phiên bản relation OSM của lần import gần nhất, dùng để phát hiện thay đổi biên giới
+	OsmRelationID *int64  `json:"osmRelationId" gorm:"column:OSM_RELATION_ID"`
+	OsmVersion    *int    `json:"osmVersion" gorm:"column:OSM_VERSION"`
+	OsmChangeset  *int64  `json:"osmChangeset" gorm:"column:OSM_CHANGESET"`
+	OsmTimestamp  *string `json:"osmTimestamp" gorm:"column:OSM_TIMESTAMP"`
+	GeometryHash  *string `json:"geometryHash" gorm:"column:GEOMETRY_HASH"`
 }
 type Address struct {
 	ID  int64   `json:"id" gorm:"column:ID"` // OSM Node ID
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"strings"
+	"tool-map/config"
+)
+
+// setupLogging cấu hình slog mặc định theo [log]: level (debug, info, warn, error; mặc định info)
+// và format (text hoặc json). Log ghi ra stderr để stdout chỉ chứa kết quả của lệnh.
+// Chi tiết từng node/way/polygon chỉ hiện ở mức debug. cfg đã được config.Validate kiểm tra.
+// Mọi message và thuộc tính đi qua redactor để password/secret key không lọt vào log tập trung.
+func setupLogging(cfg config.LogConfig, redactor *config.Redactor) {
+	level := slog.LevelInfo
+	_ = level.UnmarshalText([]byte(cfg.Level))
+
+	options := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	if strings.ToLower(cfg.Format) == "json" {
+		handler = slog.NewJSONHandler(os.Stderr, options)
+	} else {
+		handler = slog.NewTextHandler(os.Stderr, options)
+	}
+	slog.SetDefault(slog.New(redactor.Handler(handler)))
+}
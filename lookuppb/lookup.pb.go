@@ -0,0 +1,774 @@
+// Dịch vụ tra cứu ranh giới hành chính qua gRPC, dùng chung chỉ mục polygon và quy tắc tra cứu với REST API.
+// Sinh lại code Go: xem README, mục "gRPC".
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.8
+// 	protoc        (unknown)
+// source: lookup.proto
+
+package lookuppb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Level là cấp đơn vị hành chính, cùng giá trị với trường level của REST API
+type Level int32
+
+const (
+	Level_LEVEL_UNSPECIFIED Level = 0
+	Level_LEVEL_PROVINCE    Level = 4
+	Level_LEVEL_COMMUNE     Level = 6
+)
+
+// Enum value maps for Level.
+var (
+	Level_name = map[int32]string{
+		0: "LEVEL_UNSPECIFIED",
+		4: "LEVEL_PROVINCE",
+		6: "LEVEL_COMMUNE",
+	}
+	Level_value = map[string]int32{
+		"LEVEL_UNSPECIFIED": 0,
+		"LEVEL_PROVINCE":    4,
+		"LEVEL_COMMUNE":     6,
+	}
+)
+
+func (x Level) Enum() *Level {
+	p := new(Level)
+	*p = x
+	return p
+}
+
+func (x Level) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Level) Descriptor() protoreflect.EnumDescriptor {
+	return file_lookup_proto_enumTypes[0].Descriptor()
+}
+
+func (Level) Type() protoreflect.EnumType {
+	return &file_lookup_proto_enumTypes[0]
+}
+
+func (x Level) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Level.Descriptor instead.
+func (Level) EnumDescriptor() ([]byte, []int) {
+	return file_lookup_proto_rawDescGZIP(), []int{0}
+}
+
+type LookupRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// id tùy chọn, được trả lại trong response (dùng để ghép kết quả của BatchLookup)
+	Id  string  `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Lat float64 `protobuf:"fixed64,2,opt,name=lat,proto3" json:"lat,omitempty"`
+	Lon float64 `protobuf:"fixed64,3,opt,name=lon,proto3" json:"lon,omitempty"`
+	// province (MATT) khác rỗng thì chỉ nhận xã/phường thuộc tỉnh này
+	Province      string `protobuf:"bytes,4,opt,name=province,proto3" json:"province,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LookupRequest) Reset() {
+	*x = LookupRequest{}
+	mi := &file_lookup_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LookupRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LookupRequest) ProtoMessage() {}
+
+func (x *LookupRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_lookup_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LookupRequest.ProtoReflect.Descriptor instead.
+func (*LookupRequest) Descriptor() ([]byte, []int) {
+	return file_lookup_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *LookupRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *LookupRequest) GetLat() float64 {
+	if x != nil {
+		return x.Lat
+	}
+	return 0
+}
+
+func (x *LookupRequest) GetLon() float64 {
+	if x != nil {
+		return x.Lon
+	}
+	return 0
+}
+
+func (x *LookupRequest) GetProvince() string {
+	if x != nil {
+		return x.Province
+	}
+	return ""
+}
+
+type LookupResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Found bool                   `protobuf:"varint,2,opt,name=found,proto3" json:"found,omitempty"`
+	// commune và province không kèm hình học
+	Commune  *Unit `protobuf:"bytes,3,opt,name=commune,proto3" json:"commune,omitempty"`
+	Province *Unit `protobuf:"bytes,4,opt,name=province,proto3" json:"province,omitempty"`
+	// khoảng cách (mét) từ điểm tới ranh giới xã/phường chứa nó khi found, ngược lại tới ranh giới của nearest
+	DistanceToBoundaryMeters float64 `protobuf:"fixed64,5,opt,name=distance_to_boundary_meters,json=distanceToBoundaryMeters,proto3" json:"distance_to_boundary_meters,omitempty"`
+	// lỗi của riêng điểm này (tọa độ không hợp lệ), các điểm khác của BatchLookup vẫn được xử lý
+	Error string `protobuf:"bytes,6,opt,name=error,proto3" json:"error,omitempty"`
+	// khi không found: xã/phường có ranh giới gần nhất trong bán kính ~1 km, nếu có (như nearest của GET /lookup)
+	Nearest       *Unit `protobuf:"bytes,7,opt,name=nearest,proto3" json:"nearest,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LookupResponse) Reset() {
+	*x = LookupResponse{}
+	mi := &file_lookup_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LookupResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LookupResponse) ProtoMessage() {}
+
+func (x *LookupResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_lookup_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LookupResponse.ProtoReflect.Descriptor instead.
+func (*LookupResponse) Descriptor() ([]byte, []int) {
+	return file_lookup_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *LookupResponse) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *LookupResponse) GetFound() bool {
+	if x != nil {
+		return x.Found
+	}
+	return false
+}
+
+func (x *LookupResponse) GetCommune() *Unit {
+	if x != nil {
+		return x.Commune
+	}
+	return nil
+}
+
+func (x *LookupResponse) GetProvince() *Unit {
+	if x != nil {
+		return x.Province
+	}
+	return nil
+}
+
+func (x *LookupResponse) GetDistanceToBoundaryMeters() float64 {
+	if x != nil {
+		return x.DistanceToBoundaryMeters
+	}
+	return 0
+}
+
+func (x *LookupResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *LookupResponse) GetNearest() *Unit {
+	if x != nil {
+		return x.Nearest
+	}
+	return nil
+}
+
+type GetUnitRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// LEVEL_UNSPECIFIED thì tìm tỉnh trước, rồi xã/phường
+	Level Level `protobuf:"varint,1,opt,name=level,proto3,enum=toolmap.lookup.v1.Level" json:"level,omitempty"`
+	// MATT hoặc MA_PHUONG_XA
+	Code            string `protobuf:"bytes,2,opt,name=code,proto3" json:"code,omitempty"`
+	IncludeGeometry bool   `protobuf:"varint,3,opt,name=include_geometry,json=includeGeometry,proto3" json:"include_geometry,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *GetUnitRequest) Reset() {
+	*x = GetUnitRequest{}
+	mi := &file_lookup_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetUnitRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetUnitRequest) ProtoMessage() {}
+
+func (x *GetUnitRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_lookup_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetUnitRequest.ProtoReflect.Descriptor instead.
+func (*GetUnitRequest) Descriptor() ([]byte, []int) {
+	return file_lookup_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *GetUnitRequest) GetLevel() Level {
+	if x != nil {
+		return x.Level
+	}
+	return Level_LEVEL_UNSPECIFIED
+}
+
+func (x *GetUnitRequest) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *GetUnitRequest) GetIncludeGeometry() bool {
+	if x != nil {
+		return x.IncludeGeometry
+	}
+	return false
+}
+
+type ListChildrenRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// MATT của tỉnh; rỗng thì liệt kê các tỉnh
+	ParentCode      string `protobuf:"bytes,1,opt,name=parent_code,json=parentCode,proto3" json:"parent_code,omitempty"`
+	IncludeGeometry bool   `protobuf:"varint,2,opt,name=include_geometry,json=includeGeometry,proto3" json:"include_geometry,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *ListChildrenRequest) Reset() {
+	*x = ListChildrenRequest{}
+	mi := &file_lookup_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListChildrenRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListChildrenRequest) ProtoMessage() {}
+
+func (x *ListChildrenRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_lookup_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListChildrenRequest.ProtoReflect.Descriptor instead.
+func (*ListChildrenRequest) Descriptor() ([]byte, []int) {
+	return file_lookup_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ListChildrenRequest) GetParentCode() string {
+	if x != nil {
+		return x.ParentCode
+	}
+	return ""
+}
+
+func (x *ListChildrenRequest) GetIncludeGeometry() bool {
+	if x != nil {
+		return x.IncludeGeometry
+	}
+	return false
+}
+
+type ListChildrenResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Units         []*Unit                `protobuf:"bytes,1,rep,name=units,proto3" json:"units,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListChildrenResponse) Reset() {
+	*x = ListChildrenResponse{}
+	mi := &file_lookup_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListChildrenResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListChildrenResponse) ProtoMessage() {}
+
+func (x *ListChildrenResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_lookup_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListChildrenResponse.ProtoReflect.Descriptor instead.
+func (*ListChildrenResponse) Descriptor() ([]byte, []int) {
+	return file_lookup_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ListChildrenResponse) GetUnits() []*Unit {
+	if x != nil {
+		return x.Units
+	}
+	return nil
+}
+
+type Unit struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Level Level                  `protobuf:"varint,1,opt,name=level,proto3,enum=toolmap.lookup.v1.Level" json:"level,omitempty"`
+	Code  string                 `protobuf:"bytes,2,opt,name=code,proto3" json:"code,omitempty"`
+	Name  string                 `protobuf:"bytes,3,opt,name=name,proto3" json:"name,omitempty"`
+	// với xã/phường là tỉnh chứa nó, với tỉnh là chính mã tỉnh
+	MaTt string `protobuf:"bytes,4,opt,name=ma_tt,json=maTt,proto3" json:"ma_tt,omitempty"`
+	Bbox *BBox  `protobuf:"bytes,5,opt,name=bbox,proto3" json:"bbox,omitempty"`
+	// chỉ có khi include_geometry
+	Polygons      []*Polygon `protobuf:"bytes,6,rep,name=polygons,proto3" json:"polygons,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Unit) Reset() {
+	*x = Unit{}
+	mi := &file_lookup_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Unit) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Unit) ProtoMessage() {}
+
+func (x *Unit) ProtoReflect() protoreflect.Message {
+	mi := &file_lookup_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Unit.ProtoReflect.Descriptor instead.
+func (*Unit) Descriptor() ([]byte, []int) {
+	return file_lookup_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Unit) GetLevel() Level {
+	if x != nil {
+		return x.Level
+	}
+	return Level_LEVEL_UNSPECIFIED
+}
+
+func (x *Unit) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *Unit) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Unit) GetMaTt() string {
+	if x != nil {
+		return x.MaTt
+	}
+	return ""
+}
+
+func (x *Unit) GetBbox() *BBox {
+	if x != nil {
+		return x.Bbox
+	}
+	return nil
+}
+
+func (x *Unit) GetPolygons() []*Polygon {
+	if x != nil {
+		return x.Polygons
+	}
+	return nil
+}
+
+type BBox struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MinLon        float64                `protobuf:"fixed64,1,opt,name=min_lon,json=minLon,proto3" json:"min_lon,omitempty"`
+	MinLat        float64                `protobuf:"fixed64,2,opt,name=min_lat,json=minLat,proto3" json:"min_lat,omitempty"`
+	MaxLon        float64                `protobuf:"fixed64,3,opt,name=max_lon,json=maxLon,proto3" json:"max_lon,omitempty"`
+	MaxLat        float64                `protobuf:"fixed64,4,opt,name=max_lat,json=maxLat,proto3" json:"max_lat,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BBox) Reset() {
+	*x = BBox{}
+	mi := &file_lookup_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BBox) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BBox) ProtoMessage() {}
+
+func (x *BBox) ProtoReflect() protoreflect.Message {
+	mi := &file_lookup_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BBox.ProtoReflect.Descriptor instead.
+func (*BBox) Descriptor() ([]byte, []int) {
+	return file_lookup_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *BBox) GetMinLon() float64 {
+	if x != nil {
+		return x.MinLon
+	}
+	return 0
+}
+
+func (x *BBox) GetMinLat() float64 {
+	if x != nil {
+		return x.MinLat
+	}
+	return 0
+}
+
+func (x *BBox) GetMaxLon() float64 {
+	if x != nil {
+		return x.MaxLon
+	}
+	return 0
+}
+
+func (x *BBox) GetMaxLat() float64 {
+	if x != nil {
+		return x.MaxLat
+	}
+	return 0
+}
+
+// Polygon là vòng ngoài và các lỗ
+type Polygon struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Rings         []*Ring                `protobuf:"bytes,1,rep,name=rings,proto3" json:"rings,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Polygon) Reset() {
+	*x = Polygon{}
+	mi := &file_lookup_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Polygon) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Polygon) ProtoMessage() {}
+
+func (x *Polygon) ProtoReflect() protoreflect.Message {
+	mi := &file_lookup_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Polygon.ProtoReflect.Descriptor instead.
+func (*Polygon) Descriptor() ([]byte, []int) {
+	return file_lookup_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Polygon) GetRings() []*Ring {
+	if x != nil {
+		return x.Rings
+	}
+	return nil
+}
+
+// Ring là một vòng khép kín, tọa độ xếp liên tiếp [lon0, lat0, lon1, lat1, ...] như thứ tự GeoJSON
+type Ring struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Coordinates   []float64              `protobuf:"fixed64,1,rep,packed,name=coordinates,proto3" json:"coordinates,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Ring) Reset() {
+	*x = Ring{}
+	mi := &file_lookup_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Ring) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Ring) ProtoMessage() {}
+
+func (x *Ring) ProtoReflect() protoreflect.Message {
+	mi := &file_lookup_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Ring.ProtoReflect.Descriptor instead.
+func (*Ring) Descriptor() ([]byte, []int) {
+	return file_lookup_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Ring) GetCoordinates() []float64 {
+	if x != nil {
+		return x.Coordinates
+	}
+	return nil
+}
+
+var File_lookup_proto protoreflect.FileDescriptor
+
+const file_lookup_proto_rawDesc = "" +
+	"\n" +
+	"\flookup.proto\x12\x11toolmap.lookup.v1\"_\n" +
+	"\rLookupRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x10\n" +
+	"\x03lat\x18\x02 \x01(\x01R\x03lat\x12\x10\n" +
+	"\x03lon\x18\x03 \x01(\x01R\x03lon\x12\x1a\n" +
+	"\bprovince\x18\x04 \x01(\tR\bprovince\"\xa6\x02\n" +
+	"\x0eLookupResponse\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x14\n" +
+	"\x05found\x18\x02 \x01(\bR\x05found\x121\n" +
+	"\acommune\x18\x03 \x01(\v2\x17.toolmap.lookup.v1.UnitR\acommune\x123\n" +
+	"\bprovince\x18\x04 \x01(\v2\x17.toolmap.lookup.v1.UnitR\bprovince\x12=\n" +
+	"\x1bdistance_to_boundary_meters\x18\x05 \x01(\x01R\x18distanceToBoundaryMeters\x12\x14\n" +
+	"\x05error\x18\x06 \x01(\tR\x05error\x121\n" +
+	"\anearest\x18\a \x01(\v2\x17.toolmap.lookup.v1.UnitR\anearest\"\x7f\n" +
+	"\x0eGetUnitRequest\x12.\n" +
+	"\x05level\x18\x01 \x01(\x0e2\x18.toolmap.lookup.v1.LevelR\x05level\x12\x12\n" +
+	"\x04code\x18\x02 \x01(\tR\x04code\x12)\n" +
+	"\x10include_geometry\x18\x03 \x01(\bR\x0fincludeGeometry\"a\n" +
+	"\x13ListChildrenRequest\x12\x1f\n" +
+	"\vparent_code\x18\x01 \x01(\tR\n" +
+	"parentCode\x12)\n" +
+	"\x10include_geometry\x18\x02 \x01(\bR\x0fincludeGeometry\"E\n" +
+	"\x14ListChildrenResponse\x12-\n" +
+	"\x05units\x18\x01 \x03(\v2\x17.toolmap.lookup.v1.UnitR\x05units\"\xd8\x01\n" +
+	"\x04Unit\x12.\n" +
+	"\x05level\x18\x01 \x01(\x0e2\x18.toolmap.lookup.v1.LevelR\x05level\x12\x12\n" +
+	"\x04code\x18\x02 \x01(\tR\x04code\x12\x12\n" +
+	"\x04name\x18\x03 \x01(\tR\x04name\x12\x13\n" +
+	"\x05ma_tt\x18\x04 \x01(\tR\x04maTt\x12+\n" +
+	"\x04bbox\x18\x05 \x01(\v2\x17.toolmap.lookup.v1.BBoxR\x04bbox\x126\n" +
+	"\bpolygons\x18\x06 \x03(\v2\x1a.toolmap.lookup.v1.PolygonR\bpolygons\"j\n" +
+	"\x04BBox\x12\x17\n" +
+	"\amin_lon\x18\x01 \x01(\x01R\x06minLon\x12\x17\n" +
+	"\amin_lat\x18\x02 \x01(\x01R\x06minLat\x12\x17\n" +
+	"\amax_lon\x18\x03 \x01(\x01R\x06maxLon\x12\x17\n" +
+	"\amax_lat\x18\x04 \x01(\x01R\x06maxLat\"8\n" +
+	"\aPolygon\x12-\n" +
+	"\x05rings\x18\x01 \x03(\v2\x17.toolmap.lookup.v1.RingR\x05rings\"(\n" +
+	"\x04Ring\x12 \n" +
+	"\vcoordinates\x18\x01 \x03(\x01R\vcoordinates*E\n" +
+	"\x05Level\x12\x15\n" +
+	"\x11LEVEL_UNSPECIFIED\x10\x00\x12\x12\n" +
+	"\x0eLEVEL_PROVINCE\x10\x04\x12\x11\n" +
+	"\rLEVEL_COMMUNE\x10\x062\xde\x02\n" +
+	"\rLookupService\x12M\n" +
+	"\x06Lookup\x12 .toolmap.lookup.v1.LookupRequest\x1a!.toolmap.lookup.v1.LookupResponse\x12V\n" +
+	"\vBatchLookup\x12 .toolmap.lookup.v1.LookupRequest\x1a!.toolmap.lookup.v1.LookupResponse(\x010\x01\x12E\n" +
+	"\aGetUnit\x12!.toolmap.lookup.v1.GetUnitRequest\x1a\x17.toolmap.lookup.v1.Unit\x12_\n" +
+	"\fListChildren\x12&.toolmap.lookup.v1.ListChildrenRequest\x1a'.toolmap.lookup.v1.ListChildrenResponseB\x13Z\x11tool-map/lookuppbb\x06proto3"
+
+var (
+	file_lookup_proto_rawDescOnce sync.Once
+	file_lookup_proto_rawDescData []byte
+)
+
+func file_lookup_proto_rawDescGZIP() []byte {
+	file_lookup_proto_rawDescOnce.Do(func() {
+		file_lookup_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_lookup_proto_rawDesc), len(file_lookup_proto_rawDesc)))
+	})
+	return file_lookup_proto_rawDescData
+}
+
+var file_lookup_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_lookup_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
+var file_lookup_proto_goTypes = []any{
+	(Level)(0),                   // 0: toolmap.lookup.v1.Level
+	(*LookupRequest)(nil),        // 1: toolmap.lookup.v1.LookupRequest
+	(*LookupResponse)(nil),       // 2: toolmap.lookup.v1.LookupResponse
+	(*GetUnitRequest)(nil),       // 3: toolmap.lookup.v1.GetUnitRequest
+	(*ListChildrenRequest)(nil),  // 4: toolmap.lookup.v1.ListChildrenRequest
+	(*ListChildrenResponse)(nil), // 5: toolmap.lookup.v1.ListChildrenResponse
+	(*Unit)(nil),                 // 6: toolmap.lookup.v1.Unit
+	(*BBox)(nil),                 // 7: toolmap.lookup.v1.BBox
+	(*Polygon)(nil),              // 8: toolmap.lookup.v1.Polygon
+	(*Ring)(nil),                 // 9: toolmap.lookup.v1.Ring
+}
+var file_lookup_proto_depIdxs = []int32{
+	6,  // 0: toolmap.lookup.v1.LookupResponse.commune:type_name -> toolmap.lookup.v1.Unit
+	6,  // 1: toolmap.lookup.v1.LookupResponse.province:type_name -> toolmap.lookup.v1.Unit
+	6,  // 2: toolmap.lookup.v1.LookupResponse.nearest:type_name -> toolmap.lookup.v1.Unit
+	0,  // 3: toolmap.lookup.v1.GetUnitRequest.level:type_name -> toolmap.lookup.v1.Level
+	6,  // 4: toolmap.lookup.v1.ListChildrenResponse.units:type_name -> toolmap.lookup.v1.Unit
+	0,  // 5: toolmap.lookup.v1.Unit.level:type_name -> toolmap.lookup.v1.Level
+	7,  // 6: toolmap.lookup.v1.Unit.bbox:type_name -> toolmap.lookup.v1.BBox
+	8,  // 7: toolmap.lookup.v1.Unit.polygons:type_name -> toolmap.lookup.v1.Polygon
+	9,  // 8: toolmap.lookup.v1.Polygon.rings:type_name -> toolmap.lookup.v1.Ring
+	1,  // 9: toolmap.lookup.v1.LookupService.Lookup:input_type -> toolmap.lookup.v1.LookupRequest
+	1,  // 10: toolmap.lookup.v1.LookupService.BatchLookup:input_type -> toolmap.lookup.v1.LookupRequest
+	3,  // 11: toolmap.lookup.v1.LookupService.GetUnit:input_type -> toolmap.lookup.v1.GetUnitRequest
+	4,  // 12: toolmap.lookup.v1.LookupService.ListChildren:input_type -> toolmap.lookup.v1.ListChildrenRequest
+	2,  // 13: toolmap.lookup.v1.LookupService.Lookup:output_type -> toolmap.lookup.v1.LookupResponse
+	2,  // 14: toolmap.lookup.v1.LookupService.BatchLookup:output_type -> toolmap.lookup.v1.LookupResponse
+	6,  // 15: toolmap.lookup.v1.LookupService.GetUnit:output_type -> toolmap.lookup.v1.Unit
+	5,  // 16: toolmap.lookup.v1.LookupService.ListChildren:output_type -> toolmap.lookup.v1.ListChildrenResponse
+	13, // [13:17] is the sub-list for method output_type
+	9,  // [9:13] is the sub-list for method input_type
+	9,  // [9:9] is the sub-list for extension type_name
+	9,  // [9:9] is the sub-list for extension extendee
+	0,  // [0:9] is the sub-list for field type_name
+}
+
+func init() { file_lookup_proto_init() }
+func file_lookup_proto_init() {
+	if File_lookup_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_lookup_proto_rawDesc), len(file_lookup_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   9,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_lookup_proto_goTypes,
+		DependencyIndexes: file_lookup_proto_depIdxs,
+		EnumInfos:         file_lookup_proto_enumTypes,
+		MessageInfos:      file_lookup_proto_msgTypes,
+	}.Build()
+	File_lookup_proto = out.File
+	file_lookup_proto_goTypes = nil
+	file_lookup_proto_depIdxs = nil
+}
@@ -0,0 +1,230 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+)
+
+// Registry chứa mọi metric của tool-map kèm metric Go runtime và process
+var Registry = prometheus.NewRegistry()
+
+var factory = promauto.With(Registry)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+const namespace = "tool_map"
+
+// Tên API gọi ra ngoài
+const (
+	APIOSM      = "osm"
+	APIOverpass = "overpass"
+)
+
+// Đích ghi dữ liệu khi import
+const (
+	TargetOracle = "oracle"
+	TargetRedis  = "redis"
+	TargetMinIO  = "minio"
+)
+
+// Kết quả đối chiếu xã/phường OSM với DM_PHUONG_XA
+const (
+	CommuneMatched   = "matched"
+	CommuneUnmatched = "unmatched"
+	CommuneFailed    = "failed"
+	CommuneSkipped   = "skipped"
+)
+
+// Tên gauge lần import thành công gần nhất, dùng khi đọc lại textfile của lần chạy trước
+const provinceLastSuccessName = namespace + "_province_last_success_timestamp_seconds"
+
+var (
+	osmRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "osm_requests_total",
+		Help:      "Số request tới OSM API/Overpass theo endpoint và HTTP status (error = lỗi kết nối).",
+	}, []string{"api", "endpoint", "status"})
+
+	osmRequestDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "osm_request_duration_seconds",
+		Help:      "Thời gian một request tới OSM API/Overpass, không tính thời gian chờ rate limit.",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 180},
+	}, []string{"api", "endpoint"})
+
+	polygonsBuilt = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "polygons_built_total",
+		Help:      "Số polygon đã dựng theo cấp đơn vị.",
+	}, []string{"level"})
+
+	polygonsApproximated = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "polygons_approximated_total",
+		Help:      "Số đơn vị có polygon dựng gần đúng (convex hull) vì các way không nối được.",
+	}, []string{"level"})
+
+	writeErrors = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "write_errors_total",
+		Help:      "Số lỗi ghi khi import theo đích (oracle, redis, minio).",
+	}, []string{"target"})
+
+	communes = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "communes_total",
+		Help:      "Số xã/phường đã xử lý theo kết quả đối chiếu (matched, unmatched, failed, skipped).",
+	}, []string{"result"})
+
+	provinces = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "provinces_total",
+		Help:      "Số relation tỉnh đã xử lý theo trạng thái (published, failed, skipped, interrupted).",
+	}, []string{"status"})
+
+	httpRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Số request HTTP theo client (tên API key, anonymous), route và status.",
+	}, []string{"client", "route", "status"})
+
+	httpRateLimited = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_rate_limited_total",
+		Help:      "Số request HTTP bị từ chối vì vượt rate limit theo client.",
+	}, []string{"client"})
+
+	grpcRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "grpc_requests_total",
+		Help:      "Số lời gọi gRPC theo client, method và mã status gRPC; một stream BatchLookup tính là một lời gọi.",
+	}, []string{"client", "method", "code"})
+
+	provinceLastSuccess = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "province_last_success_timestamp_seconds",
+		Help:      "Unix time của lần import thành công gần nhất theo relation tỉnh.",
+	}, []string{"relation", "province"})
+)
+
+// ObserveOSMRequest ghi nhận một request tới api (osm, overpass); status = 0 khi không nhận được response
+func ObserveOSMRequest(api, endpoint string, status int, started time.Time) {
+	label := "error"
+	if status > 0 {
+		label = strconv.Itoa(status)
+	}
+	osmRequests.WithLabelValues(api, endpoint, label).Inc()
+	osmRequestDuration.WithLabelValues(api, endpoint).Observe(time.Since(started).Seconds())
+}
+
+// PolygonsBuilt ghi nhận số polygon dựng cho một đơn vị cấp level (province, commune)
+func PolygonsBuilt(level string, count int, approximated bool) {
+	polygonsBuilt.WithLabelValues(level).Add(float64(count))
+	if approximated {
+		polygonsApproximated.WithLabelValues(level).Inc()
+	}
+}
+
+// CountWriteError tăng bộ đếm lỗi ghi của target khi err khác nil (trừ lỗi do ctx bị hủy) và trả lại err
+func CountWriteError(target string, err error) error {
+	if err != nil && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
+		writeErrors.WithLabelValues(target).Inc()
+	}
+	return err
+}
+
+// Commune ghi nhận kết quả đối chiếu của một xã/phường
+func Commune(result string) {
+	communes.WithLabelValues(result).Inc()
+}
+
+// Province ghi nhận trạng thái xử lý một relation tỉnh
+func Province(status string) {
+	provinces.WithLabelValues(status).Inc()
+}
+
+// ProvinceSucceeded đặt thời điểm import thành công gần nhất của relation tỉnh
+func ProvinceSucceeded(relationID int64, name string, at time.Time) {
+	provinceLastSuccess.WithLabelValues(strconv.FormatInt(relationID, 10), name).Set(float64(at.Unix()))
+}
+
+// HTTPRequest ghi nhận một request HTTP của client; route là pattern của mux
+func HTTPRequest(client, route string, status int) {
+	httpRequests.WithLabelValues(client, route, strconv.Itoa(status)).Inc()
+}
+
+// HTTPRateLimited ghi nhận một request bị từ chối vì rate limit
+func HTTPRateLimited(client string) {
+	httpRateLimited.WithLabelValues(client).Inc()
+}
+
+// GRPCRequest ghi nhận một lời gọi gRPC của client; method là tên đầy đủ (/package.Service/Method)
+func GRPCRequest(client, method, code string) {
+	grpcRequests.WithLabelValues(client, method, code).Inc()
+}
+
+// Handler trả về handler /metrics
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// WriteTextfile ghi toàn bộ metric ra file theo định dạng của node_exporter textfile collector
+// (ghi file tạm rồi rename nên collector không đọc phải file dở dang)
+func WriteTextfile(path string) error {
+	if err := prometheus.WriteToTextfile(path, Registry); err != nil {
+		return fmt.Errorf("failed to write metrics to %s: %w", path, err)
+	}
+	return nil
+}
+
+// LoadLastSuccess đọc gauge lần import thành công gần nhất từ textfile của lần chạy trước, để tỉnh không
+// chạy lần này vẫn giữ giá trị cũ. File không tồn tại không phải lỗi.
+func LoadLastSuccess(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to open metrics file %s: %w", path, err)
+	}
+	defer file.Close()
+
+	parser := expfmt.NewTextParser(model.UTF8Validation)
+	families, err := parser.TextToMetricFamilies(file)
+	if err != nil {
+		return fmt.Errorf("failed to parse metrics file %s: %w", path, err)
+	}
+	family, ok := families[provinceLastSuccessName]
+	if !ok {
+		return nil
+	}
+	for _, metric := range family.GetMetric() {
+		labels := make(prometheus.Labels)
+		for _, pair := range metric.GetLabel() {
+			labels[pair.GetName()] = pair.GetValue()
+		}
+		gauge, err := provinceLastSuccess.GetMetricWith(labels)
+		if err != nil {
+			continue
+		}
+		gauge.Set(metric.GetGauge().GetValue())
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package models
+
+// Cấp đơn vị hành chính trong DB (DMTT, DM_PHUONG_XA)
+const (
+	AdminLevelProvince = 4 // Tỉnh/thành phố
+	AdminLevelCommune  = 6 // Xã/phường
+)
+
+// admin_level trên OSM tương ứng với tỉnh và xã/phường; đổi qua SetOSMAdminLevels
+var (
+	OSMProvinceLevel = AdminLevelProvince
+	OSMCommuneLevel  = AdminLevelCommune
+)
+
+// SetOSMAdminLevels đặt admin_level OSM của tỉnh và xã/phường (theo cấu hình admin_levels)
+func SetOSMAdminLevels(province, commune int) {
+	OSMProvinceLevel = province
+	OSMCommuneLevel = commune
+}
+
+// UnitLevel đổi admin_level OSM sang cấp đơn vị trong DB; cấp không ánh xạ được giữ nguyên
+func UnitLevel(osmLevel int) int {
+	switch osmLevel {
+	case OSMProvinceLevel:
+		return AdminLevelProvince
+	case OSMCommuneLevel:
+		return AdminLevelCommune
+	default:
+		return osmLevel
+	}
+}
+
+// AdminTreeNode is one administrative unit in the province → (district) → commune tree
+type AdminTreeNode struct {
+	RelationID int64            `json:"relationId"` // OSM Relation ID
+	Name       string           `json:"name"`       // Primary name
+	AdminLevel int              `json:"adminLevel"` // admin_level tag (-1 if missing)
+	Version    int              `json:"version"`    // OSM version at discovery time
+	Children   []*AdminTreeNode `json:"children,omitempty"`
+
+	subareas []int64 // subarea member IDs still to be resolved
+}
+
+// NewAdminTreeNode creates a tree node from a relation
+func NewAdminTreeNode(relation *Relation) *AdminTreeNode {
+	return &AdminTreeNode{
+		RelationID: relation.ID,
+		Name:       relation.GetName(),
+		AdminLevel: relation.GetAdminLevel(),
+		Version:    relation.Version,
+		subareas:   relation.SubareaIDs(),
+	}
+}
+
+// PendingSubareas returns the subarea relation IDs not yet resolved into children
+func (n *AdminTreeNode) PendingSubareas() []int64 {
+	return n.subareas
+}
+
+// ClearPendingSubareas marks the subareas of this node as resolved
+func (n *AdminTreeNode) ClearPendingSubareas() {
+	n.subareas = nil
+}
+
+// Communes returns all commune-level nodes below (or equal to) this node, in tree order
+func (n *AdminTreeNode) Communes() []*AdminTreeNode {
+	if n.AdminLevel == OSMCommuneLevel {
+		return []*AdminTreeNode{n}
+	}
+	var communes []*AdminTreeNode
+	for _, child := range n.Children {
+		communes = append(communes, child.Communes()...)
+	}
+	return communes
+}
+
+// SubareaIDs returns the IDs of relation members with role "subarea"
+func (r *Relation) SubareaIDs() []int64 {
+	var ids []int64
+	for _, member := range r.Members {
+		if member.Type == "relation" && member.Role == "subarea" {
+			ids = append(ids, member.Ref)
+		}
+	}
+	return ids
+}
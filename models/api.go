@@ -1,15 +1,19 @@
 package models
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"math"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
+	"tool-map/metrics"
 )
 
 const (
@@ -17,9 +21,12 @@ const (
 )
 
 type ApiInterface interface {
-	FetchRelationFull(relationID int64) (*OSM, error)
-	FetchWayFull(wayID int64) (*OSM, error)
-	FetchNode(nodeID int64) (*OSM, error)
+	FetchRelationFull(ctx context.Context, relationID int64) (*OSM, error)
+	FetchRelation(ctx context.Context, relationID int64) (*OSM, error)
+	FetchRelationHistory(ctx context.Context, relationID int64) (*OSM, error)
+	FetchRelations(ctx context.Context, relationIDs []int64) (*OSM, error)
+	FetchWayFull(ctx context.Context, wayID int64) (*OSM, error)
+	FetchNode(ctx context.Context, nodeID int64) (*OSM, error)
 
 	EncodeCoordinatesToJSON(coordinates []Coordinate) (string, error)
 	DecodeCoordinatesFromJSON(jsonString string) ([]Coordinate, error)
@@ -50,9 +57,10 @@ type OSMApiClient struct {
 	BaseURL    string
 	HTTPClient *http.Client
 	UserAgent  string
+	Limiter    *RateLimiter // nil = không giới hạn (ví dụ khi dùng stub server cục bộ)
 }
 
-// NewOSMApiClient creates a new OSM API client
+// NewOSMApiClient creates a new OSM API client limited to DefaultOSMRequestsPerSecond
 func NewOSMApiClient() *OSMApiClient {
 	return &OSMApiClient{
 		BaseURL: OSMBaseURL,
@@ -60,30 +68,60 @@ func NewOSMApiClient() *OSMApiClient {
 			Timeout: 30 * time.Second,
 		},
 		UserAgent: "tool-map/1.0",
+		Limiter:   NewRateLimiter(DefaultOSMRequestsPerSecond),
 	}
 }
 
 // FetchRelationFull fetches a relation with all its members (nodes, ways, and sub-relations)
-func (client *OSMApiClient) FetchRelationFull(relationID int64) (*OSM, error) {
+func (client *OSMApiClient) FetchRelationFull(ctx context.Context, relationID int64) (*OSM, error) {
 	url := fmt.Sprintf("%s/relation/%d/full", client.BaseURL, relationID)
-	return client.fetchOSMData(url)
+	return client.fetchOSMData(ctx, "relation_full", url)
+}
+
+// FetchRelation fetches a single relation with its tags and member list only
+func (client *OSMApiClient) FetchRelation(ctx context.Context, relationID int64) (*OSM, error) {
+	url := fmt.Sprintf("%s/relation/%d", client.BaseURL, relationID)
+	return client.fetchOSMData(ctx, "relation", url)
+}
+
+// FetchRelationHistory fetches every version of a relation (oldest first)
+func (client *OSMApiClient) FetchRelationHistory(ctx context.Context, relationID int64) (*OSM, error) {
+	url := fmt.Sprintf("%s/relation/%d/history", client.BaseURL, relationID)
+	return client.fetchOSMData(ctx, "relation_history", url)
+}
+
+// FetchRelations fetches several relations (tags and member lists) in one request
+func (client *OSMApiClient) FetchRelations(ctx context.Context, relationIDs []int64) (*OSM, error) {
+	ids := make([]string, len(relationIDs))
+	for i, id := range relationIDs {
+		ids[i] = strconv.FormatInt(id, 10)
+	}
+	url := fmt.Sprintf("%s/relations?relations=%s", client.BaseURL, strings.Join(ids, ","))
+	return client.fetchOSMData(ctx, "relations", url)
 }
 
 // FetchWayFull fetches a way with all its node members
-func (client *OSMApiClient) FetchWayFull(wayID int64) (*OSM, error) {
+func (client *OSMApiClient) FetchWayFull(ctx context.Context, wayID int64) (*OSM, error) {
 	url := fmt.Sprintf("%s/way/%d/full", client.BaseURL, wayID)
-	return client.fetchOSMData(url)
+	return client.fetchOSMData(ctx, "way_full", url)
 }
 
 // FetchNode fetches a single node
-func (client *OSMApiClient) FetchNode(nodeID int64) (*OSM, error) {
+func (client *OSMApiClient) FetchNode(ctx context.Context, nodeID int64) (*OSM, error) {
 	url := fmt.Sprintf("%s/node/%d", client.BaseURL, nodeID)
-	return client.fetchOSMData(url)
+	return client.fetchOSMData(ctx, "node", url)
 }
 
-// fetchOSMData fetches OSM data from the given URL
-func (client *OSMApiClient) fetchOSMData(url string) (*OSM, error) {
-	req, err := http.NewRequest("GET", url, nil)
+// fetchOSMData fetches OSM data from the given URL, aborting when ctx is done.
+// endpoint is the metrics label of the request kind (relation_full, node...)
+func (client *OSMApiClient) fetchOSMData(ctx context.Context, endpoint, url string) (*OSM, error) {
+	if client.Limiter != nil {
+		if err := client.Limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -91,18 +129,20 @@ func (client *OSMApiClient) fetchOSMData(url string) (*OSM, error) {
 	// Set User-Agent header (required by OSM API)
 	req.Header.Set("User-Agent", client.UserAgent)
 
+	started := time.Now()
 	resp, err := client.HTTPClient.Do(req)
 	if err != nil {
+		metrics.ObserveOSMRequest(metrics.APIOSM, endpoint, 0, started)
 		return nil, fmt.Errorf("failed to make request: %w", err)
 	}
 	defer resp.Body.Close()
 
+	// Read response body
+	body, err := io.ReadAll(resp.Body)
+	metrics.ObserveOSMRequest(metrics.APIOSM, endpoint, resp.StatusCode, started)
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, resp.Status)
 	}
-
-	// Read response body
-	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read response body: %w", err)
 	}
@@ -212,24 +252,25 @@ func DecodeCoordinatesFromBinary(binaryString string) ([]Coordinate, error) {
 
 // GetBoundaryCoordinates extracts coordinates from administrative boundary ways and relations
 func (osm *OSM) GetBoundaryCoordinates() ([]Coordinate, error) {
+	ix := osm.Index()
 	var allCoordinates []Coordinate
 
 	// Process administrative boundary ways
-	for _, way := range osm.Ways {
-		if way.IsAdministrativeBoundary() {
-			coordinates := osm.GetWayCoordinates(&way)
+	for i := range osm.Ways {
+		if ix.WayTags(i).IsAdministrativeBoundary() {
+			coordinates := ix.WayCoordinates(&osm.Ways[i])
 			allCoordinates = append(allCoordinates, coordinates...)
 		}
 	}
 
 	// Process administrative boundary relations
-	for _, relation := range osm.Relations {
-		if relation.IsAdministrativeBoundary() {
+	for i := range osm.Relations {
+		if ix.RelationTags(i).IsAdministrativeBoundary() {
 			// Get coordinates from all ways in the relation
-			for _, member := range relation.Members {
+			for _, member := range osm.Relations[i].Members {
 				if member.Type == "way" {
-					if way, found := osm.FindWayByID(member.Ref); found {
-						coordinates := osm.GetWayCoordinates(way)
+					if way, found := ix.Way(member.Ref); found {
+						coordinates := ix.WayCoordinates(way)
 						allCoordinates = append(allCoordinates, coordinates...)
 					}
 				}
@@ -245,10 +286,13 @@ func (osm *OSM) ConvertToDmPhuongXaFormat() ([]map[string]interface{}, error) {
 	var results []map[string]interface{}
 
 	// Process administrative boundary relations (communes)
-	for _, relation := range osm.Relations {
-		if relation.IsAdministrativeBoundary() && relation.GetAdminLevel() == 6 { // Commune level
+	ix := osm.Index()
+	for i := range osm.Relations {
+		relation := &osm.Relations[i]
+		tags := ix.RelationTags(i)
+		if tags.IsAdministrativeBoundary() && tags.AdminLevel() == OSMCommuneLevel { // Commune level
 			// Get boundary coordinates
-			coordinates, err := osm.GetBoundaryCoordinatesFromRelation(&relation)
+			coordinates, err := osm.GetBoundaryCoordinatesFromRelation(relation)
 			if err != nil {
 				continue // Skip this relation if we can't get coordinates
 			}
@@ -260,12 +304,12 @@ func (osm *OSM) ConvertToDmPhuongXaFormat() ([]map[string]interface{}, error) {
 			}
 
 			result := map[string]interface{}{
-				"tenPhuongXa":   relation.GetName(),
-				"tenPhuongXaEn": relation.GetTagValue("name:en"),
+				"tenPhuongXa":   tags.Name(),
+				"tenPhuongXaEn": tags.Get("name:en"),
 				"toaDoBienGioi": &jsonString,
-				"admin_level":   relation.GetAdminLevel(),
-				"capital_level": relation.GetCapitalLevel(),
-				"place":         relation.GetTagValue("place"),
+				"admin_level":   tags.AdminLevel(),
+				"capital_level": tags.CapitalLevel(),
+				"place":         tags.Get("place"),
 				"osm_id":        relation.ID,
 			}
 
@@ -281,10 +325,13 @@ func (osm *OSM) ConvertToDmTTFormat() ([]map[string]interface{}, error) {
 	var results []map[string]interface{}
 
 	// Process administrative boundary relations (provinces/cities)
-	for _, relation := range osm.Relations {
-		if relation.IsAdministrativeBoundary() && relation.GetAdminLevel() == 4 { // Province/City level
+	ix := osm.Index()
+	for i := range osm.Relations {
+		relation := &osm.Relations[i]
+		tags := ix.RelationTags(i)
+		if tags.IsAdministrativeBoundary() && tags.AdminLevel() == OSMProvinceLevel { // Province/City level
 			// Get boundary coordinates
-			coordinates, err := osm.GetBoundaryCoordinatesFromRelation(&relation)
+			coordinates, err := osm.GetBoundaryCoordinatesFromRelation(relation)
 			if err != nil {
 				continue // Skip this relation if we can't get coordinates
 			}
@@ -296,12 +343,12 @@ func (osm *OSM) ConvertToDmTTFormat() ([]map[string]interface{}, error) {
 			}
 
 			result := map[string]interface{}{
-				"tenTT":         relation.GetName(),
-				"tenTTEn":       relation.GetTagValue("name:en"),
+				"tenTT":         tags.Name(),
+				"tenTTEn":       tags.Get("name:en"),
 				"toaDoBienGioi": &jsonString,
-				"admin_level":   relation.GetAdminLevel(),
-				"capital_level": relation.GetCapitalLevel(),
-				"place":         relation.GetTagValue("place"),
+				"admin_level":   tags.AdminLevel(),
+				"capital_level": tags.CapitalLevel(),
+				"place":         tags.Get("place"),
 				"osm_id":        relation.ID,
 			}
 
@@ -320,13 +367,16 @@ func (osm *OSM) ConvertToAllAdministrativeLevels() (map[string][]map[string]inte
 	}
 
 	// Process all administrative boundary relations
-	for _, relation := range osm.Relations {
-		if !relation.IsAdministrativeBoundary() {
+	ix := osm.Index()
+	for i := range osm.Relations {
+		relation := &osm.Relations[i]
+		tags := ix.RelationTags(i)
+		if !tags.IsAdministrativeBoundary() {
 			continue
 		}
 
 		// Get boundary coordinates
-		coordinates, err := osm.GetBoundaryCoordinatesFromRelation(&relation)
+		coordinates, err := osm.GetBoundaryCoordinatesFromRelation(relation)
 		if err != nil {
 			continue // Skip this relation if we can't get coordinates
 		}
@@ -337,23 +387,23 @@ func (osm *OSM) ConvertToAllAdministrativeLevels() (map[string][]map[string]inte
 			continue // Skip this relation if we can't encode coordinates
 		}
 
-		adminLevel := relation.GetAdminLevel()
-		capitalLevel := relation.GetCapitalLevel()
+		adminLevel := tags.AdminLevel()
+		capitalLevel := tags.CapitalLevel()
 
 		baseData := map[string]interface{}{
-			"name":          relation.GetName(),
-			"nameEn":        relation.GetTagValue("name:en"),
+			"name":          tags.Name(),
+			"nameEn":        tags.Get("name:en"),
 			"toaDoBienGioi": &jsonString,
 			"admin_level":   adminLevel,
 			"capital_level": capitalLevel,
-			"place":         relation.GetTagValue("place"),
+			"place":         tags.Get("place"),
 			"osm_id":        relation.ID,
 		}
 
 		// Classify by capital level
-		if capitalLevel == 4 { // Province/City
+		if capitalLevel == OSMProvinceLevel { // Province/City
 			result["provinces"] = append(result["provinces"], baseData)
-		} else if capitalLevel == 6 { // Commune
+		} else if capitalLevel == OSMCommuneLevel { // Commune
 			result["communes"] = append(result["communes"], baseData)
 		}
 	}
@@ -364,10 +414,11 @@ func (osm *OSM) ConvertToAllAdministrativeLevels() (map[string][]map[string]inte
 // GetBoundaryCoordinatesFromRelation extracts coordinates from a specific relation
 func (osm *OSM) GetBoundaryCoordinatesFromRelation(relation *Relation) ([]Coordinate, error) {
 	// Lấy tất cả ways của relation
+	ix := osm.Index()
 	var ways []Way
 	for _, member := range relation.Members {
 		if member.Type == "way" && member.Role == "outer" {
-			if way, found := osm.FindWayByID(member.Ref); found {
+			if way, found := ix.Way(member.Ref); found {
 				ways = append(ways, *way)
 			}
 		}
@@ -393,9 +444,11 @@ func (osm *OSM) connectWaysIntoPolygon(ways []Way) ([]Coordinate, error) {
 	}
 
 	// Bước 1: Tạo map để lưu trữ coordinates của từng way
+	ix := osm.Index()
 	wayCoordsMap := make(map[int64][]Coordinate)
-	for _, way := range ways {
-		coords := osm.GetWayCoordinates(&way)
+	for i := range ways {
+		way := &ways[i]
+		coords := ix.WayCoordinates(way)
 		if len(coords) > 0 {
 			wayCoordsMap[way.ID] = coords
 		}
@@ -409,7 +462,7 @@ func (osm *OSM) connectWaysIntoPolygon(ways []Way) ([]Coordinate, error) {
 	connectedPath, err := osm.buildConnectedPath(wayCoordsMap)
 	if err != nil {
 		// Nếu không thể nối được, fallback về cách cũ
-		fmt.Printf("Warning: Could not build connected path, using fallback: %v\n", err)
+		slog.Warn("Could not build connected path, using fallback", "error", err)
 		return osm.fallbackPolygonConstruction(wayCoordsMap), nil
 	}
 
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+)
+
+// GeometryFingerprint returns a SHA-256 over every relation's ordered members (type, ref, role)
+// and every way's node refs and node coordinates, ordered by relation/way ID. Tag-only edits
+// (names, versions bumped by retagging) do not change it, so it tells whether the boundary shape
+// really moved between two imports; adding, dropping, reordering or re-roling a member does.
+func (osm *OSM) GeometryFingerprint() string {
+	ix := osm.Index()
+
+	ways := make([]*Way, len(osm.Ways))
+	for i := range osm.Ways {
+		ways[i] = &osm.Ways[i]
+	}
+	sort.Slice(ways, func(i, j int) bool { return ways[i].ID < ways[j].ID })
+
+	relations := make([]*Relation, len(osm.Relations))
+	for i := range osm.Relations {
+		relations[i] = &osm.Relations[i]
+	}
+	sort.Slice(relations, func(i, j int) bool { return relations[i].ID < relations[j].ID })
+
+	h := sha256.New()
+	for _, relation := range relations {
+		fmt.Fprintf(h, "r%d:", relation.ID)
+		for _, member := range relation.Members {
+			fmt.Fprintf(h, "%s,%d,%s;", member.Type, member.Ref, member.Role)
+		}
+	}
+	for _, way := range ways {
+		fmt.Fprintf(h, "w%d:", way.ID)
+		for _, ref := range way.Nodes {
+			lat, lon, _ := ix.Coord(ref.Ref)
+			fmt.Fprintf(h, "%d,%.7f,%.7f;", ref.Ref, lat, lon)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ChangesetsSince returns the changesets of relation versions newer than version, oldest first
+func (osm *OSM) ChangesetsSince(relationID int64, version int) []int64 {
+	var changesets []int64
+	for _, relation := range osm.Relations {
+		if relation.ID == relationID && relation.Version > version {
+			changesets = append(changesets, relation.Changeset)
+		}
+	}
+	return changesets
+}
@@ -0,0 +1,58 @@
+package models
+
+import "testing"
+
+const fingerprintBase = `<?xml version="1.0" encoding="UTF-8"?>
+<osm version="0.6" generator="test">
+  <node id="1" lat="21.0" lon="105.0"/>
+  <node id="2" lat="21.0" lon="105.1"/>
+  <node id="3" lat="21.1" lon="105.1"/>
+  <way id="10"><nd ref="1"/><nd ref="2"/></way>
+  <way id="11"><nd ref="2"/><nd ref="3"/><nd ref="1"/></way>
+  <relation id="100" version="1">
+    <member type="way" ref="10" role="outer"/>
+    <member type="way" ref="11" role="outer"/>
+    <member type="node" ref="3" role="admin_centre"/>
+    <tag k="name" v="Xã A"/>
+  </relation>
+</osm>`
+
+func TestGeometryFingerprint(t *testing.T) {
+	fingerprint := func(t *testing.T, edit func(osm *OSM)) string {
+		t.Helper()
+		osm, err := ParseOSMFromBytes([]byte(fingerprintBase))
+		if err != nil {
+			t.Fatalf("ParseOSMFromBytes: %v", err)
+		}
+		edit(osm)
+		osm.InvalidateIndex()
+		return osm.GeometryFingerprint()
+	}
+	base := fingerprint(t, func(osm *OSM) {})
+
+	tests := []struct {
+		name    string
+		edit    func(osm *OSM)
+		changed bool
+	}{
+		{"tag and version only", func(osm *OSM) {
+			osm.Relations[0].Version = 2
+			osm.Relations[0].Tags[0].Value = "Phường A"
+		}, false},
+		{"node moved", func(osm *OSM) { osm.Nodes[2].Lat = 21.2 }, true},
+		{"member role", func(osm *OSM) { osm.Relations[0].Members[1].Role = "inner" }, true},
+		{"member order", func(osm *OSM) {
+			members := osm.Relations[0].Members
+			members[0], members[1] = members[1], members[0]
+		}, true},
+		{"member dropped", func(osm *OSM) { osm.Relations[0].Members = osm.Relations[0].Members[:2] }, true},
+		{"member type", func(osm *OSM) { osm.Relations[0].Members[2].Type = "way" }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fingerprint(t, tt.edit); (got != base) != tt.changed {
+				t.Errorf("fingerprint changed = %v, want %v", got != base, tt.changed)
+			}
+		})
+	}
+}
@@ -0,0 +1,276 @@
+package models
+
+import (
+	"strconv"
+	"sync"
+)
+
+// TagMap is a key → value view of an element's tags
+type TagMap map[string]string
+
+// Get returns the value of a tag by key, or empty string if not found
+func (t TagMap) Get(key string) string {
+	return t[key]
+}
+
+// Name returns name, falling back to name:vi then name:en
+func (t TagMap) Name() string {
+	return tagName(t)
+}
+
+// AdminLevel returns admin_level as integer, or -1 when missing/invalid
+func (t TagMap) AdminLevel() int {
+	return tagInt(t, "admin_level")
+}
+
+// CapitalLevel returns capital as integer (4=tỉnh/tp, 6=xã), or -1 when missing/invalid
+func (t TagMap) CapitalLevel() int {
+	return tagInt(t, "capital")
+}
+
+// IsAdministrativeBoundary checks for boundary=administrative with an admin_level
+func (t TagMap) IsAdministrativeBoundary() bool {
+	return tagIsAdministrativeBoundary(t)
+}
+
+// IsPlace checks for a place tag
+func (t TagMap) IsPlace() bool {
+	return t["place"] != ""
+}
+
+// tagReader is read access to an element's tags: a TagMap for indexed elements, a tagList otherwise
+type tagReader interface {
+	Get(key string) string
+}
+
+// tagList reads tags straight from the element's slice; elements that are not part of an indexed
+// document have only a handful of tags, so a linear scan is cheaper than building a map per call
+type tagList []Tag
+
+// Get returns the value of the last tag with key (same as the map built by the index), or empty string.
+// The receiver is a pointer so wrapping an element's Tags in a tagReader does not allocate.
+func (t *tagList) Get(key string) string {
+	tags := *t
+	for i := len(tags) - 1; i >= 0; i-- {
+		if tags[i].Key == key {
+			return tags[i].Value
+		}
+	}
+	return ""
+}
+
+func tagName(t tagReader) string {
+	if name := t.Get("name"); name != "" {
+		return name
+	}
+	if name := t.Get("name:vi"); name != "" {
+		return name
+	}
+	return t.Get("name:en")
+}
+
+func tagIsAdministrativeBoundary(t tagReader) bool {
+	return t.Get("boundary") == "administrative" && t.Get("admin_level") != ""
+}
+
+func tagInt(t tagReader, key string) int {
+	value := t.Get(key)
+	if value == "" {
+		return -1
+	}
+	if n, err := strconv.Atoi(value); err == nil {
+		return n
+	}
+	return -1
+}
+
+// OSMIndex is an indexed view of an OSM document, built once after parsing.
+// It maps element IDs to their position in the OSM slices, keeps tags as maps with
+// interned keys/values, and packs node coordinates into one flat array so way geometry
+// lookups are O(1) per node ref instead of a scan over every node.
+type OSMIndex struct {
+	osm *OSM
+
+	nodePos     map[int64]int
+	wayPos      map[int64]int
+	relationPos map[int64]int
+
+	// coords[2*i], coords[2*i+1] are lat, lon of osm.Nodes[i]
+	coords []float64
+
+	nodeTags     []TagMap
+	wayTags      []TagMap
+	relationTags []TagMap
+}
+
+// Index returns the document's index, building it on first use. It is safe for concurrent use;
+// code that changes Nodes, Ways, Relations or their tags after the index was built must call
+// InvalidateIndex before the document is read again (and not while other goroutines read it).
+func (osm *OSM) Index() *OSMIndex {
+	osm.indexOnce.Do(func() {
+		osm.index = osm.buildIndex()
+	})
+	return osm.index
+}
+
+// InvalidateIndex drops the index so the next Index call rebuilds it over the current elements
+func (osm *OSM) InvalidateIndex() {
+	for i := range osm.Nodes {
+		osm.Nodes[i].tags = nil
+	}
+	for i := range osm.Ways {
+		osm.Ways[i].tags = nil
+	}
+	for i := range osm.Relations {
+		osm.Relations[i].tags = nil
+	}
+	osm.indexOnce = sync.Once{}
+	osm.index = nil
+}
+
+// buildIndex builds the index over the current nodes, ways and relations and gives each
+// element its TagMap, so element tag getters read the same maps as the index
+func (osm *OSM) buildIndex() *OSMIndex {
+	pool := make(map[string]string)
+	intern := func(s string) string {
+		if v, ok := pool[s]; ok {
+			return v
+		}
+		pool[s] = s
+		return s
+	}
+	tagMap := func(tags []Tag) TagMap {
+		if len(tags) == 0 {
+			return nil
+		}
+		m := make(TagMap, len(tags))
+		for i := range tags {
+			// Dùng chung chuỗi cho key/value lặp lại (boundary, administrative, ...) để giảm bộ nhớ
+			tags[i].Key = intern(tags[i].Key)
+			tags[i].Value = intern(tags[i].Value)
+			m[tags[i].Key] = tags[i].Value
+		}
+		return m
+	}
+
+	ix := &OSMIndex{
+		osm:          osm,
+		nodePos:      make(map[int64]int, len(osm.Nodes)),
+		wayPos:       make(map[int64]int, len(osm.Ways)),
+		relationPos:  make(map[int64]int, len(osm.Relations)),
+		coords:       make([]float64, 2*len(osm.Nodes)),
+		nodeTags:     make([]TagMap, len(osm.Nodes)),
+		wayTags:      make([]TagMap, len(osm.Ways)),
+		relationTags: make([]TagMap, len(osm.Relations)),
+	}
+
+	for i := range osm.Nodes {
+		node := &osm.Nodes[i]
+		ix.nodePos[node.ID] = i
+		ix.coords[2*i] = node.Lat
+		ix.coords[2*i+1] = node.Lon
+		ix.nodeTags[i] = tagMap(node.Tags)
+		node.tags = ix.nodeTags[i]
+	}
+	for i := range osm.Ways {
+		ix.wayPos[osm.Ways[i].ID] = i
+		ix.wayTags[i] = tagMap(osm.Ways[i].Tags)
+		osm.Ways[i].tags = ix.wayTags[i]
+	}
+	for i := range osm.Relations {
+		ix.relationPos[osm.Relations[i].ID] = i
+		ix.relationTags[i] = tagMap(osm.Relations[i].Tags)
+		osm.Relations[i].tags = ix.relationTags[i]
+	}
+
+	return ix
+}
+
+// Node returns the node with the given ID
+func (ix *OSMIndex) Node(id int64) (*Node, bool) {
+	if i, ok := ix.nodePos[id]; ok {
+		return &ix.osm.Nodes[i], true
+	}
+	return nil, false
+}
+
+// Way returns the way with the given ID
+func (ix *OSMIndex) Way(id int64) (*Way, bool) {
+	if i, ok := ix.wayPos[id]; ok {
+		return &ix.osm.Ways[i], true
+	}
+	return nil, false
+}
+
+// Relation returns the relation with the given ID
+func (ix *OSMIndex) Relation(id int64) (*Relation, bool) {
+	if i, ok := ix.relationPos[id]; ok {
+		return &ix.osm.Relations[i], true
+	}
+	return nil, false
+}
+
+// Coord returns lat, lon of the node with the given ID
+func (ix *OSMIndex) Coord(id int64) (lat, lon float64, ok bool) {
+	i, ok := ix.nodePos[id]
+	if !ok {
+		return 0, 0, false
+	}
+	return ix.coords[2*i], ix.coords[2*i+1], true
+}
+
+// NodeTags returns the tags of osm.Nodes[i]
+func (ix *OSMIndex) NodeTags(i int) TagMap {
+	return ix.nodeTags[i]
+}
+
+// WayTags returns the tags of osm.Ways[i]
+func (ix *OSMIndex) WayTags(i int) TagMap {
+	return ix.wayTags[i]
+}
+
+// RelationTags returns the tags of osm.Relations[i]
+func (ix *OSMIndex) RelationTags(i int) TagMap {
+	return ix.relationTags[i]
+}
+
+// WayCoordinates returns the coordinates of a way's nodes, skipping refs not in the document
+func (ix *OSMIndex) WayCoordinates(way *Way) []Coordinate {
+	coordinates := make([]Coordinate, 0, len(way.Nodes))
+	for _, nodeRef := range way.Nodes {
+		if i, ok := ix.nodePos[nodeRef.Ref]; ok {
+			coordinates = append(coordinates, Coordinate{
+				ID:  nodeRef.Ref, // Include OSM Node ID
+				Lat: ix.coords[2*i],
+				Lon: ix.coords[2*i+1],
+			})
+		}
+	}
+	return coordinates
+}
+
+// Bounds returns the bounding box of all nodes
+func (ix *OSMIndex) Bounds() (minLat, maxLat, minLon, maxLon float64, hasData bool) {
+	if len(ix.coords) == 0 {
+		return 0, 0, 0, 0, false
+	}
+
+	minLat, maxLat = ix.coords[0], ix.coords[0]
+	minLon, maxLon = ix.coords[1], ix.coords[1]
+	for i := 0; i < len(ix.coords); i += 2 {
+		lat, lon := ix.coords[i], ix.coords[i+1]
+		if lat < minLat {
+			minLat = lat
+		}
+		if lat > maxLat {
+			maxLat = lat
+		}
+		if lon < minLon {
+			minLon = lon
+		}
+		if lon > maxLon {
+			maxLon = lon
+		}
+	}
+	return minLat, maxLat, minLon, maxLon, true
+}
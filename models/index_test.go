@@ -0,0 +1,41 @@
+package models
+
+import "testing"
+
+func TestUnindexedTagGetters(t *testing.T) {
+	tags := []Tag{
+		{Key: "boundary", Value: "administrative"},
+		{Key: "admin_level", Value: "4"},
+		{Key: "name:vi", Value: "Tỉnh A"},
+		{Key: "capital", Value: "6"},
+		{Key: "place", Value: "province"},
+		// Key lặp lại: giá trị sau cùng thắng, giống map của index
+		{Key: "admin_level", Value: "6"},
+	}
+	relation := Relation{ID: 1, Tags: tags}
+	indexed := &OSM{Relations: []Relation{{ID: 1, Tags: append([]Tag(nil), tags...)}}}
+	indexed.Index()
+
+	tests := []struct {
+		name string
+		get  func(r *Relation) any
+	}{
+		{"name", func(r *Relation) any { return r.GetName() }},
+		{"admin level", func(r *Relation) any { return r.GetAdminLevel() }},
+		{"capital level", func(r *Relation) any { return r.GetCapitalLevel() }},
+		{"boundary", func(r *Relation) any { return r.IsAdministrativeBoundary() }},
+		{"place", func(r *Relation) any { return r.IsPlace() }},
+		{"missing tag", func(r *Relation) any { return r.GetTagValue("population") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, want := tt.get(&relation), tt.get(&indexed.Relations[0]); got != want {
+				t.Errorf("unindexed = %v, indexed = %v", got, want)
+			}
+		})
+	}
+
+	if allocs := testing.AllocsPerRun(100, func() { _ = relation.GetAdminLevel() }); allocs != 0 {
+		t.Errorf("GetAdminLevel on an unindexed relation allocates %v times per call", allocs)
+	}
+}
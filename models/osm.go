@@ -4,6 +4,7 @@ import (
 	"encoding/xml"
 	"fmt"
 	"strconv"
+	"sync"
 	"time"
 )
 
@@ -15,9 +16,13 @@ type OSM struct {
 	Copyright   string     `xml:"copyright,attr"`
 	Attribution string     `xml:"attribution,attr"`
 	License     string     `xml:"license,attr"`
+	Remark      string     `xml:"remark"` // Overpass runtime remarks (errors, timeouts)
 	Nodes       []Node     `xml:"node"`
 	Ways        []Way      `xml:"way"`
 	Relations   []Relation `xml:"relation"`
+
+	indexOnce sync.Once
+	index     *OSMIndex // built by Index, see index.go
 }
 
 // Node represents an OSM node (point)
@@ -33,6 +38,8 @@ type Node struct {
 	Lat       float64  `xml:"lat,attr"`
 	Lon       float64  `xml:"lon,attr"`
 	Tags      []Tag    `xml:"tag"`
+
+	tags TagMap // set by OSM.Index, see tagReader
 }
 
 // Way represents an OSM way (sequence of nodes forming a line or area)
@@ -47,12 +54,17 @@ type Way struct {
 	UID       int64     `xml:"uid,attr"`
 	Nodes     []NodeRef `xml:"nd"`
 	Tags      []Tag     `xml:"tag"`
+
+	tags TagMap // set by OSM.Index, see tagReader
 }
 
-// NodeRef represents a reference to a node in a way
+// NodeRef represents a reference to a node in a way.
+// Lat/Lon are only filled when the source inlines geometry (Overpass "out geom").
 type NodeRef struct {
 	XMLName xml.Name `xml:"nd"`
 	Ref     int64    `xml:"ref,attr"`
+	Lat     float64  `xml:"lat,attr,omitempty"`
+	Lon     float64  `xml:"lon,attr,omitempty"`
 }
 
 // Relation represents an OSM relation (grouping of nodes, ways, and other relations)
@@ -67,14 +79,17 @@ type Relation struct {
 	UID       int64    `xml:"uid,attr"`
 	Members   []Member `xml:"member"`
 	Tags      []Tag    `xml:"tag"`
+
+	tags TagMap // set by OSM.Index, see tagReader
 }
 
 // Member represents a member of a relation
 type Member struct {
-	XMLName xml.Name `xml:"member"`
-	Type    string   `xml:"type,attr"`
-	Ref     int64    `xml:"ref,attr"`
-	Role    string   `xml:"role,attr"`
+	XMLName  xml.Name  `xml:"member"`
+	Type     string    `xml:"type,attr"`
+	Ref      int64     `xml:"ref,attr"`
+	Role     string    `xml:"role,attr"`
+	Geometry []NodeRef `xml:"nd"` // inline member geometry (Overpass "out geom")
 }
 
 // Tag represents a key-value tag
@@ -194,16 +209,16 @@ func (node *Node) ToCenterPoint() AdministrativeCenter {
 	level := ""
 	if capital != nil {
 		switch *capital {
-		case "4":
+		case strconv.Itoa(OSMProvinceLevel):
 			level = "province"
-		case "6":
+		case strconv.Itoa(OSMCommuneLevel):
 			level = "commune"
 		}
 	} else if adminLevel != nil {
 		switch *adminLevel {
-		case 4:
+		case OSMProvinceLevel:
 			level = "province"
-		case 6:
+		case OSMCommuneLevel:
 			level = "commune"
 		}
 	}
@@ -316,6 +331,8 @@ type RelationInfo struct {
 
 // OSMProcessingResult contains processed OSM data
 type OSMProcessingResult struct {
+	Relation        *RelationInfo            `json:"relation,omitempty"` // The fetched relation itself
+	GeometryHash    string                   `json:"geometryHash"`       // Fingerprint of member way geometry
 	BasicInfo       *BasicOSMInfo            `json:"basicInfo"`
 	Boundaries      *BoundaryData            `json:"boundaries"`
 	Administrative  map[string][]AdminEntity `json:"administrative"`
@@ -367,34 +384,42 @@ type AdminBoundariesData struct {
 	BoundaryRelations int `json:"boundaryRelations"`
 }
 
+// tagReader returns the index's TagMap when the element belongs to an indexed document,
+// otherwise a tagList scanning Tags
+func (n *Node) tagReader() tagReader {
+	if n.tags != nil {
+		return n.tags
+	}
+	return (*tagList)(&n.Tags)
+}
+
+func (w *Way) tagReader() tagReader {
+	if w.tags != nil {
+		return w.tags
+	}
+	return (*tagList)(&w.Tags)
+}
+
+func (r *Relation) tagReader() tagReader {
+	if r.tags != nil {
+		return r.tags
+	}
+	return (*tagList)(&r.Tags)
+}
+
 // GetTagValue returns the value of a tag by key, or empty string if not found
 func (n *Node) GetTagValue(key string) string {
-	for _, tag := range n.Tags {
-		if tag.Key == key {
-			return tag.Value
-		}
-	}
-	return ""
+	return n.tagReader().Get(key)
 }
 
 // GetTagValue returns the value of a tag by key, or empty string if not found
 func (w *Way) GetTagValue(key string) string {
-	for _, tag := range w.Tags {
-		if tag.Key == key {
-			return tag.Value
-		}
-	}
-	return ""
+	return w.tagReader().Get(key)
 }
 
 // GetTagValue returns the value of a tag by key, or empty string if not found
 func (r *Relation) GetTagValue(key string) string {
-	for _, tag := range r.Tags {
-		if tag.Key == key {
-			return tag.Value
-		}
-	}
-	return ""
+	return r.tagReader().Get(key)
 }
 
 // GetTimestamp returns the parsed timestamp as time.Time
@@ -414,138 +439,79 @@ func (r *Relation) GetTimestamp() (time.Time, error) {
 
 // IsAdministrativeBoundary checks if this way/relation is an administrative boundary
 func (w *Way) IsAdministrativeBoundary() bool {
-	boundary := w.GetTagValue("boundary")
-	adminLevel := w.GetTagValue("admin_level")
-	return boundary == "administrative" && adminLevel != ""
+	return tagIsAdministrativeBoundary(w.tagReader())
 }
 
 // IsAdministrativeBoundary checks if this relation is an administrative boundary
 func (r *Relation) IsAdministrativeBoundary() bool {
-	boundary := r.GetTagValue("boundary")
-	adminLevel := r.GetTagValue("admin_level")
-	return boundary == "administrative" && adminLevel != ""
+	return tagIsAdministrativeBoundary(r.tagReader())
 }
 
 // IsPlace checks if this node/relation is a place
 func (n *Node) IsPlace() bool {
-	return n.GetTagValue("place") != ""
+	return n.tagReader().Get("place") != ""
 }
 
 // IsPlace checks if this relation is a place
 func (r *Relation) IsPlace() bool {
-	return r.GetTagValue("place") != ""
+	return r.tagReader().Get("place") != ""
 }
 
 // GetName returns the name of the node/way/relation
 func (n *Node) GetName() string {
-	if name := n.GetTagValue("name"); name != "" {
-		return name
-	}
-	if name := n.GetTagValue("name:vi"); name != "" {
-		return name
-	}
-	if name := n.GetTagValue("name:en"); name != "" {
-		return name
-	}
-	return ""
+	return tagName(n.tagReader())
 }
 
 // GetName returns the name of the way
 func (w *Way) GetName() string {
-	if name := w.GetTagValue("name"); name != "" {
-		return name
-	}
-	if name := w.GetTagValue("name:vi"); name != "" {
-		return name
-	}
-	if name := w.GetTagValue("name:en"); name != "" {
-		return name
-	}
-	return ""
+	return tagName(w.tagReader())
 }
 
 // GetName returns the name of the relation
 func (r *Relation) GetName() string {
-	if name := r.GetTagValue("name"); name != "" {
-		return name
-	}
-	if name := r.GetTagValue("name:vi"); name != "" {
-		return name
-	}
-	if name := r.GetTagValue("name:en"); name != "" {
-		return name
-	}
-	return ""
+	return tagName(r.tagReader())
 }
 
 // GetAdminLevel returns the administrative level as integer
 func (w *Way) GetAdminLevel() int {
-	level := w.GetTagValue("admin_level")
-	if level == "" {
-		return -1
-	}
-	if adminLevel, err := strconv.Atoi(level); err == nil {
-		return adminLevel
-	}
-	return -1
+	return tagInt(w.tagReader(), "admin_level")
 }
 
 // GetAdminLevel returns the administrative level as integer
 func (r *Relation) GetAdminLevel() int {
-	level := r.GetTagValue("admin_level")
-	if level == "" {
-		return -1
-	}
-	if adminLevel, err := strconv.Atoi(level); err == nil {
-		return adminLevel
-	}
-	return -1
+	return tagInt(r.tagReader(), "admin_level")
 }
 
 // GetCapitalLevel returns the capital level as integer (4=tỉnh/tp, 6=xã)
 func (n *Node) GetCapitalLevel() int {
-	capital := n.GetTagValue("capital")
-	if capital == "" {
-		return -1
-	}
-	if capitalLevel, err := strconv.Atoi(capital); err == nil {
-		return capitalLevel
-	}
-	return -1
+	return tagInt(n.tagReader(), "capital")
 }
 
 // GetCapitalLevel returns the capital level as integer (4=tỉnh/tp, 6=xã)
 func (r *Relation) GetCapitalLevel() int {
-	capital := r.GetTagValue("capital")
-	if capital == "" {
-		return -1
-	}
-	if capitalLevel, err := strconv.Atoi(capital); err == nil {
-		return capitalLevel
-	}
-	return -1
+	return tagInt(r.tagReader(), "capital")
 }
 
 // IsProvinceOrCity checks if this is a province or city
 func (n *Node) IsProvinceOrCity() bool {
 	// Tỉnh/thành phố thường là Node với capital=4
-	return n.GetCapitalLevel() == 4
+	return n.GetCapitalLevel() == OSMProvinceLevel
 }
 
 // IsCommune checks if this is a commune/ward
 func (n *Node) IsCommune() bool {
 	// Commune/ward ít khi là Node, chủ yếu là Relation
-	return n.GetCapitalLevel() == 6
+	return n.GetCapitalLevel() == OSMCommuneLevel
 }
 
 // IsProvinceOrCity checks if this is a province or city
 func (r *Relation) IsProvinceOrCity() bool {
-	// Tỉnh/thành phố có thể là Relation với admin_level=4
-	return r.GetAdminLevel() == 4
+	// Tỉnh/thành phố có thể là Relation với admin_level=4 (OSMProvinceLevel)
+	return r.GetAdminLevel() == OSMProvinceLevel
 }
 
 // IsCommune checks if this is a commune/ward
 func (r *Relation) IsCommune() bool {
-	// Commune/ward thường là Relation với admin_level=6
-	return r.GetAdminLevel() == 6
+	// Commune/ward thường là Relation với admin_level=6 (OSMCommuneLevel)
+	return r.GetAdminLevel() == OSMCommuneLevel
 }
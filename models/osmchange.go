@@ -0,0 +1,103 @@
+package models
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	ChangeActionCreate = "create"
+	ChangeActionModify = "modify"
+	ChangeActionDelete = "delete"
+)
+
+// OsmChange represents an OsmChange (.osc) document, e.g. a minutely/daily replication diff
+type OsmChange struct {
+	Version   string         `xml:"version,attr"`
+	Generator string         `xml:"generator,attr"`
+	Actions   []ChangeAction // create/modify/delete sections, in document order
+}
+
+// ChangeAction is one create, modify or delete section of an OsmChange document
+type ChangeAction struct {
+	Type      string     // "create", "modify" or "delete"
+	Nodes     []Node     `xml:"node"`
+	Ways      []Way      `xml:"way"`
+	Relations []Relation `xml:"relation"`
+}
+
+// UnmarshalXML decodes <osmChange> keeping the order of its sections, which matters when
+// the same element is created and then modified in a single diff
+func (change *OsmChange) UnmarshalXML(decoder *xml.Decoder, start xml.StartElement) error {
+	for _, attr := range start.Attr {
+		switch attr.Name.Local {
+		case "version":
+			change.Version = attr.Value
+		case "generator":
+			change.Generator = attr.Value
+		}
+	}
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+
+		switch element := token.(type) {
+		case xml.StartElement:
+			switch element.Name.Local {
+			case ChangeActionCreate, ChangeActionModify, ChangeActionDelete:
+				action := ChangeAction{Type: element.Name.Local}
+				if err := decoder.DecodeElement(&action, &element); err != nil {
+					return fmt.Errorf("failed to decode %s section: %w", element.Name.Local, err)
+				}
+				change.Actions = append(change.Actions, action)
+			default:
+				if err := decoder.Skip(); err != nil {
+					return err
+				}
+			}
+		case xml.EndElement:
+			if element.Name.Local == start.Name.Local {
+				return nil
+			}
+		}
+	}
+}
+
+// ParseOsmChangeFromReader parses an OsmChange document, transparently un-gzipping .osc.gz input
+func ParseOsmChangeFromReader(reader io.Reader) (*OsmChange, error) {
+	buffered := bufio.NewReader(reader)
+	if magic, err := buffered.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open gzip stream: %w", err)
+		}
+		defer gz.Close()
+		reader = gz
+	} else {
+		reader = buffered
+	}
+
+	var change OsmChange
+	if err := xml.NewDecoder(reader).Decode(&change); err != nil {
+		return nil, fmt.Errorf("failed to decode OsmChange XML: %w", err)
+	}
+	return &change, nil
+}
+
+// ParseOsmChangeFromFile parses an OsmChange (.osc or .osc.gz) file
+func ParseOsmChangeFromFile(filename string) (*OsmChange, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file %s: %w", filename, err)
+	}
+	defer file.Close()
+
+	return ParseOsmChangeFromReader(file)
+}
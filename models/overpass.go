@@ -0,0 +1,304 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+	"tool-map/metrics"
+)
+
+const (
+	OverpassBaseURL = "https://overpass-api.de/api/interpreter"
+
+	// overpassAreaOffset is added to a relation ID to get its Overpass area ID
+	overpassAreaOffset = 3600000000
+
+	defaultOverpassTimeout = 180 // seconds, passed to the [timeout:] setting
+)
+
+// OverpassOutput selects how elements are returned by an Overpass query
+type OverpassOutput string
+
+const (
+	// OverpassOutputBody returns relations plus their member ways and nodes (like /full)
+	OverpassOutputBody OverpassOutput = "body"
+	// OverpassOutputGeom returns relations with member geometry inline (lat/lon on each member nd)
+	OverpassOutputGeom OverpassOutput = "geom"
+	// OverpassOutputIDs returns only element IDs
+	OverpassOutputIDs OverpassOutput = "ids"
+)
+
+// OverpassClient represents an Overpass API client
+type OverpassClient struct {
+	BaseURL    string
+	HTTPClient *http.Client
+	UserAgent  string
+}
+
+// NewOverpassClient creates a new Overpass API client
+func NewOverpassClient() *OverpassClient {
+	return &OverpassClient{
+		BaseURL: OverpassBaseURL,
+		HTTPClient: &http.Client{
+			Timeout: (defaultOverpassTimeout + 30) * time.Second,
+		},
+		UserAgent: "tool-map/1.0",
+	}
+}
+
+// BuildAdminLevelQuery builds an Overpass QL query selecting all boundary=administrative
+// relations with the given admin_level inside the area of areaRelationID
+func BuildAdminLevelQuery(areaRelationID int64, adminLevel int, output OverpassOutput) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "[out:xml][timeout:%d];\n", defaultOverpassTimeout)
+	fmt.Fprintf(&sb, "area(%d)->.searchArea;\n", overpassAreaOffset+areaRelationID)
+	fmt.Fprintf(&sb, "relation[\"boundary\"=\"administrative\"][\"admin_level\"=\"%d\"](area.searchArea);\n", adminLevel)
+
+	switch output {
+	case OverpassOutputGeom:
+		sb.WriteString("out geom;\n")
+	case OverpassOutputIDs:
+		sb.WriteString("out ids;\n")
+	default:
+		// Lấy kèm ways và nodes thành viên giống /relation/{id}/full
+		sb.WriteString("(._;>;);\nout body;\n")
+	}
+	return sb.String()
+}
+
+// FetchAdminRelationsInArea fetches all administrative relations of adminLevel inside areaRelationID
+func (client *OverpassClient) FetchAdminRelationsInArea(ctx context.Context, areaRelationID int64, adminLevel int, output OverpassOutput) (*OSM, error) {
+	osm, err := client.Query(ctx, BuildAdminLevelQuery(areaRelationID, adminLevel, output))
+	if err != nil {
+		return nil, err
+	}
+	if output == OverpassOutputGeom {
+		osm.ExpandInlineGeometry()
+	}
+	return osm, nil
+}
+
+// FetchAdminRelationIDsInArea returns the IDs of administrative relations of adminLevel inside areaRelationID
+func (client *OverpassClient) FetchAdminRelationIDsInArea(ctx context.Context, areaRelationID int64, adminLevel int) ([]int64, error) {
+	osm, err := client.FetchAdminRelationsInArea(ctx, areaRelationID, adminLevel, OverpassOutputIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int64, 0, len(osm.Relations))
+	for _, relation := range osm.Relations {
+		ids = append(ids, relation.ID)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
+
+// Query runs a raw Overpass QL query and parses the XML or JSON response into OSM
+func (client *OverpassClient) Query(ctx context.Context, query string) (*OSM, error) {
+	form := url.Values{"data": {query}}
+	req, err := http.NewRequestWithContext(ctx, "POST", client.BaseURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", client.UserAgent)
+
+	started := time.Now()
+	resp, err := client.HTTPClient.Do(req)
+	if err != nil {
+		metrics.ObserveOSMRequest(metrics.APIOverpass, "interpreter", 0, started)
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	metrics.ObserveOSMRequest(metrics.APIOverpass, "interpreter", resp.StatusCode, started)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Overpass request failed with status %d: %s", resp.StatusCode, resp.Status)
+	}
+
+	var osm *OSM
+	if strings.Contains(resp.Header.Get("Content-Type"), "json") || bytes.HasPrefix(bytes.TrimSpace(body), []byte("{")) {
+		osm, err = ParseOverpassJSON(body)
+	} else {
+		osm, err = ParseOSMFromBytes(body)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Overpass data: %w", err)
+	}
+
+	// Overpass trả về 200 kèm remark khi query bị timeout hoặc hết bộ nhớ
+	if strings.Contains(osm.Remark, "error") {
+		return nil, fmt.Errorf("Overpass query failed: %s", strings.TrimSpace(osm.Remark))
+	}
+
+	return osm, nil
+}
+
+// overpassJSON mirrors the [out:json] response format of Overpass
+type overpassJSON struct {
+	Version   float64           `json:"version"`
+	Generator string            `json:"generator"`
+	Remark    string            `json:"remark"`
+	Elements  []overpassElement `json:"elements"`
+}
+
+type overpassElement struct {
+	Type      string            `json:"type"`
+	ID        int64             `json:"id"`
+	Version   int               `json:"version"`
+	Changeset int64             `json:"changeset"`
+	Timestamp string            `json:"timestamp"`
+	User      string            `json:"user"`
+	UID       int64             `json:"uid"`
+	Lat       float64           `json:"lat"`
+	Lon       float64           `json:"lon"`
+	Nodes     []int64           `json:"nodes"`
+	Geometry  []overpassPoint   `json:"geometry"`
+	Members   []overpassMember  `json:"members"`
+	Tags      map[string]string `json:"tags"`
+}
+
+type overpassMember struct {
+	Type     string          `json:"type"`
+	Ref      int64           `json:"ref"`
+	Role     string          `json:"role"`
+	Geometry []overpassPoint `json:"geometry"`
+}
+
+type overpassPoint struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+// ParseOverpassJSON parses an Overpass [out:json] response into OSM
+func ParseOverpassJSON(data []byte) (*OSM, error) {
+	var doc overpassJSON
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal Overpass JSON: %w", err)
+	}
+
+	osm := &OSM{
+		Version:   fmt.Sprintf("%g", doc.Version),
+		Generator: doc.Generator,
+		Remark:    doc.Remark,
+	}
+
+	for _, el := range doc.Elements {
+		tags := tagsFromMap(el.Tags)
+		switch el.Type {
+		case "node":
+			osm.Nodes = append(osm.Nodes, Node{
+				ID:        el.ID,
+				Visible:   true,
+				Version:   el.Version,
+				Changeset: el.Changeset,
+				Timestamp: el.Timestamp,
+				User:      el.User,
+				UID:       el.UID,
+				Lat:       el.Lat,
+				Lon:       el.Lon,
+				Tags:      tags,
+			})
+		case "way":
+			refs := make([]NodeRef, len(el.Nodes))
+			for i, ref := range el.Nodes {
+				refs[i] = NodeRef{Ref: ref}
+				if i < len(el.Geometry) {
+					refs[i].Lat = el.Geometry[i].Lat
+					refs[i].Lon = el.Geometry[i].Lon
+				}
+			}
+			osm.Ways = append(osm.Ways, Way{
+				ID:        el.ID,
+				Visible:   true,
+				Version:   el.Version,
+				Changeset: el.Changeset,
+				Timestamp: el.Timestamp,
+				User:      el.User,
+				UID:       el.UID,
+				Nodes:     refs,
+				Tags:      tags,
+			})
+		case "relation":
+			members := make([]Member, len(el.Members))
+			for i, m := range el.Members {
+				members[i] = Member{Type: m.Type, Ref: m.Ref, Role: m.Role}
+				for _, p := range m.Geometry {
+					members[i].Geometry = append(members[i].Geometry, NodeRef{Lat: p.Lat, Lon: p.Lon})
+				}
+			}
+			osm.Relations = append(osm.Relations, Relation{
+				ID:        el.ID,
+				Visible:   true,
+				Version:   el.Version,
+				Changeset: el.Changeset,
+				Timestamp: el.Timestamp,
+				User:      el.User,
+				UID:       el.UID,
+				Members:   members,
+				Tags:      tags,
+			})
+		}
+	}
+
+	osm.Index()
+	return osm, nil
+}
+
+// tagsFromMap converts a JSON tag object into a key-sorted tag slice
+func tagsFromMap(m map[string]string) []Tag {
+	if len(m) == 0 {
+		return nil
+	}
+	tags := make([]Tag, 0, len(m))
+	for k, v := range m {
+		tags = append(tags, Tag{Key: k, Value: v})
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Key < tags[j].Key })
+	return tags
+}
+
+// ExpandInlineGeometry turns "out geom" member geometry into regular ways and nodes so the
+// rest of the pipeline (GetWayCoordinates, CreatePolygonFromWaysAndNodes) can use it unchanged.
+// Inline member points carry no node IDs, so synthetic negative IDs are assigned.
+func (osm *OSM) ExpandInlineGeometry() {
+	existingWays := make(map[int64]bool, len(osm.Ways))
+	for _, way := range osm.Ways {
+		existingWays[way.ID] = true
+	}
+
+	nextNodeID := int64(-1)
+	expanded := false
+	for _, relation := range osm.Relations {
+		for _, member := range relation.Members {
+			if member.Type != "way" || len(member.Geometry) == 0 || existingWays[member.Ref] {
+				continue
+			}
+
+			way := Way{ID: member.Ref, Visible: true}
+			for _, point := range member.Geometry {
+				osm.Nodes = append(osm.Nodes, Node{ID: nextNodeID, Visible: true, Lat: point.Lat, Lon: point.Lon})
+				way.Nodes = append(way.Nodes, NodeRef{Ref: nextNodeID, Lat: point.Lat, Lon: point.Lon})
+				nextNodeID--
+			}
+			osm.Ways = append(osm.Ways, way)
+			existingWays[member.Ref] = true
+			expanded = true
+		}
+	}
+	if expanded {
+		osm.InvalidateIndex()
+	}
+}
@@ -0,0 +1,249 @@
+package models
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+const overpassXMLResponse = `<?xml version="1.0" encoding="UTF-8"?>
+<osm version="0.6" generator="Overpass API">
+  <node id="1" lat="21.0" lon="105.0"/>
+  <node id="2" lat="21.0" lon="105.1"/>
+  <node id="3" lat="21.1" lon="105.1"/>
+  <way id="10">
+    <nd ref="1"/><nd ref="2"/><nd ref="3"/><nd ref="1"/>
+  </way>
+  <relation id="100">
+    <member type="way" ref="10" role="outer"/>
+    <tag k="boundary" v="administrative"/>
+    <tag k="admin_level" v="6"/>
+    <tag k="name" v="Xã A"/>
+  </relation>
+</osm>`
+
+const overpassJSONResponse = `{
+  "version": 0.6,
+  "generator": "Overpass API",
+  "elements": [
+    {"type": "node", "id": 1, "lat": 21.0, "lon": 105.0, "tags": {"place": "village", "capital": "6"}},
+    {"type": "way", "id": 10, "nodes": [1, 2], "geometry": [{"lat": 21.0, "lon": 105.0}, {"lat": 21.0, "lon": 105.1}]},
+    {"type": "relation", "id": 100, "tags": {"boundary": "administrative", "admin_level": "4", "name:vi": "Tỉnh B"},
+     "members": [{"type": "way", "ref": 10, "role": "outer"}]}
+  ]
+}`
+
+const overpassRemarkResponse = `<?xml version="1.0" encoding="UTF-8"?>
+<osm version="0.6" generator="Overpass API">
+  <remark> runtime error: Query timed out in "query" at line 3 after 180 seconds. </remark>
+</osm>`
+
+// newOverpassServer trả về server giả trả body với content type cho trước và ghi lại query nhận được
+func newOverpassServer(t *testing.T, contentType, body string, queries *[]string) *OverpassClient {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("method = %s, want POST", r.Method)
+		}
+		data, _ := io.ReadAll(r.Body)
+		form, err := url.ParseQuery(string(data))
+		if err != nil {
+			t.Errorf("body không phải form: %v", err)
+		}
+		if queries != nil {
+			*queries = append(*queries, form.Get("data"))
+		}
+		w.Header().Set("Content-Type", contentType)
+		_, _ = io.WriteString(w, body)
+	}))
+	t.Cleanup(server.Close)
+
+	client := NewOverpassClient()
+	client.BaseURL = server.URL
+	client.HTTPClient = server.Client()
+	return client
+}
+
+func TestOverpassQueryXML(t *testing.T) {
+	var queries []string
+	client := newOverpassServer(t, "application/osm3s+xml", overpassXMLResponse, &queries)
+
+	osm, err := client.Query(context.Background(), "relation(100);out body;")
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if len(queries) != 1 || queries[0] != "relation(100);out body;" {
+		t.Errorf("queries = %q", queries)
+	}
+	if len(osm.Nodes) != 3 || len(osm.Ways) != 1 || len(osm.Relations) != 1 {
+		t.Fatalf("got %d nodes, %d ways, %d relations", len(osm.Nodes), len(osm.Ways), len(osm.Relations))
+	}
+
+	relation, ok := osm.FindRelationByID(100)
+	if !ok {
+		t.Fatal("relation 100 not found")
+	}
+	if relation.GetName() != "Xã A" || relation.GetAdminLevel() != 6 || !relation.IsAdministrativeBoundary() {
+		t.Errorf("relation tags: name=%q admin_level=%d", relation.GetName(), relation.GetAdminLevel())
+	}
+	coordinates, err := osm.GetBoundaryCoordinatesFromRelation(relation)
+	if err != nil {
+		t.Fatalf("GetBoundaryCoordinatesFromRelation: %v", err)
+	}
+	if len(coordinates) < 4 {
+		t.Errorf("got %d boundary coordinates, want a closed ring", len(coordinates))
+	}
+}
+
+func TestOverpassQueryJSON(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+	}{
+		{"json content type", "application/json"},
+		// Một số mirror trả JSON với content type text/plain
+		{"sniffed from body", "text/plain"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newOverpassServer(t, tt.contentType, overpassJSONResponse, nil)
+			osm, err := client.Query(context.Background(), "[out:json];relation(100);out body;")
+			if err != nil {
+				t.Fatalf("Query: %v", err)
+			}
+			if len(osm.Nodes) != 1 || len(osm.Ways) != 1 || len(osm.Relations) != 1 {
+				t.Fatalf("got %d nodes, %d ways, %d relations", len(osm.Nodes), len(osm.Ways), len(osm.Relations))
+			}
+			if got := osm.Relations[0].GetName(); got != "Tỉnh B" {
+				t.Errorf("relation name = %q, want name:vi fallback", got)
+			}
+		})
+	}
+}
+
+func TestOverpassQueryErrors(t *testing.T) {
+	t.Run("remark", func(t *testing.T) {
+		client := newOverpassServer(t, "application/osm3s+xml", overpassRemarkResponse, nil)
+		_, err := client.Query(context.Background(), "relation(100);out body;")
+		if err == nil || !strings.Contains(err.Error(), "Query timed out") {
+			t.Fatalf("err = %v, want remark error", err)
+		}
+	})
+
+	t.Run("status", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "rate limited", http.StatusTooManyRequests)
+		}))
+		defer server.Close()
+		client := NewOverpassClient()
+		client.BaseURL = server.URL
+		_, err := client.Query(context.Background(), "relation(100);out body;")
+		if err == nil || !strings.Contains(err.Error(), "429") {
+			t.Fatalf("err = %v, want status error", err)
+		}
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		client := newOverpassServer(t, "application/json", `{"elements": [`, nil)
+		if _, err := client.Query(context.Background(), "relation(100);out body;"); err == nil {
+			t.Fatal("err = nil, want parse error")
+		}
+	})
+}
+
+func TestParseOverpassJSON(t *testing.T) {
+	osm, err := ParseOverpassJSON([]byte(overpassJSONResponse))
+	if err != nil {
+		t.Fatalf("ParseOverpassJSON: %v", err)
+	}
+	if osm.Version != "0.6" || osm.Generator != "Overpass API" {
+		t.Errorf("version=%q generator=%q", osm.Version, osm.Generator)
+	}
+
+	node, ok := osm.FindNodeByID(1)
+	if !ok {
+		t.Fatal("node 1 not found")
+	}
+	if node.Lat != 21.0 || node.Lon != 105.0 || node.GetCapitalLevel() != 6 || !node.IsPlace() {
+		t.Errorf("node = %+v", node)
+	}
+	// Tag được sắp theo key để output ổn định
+	for i := 1; i < len(node.Tags); i++ {
+		if node.Tags[i-1].Key > node.Tags[i].Key {
+			t.Errorf("tags not sorted: %+v", node.Tags)
+		}
+	}
+
+	way, ok := osm.FindWayByID(10)
+	if !ok {
+		t.Fatal("way 10 not found")
+	}
+	if len(way.Nodes) != 2 || way.Nodes[1].Ref != 2 || way.Nodes[1].Lon != 105.1 {
+		t.Errorf("way nodes = %+v, want inline geometry on refs", way.Nodes)
+	}
+
+	relation := osm.Relations[0]
+	if relation.GetAdminLevel() != 4 || len(relation.Members) != 1 || relation.Members[0].Role != "outer" {
+		t.Errorf("relation = %+v", relation)
+	}
+
+	if _, err := ParseOverpassJSON([]byte("not json")); err == nil {
+		t.Error("ParseOverpassJSON(invalid) err = nil")
+	}
+}
+
+func TestExpandInlineGeometry(t *testing.T) {
+	data := `{"version": 0.6, "elements": [
+	  {"type": "way", "id": 20, "nodes": [5, 6]},
+	  {"type": "relation", "id": 100, "members": [
+	    {"type": "way", "ref": 10, "role": "outer", "geometry": [{"lat": 21.0, "lon": 105.0}, {"lat": 21.0, "lon": 105.1}, {"lat": 21.1, "lon": 105.1}]},
+	    {"type": "way", "ref": 20, "role": "outer", "geometry": [{"lat": 22.0, "lon": 106.0}]},
+	    {"type": "node", "ref": 30, "role": "admin_centre", "geometry": [{"lat": 21.05, "lon": 105.05}]}
+	  ]}
+	]}`
+	osm, err := ParseOverpassJSON([]byte(data))
+	if err != nil {
+		t.Fatalf("ParseOverpassJSON: %v", err)
+	}
+	if _, ok := osm.FindWayByID(10); ok {
+		t.Fatal("way 10 exists before expansion")
+	}
+
+	osm.ExpandInlineGeometry()
+
+	way, ok := osm.FindWayByID(10)
+	if !ok {
+		t.Fatal("way 10 not created from member geometry")
+	}
+	coordinates := osm.GetWayCoordinates(way)
+	if len(coordinates) != 3 {
+		t.Fatalf("got %d coordinates, want 3", len(coordinates))
+	}
+	for i, coordinate := range coordinates {
+		if coordinate.ID >= 0 {
+			t.Errorf("coordinate %d has ID %d, want a synthetic negative ID", i, coordinate.ID)
+		}
+	}
+	if coordinates[2].Lat != 21.1 || coordinates[2].Lon != 105.1 {
+		t.Errorf("last coordinate = %+v", coordinates[2])
+	}
+
+	// Way đã có trong tài liệu và member không phải way được giữ nguyên
+	if len(osm.Ways) != 2 || len(osm.Nodes) != 3 {
+		t.Errorf("got %d ways, %d nodes, want 2 ways, 3 nodes", len(osm.Ways), len(osm.Nodes))
+	}
+	existing, _ := osm.FindWayByID(20)
+	if len(existing.Nodes) != 2 || existing.Nodes[0].Ref != 5 {
+		t.Errorf("existing way changed: %+v", existing.Nodes)
+	}
+
+	// Gọi lại không tạo thêm way/node
+	osm.ExpandInlineGeometry()
+	if len(osm.Ways) != 2 || len(osm.Nodes) != 3 {
+		t.Errorf("second expansion: got %d ways, %d nodes", len(osm.Ways), len(osm.Nodes))
+	}
+}
@@ -28,6 +28,7 @@ func ParseOSMFromReader(reader io.Reader) (*OSM, error) {
 		return nil, fmt.Errorf("failed to decode OSM XML: %w", err)
 	}
 
+	osm.Index()
 	return &osm, nil
 }
 
@@ -39,15 +40,17 @@ func ParseOSMFromBytes(data []byte) (*OSM, error) {
 		return nil, fmt.Errorf("failed to unmarshal OSM XML: %w", err)
 	}
 
+	osm.Index()
 	return &osm, nil
 }
 
 // FilterNodesByTag filters nodes by a specific tag key-value pair
 func (osm *OSM) FilterNodesByTag(key, value string) []Node {
+	ix := osm.Index()
 	var filtered []Node
-	for _, node := range osm.Nodes {
-		if node.GetTagValue(key) == value {
-			filtered = append(filtered, node)
+	for i := range osm.Nodes {
+		if ix.NodeTags(i).Get(key) == value {
+			filtered = append(filtered, osm.Nodes[i])
 		}
 	}
 	return filtered
@@ -55,10 +58,11 @@ func (osm *OSM) FilterNodesByTag(key, value string) []Node {
 
 // FilterWaysByTag filters ways by a specific tag key-value pair
 func (osm *OSM) FilterWaysByTag(key, value string) []Way {
+	ix := osm.Index()
 	var filtered []Way
-	for _, way := range osm.Ways {
-		if way.GetTagValue(key) == value {
-			filtered = append(filtered, way)
+	for i := range osm.Ways {
+		if ix.WayTags(i).Get(key) == value {
+			filtered = append(filtered, osm.Ways[i])
 		}
 	}
 	return filtered
@@ -66,10 +70,11 @@ func (osm *OSM) FilterWaysByTag(key, value string) []Way {
 
 // FilterRelationsByTag filters relations by a specific tag key-value pair
 func (osm *OSM) FilterRelationsByTag(key, value string) []Relation {
+	ix := osm.Index()
 	var filtered []Relation
-	for _, relation := range osm.Relations {
-		if relation.GetTagValue(key) == value {
-			filtered = append(filtered, relation)
+	for i := range osm.Relations {
+		if ix.RelationTags(i).Get(key) == value {
+			filtered = append(filtered, osm.Relations[i])
 		}
 	}
 	return filtered
@@ -77,18 +82,19 @@ func (osm *OSM) FilterRelationsByTag(key, value string) []Relation {
 
 // GetAdministrativeBoundaries returns all administrative boundary ways and relations
 func (osm *OSM) GetAdministrativeBoundaries() ([]Way, []Relation) {
+	ix := osm.Index()
 	var boundaryWays []Way
 	var boundaryRelations []Relation
 
-	for _, way := range osm.Ways {
-		if way.IsAdministrativeBoundary() {
-			boundaryWays = append(boundaryWays, way)
+	for i := range osm.Ways {
+		if ix.WayTags(i).IsAdministrativeBoundary() {
+			boundaryWays = append(boundaryWays, osm.Ways[i])
 		}
 	}
 
-	for _, relation := range osm.Relations {
-		if relation.IsAdministrativeBoundary() {
-			boundaryRelations = append(boundaryRelations, relation)
+	for i := range osm.Relations {
+		if ix.RelationTags(i).IsAdministrativeBoundary() {
+			boundaryRelations = append(boundaryRelations, osm.Relations[i])
 		}
 	}
 
@@ -97,18 +103,19 @@ func (osm *OSM) GetAdministrativeBoundaries() ([]Way, []Relation) {
 
 // GetPlaces returns all place nodes and relations
 func (osm *OSM) GetPlaces() ([]Node, []Relation) {
+	ix := osm.Index()
 	var placeNodes []Node
 	var placeRelations []Relation
 
-	for _, node := range osm.Nodes {
-		if node.IsPlace() {
-			placeNodes = append(placeNodes, node)
+	for i := range osm.Nodes {
+		if ix.NodeTags(i).IsPlace() {
+			placeNodes = append(placeNodes, osm.Nodes[i])
 		}
 	}
 
-	for _, relation := range osm.Relations {
-		if relation.IsPlace() {
-			placeRelations = append(placeRelations, relation)
+	for i := range osm.Relations {
+		if ix.RelationTags(i).IsPlace() {
+			placeRelations = append(placeRelations, osm.Relations[i])
 		}
 	}
 
@@ -117,39 +124,25 @@ func (osm *OSM) GetPlaces() ([]Node, []Relation) {
 
 // FindNodeByID finds a node by its ID
 func (osm *OSM) FindNodeByID(id int64) (*Node, bool) {
-	for _, node := range osm.Nodes {
-		if node.ID == id {
-			return &node, true
-		}
-	}
-	return nil, false
+	return osm.Index().Node(id)
 }
 
 // FindWayByID finds a way by its ID
 func (osm *OSM) FindWayByID(id int64) (*Way, bool) {
-	for _, way := range osm.Ways {
-		if way.ID == id {
-			return &way, true
-		}
-	}
-	return nil, false
+	return osm.Index().Way(id)
 }
 
 // FindRelationByID finds a relation by its ID
 func (osm *OSM) FindRelationByID(id int64) (*Relation, bool) {
-	for _, relation := range osm.Relations {
-		if relation.ID == id {
-			return &relation, true
-		}
-	}
-	return nil, false
+	return osm.Index().Relation(id)
 }
 
 // GetNodesInWay returns all nodes that are referenced in a way
 func (osm *OSM) GetNodesInWay(way *Way) []Node {
-	var nodes []Node
+	ix := osm.Index()
+	nodes := make([]Node, 0, len(way.Nodes))
 	for _, nodeRef := range way.Nodes {
-		if node, found := osm.FindNodeByID(nodeRef.Ref); found {
+		if node, found := ix.Node(nodeRef.Ref); found {
 			nodes = append(nodes, *node)
 		}
 	}
@@ -158,44 +151,10 @@ func (osm *OSM) GetNodesInWay(way *Way) []Node {
 
 // GetWayCoordinates returns coordinates of all nodes in a way
 func (osm *OSM) GetWayCoordinates(way *Way) []Coordinate {
-	var coordinates []Coordinate
-	for _, nodeRef := range way.Nodes {
-		if node, found := osm.FindNodeByID(nodeRef.Ref); found {
-			coordinates = append(coordinates, Coordinate{
-				ID:  node.ID, // Include OSM Node ID
-				Lat: node.Lat,
-				Lon: node.Lon,
-			})
-		}
-	}
-	return coordinates
+	return osm.Index().WayCoordinates(way)
 }
 
 // GetBounds returns the bounding box of all nodes in the OSM data
 func (osm *OSM) GetBounds() (minLat, maxLat, minLon, maxLon float64, hasData bool) {
-	if len(osm.Nodes) == 0 {
-		return 0, 0, 0, 0, false
-	}
-
-	minLat = osm.Nodes[0].Lat
-	maxLat = osm.Nodes[0].Lat
-	minLon = osm.Nodes[0].Lon
-	maxLon = osm.Nodes[0].Lon
-
-	for _, node := range osm.Nodes {
-		if node.Lat < minLat {
-			minLat = node.Lat
-		}
-		if node.Lat > maxLat {
-			maxLat = node.Lat
-		}
-		if node.Lon < minLon {
-			minLon = node.Lon
-		}
-		if node.Lon > maxLon {
-			maxLon = node.Lon
-		}
-	}
-
-	return minLat, maxLat, minLon, maxLon, true
+	return osm.Index().Bounds()
 }
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultOSMRequestsPerSecond giữ lượng request tới api.openstreetmap.org ở mức chính sách sử dụng cho phép
+const DefaultOSMRequestsPerSecond = 2
+
+// RateLimiter spaces requests at least 1/rps apart. It is shared by every goroutine
+// using the same client, so concurrent workers cannot exceed the configured rate.
+type RateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// NewRateLimiter creates a limiter allowing requestsPerSecond requests per second
+func NewRateLimiter(requestsPerSecond float64) *RateLimiter {
+	return &RateLimiter{interval: time.Duration(float64(time.Second) / requestsPerSecond)}
+}
+
+// Wait blocks until the caller may send the next request, or ctx is done
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	slot := l.next
+	if slot.Before(now) {
+		slot = now
+	}
+	l.next = slot.Add(l.interval)
+	l.mu.Unlock()
+
+	delay := time.Until(slot)
+	if delay <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
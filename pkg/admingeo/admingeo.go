@@ -0,0 +1,359 @@
+// Package admingeo tra cứu đơn vị hành chính (tỉnh, xã/phường) offline trong bộ nhớ, cho các service Go
+// cần tra cứu tọa độ mà không gọi tool-map qua HTTP/gRPC. Package không phụ thuộc Oracle, Redis hay MinIO:
+// dữ liệu là snapshot do `tool-map export -format index` (nhị phân) hoặc `-format geojson` tạo ra, nhúng vào
+// binary bằng go:embed hoặc đọc từ file. Index an toàn khi dùng đồng thời.
+//
+//	//go:embed provinces.index communes.index
+//	var snapshots embed.FS
+//
+//	index, err := admingeo.LoadFS(snapshots, "provinces.index", "communes.index")
+//	result := index.Lookup(21.0285, 105.8542) // result.Commune, result.Province
+//
+// Snapshot chỉ chứa dữ liệu tại thời điểm export; cập nhật bằng cách export lại và build lại service.
+// Chỉ mục trong bộ nhớ của tool-map (vector tile, gRPC, geocode) cũng là Index của package này.
+package admingeo
+
+import (
+	"math"
+	"sort"
+	"time"
+	"tool-map/util"
+)
+
+// Cấp đơn vị hành chính (admin_level OSM mặc định)
+const (
+	LevelProvince = 4
+	LevelCommune  = 6
+)
+
+// NeighborToleranceMeters là khoảng cách tối đa giữa ranh giới hai đơn vị để Neighbors coi là giáp nhau;
+// polygon đã đơn giản hóa của hai đơn vị kề nhau thường không trùng đỉnh
+const NeighborToleranceMeters = 50
+
+// cellDegrees là kích thước ô lưới (độ, ~5.5 km) của chỉ mục; mỗi ô giữ danh sách đơn vị có bbox chạm ô
+const cellDegrees = 0.05
+
+// Unit là một tỉnh hoặc xã/phường trong Index; không được sửa
+type Unit struct {
+	Level int
+	Code  string // MATT hoặc MA_PHUONG_XA
+	Name  string
+	MaTT  string     // với xã/phường là tỉnh chứa nó, với tỉnh là chính mã tỉnh
+	BBox  [4]float64 // [minLon, minLat, maxLon, maxLat]
+
+	// polygons là các polygon [vòng ngoài, các lỗ...], tọa độ [lat, lon]
+	polygons [][][][2]float64
+}
+
+// Polygons trả về các polygon [vòng ngoài, các lỗ...] của đơn vị, tọa độ [lat, lon] như POLYGON_DATA;
+// không được sửa
+func (u *Unit) Polygons() [][][][2]float64 {
+	return u.polygons
+}
+
+// Contains cho biết điểm (lat, lon) có nằm trong đơn vị không, tính cả lỗ của polygon
+func (u *Unit) Contains(lat, lon float64) bool {
+	if lat < u.BBox[1] || lat > u.BBox[3] || lon < u.BBox[0] || lon > u.BBox[2] {
+		return false
+	}
+	for _, polygon := range u.polygons {
+		if !util.PointInRing(lat, lon, polygon[0]) {
+			continue
+		}
+		inHole := false
+		for _, hole := range polygon[1:] {
+			if util.PointInRing(lat, lon, hole) {
+				inHole = true
+				break
+			}
+		}
+		if !inHole {
+			return true
+		}
+	}
+	return false
+}
+
+// DistanceToBoundaryMeters là khoảng cách (mét) từ điểm tới ranh giới gần nhất của đơn vị
+func (u *Unit) DistanceToBoundaryMeters(lat, lon float64) float64 {
+	distance := math.Inf(1)
+	for _, polygon := range u.polygons {
+		for _, ring := range polygon {
+			distance = math.Min(distance, util.DistanceToRingMeters(lat, lon, ring))
+		}
+	}
+	return distance
+}
+
+// near cho biết ranh giới của u và other cách nhau không quá toleranceMeters, hoặc chồng lên nhau
+func (u *Unit) near(other *Unit, toleranceMeters float64) bool {
+	margin := toleranceMeters / 111000 // độ vĩ
+	lonMargin := margin / math.Cos(math.Max(math.Abs(u.BBox[1]), math.Abs(u.BBox[3]))*math.Pi/180)
+	for _, polygon := range u.polygons {
+		for _, ring := range polygon {
+			for _, point := range ring {
+				lat, lon := point[0], point[1]
+				if lat < other.BBox[1]-margin || lat > other.BBox[3]+margin ||
+					lon < other.BBox[0]-lonMargin || lon > other.BBox[2]+lonMargin {
+					continue
+				}
+				if other.Contains(lat, lon) || other.DistanceToBoundaryMeters(lat, lon) <= toleranceMeters {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func (u *Unit) overlaps(minLat, minLon, maxLat, maxLon float64) bool {
+	return u.BBox[1] <= maxLat && u.BBox[3] >= minLat && u.BBox[0] <= maxLon && u.BBox[2] >= minLon
+}
+
+// Result là kết quả Lookup. Commune nil là điểm không thuộc xã/phường nào trong snapshot (ngoài khơi,
+// ngoài biên giới, hoặc snapshot chỉ có tỉnh).
+type Result struct {
+	Commune  *Unit
+	Province *Unit
+	// DistanceToBoundaryMeters là khoảng cách từ điểm tới ranh giới xã/phường chứa nó (nhỏ = sát ranh giới)
+	DistanceToBoundaryMeters float64
+}
+
+// Found cho biết điểm thuộc một xã/phường
+func (r Result) Found() bool {
+	return r.Commune != nil
+}
+
+// Index là chỉ mục polygon trong bộ nhớ, tra cứu theo lưới ô cellDegrees.
+// Chỉ đọc sau khi tạo nên dùng đồng thời từ nhiều goroutine được.
+type Index struct {
+	units    []*Unit
+	cells    map[[2]int32][]*Unit
+	byCode   map[unitKey]*Unit
+	children map[string][]*Unit // xã/phường theo MATT
+	created  time.Time
+}
+
+type unitKey struct {
+	level int
+	code  string
+}
+
+func newIndex() *Index {
+	return &Index{
+		cells:    make(map[[2]int32][]*Unit),
+		byCode:   make(map[unitKey]*Unit),
+		children: make(map[string][]*Unit),
+	}
+}
+
+// New tạo chỉ mục từ các đơn vị; mỗi vòng của SnapshotUnit.Polygons là một polygon không có lỗ
+func New(units []SnapshotUnit) *Index {
+	index := newIndex()
+	index.addUnits(units)
+	index.finish()
+	return index
+}
+
+// Len trả về số đơn vị trong chỉ mục
+func (x *Index) Len() int {
+	return len(x.units)
+}
+
+// Created là thời điểm export của snapshot cũ nhất đã nạp; zero nếu không biết (GeoJSON, snapshot cũ)
+func (x *Index) Created() time.Time {
+	return x.created
+}
+
+// Locate tìm xã/phường và tỉnh chứa (lat, lon); nil nếu không có đơn vị cấp đó chứa điểm
+func (x *Index) Locate(lat, lon float64) (commune, province *Unit) {
+	for _, unit := range x.cells[cellOf(lat, lon)] {
+		if (unit.Level == LevelCommune && commune != nil) || (unit.Level == LevelProvince && province != nil) {
+			continue
+		}
+		if !unit.Contains(lat, lon) {
+			continue
+		}
+		switch unit.Level {
+		case LevelCommune:
+			commune = unit
+		case LevelProvince:
+			province = unit
+		}
+		if commune != nil && province != nil {
+			break
+		}
+	}
+	return commune, province
+}
+
+// Lookup tìm xã/phường và tỉnh chứa (lat, lon). Tỉnh lấy theo xã/phường khi điểm nằm ở khe giữa polygon
+// tỉnh và polygon xã; tọa độ không hợp lệ trả về Result rỗng.
+func (x *Index) Lookup(lat, lon float64) Result {
+	var result Result
+	if !(lat >= -90 && lat <= 90) || !(lon >= -180 && lon <= 180) {
+		return result
+	}
+	result.Commune, result.Province = x.Locate(lat, lon)
+	if result.Commune != nil {
+		result.DistanceToBoundaryMeters = result.Commune.DistanceToBoundaryMeters(lat, lon)
+		if result.Province == nil {
+			result.Province = x.UnitAt(LevelProvince, result.Commune.MaTT)
+		}
+	}
+	return result
+}
+
+// Unit trả về tỉnh (MATT) hoặc xã/phường (MA_PHUONG_XA) theo mã, ưu tiên tỉnh; nil nếu không có
+func (x *Index) Unit(code string) *Unit {
+	if unit := x.UnitAt(LevelProvince, code); unit != nil {
+		return unit
+	}
+	return x.UnitAt(LevelCommune, code)
+}
+
+// UnitAt trả về đơn vị cấp level có mã code; nil nếu không có
+func (x *Index) UnitAt(level int, code string) *Unit {
+	return x.byCode[unitKey{level, code}]
+}
+
+// Provinces trả về các tỉnh theo mã
+func (x *Index) Provinces() []*Unit {
+	var provinces []*Unit
+	for _, unit := range x.units {
+		if unit.Level == LevelProvince && unit.Code != "" {
+			provinces = append(provinces, unit)
+		}
+	}
+	sortUnits(provinces)
+	return provinces
+}
+
+// Children trả về các xã/phường của tỉnh code theo mã; nil nếu code không phải tỉnh có xã/phường trong snapshot
+func (x *Index) Children(code string) []*Unit {
+	children := x.children[code]
+	if len(children) == 0 {
+		return nil
+	}
+	return append([]*Unit(nil), children...)
+}
+
+// Neighbors trả về các đơn vị cùng cấp giáp ranh giới với đơn vị code (cách nhau không quá
+// NeighborToleranceMeters), theo mã. Kết quả được tính khi gọi, từ các đơn vị có bbox gần bbox của code.
+func (x *Index) Neighbors(code string) []*Unit {
+	unit := x.Unit(code)
+	if unit == nil {
+		return nil
+	}
+
+	var neighbors []*Unit
+	candidates := x.Intersecting(unit.Level, unit.BBox[1]-cellDegrees, unit.BBox[0]-cellDegrees, unit.BBox[3]+cellDegrees, unit.BBox[2]+cellDegrees)
+	for _, other := range candidates {
+		if other == unit || other.Code == "" {
+			continue
+		}
+		if unit.near(other, NeighborToleranceMeters) || other.near(unit, NeighborToleranceMeters) {
+			neighbors = append(neighbors, other)
+		}
+	}
+	sortUnits(neighbors)
+	return neighbors
+}
+
+// Intersecting trả về các đơn vị cấp level có bbox giao khung [minLat, maxLat] x [minLon, maxLon].
+// Khung lớn hơn số đơn vị (tính theo ô lưới) thì duyệt thẳng danh sách thay vì từng ô.
+func (x *Index) Intersecting(level int, minLat, minLon, maxLat, maxLon float64) []*Unit {
+	var result []*Unit
+	minCell, maxCell := cellOf(minLat, minLon), cellOf(maxLat, maxLon)
+	cells := (int64(maxCell[0]-minCell[0]) + 1) * (int64(maxCell[1]-minCell[1]) + 1)
+	if cells > int64(len(x.units)) {
+		for _, unit := range x.units {
+			if unit.Level == level && unit.overlaps(minLat, minLon, maxLat, maxLon) {
+				result = append(result, unit)
+			}
+		}
+		return result
+	}
+
+	seen := make(map[*Unit]bool)
+	for row := minCell[0]; row <= maxCell[0]; row++ {
+		for col := minCell[1]; col <= maxCell[1]; col++ {
+			for _, unit := range x.cells[[2]int32{row, col}] {
+				if seen[unit] {
+					continue
+				}
+				seen[unit] = true
+				if unit.Level == level && unit.overlaps(minLat, minLon, maxLat, maxLon) {
+					result = append(result, unit)
+				}
+			}
+		}
+	}
+	return result
+}
+
+func (x *Index) addUnits(units []SnapshotUnit) {
+	for i := range units {
+		unit := &units[i]
+		polygons := make([][][][2]float64, 0, len(unit.Polygons))
+		for _, ring := range unit.Polygons {
+			polygons = append(polygons, [][][2]float64{ring})
+		}
+		x.add(unit.Level, unit.Code, unit.Name, unit.MaTT, polygons)
+	}
+}
+
+// add tính bbox của đơn vị có polygon [lat, lon] và đưa vào các ô lưới mà bbox chạm tới. Đơn vị không có
+// polygon bị bỏ qua; đơn vị không có mã (dựng từ file OSM) chỉ tra cứu được theo tọa độ.
+func (x *Index) add(level int, code, name, maTT string, polygons [][][][2]float64) {
+	if level == LevelProvince {
+		maTT = code
+	}
+	minLat, minLon := math.Inf(1), math.Inf(1)
+	maxLat, maxLon := math.Inf(-1), math.Inf(-1)
+	var kept [][][][2]float64
+	for _, polygon := range polygons {
+		if len(polygon) == 0 || len(polygon[0]) < 3 {
+			continue
+		}
+		kept = append(kept, polygon)
+		for _, point := range polygon[0] {
+			minLat, maxLat = math.Min(minLat, point[0]), math.Max(maxLat, point[0])
+			minLon, maxLon = math.Min(minLon, point[1]), math.Max(maxLon, point[1])
+		}
+	}
+	if len(kept) == 0 {
+		return
+	}
+
+	unit := &Unit{Level: level, Code: code, Name: name, MaTT: maTT, BBox: [4]float64{minLon, minLat, maxLon, maxLat}, polygons: kept}
+	x.units = append(x.units, unit)
+	if code != "" {
+		x.byCode[unitKey{level, code}] = unit
+		if level == LevelCommune {
+			x.children[maTT] = append(x.children[maTT], unit)
+		}
+	}
+	minCell, maxCell := cellOf(minLat, minLon), cellOf(maxLat, maxLon)
+	for row := minCell[0]; row <= maxCell[0]; row++ {
+		for col := minCell[1]; col <= maxCell[1]; col++ {
+			cell := [2]int32{row, col}
+			x.cells[cell] = append(x.cells[cell], unit)
+		}
+	}
+}
+
+// finish sắp xếp danh sách xã/phường của từng tỉnh sau khi nạp xong
+func (x *Index) finish() {
+	for _, children := range x.children {
+		sortUnits(children)
+	}
+}
+
+func cellOf(lat, lon float64) [2]int32 {
+	return [2]int32{int32(math.Floor(lat / cellDegrees)), int32(math.Floor(lon / cellDegrees))}
+}
+
+func sortUnits(units []*Unit) {
+	sort.Slice(units, func(i, j int) bool { return units[i].Code < units[j].Code })
+}
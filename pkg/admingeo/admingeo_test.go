@@ -0,0 +1,217 @@
+package admingeo
+
+import (
+	"bytes"
+	"encoding/gob"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// square trả về vòng khép kín [lat, lon] của khung [minLat, maxLat] x [minLon, maxLon]
+func square(minLat, minLon, maxLat, maxLon float64) [][2]float64 {
+	return [][2]float64{{minLat, minLon}, {minLat, maxLon}, {maxLat, maxLon}, {maxLat, minLon}, {minLat, minLon}}
+}
+
+// testUnits: tỉnh 01 chia thành ba xã 00001 (tây nam), 00002 (đông nam), 00003 (tây bắc); tỉnh 02 ở xa
+var testUnits = []SnapshotUnit{
+	{Level: LevelProvince, Code: "01", Name: "Tỉnh A", Polygons: [][][2]float64{square(21, 105, 21.2, 105.2)}},
+	{Level: LevelProvince, Code: "02", Name: "Tỉnh B", Polygons: [][][2]float64{square(10, 106, 10.1, 106.1)}},
+	{Level: LevelCommune, Code: "00001", Name: "Xã A1", MaTT: "01", Polygons: [][][2]float64{square(21, 105, 21.1, 105.1)}},
+	{Level: LevelCommune, Code: "00002", Name: "Xã A2", MaTT: "01", Polygons: [][][2]float64{square(21, 105.1, 21.1, 105.2)}},
+	{Level: LevelCommune, Code: "00003", Name: "Xã A3", MaTT: "01", Polygons: [][][2]float64{square(21.1, 105, 21.2, 105.1)}},
+	{Level: LevelCommune, Code: "00021", Name: "Xã B1", MaTT: "02", Polygons: [][][2]float64{square(10, 106, 10.1, 106.1)}},
+}
+
+// testGeoJSON: tỉnh 03 có xã 00031 với một lỗ, và xã 00032 nằm đúng trong lỗ đó; tọa độ [lon, lat]
+const testGeoJSON = `{"type": "FeatureCollection", "features": [
+  {"type": "Feature", "properties": {"level": 4, "code": "03", "name": "Tỉnh C"},
+   "geometry": {"type": "Polygon", "coordinates": [[[100, 15], [100.2, 15], [100.2, 15.2], [100, 15.2], [100, 15]]]}},
+  {"type": "Feature", "properties": {"level": 6, "code": "00031", "name": "Xã C1", "maTT": "03"},
+   "geometry": {"type": "MultiPolygon", "coordinates": [[
+     [[100, 15], [100.2, 15], [100.2, 15.2], [100, 15.2], [100, 15]],
+     [[100.05, 15.05], [100.15, 15.05], [100.15, 15.15], [100.05, 15.15], [100.05, 15.05]]
+   ]]}},
+  {"type": "Feature", "properties": {"level": 6, "code": "00032", "name": "Xã C2", "maTT": "03"},
+   "geometry": {"type": "Polygon", "coordinates": [[[100.05, 15.05], [100.15, 15.05], [100.15, 15.15], [100.05, 15.15], [100.05, 15.05]]]}},
+  {"type": "Feature", "properties": {"level": 6, "name": "không có mã"},
+   "geometry": {"type": "Polygon", "coordinates": [[[0, 0], [1, 0], [1, 1], [0, 0]]]}}
+]}`
+
+func loadTestIndex(t *testing.T) *Index {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := WriteSnapshot(&buf, testUnits); err != nil {
+		t.Fatalf("WriteSnapshot: %v", err)
+	}
+	index, err := Load(buf.Bytes(), []byte(testGeoJSON))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	return index
+}
+
+func codes(units []*Unit) []string {
+	var result []string
+	for _, unit := range units {
+		result = append(result, unit.Code)
+	}
+	return result
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	before := time.Now().UTC()
+	if err := WriteSnapshot(&buf, testUnits); err != nil {
+		t.Fatalf("WriteSnapshot: %v", err)
+	}
+	index, err := Load(buf.Bytes())
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if index.Len() != len(testUnits) {
+		t.Errorf("Len = %d, want %d", index.Len(), len(testUnits))
+	}
+	if created := index.Created(); created.Before(before.Add(-time.Second)) || created.After(time.Now().UTC()) {
+		t.Errorf("Created = %v, want the export time", created)
+	}
+	for _, want := range testUnits {
+		unit := index.UnitAt(want.Level, want.Code)
+		if unit == nil {
+			t.Errorf("unit %d/%s missing after round trip", want.Level, want.Code)
+			continue
+		}
+		wantMaTT := want.MaTT
+		if want.Level == LevelProvince {
+			wantMaTT = want.Code
+		}
+		if unit.Name != want.Name || unit.MaTT != wantMaTT {
+			t.Errorf("unit %s = %q (maTT %q), want %q (maTT %q)", want.Code, unit.Name, unit.MaTT, want.Name, wantMaTT)
+		}
+		if !reflect.DeepEqual(unit.Polygons(), [][][][2]float64{want.Polygons}) {
+			t.Errorf("unit %s polygons = %v, want %v", want.Code, unit.Polygons(), want.Polygons)
+		}
+	}
+	if got := codes(index.Provinces()); !reflect.DeepEqual(got, []string{"01", "02"}) {
+		t.Errorf("Provinces = %v", got)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	var mismatched bytes.Buffer
+	if err := gob.NewEncoder(&mismatched).Encode(snapshot{Version: SnapshotVersion + 1, Units: testUnits}); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"version mismatch", mismatched.Bytes(), "phiên bản"},
+		{"empty", []byte(" \n"), "rỗng"},
+		{"not a snapshot", []byte("garbage"), "snapshot không hợp lệ"},
+		{"bad geojson", []byte(`{"features": [`), "GeoJSON không hợp lệ"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			index, err := Load(tt.data)
+			if err == nil {
+				t.Fatalf("Load = %d units, want error", index.Len())
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %q, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	index := loadTestIndex(t)
+
+	tests := []struct {
+		name              string
+		lat, lon          float64
+		commune, province string
+		maxBoundaryMeters float64
+	}{
+		{"inside commune", 21.05, 105.05, "00001", "01", 6000},
+		{"other commune", 21.15, 105.05, "00003", "01", 6000},
+		{"gap in commune layer", 21.15, 105.15, "", "01", 0},
+		{"geojson commune", 15.02, 100.02, "00031", "03", 3000},
+		{"inside hole", 15.1, 100.1, "00032", "03", 6000},
+		{"geojson feature without code skipped", 0.5, 0.2, "", "", 0},
+		{"outside", 30, 120, "", "", 0},
+		{"invalid coordinate", 91, 105, "", "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := index.Lookup(tt.lat, tt.lon)
+			var commune, province string
+			if result.Commune != nil {
+				commune = result.Commune.Code
+			}
+			if result.Province != nil {
+				province = result.Province.Code
+			}
+			if commune != tt.commune || province != tt.province {
+				t.Fatalf("Lookup = commune %q province %q, want %q %q", commune, province, tt.commune, tt.province)
+			}
+			if result.Found() && result.DistanceToBoundaryMeters > tt.maxBoundaryMeters {
+				t.Errorf("DistanceToBoundaryMeters = %g, want <= %g", result.DistanceToBoundaryMeters, tt.maxBoundaryMeters)
+			}
+		})
+	}
+
+	// Điểm trong lỗ không thuộc xã có lỗ
+	if index.Unit("00031").Contains(15.1, 100.1) {
+		t.Error("00031 contains a point inside its hole")
+	}
+
+	// Điểm nằm đúng trên ranh giới chung của 00001 và 00002 thuộc về đúng một trong hai xã
+	result := index.Lookup(21.05, 105.1)
+	if !result.Found() || (result.Commune.Code != "00001" && result.Commune.Code != "00002") {
+		t.Fatalf("Lookup on shared boundary = %+v, want 00001 or 00002", result.Commune)
+	}
+	if result.DistanceToBoundaryMeters > 1 {
+		t.Errorf("DistanceToBoundaryMeters on boundary = %g, want ~0", result.DistanceToBoundaryMeters)
+	}
+}
+
+func TestChildrenAndNeighbors(t *testing.T) {
+	index := loadTestIndex(t)
+
+	tests := []struct {
+		name string
+		got  []*Unit
+		want []string
+	}{
+		{"children of province", index.Children("01"), []string{"00001", "00002", "00003"}},
+		{"children of geojson province", index.Children("03"), []string{"00031", "00032"}},
+		{"children of commune", index.Children("00001"), nil},
+		{"children of unknown", index.Children("99"), nil},
+		{"neighbors sharing edges", index.Neighbors("00001"), []string{"00002", "00003"}},
+		// 00002 và 00003 chỉ chạm nhau ở một góc, vẫn nằm trong NeighborToleranceMeters
+		{"neighbors touching at a corner", index.Neighbors("00002"), []string{"00001", "00003"}},
+		{"neighbors of isolated commune", index.Neighbors("00021"), nil},
+		{"neighbors across a hole", index.Neighbors("00032"), []string{"00031"}},
+		{"neighbors of province", index.Neighbors("01"), nil},
+		{"neighbors of unknown", index.Neighbors("99"), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := codes(tt.got); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Children trả về bản sao, sửa kết quả không ảnh hưởng index
+	children := index.Children("01")
+	children[0] = nil
+	if index.Children("01")[0] == nil {
+		t.Error("Children returned the index's own slice")
+	}
+}
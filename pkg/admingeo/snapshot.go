@@ -0,0 +1,175 @@
+package admingeo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// SnapshotVersion là phiên bản định dạng snapshot nhị phân; snapshot khác phiên bản bị từ chối khi nạp.
+// Đổi tên hoặc kiểu field của snapshot/SnapshotUnit thì phải tăng phiên bản.
+const SnapshotVersion = 1
+
+// snapshot là nội dung file nhị phân của `export -format index`, mã hóa bằng encoding/gob
+type snapshot struct {
+	Version int
+	Created time.Time // thời điểm export
+	Units   []SnapshotUnit
+}
+
+// SnapshotUnit là một đơn vị trong snapshot; mỗi phần tử của Polygons là một vòng [lat, lon] không có lỗ
+// như POLYGON_DATA
+type SnapshotUnit struct {
+	Level    int
+	Code     string
+	Name     string
+	MaTT     string
+	Polygons [][][2]float64
+}
+
+// WriteSnapshot ghi snapshot nhị phân của các đơn vị cho Load/LoadFile/LoadFS
+func WriteSnapshot(w io.Writer, units []SnapshotUnit) error {
+	return gob.NewEncoder(w).Encode(snapshot{Version: SnapshotVersion, Created: time.Now().UTC(), Units: units})
+}
+
+// Load nạp chỉ mục từ nội dung các snapshot (ví dụ biến []byte của go:embed); mỗi snapshot là file nhị phân
+// của `export -format index` hoặc FeatureCollection GeoJSON, nhận dạng theo nội dung
+func Load(snapshots ...[]byte) (*Index, error) {
+	index := newIndex()
+	for i, data := range snapshots {
+		if err := index.read(bytes.NewReader(data)); err != nil {
+			return nil, fmt.Errorf("admingeo: snapshot %d: %w", i, err)
+		}
+	}
+	index.finish()
+	return index, nil
+}
+
+// LoadFile nạp chỉ mục từ các file snapshot, ví dụ một file tỉnh và một file xã/phường
+func LoadFile(paths ...string) (*Index, error) {
+	return loadFiles(func(path string) (io.ReadCloser, error) { return os.Open(path) }, paths)
+}
+
+// LoadFS nạp chỉ mục từ các file snapshot trong fsys (ví dụ embed.FS)
+func LoadFS(fsys fs.FS, paths ...string) (*Index, error) {
+	return loadFiles(func(path string) (io.ReadCloser, error) { return fsys.Open(path) }, paths)
+}
+
+func loadFiles(open func(string) (io.ReadCloser, error), paths []string) (*Index, error) {
+	index := newIndex()
+	for _, path := range paths {
+		file, err := open(path)
+		if err != nil {
+			return nil, fmt.Errorf("admingeo: %w", err)
+		}
+		err = index.read(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("admingeo: %s: %w", path, err)
+		}
+	}
+	index.finish()
+	return index, nil
+}
+
+// read nạp một snapshot nhị phân hoặc GeoJSON (bắt đầu bằng '{') từ r
+func (x *Index) read(r io.Reader) error {
+	reader := bufio.NewReader(r)
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return errors.New("snapshot rỗng")
+			}
+			return err
+		}
+		switch b {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+		if err := reader.UnreadByte(); err != nil {
+			return err
+		}
+		if b == '{' {
+			return x.readGeoJSON(reader)
+		}
+		return x.readSnapshot(reader)
+	}
+}
+
+func (x *Index) readSnapshot(r io.Reader) error {
+	var data snapshot
+	if err := gob.NewDecoder(r).Decode(&data); err != nil {
+		return fmt.Errorf("snapshot không hợp lệ: %w", err)
+	}
+	if data.Version != SnapshotVersion {
+		return fmt.Errorf("snapshot có phiên bản %d, package đọc được phiên bản %d", data.Version, SnapshotVersion)
+	}
+	if !data.Created.IsZero() && (x.created.IsZero() || data.Created.Before(x.created)) {
+		x.created = data.Created
+	}
+	x.addUnits(data.Units)
+	return nil
+}
+
+// readGeoJSON đọc FeatureCollection của `export -format geojson`: hình học Polygon/MultiPolygon và thuộc tính
+// level, code, name, maTT
+func (x *Index) readGeoJSON(r io.Reader) error {
+	var collection struct {
+		Features []struct {
+			Properties struct {
+				Level int    `json:"level"`
+				Code  string `json:"code"`
+				Name  string `json:"name"`
+				MaTT  string `json:"maTT"`
+			} `json:"properties"`
+			Geometry struct {
+				Type        string          `json:"type"`
+				Coordinates json.RawMessage `json:"coordinates"`
+			} `json:"geometry"`
+		} `json:"features"`
+	}
+	if err := json.NewDecoder(r).Decode(&collection); err != nil {
+		return fmt.Errorf("GeoJSON không hợp lệ: %w", err)
+	}
+
+	for _, feature := range collection.Features {
+		properties := feature.Properties
+		if properties.Code == "" {
+			continue
+		}
+		var polygons [][][][2]float64
+		switch feature.Geometry.Type {
+		case "Polygon":
+			var polygon [][][2]float64
+			if err := json.Unmarshal(feature.Geometry.Coordinates, &polygon); err != nil {
+				return fmt.Errorf("feature %s: %w", properties.Code, err)
+			}
+			polygons = [][][][2]float64{polygon}
+		case "MultiPolygon":
+			if err := json.Unmarshal(feature.Geometry.Coordinates, &polygons); err != nil {
+				return fmt.Errorf("feature %s: %w", properties.Code, err)
+			}
+		default:
+			continue
+		}
+
+		// GeoJSON dùng [lon, lat], chỉ mục dùng [lat, lon] như POLYGON_DATA
+		for _, polygon := range polygons {
+			for _, ring := range polygon {
+				for i := range ring {
+					ring[i][0], ring[i][1] = ring[i][1], ring[i][0]
+				}
+			}
+		}
+		x.add(properties.Level, properties.Code, properties.Name, properties.MaTT, polygons)
+	}
+	return nil
+}
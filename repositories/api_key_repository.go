@@ -0,0 +1,37 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"tool-map/entities"
+
+	"gorm.io/gorm"
+)
+
+type ApiKeyRepositoryInterface interface {
+	GetActive(ctx context.Context) ([]entities.ApiKey, error)
+}
+
+type ApiKeyRepository struct {
+	*BaseRepository
+}
+
+// NewApiKeyRepository creates a new ApiKey repository
+func NewApiKeyRepository(db *gorm.DB) *ApiKeyRepository {
+	return &ApiKeyRepository{
+		BaseRepository: NewBaseRepository(db),
+	}
+}
+
+// GetActive returns keys that are enabled and not expired
+func (r *ApiKeyRepository) GetActive(ctx context.Context) ([]entities.ApiKey, error) {
+	var keys []entities.ApiKey
+	err := r.db.WithContext(ctx).
+		Where("TRANG_THAI = 1 AND (NGAY_HET_HAN IS NULL OR NGAY_HET_HAN > ?)", time.Now()).
+		Find(&keys).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get api keys: %w", err)
+	}
+	return keys, nil
+}
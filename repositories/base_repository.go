@@ -1,6 +1,8 @@
 package repositories
 
 import (
+	"context"
+
 	"gorm.io/gorm"
 )
 
@@ -21,9 +23,9 @@ func (r *BaseRepository) GetDB() *gorm.DB {
 	return r.db
 }
 
-// Begin starts a transaction
-func (r *BaseRepository) Begin() *gorm.DB {
-	return r.db.Begin()
+// Begin starts a transaction bound to ctx
+func (r *BaseRepository) Begin(ctx context.Context) *gorm.DB {
+	return r.db.WithContext(ctx).Begin()
 }
 
 // Commit commits a transaction
@@ -1,8 +1,9 @@
 package repositories
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"strings"
 	"tool-map/entities"
 	"tool-map/util"
@@ -11,12 +12,20 @@ import (
 )
 
 type DmPhuongXaRepositoryInterface interface {
-	GetByName(name string, maTT string) (*entities.DmPhuongXa, error)
-	GetWhenHavePolygonAndCenterNull() ([]entities.DmPhuongXa, error)
+	GetByName(ctx context.Context, name string, maTT string) (*entities.DmPhuongXa, error)
+	GetByOsmRelationID(ctx context.Context, relationID int64) (*entities.DmPhuongXa, error)
+	GetByMaPhuongXa(ctx context.Context, maPhuongXa string) (*entities.DmPhuongXa, error)
+	ListByMaTT(ctx context.Context, maTT string) ([]entities.DmPhuongXa, error)
+	FindCandidatesByCoordinate(ctx context.Context, maTT string, lat, lon, margin float64) ([]entities.DmPhuongXa, error)
+	GetWhenHavePolygonAndCenterNull(ctx context.Context) ([]entities.DmPhuongXa, error)
+	GetAllByMaTT(ctx context.Context, maTT string) ([]entities.DmPhuongXa, error)
+	GetWithPolygonByMaTT(ctx context.Context, maTT string) ([]entities.DmPhuongXa, error)
 
-	UpdateDataAddressByMaPhuongXa(id string, maxLat, minLat, maxLon, minLon, lonCenter, latCenter *float64) error
-	UpdatePolygonDataByMaPhuongXa(id string, polygonData *string) error
-	UpdateLatLonCenterByMaPhuongXa(id string, latCenter, lonCenter *float64) error
+	UpdateDataAddressByMaPhuongXa(ctx context.Context, id string, maxLat, minLat, maxLon, minLon, lonCenter, latCenter *float64) error
+	UpdateOsmVersionByMaPhuongXa(ctx context.Context, id string, relationID *int64, version *int, changeset *int64, timestamp, geometryHash *string) error
+	ClaimOsmRelationByMaPhuongXa(ctx context.Context, id string, relationID int64) (bool, error)
+	UpdatePolygonDataByMaPhuongXa(ctx context.Context, id string, polygonData *string) error
+	UpdateLatLonCenterByMaPhuongXa(ctx context.Context, id string, latCenter, lonCenter *float64) error
 }
 
 // DmPhuongXaRepository handles database operations for DmPhuongXa entities
@@ -31,23 +40,26 @@ func NewDmPhuongXaRepository(db *gorm.DB) *DmPhuongXaRepository {
 	}
 }
 
-func (r *DmPhuongXaRepository) GetByName(name string, maTT string) (*entities.DmPhuongXa, error) {
+// GetByName finds a commune of the province by name (exact, then accent-insensitive). Rows already linked to an
+// OSM relation are skipped so that two communes with the same name do not resolve to the same row; look those
+// up with GetByOsmRelationID.
+func (r *DmPhuongXaRepository) GetByName(ctx context.Context, name string, maTT string) (*entities.DmPhuongXa, error) {
 	var dmPhuongXa entities.DmPhuongXa
 	// Thử tìm kiếm chính xác trước
-	if err := r.db.Where("TEN_PHUONG_XA = ? AND TRUC_THUOC_TINH = ? AND POLYGON_DATA IS NULL", name, maTT).First(&dmPhuongXa).Error; err != nil {
+	if err := r.db.WithContext(ctx).Where("TEN_PHUONG_XA = ? AND TRUC_THUOC_TINH = ? AND OSM_RELATION_ID IS NULL", name, maTT).First(&dmPhuongXa).Error; err != nil {
 		// Lấy ra toàn bộ phường xã thuộc tỉnh theo mã tỉnh, chỉ lấy name và mã phường xã
 		var phuongs []struct {
 			MaPhuongXa  string
 			TenPhuongXa string
 		}
 
-		if err := r.db.
+		if err := r.db.WithContext(ctx).
 			Table("DM_PHUONG_XA").
 			Select("MA_PHUONG_XA, TEN_PHUONG_XA").
 			Where("TRUC_THUOC_TINH = ?", maTT).
-			Where("POLYGON_DATA IS NULL").
+			Where("OSM_RELATION_ID IS NULL").
 			Find(&phuongs).Error; err != nil {
-			log.Printf("Lỗi khi lấy ra toàn bộ phường xã thuộc tỉnh theo mã tỉnh: %v", err)
+			slog.Error("Lỗi khi lấy ra toàn bộ phường xã thuộc tỉnh theo mã tỉnh", "ma_tt", maTT, "error", err)
 			return nil, err
 		}
 
@@ -56,9 +68,9 @@ func (r *DmPhuongXaRepository) GetByName(name string, maTT string) (*entities.Dm
 				dmPhuongXa.MaPhuongXa = phuong.MaPhuongXa
 				dmPhuongXa.TenPhuongXa = phuong.TenPhuongXa
 
-				err := r.db.Where("MA_PHUONG_XA = ? AND POLYGON_DATA IS NULL", dmPhuongXa.MaPhuongXa).First(&dmPhuongXa).Error
+				err := r.db.WithContext(ctx).Where("MA_PHUONG_XA = ? AND OSM_RELATION_ID IS NULL", dmPhuongXa.MaPhuongXa).First(&dmPhuongXa).Error
 				if err != nil {
-					log.Printf("Lỗi khi lấy ra phường xã từ database: %v", err)
+					slog.Error("Lỗi khi lấy ra phường xã từ database", "commune", dmPhuongXa.MaPhuongXa, "error", err)
 					return nil, err
 				}
 				return &dmPhuongXa, nil
@@ -68,16 +80,97 @@ func (r *DmPhuongXaRepository) GetByName(name string, maTT string) (*entities.Dm
 	return &dmPhuongXa, nil
 }
 
-func (r *DmPhuongXaRepository) GetWhenHavePolygonAndCenterNull() ([]entities.DmPhuongXa, error) {
+// GetByOsmRelationID finds the commune imported from the given OSM relation
+func (r *DmPhuongXaRepository) GetByOsmRelationID(ctx context.Context, relationID int64) (*entities.DmPhuongXa, error) {
+	var dmPhuongXa entities.DmPhuongXa
+	if err := r.db.WithContext(ctx).Where("OSM_RELATION_ID = ?", relationID).First(&dmPhuongXa).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get DmPhuongXa by OSM relation %d: %w", relationID, err)
+	}
+	return &dmPhuongXa, nil
+}
+
+// GetByMaPhuongXa finds a commune by its code
+func (r *DmPhuongXaRepository) GetByMaPhuongXa(ctx context.Context, maPhuongXa string) (*entities.DmPhuongXa, error) {
+	var dmPhuongXa entities.DmPhuongXa
+	if err := r.db.WithContext(ctx).Where("MA_PHUONG_XA = ?", maPhuongXa).First(&dmPhuongXa).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get DmPhuongXa %s: %w", maPhuongXa, err)
+	}
+	return &dmPhuongXa, nil
+}
+
+func (r *DmPhuongXaRepository) GetWhenHavePolygonAndCenterNull(ctx context.Context) ([]entities.DmPhuongXa, error) {
 	var dmPhuongXas []entities.DmPhuongXa
 	// In SQL, equality should be a single '='. ORA-00936: missing expression likely due to '==' instead of '='.
-	if err := r.db.Where("POLYGON_DATA IS NOT NULL AND LAT_CENTER = 0 AND LON_CENTER = 0").Find(&dmPhuongXas).Error; err != nil {
+	if err := r.db.WithContext(ctx).Where("POLYGON_DATA IS NOT NULL AND LAT_CENTER = 0 AND LON_CENTER = 0").Find(&dmPhuongXas).Error; err != nil {
 		return nil, err
 	}
 	return dmPhuongXas, nil
 }
 
-func (r *DmPhuongXaRepository) UpdateDataAddressByMaPhuongXa(id string, maxLat, minLat, maxLon, minLon, lonCenter, latCenter *float64) error {
+// GetAllByMaTT lấy toàn bộ xã/phường thuộc tỉnh (kể cả đã có polygon)
+func (r *DmPhuongXaRepository) GetAllByMaTT(ctx context.Context, maTT string) ([]entities.DmPhuongXa, error) {
+	var dmPhuongXas []entities.DmPhuongXa
+	if err := r.db.WithContext(ctx).
+		Select("MA_PHUONG_XA, TEN_PHUONG_XA, TRUC_THUOC_TINH").
+		Where("TRUC_THUOC_TINH = ?", maTT).
+		Order("MA_PHUONG_XA").
+		Find(&dmPhuongXas).Error; err != nil {
+		return nil, fmt.Errorf("failed to get DmPhuongXa by MaTT %s: %w", maTT, err)
+	}
+	return dmPhuongXas, nil
+}
+
+// ListByMaTT lấy các xã/phường thuộc tỉnh kèm tâm và bounding box, không tải POLYGON_DATA
+func (r *DmPhuongXaRepository) ListByMaTT(ctx context.Context, maTT string) ([]entities.DmPhuongXa, error) {
+	var dmPhuongXas []entities.DmPhuongXa
+	if err := r.db.WithContext(ctx).
+		Omit("POLYGON_DATA").
+		Where("TRUC_THUOC_TINH = ?", maTT).
+		Order("MA_PHUONG_XA").
+		Find(&dmPhuongXas).Error; err != nil {
+		return nil, fmt.Errorf("failed to list DmPhuongXa by MaTT %s: %w", maTT, err)
+	}
+	return dmPhuongXas, nil
+}
+
+// FindCandidatesByCoordinate lấy các xã/phường có polygon mà bounding box (nới thêm margin độ) chứa điểm,
+// không tải POLYGON_DATA. maTT rỗng là tìm trên mọi tỉnh.
+func (r *DmPhuongXaRepository) FindCandidatesByCoordinate(ctx context.Context, maTT string, lat, lon, margin float64) ([]entities.DmPhuongXa, error) {
+	query := r.db.WithContext(ctx).
+		Omit("POLYGON_DATA").
+		Where("POLYGON_DATA IS NOT NULL").
+		Where("MIN_LAT <= ? AND MAX_LAT >= ?", lat+margin, lat-margin).
+		Where("MIN_LON <= ? AND MAX_LON >= ?", lon+margin, lon-margin)
+	if maTT != "" {
+		query = query.Where("TRUC_THUOC_TINH = ?", maTT)
+	}
+
+	var dmPhuongXas []entities.DmPhuongXa
+	if err := query.Order("MA_PHUONG_XA").Find(&dmPhuongXas).Error; err != nil {
+		return nil, fmt.Errorf("failed to find DmPhuongXa candidates at (%f, %f): %w", lat, lon, err)
+	}
+	return dmPhuongXas, nil
+}
+
+// GetWithPolygonByMaTT lấy đầy đủ các xã/phường đã có polygon thuộc tỉnh
+func (r *DmPhuongXaRepository) GetWithPolygonByMaTT(ctx context.Context, maTT string) ([]entities.DmPhuongXa, error) {
+	var dmPhuongXas []entities.DmPhuongXa
+	if err := r.db.WithContext(ctx).
+		Where("TRUC_THUOC_TINH = ? AND POLYGON_DATA IS NOT NULL", maTT).
+		Order("MA_PHUONG_XA").
+		Find(&dmPhuongXas).Error; err != nil {
+		return nil, fmt.Errorf("failed to get DmPhuongXa with polygon by MaTT %s: %w", maTT, err)
+	}
+	return dmPhuongXas, nil
+}
+
+func (r *DmPhuongXaRepository) UpdateDataAddressByMaPhuongXa(ctx context.Context, id string, maxLat, minLat, maxLon, minLon, lonCenter, latCenter *float64) error {
 	mapUpdate := map[string]interface{}{
 		"MAX_LAT":    maxLat,
 		"MIN_LAT":    minLat,
@@ -86,7 +179,7 @@ func (r *DmPhuongXaRepository) UpdateDataAddressByMaPhuongXa(id string, maxLat,
 		"LON_CENTER": lonCenter,
 		"LAT_CENTER": latCenter,
 	}
-	if err := r.db.Model(&entities.DmPhuongXa{}).
+	if err := r.db.WithContext(ctx).Model(&entities.DmPhuongXa{}).
 		Where("MA_PHUONG_XA = ?", id).
 		Updates(mapUpdate).Error; err != nil {
 		return fmt.Errorf("failed to update boundary for DmPhuongXa %s: %w", id, err)
@@ -94,11 +187,11 @@ func (r *DmPhuongXaRepository) UpdateDataAddressByMaPhuongXa(id string, maxLat,
 	return nil
 }
 
-func (r *DmPhuongXaRepository) UpdatePolygonDataByMaPhuongXa(id string, polygonData *string) error {
+func (r *DmPhuongXaRepository) UpdatePolygonDataByMaPhuongXa(ctx context.Context, id string, polygonData *string) error {
 	mapUpdate := map[string]interface{}{
 		"POLYGON_DATA": polygonData,
 	}
-	if err := r.db.Model(&entities.DmPhuongXa{}).
+	if err := r.db.WithContext(ctx).Model(&entities.DmPhuongXa{}).
 		Where("MA_PHUONG_XA = ?", id).
 		Updates(mapUpdate).Error; err != nil {
 		return fmt.Errorf("failed to update polygon data for DmPhuongXa %s: %w", id, err)
@@ -106,15 +199,45 @@ func (r *DmPhuongXaRepository) UpdatePolygonDataByMaPhuongXa(id string, polygonD
 	return nil
 }
 
-func (r *DmPhuongXaRepository) UpdateLatLonCenterByMaPhuongXa(id string, latCenter, lonCenter *float64) error {
+func (r *DmPhuongXaRepository) UpdateLatLonCenterByMaPhuongXa(ctx context.Context, id string, latCenter, lonCenter *float64) error {
 	mapUpdate := map[string]interface{}{
 		"LAT_CENTER": latCenter,
 		"LON_CENTER": lonCenter,
 	}
-	if err := r.db.Model(&entities.DmPhuongXa{}).
+	if err := r.db.WithContext(ctx).Model(&entities.DmPhuongXa{}).
 		Where("MA_PHUONG_XA = ?", id).
 		Updates(mapUpdate).Error; err != nil {
 		return fmt.Errorf("failed to update lat lon center for DmPhuongXa %s: %w", id, err)
 	}
 	return nil
 }
+
+// UpdateOsmVersionByMaPhuongXa lưu phiên bản relation OSM và fingerprint hình học của lần import gần nhất
+func (r *DmPhuongXaRepository) UpdateOsmVersionByMaPhuongXa(ctx context.Context, id string, relationID *int64, version *int, changeset *int64, timestamp, geometryHash *string) error {
+	mapUpdate := map[string]interface{}{
+		"OSM_RELATION_ID": relationID,
+		"OSM_VERSION":     version,
+		"OSM_CHANGESET":   changeset,
+		"OSM_TIMESTAMP":   timestamp,
+		"GEOMETRY_HASH":   geometryHash,
+	}
+	if err := r.db.WithContext(ctx).Model(&entities.DmPhuongXa{}).
+		Where("MA_PHUONG_XA = ?", id).
+		Updates(mapUpdate).Error; err != nil {
+		return fmt.Errorf("failed to update OSM version for DmPhuongXa %s: %w", id, err)
+	}
+	return nil
+}
+
+// ClaimOsmRelationByMaPhuongXa links the commune to relationID unless it already belongs to another relation.
+// It reports whether the row is now linked to relationID; the check and the update are one statement, so two
+// importers cannot both claim the same row.
+func (r *DmPhuongXaRepository) ClaimOsmRelationByMaPhuongXa(ctx context.Context, id string, relationID int64) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&entities.DmPhuongXa{}).
+		Where("MA_PHUONG_XA = ? AND (OSM_RELATION_ID IS NULL OR OSM_RELATION_ID = ?)", id, relationID).
+		Update("OSM_RELATION_ID", relationID)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to claim DmPhuongXa %s for OSM relation %d: %w", id, relationID, result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
@@ -1,18 +1,25 @@
 package repositories
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"tool-map/entities"
+	"tool-map/util"
 
 	"gorm.io/gorm"
 )
 
 type DmTTRepositoryInterface interface {
-	GetByName(name string) (*entities.DmTT, error)
-	UpdateDataAddressByMaTT(id string, maxLat, minLat, maxLon, minLon, lonCenter, latCenter *float64) error
-	UpdatePolygonDataByMaTT(id string, polygonData *string) error
-	UpdatePolygonDataWithBoundsByMaTT(id string, polygonData *string, minLat, maxLat, minLon, maxLon *float64) error
-	FindCommuneByCoordinate(mattChu string, lat, lon float64) (*entities.DmPhuongXa, error)
+	GetByName(ctx context.Context, name string) (*entities.DmTT, error)
+	GetByOsmRelationID(ctx context.Context, relationID int64) (*entities.DmTT, error)
+	GetByMaTT(ctx context.Context, maTT string) (*entities.DmTT, error)
+	GetAll(ctx context.Context) ([]entities.DmTT, error)
+	UpdateDataAddressByMaTT(ctx context.Context, id string, maxLat, minLat, maxLon, minLon, lonCenter, latCenter *float64) error
+	UpdateOsmVersionByMaTT(ctx context.Context, id string, relationID *int64, version *int, changeset *int64, timestamp, geometryHash *string) error
+	UpdatePolygonDataByMaTT(ctx context.Context, id string, polygonData *string) error
+	UpdatePolygonDataWithBoundsByMaTT(ctx context.Context, id string, polygonData *string, minLat, maxLat, minLon, maxLon *float64) error
+	FindCommuneByCoordinate(ctx context.Context, mattChu string, lat, lon float64) (*entities.DmPhuongXa, error)
 }
 type DmTTRepository struct {
 	*BaseRepository
@@ -26,16 +33,16 @@ func NewDmTTRepository(db *gorm.DB) *DmTTRepository {
 }
 
 // Create creates a new DmTT record
-func (r *DmTTRepository) Create(dmTT *entities.DmTT) error {
-	if err := r.db.Create(dmTT).Error; err != nil {
+func (r *DmTTRepository) Create(ctx context.Context, dmTT *entities.DmTT) error {
+	if err := r.db.WithContext(ctx).Create(dmTT).Error; err != nil {
 		return fmt.Errorf("failed to create DmTT: %w", err)
 	}
 	return nil
 }
 
-func (r *DmTTRepository) GetByName(name string) (*entities.DmTT, error) {
+func (r *DmTTRepository) GetByName(ctx context.Context, name string) (*entities.DmTT, error) {
 	var dmTT entities.DmTT
-	if err := r.db.Where("TENTT LIKE ?", "%"+name+"%").First(&dmTT).Error; err != nil {
+	if err := r.db.WithContext(ctx).Where("TENTT LIKE ?", "%"+name+"%").First(&dmTT).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return nil, nil
 		}
@@ -44,7 +51,40 @@ func (r *DmTTRepository) GetByName(name string) (*entities.DmTT, error) {
 	return &dmTT, nil
 }
 
-func (r *DmTTRepository) UpdateDataAddressByMaTT(id string, maxLat, minLat, maxLon, minLon, lonCenter, latCenter *float64) error {
+// GetByMaTT finds a province by its code
+func (r *DmTTRepository) GetByMaTT(ctx context.Context, maTT string) (*entities.DmTT, error) {
+	var dmTT entities.DmTT
+	if err := r.db.WithContext(ctx).Where("MATT = ?", maTT).First(&dmTT).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get DmTT %s: %w", maTT, err)
+	}
+	return &dmTT, nil
+}
+
+// GetAll lists every province ordered by code
+func (r *DmTTRepository) GetAll(ctx context.Context) ([]entities.DmTT, error) {
+	var dmTTs []entities.DmTT
+	if err := r.db.WithContext(ctx).Order("MATT").Find(&dmTTs).Error; err != nil {
+		return nil, fmt.Errorf("failed to list DmTT: %w", err)
+	}
+	return dmTTs, nil
+}
+
+// GetByOsmRelationID finds the province imported from the given OSM relation
+func (r *DmTTRepository) GetByOsmRelationID(ctx context.Context, relationID int64) (*entities.DmTT, error) {
+	var dmTT entities.DmTT
+	if err := r.db.WithContext(ctx).Where("OSM_RELATION_ID = ?", relationID).First(&dmTT).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get DmTT by OSM relation %d: %w", relationID, err)
+	}
+	return &dmTT, nil
+}
+
+func (r *DmTTRepository) UpdateDataAddressByMaTT(ctx context.Context, id string, maxLat, minLat, maxLon, minLon, lonCenter, latCenter *float64) error {
 	mapUpdate := map[string]interface{}{
 		"MAX_LAT":    maxLat,
 		"MIN_LAT":    minLat,
@@ -54,7 +94,7 @@ func (r *DmTTRepository) UpdateDataAddressByMaTT(id string, maxLat, minLat, maxL
 		"LAT_CENTER": latCenter,
 	}
 
-	if err := r.db.Model(&entities.DmTT{}).
+	if err := r.db.WithContext(ctx).Model(&entities.DmTT{}).
 		Where("MATT = ?", id).
 		Updates(mapUpdate).Error; err != nil {
 		return fmt.Errorf("failed to update boundary for DmTT %s: %w", id, err)
@@ -62,11 +102,11 @@ func (r *DmTTRepository) UpdateDataAddressByMaTT(id string, maxLat, minLat, maxL
 	return nil
 }
 
-func (r *DmTTRepository) UpdatePolygonDataByMaTT(id string, polygonData *string) error {
+func (r *DmTTRepository) UpdatePolygonDataByMaTT(ctx context.Context, id string, polygonData *string) error {
 	mapUpdate := map[string]interface{}{
 		"POLYGON_DATA": polygonData,
 	}
-	if err := r.db.Model(&entities.DmTT{}).
+	if err := r.db.WithContext(ctx).Model(&entities.DmTT{}).
 		Where("MATT = ?", id).
 		Updates(mapUpdate).Error; err != nil {
 		return fmt.Errorf("failed to update polygon data for DmTT %s: %w", id, err)
@@ -74,7 +114,7 @@ func (r *DmTTRepository) UpdatePolygonDataByMaTT(id string, polygonData *string)
 	return nil
 }
 
-func (r *DmTTRepository) UpdatePolygonDataWithBoundsByMaTT(id string, polygonData *string, minLat, maxLat, minLon, maxLon *float64) error {
+func (r *DmTTRepository) UpdatePolygonDataWithBoundsByMaTT(ctx context.Context, id string, polygonData *string, minLat, maxLat, minLon, maxLon *float64) error {
 	mapUpdate := map[string]interface{}{
 		"POLYGON_DATA": polygonData,
 		"MIN_LAT":      minLat,
@@ -82,7 +122,7 @@ func (r *DmTTRepository) UpdatePolygonDataWithBoundsByMaTT(id string, polygonDat
 		"MIN_LON":      minLon,
 		"MAX_LON":      maxLon,
 	}
-	if err := r.db.Model(&entities.DmTT{}).
+	if err := r.db.WithContext(ctx).Model(&entities.DmTT{}).
 		Where("MATT = ?", id).
 		Updates(mapUpdate).Error; err != nil {
 		return fmt.Errorf("failed to update polygon data with bounds for DmTT %s: %w", id, err)
@@ -90,29 +130,44 @@ func (r *DmTTRepository) UpdatePolygonDataWithBoundsByMaTT(id string, polygonDat
 	return nil
 }
 
-// FindCommuneByCoordinate tìm xã/phường từ tọa độ lat/lon và mã tỉnh thành
-func (r *DmTTRepository) FindCommuneByCoordinate(mattChu string, lat, lon float64) (*entities.DmPhuongXa, error) {
-	var commune entities.DmPhuongXa
-
+// FindCommuneByCoordinate tìm xã/phường thuộc tỉnh mattChu có polygon chứa tọa độ lat/lon
+func (r *DmTTRepository) FindCommuneByCoordinate(ctx context.Context, mattChu string, lat, lon float64) (*entities.DmPhuongXa, error) {
 	// Bước 1: Filter bằng bounding box (nhanh)
-	// Bước 2: Kiểm tra point-in-polygon (chính xác)
-	query := `
-		SELECT * FROM DMPHUONGXA 
-		WHERE TRUCTHUOCTINH = ? 
-		AND MIN_LAT <= ? AND MAX_LAT >= ?
-		AND MIN_LON <= ? AND MAX_LON >= ?
-		AND POLYGON_DATA IS NOT NULL
-	`
-
-	if err := r.db.Raw(query, mattChu, lat, lat, lon, lon).Scan(&commune).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return nil, nil
-		}
+	var candidates []entities.DmPhuongXa
+	if err := r.db.WithContext(ctx).
+		Where("TRUC_THUOC_TINH = ? AND POLYGON_DATA IS NOT NULL", mattChu).
+		Where("MIN_LAT <= ? AND MAX_LAT >= ?", lat, lat).
+		Where("MIN_LON <= ? AND MAX_LON >= ?", lon, lon).
+		Find(&candidates).Error; err != nil {
 		return nil, fmt.Errorf("failed to find commune by coordinate: %w", err)
 	}
 
-	// TODO: Implement point-in-polygon check using POLYGON_DATA
-	// For now, return the first match (can be improved with proper spatial query)
+	// Bước 2: Kiểm tra point-in-polygon (chính xác)
+	for i := range candidates {
+		var ring [][2]float64
+		if err := json.Unmarshal([]byte(*candidates[i].Polygon), &ring); err != nil {
+			continue
+		}
+		if util.PointInRing(lat, lon, ring) {
+			return &candidates[i], nil
+		}
+	}
+	return nil, nil
+}
 
-	return &commune, nil
+// UpdateOsmVersionByMaTT lưu phiên bản relation OSM và fingerprint hình học của lần import gần nhất
+func (r *DmTTRepository) UpdateOsmVersionByMaTT(ctx context.Context, id string, relationID *int64, version *int, changeset *int64, timestamp, geometryHash *string) error {
+	mapUpdate := map[string]interface{}{
+		"OSM_RELATION_ID": relationID,
+		"OSM_VERSION":     version,
+		"OSM_CHANGESET":   changeset,
+		"OSM_TIMESTAMP":   timestamp,
+		"GEOMETRY_HASH":   geometryHash,
+	}
+	if err := r.db.WithContext(ctx).Model(&entities.DmTT{}).
+		Where("MATT = ?", id).
+		Updates(mapUpdate).Error; err != nil {
+		return fmt.Errorf("failed to update OSM version for DmTT %s: %w", id, err)
+	}
+	return nil
 }
@@ -0,0 +1,136 @@
+package server
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"tool-map/metrics"
+	"tool-map/services"
+)
+
+// statusRecorder giữ status và số byte đã ghi để đưa vào audit log
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap cho http.ResponseController truy cập ResponseWriter gốc
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// protect xác thực API key, kiểm tra scope, đếm rate limit và ghi audit log cho endpoint.
+// Endpoint lookup nhận request không key khi auth.required = false (client anonymous, giới hạn theo IP);
+// endpoint admin luôn cần key có scope admin.
+func (s *Server) protect(scope string, next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w}
+		record := services.AuditRecord{
+			Time:      started,
+			Client:    services.AnonymousClient,
+			Method:    r.Method,
+			Path:      r.URL.Path,
+			Route:     r.Pattern,
+			Query:     r.URL.RawQuery,
+			Remote:    remoteIP(r),
+			UserAgent: r.UserAgent(),
+		}
+		defer func() {
+			record.Status = recorder.status
+			record.Bytes = recorder.bytes
+			record.DurationMs = float64(time.Since(started).Microseconds()) / 1000
+			s.access.Audit(record)
+			metrics.HTTPRequest(record.Client, r.Pattern, record.Status)
+		}()
+
+		key, err := s.authenticate(apiKeyFromRequest(r), scope)
+		if key != nil {
+			record.Client = key.Client
+			record.Scope = key.Scope
+		}
+		if err != nil {
+			record.Error = err.Error()
+			status := http.StatusUnauthorized
+			if errors.Is(err, services.ErrForbidden) {
+				status = http.StatusForbidden
+			} else {
+				recorder.Header().Set("WWW-Authenticate", `Bearer realm="tool-map"`)
+			}
+			writeError(recorder, status, err.Error())
+			return
+		}
+
+		decision := s.access.Allow(r.Context(), key, record.Remote)
+		if decision.Limit > 0 {
+			header := recorder.Header()
+			header.Set("X-RateLimit-Limit", strconv.Itoa(decision.Limit))
+			header.Set("X-RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+			header.Set("X-RateLimit-Reset", strconv.Itoa(int(decision.Reset.Seconds()+0.5)))
+		}
+		if !decision.Allowed {
+			record.Error = "rate limit"
+			metrics.HTTPRateLimited(record.Client)
+			recorder.Header().Set("Retry-After", strconv.Itoa(int(decision.Reset.Seconds())+1))
+			writeError(recorder, http.StatusTooManyRequests, "vượt quá giới hạn request, thử lại sau")
+			return
+		}
+		next(recorder, r)
+	})
+}
+
+// authenticate trả về API key ứng với token của request (nil = anonymous được phép) hoặc lỗi
+// ErrUnauthorized/ErrForbidden; với ErrForbidden key vẫn được trả về để audit ghi đúng client
+func (s *Server) authenticate(token, scope string) (*services.APIKey, error) {
+	if token == "" {
+		if scope == services.ScopeLookup && !s.access.Required() {
+			return nil, nil
+		}
+		return nil, services.ErrUnauthorized
+	}
+	key, err := s.access.Authenticate(token)
+	if err != nil {
+		return nil, err
+	}
+	if !key.Allows(scope) {
+		return key, services.ErrForbidden
+	}
+	return key, nil
+}
+
+// apiKeyFromRequest đọc key từ header "Authorization: Bearer <key>" hoặc "X-API-Key"
+func apiKeyFromRequest(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	return strings.TrimSpace(r.Header.Get("X-API-Key"))
+}
+
+// remoteIP là IP của kết nối (không tin X-Forwarded-For)
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
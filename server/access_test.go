@@ -0,0 +1,152 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+	"tool-map/config"
+	"tool-map/services"
+)
+
+const (
+	testLookupKey = "tm_lookup"
+	testAdminKey  = "tm_admin"
+)
+
+// newTestServer tạo Server chỉ có AccessControl, với một key lookup và một key admin trong auth.keys_file.
+// Không có Redis nên rate limit đếm trong bộ nhớ.
+func newTestServer(t *testing.T, required bool, rateLimit int) *Server {
+	t.Helper()
+	dir := t.TempDir()
+	keysFile := filepath.Join(dir, "keys.json")
+	keys := fmt.Sprintf(`[
+  {"client": "lookup-client", "key_sha256": %q, "scope": "lookup"},
+  {"client": "admin-client", "key_sha256": %q, "scope": "admin"}
+]`, services.HashAPIKey(testLookupKey), services.HashAPIKey(testAdminKey))
+	if err := os.WriteFile(keysFile, []byte(keys), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.Default()
+	cfg.Auth.Required = required
+	cfg.Auth.KeysFile = keysFile
+	cfg.Auth.ReloadInterval = 0
+	cfg.Auth.RateLimit = rateLimit
+	cfg.Auth.AnonymousRateLimit = rateLimit
+	cfg.Auth.RateWindow = time.Hour
+	cfg.Auth.AuditLog = filepath.Join(dir, "audit.jsonl")
+	services.SetConfig(cfg)
+	t.Cleanup(func() { services.SetConfig(config.Default()) })
+
+	access, err := services.NewOSMService().NewAccessControl(context.Background())
+	if err != nil {
+		t.Fatalf("NewAccessControl: %v", err)
+	}
+	t.Cleanup(func() { _ = access.Close() })
+	return &Server{access: access}
+}
+
+func TestAuthenticate(t *testing.T) {
+	tests := []struct {
+		name       string
+		required   bool
+		token      string
+		scope      string
+		wantClient string // rỗng = anonymous
+		wantErr    error
+	}{
+		{"anonymous lookup allowed", false, "", services.ScopeLookup, "", nil},
+		{"anonymous lookup when required", true, "", services.ScopeLookup, "", services.ErrUnauthorized},
+		{"anonymous admin", false, "", services.ScopeAdmin, "", services.ErrUnauthorized},
+		{"unknown key", false, "tm_unknown", services.ScopeLookup, "", services.ErrUnauthorized},
+		{"lookup key on lookup", true, testLookupKey, services.ScopeLookup, "lookup-client", nil},
+		{"lookup key on admin", false, testLookupKey, services.ScopeAdmin, "lookup-client", services.ErrForbidden},
+		{"admin key on lookup", true, testAdminKey, services.ScopeLookup, "admin-client", nil},
+		{"admin key on admin", true, testAdminKey, services.ScopeAdmin, "admin-client", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, tt.required, 0)
+			key, err := s.authenticate(tt.token, tt.scope)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			var client string
+			if key != nil {
+				client = key.Client
+			}
+			if client != tt.wantClient {
+				t.Errorf("client = %q, want %q", client, tt.wantClient)
+			}
+		})
+	}
+}
+
+func TestProtect(t *testing.T) {
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) }
+	request := func(handler http.Handler, key string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if key != "" {
+			r.Header.Set("Authorization", "Bearer "+key)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	tests := []struct {
+		name      string
+		required  bool
+		scope     string
+		key       string
+		rateLimit int
+		// statuses là status của các request liên tiếp
+		statuses []int
+	}{
+		{"anonymous lookup", false, services.ScopeLookup, "", 0, []int{http.StatusNoContent}},
+		{"anonymous lookup when required", true, services.ScopeLookup, "", 0, []int{http.StatusUnauthorized}},
+		{"lookup key on admin endpoint", false, services.ScopeAdmin, testLookupKey, 0, []int{http.StatusForbidden}},
+		{"admin key on admin endpoint", true, services.ScopeAdmin, testAdminKey, 0, []int{http.StatusNoContent}},
+		{"key rate limited", true, services.ScopeLookup, testLookupKey, 2,
+			[]int{http.StatusNoContent, http.StatusNoContent, http.StatusTooManyRequests}},
+		{"anonymous rate limited", false, services.ScopeLookup, "", 1,
+			[]int{http.StatusNoContent, http.StatusTooManyRequests}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, tt.required, tt.rateLimit)
+			handler := s.protect(tt.scope, ok)
+			for i, want := range tt.statuses {
+				w := request(handler, tt.key)
+				if w.Code != want {
+					t.Fatalf("request %d: status = %d, want %d (%s)", i+1, w.Code, want, w.Body)
+				}
+				header := w.Header()
+				switch want {
+				case http.StatusUnauthorized:
+					if header.Get("WWW-Authenticate") == "" {
+						t.Error("401 without WWW-Authenticate")
+					}
+				case http.StatusTooManyRequests:
+					retryAfter, err := strconv.Atoi(header.Get("Retry-After"))
+					if err != nil || retryAfter < 1 || retryAfter > int(time.Hour.Seconds())+1 {
+						t.Errorf("Retry-After = %q, want seconds until the window resets", header.Get("Retry-After"))
+					}
+					if header.Get("X-RateLimit-Remaining") != "0" {
+						t.Errorf("X-RateLimit-Remaining = %q, want 0", header.Get("X-RateLimit-Remaining"))
+					}
+				}
+				if tt.rateLimit > 0 && header.Get("X-RateLimit-Limit") != strconv.Itoa(tt.rateLimit) {
+					t.Errorf("X-RateLimit-Limit = %q, want %d", header.Get("X-RateLimit-Limit"), tt.rateLimit)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,340 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"time"
+	"tool-map/lookuppb"
+	"tool-map/metrics"
+	"tool-map/models"
+	"tool-map/services"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// GRPCServer tạo gRPC server phục vụ LookupService trên chỉ mục polygon dùng chung với vector tile.
+// Mọi RPC cần scope lookup và đi qua cùng API key, rate limit và audit log với HTTP API.
+func (s *Server) GRPCServer() *grpc.Server {
+	srv := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(s.unaryAccess),
+		grpc.ChainStreamInterceptor(s.streamAccess),
+	)
+	lookuppb.RegisterLookupServiceServer(srv, &lookupService{index: s.index})
+	return srv
+}
+
+func (s *Server) serveGRPC(ctx context.Context, addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("không lắng nghe được gRPC tại %s: %w", addr, err)
+	}
+	srv := s.GRPCServer()
+
+	errCh := make(chan error, 1)
+	go func() {
+		slog.Info("gRPC server lắng nghe", "addr", addr)
+		errCh <- srv.Serve(listener)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("Đang dừng gRPC server")
+	stopped := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(shutdownTimeout):
+		srv.Stop()
+	}
+	return nil
+}
+
+func (s *Server) unaryAccess(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	call, err := s.guardRPC(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	if err := call.allow(ctx); err != nil {
+		call.done(err)
+		return nil, err
+	}
+	resp, err := handler(ctx, req)
+	call.done(err)
+	return resp, err
+}
+
+// streamAccess kiểm tra key một lần khi mở stream, còn rate limit tính theo từng message nhận được: mỗi điểm
+// của BatchLookup là một request như khi gọi Lookup. Vượt giới hạn thì stream kết thúc với RESOURCE_EXHAUSTED.
+func (s *Server) streamAccess(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	call, err := s.guardRPC(stream.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	err = handler(srv, &meteredStream{ServerStream: stream, call: call})
+	call.done(err)
+	return err
+}
+
+// rpcCall là một lời gọi gRPC đã qua xác thực
+type rpcCall struct {
+	server *Server
+	key    *services.APIKey
+	remote string
+	// done ghi audit log và metric khi lời gọi kết thúc với err
+	done func(err error)
+}
+
+// allow tính một request vào rate limit của client; lỗi là status RESOURCE_EXHAUSTED
+func (c *rpcCall) allow(ctx context.Context) error {
+	if decision := c.server.access.Allow(ctx, c.key, c.remote); !decision.Allowed {
+		return status.Errorf(codes.ResourceExhausted, "vượt quá giới hạn request, thử lại sau %d giây", int(decision.Reset.Seconds())+1)
+	}
+	return nil
+}
+
+// meteredStream tính rate limit cho mỗi message client gửi trong stream
+type meteredStream struct {
+	grpc.ServerStream
+	call *rpcCall
+}
+
+func (m *meteredStream) RecvMsg(msg any) error {
+	if err := m.ServerStream.RecvMsg(msg); err != nil {
+		return err
+	}
+	return m.call.allow(m.Context())
+}
+
+// guardRPC xác thực và kiểm tra scope lookup của lời gọi như protect của HTTP; rate limit do caller tính qua
+// rpcCall.allow. Khi lời gọi bị từ chối, audit log đã được ghi và lỗi là status gRPC; ngược lại caller gọi
+// done khi lời gọi kết thúc.
+func (s *Server) guardRPC(ctx context.Context, method string) (*rpcCall, error) {
+	started := time.Now()
+	record := services.AuditRecord{
+		Time:   started,
+		Client: services.AnonymousClient,
+		Method: "GRPC",
+		Path:   method,
+		Route:  method,
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		record.Remote = p.Addr.String()
+		if host, _, err := net.SplitHostPort(record.Remote); err == nil {
+			record.Remote = host
+		}
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	record.UserAgent = firstMetadata(md, "user-agent")
+
+	done := func(err error) {
+		code := status.Code(err)
+		record.Status = int(code)
+		if err != nil {
+			record.Error = status.Convert(err).Message()
+		}
+		record.DurationMs = float64(time.Since(started).Microseconds()) / 1000
+		s.access.Audit(record)
+		metrics.GRPCRequest(record.Client, method, code.String())
+	}
+
+	key, err := s.authenticate(apiKeyFromMetadata(md), services.ScopeLookup)
+	if key != nil {
+		record.Client = key.Client
+		record.Scope = key.Scope
+	}
+	if err != nil {
+		code := codes.Unauthenticated
+		if errors.Is(err, services.ErrForbidden) {
+			code = codes.PermissionDenied
+		}
+		err = status.Error(code, err.Error())
+		done(err)
+		return nil, err
+	}
+	return &rpcCall{server: s, key: key, remote: record.Remote, done: done}, nil
+}
+
+// apiKeyFromMetadata đọc key từ metadata "authorization: Bearer <key>" hoặc "x-api-key"
+func apiKeyFromMetadata(md metadata.MD) string {
+	if token, ok := strings.CutPrefix(firstMetadata(md, "authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	return strings.TrimSpace(firstMetadata(md, "x-api-key"))
+}
+
+func firstMetadata(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// lookupService cài đặt lookuppb.LookupServiceServer
+type lookupService struct {
+	lookuppb.UnimplementedLookupServiceServer
+	index *services.SharedPolygonIndex
+}
+
+func (l *lookupService) polygonIndex(ctx context.Context) (*services.PolygonIndex, error) {
+	index, err := l.index.Get(ctx)
+	if err != nil {
+		slog.Error("Lỗi gRPC", "error", err)
+		return nil, status.Error(codes.Unavailable, "chỉ mục polygon chưa sẵn sàng")
+	}
+	return index, nil
+}
+
+func (l *lookupService) Lookup(ctx context.Context, req *lookuppb.LookupRequest) (*lookuppb.LookupResponse, error) {
+	index, err := l.polygonIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp := lookupPoint(index, req)
+	if resp.Error != "" {
+		return nil, status.Error(codes.InvalidArgument, resp.Error)
+	}
+	return resp, nil
+}
+
+// BatchLookup trả lời từng điểm ngay khi nhận; lỗi của một điểm nằm trong response của điểm đó
+func (l *lookupService) BatchLookup(stream lookuppb.LookupService_BatchLookupServer) error {
+	index, err := l.polygonIndex(stream.Context())
+	if err != nil {
+		return err
+	}
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(lookupPoint(index, req)); err != nil {
+			return err
+		}
+	}
+}
+
+func (l *lookupService) GetUnit(ctx context.Context, req *lookuppb.GetUnitRequest) (*lookuppb.Unit, error) {
+	if req.GetCode() == "" {
+		return nil, status.Error(codes.InvalidArgument, "cần code (MATT hoặc MA_PHUONG_XA)")
+	}
+	var levels []int
+	switch req.GetLevel() {
+	case lookuppb.Level_LEVEL_UNSPECIFIED:
+		levels = []int{models.AdminLevelProvince, models.AdminLevelCommune}
+	case lookuppb.Level_LEVEL_PROVINCE:
+		levels = []int{models.AdminLevelProvince}
+	case lookuppb.Level_LEVEL_COMMUNE:
+		levels = []int{models.AdminLevelCommune}
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "level %d không hợp lệ", req.GetLevel())
+	}
+
+	index, err := l.polygonIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, level := range levels {
+		if unit := index.UnitAt(level, req.GetCode()); unit != nil {
+			return toProtoUnit(unit, req.GetIncludeGeometry()), nil
+		}
+	}
+	return nil, status.Errorf(codes.NotFound, "không tìm thấy đơn vị '%s'", req.GetCode())
+}
+
+func (l *lookupService) ListChildren(ctx context.Context, req *lookuppb.ListChildrenRequest) (*lookuppb.ListChildrenResponse, error) {
+	index, err := l.polygonIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var units []*services.IndexedUnit
+	if req.GetParentCode() == "" {
+		units = index.Provinces()
+	} else {
+		if index.UnitAt(models.AdminLevelProvince, req.GetParentCode()) == nil {
+			return nil, status.Errorf(codes.NotFound, "không tìm thấy tỉnh '%s'", req.GetParentCode())
+		}
+		units = index.Children(req.GetParentCode())
+	}
+
+	resp := &lookuppb.ListChildrenResponse{Units: make([]*lookuppb.Unit, 0, len(units))}
+	for _, unit := range units {
+		resp.Units = append(resp.Units, toProtoUnit(unit, req.GetIncludeGeometry()))
+	}
+	return resp, nil
+}
+
+// lookupPoint tra cứu điểm của req bằng services.LookupInIndex như GET /lookup; tọa độ không hợp lệ ghi vào Error
+func lookupPoint(index *services.PolygonIndex, req *lookuppb.LookupRequest) *lookuppb.LookupResponse {
+	resp := &lookuppb.LookupResponse{Id: req.GetId()}
+	result, err := services.LookupInIndex(index, req.GetProvince(), req.GetLat(), req.GetLon())
+	if err != nil {
+		resp.Error = err.Error()
+		return resp
+	}
+
+	resp.Found = result.Found
+	if result.DistanceToBoundaryMeters != nil {
+		resp.DistanceToBoundaryMeters = *result.DistanceToBoundaryMeters
+	}
+	if result.Commune != nil {
+		resp.Commune = toProtoUnit(index.UnitAt(models.AdminLevelCommune, result.Commune.Code), false)
+	}
+	if result.Nearest != nil {
+		resp.Nearest = toProtoUnit(index.UnitAt(models.AdminLevelCommune, result.Nearest.Code), false)
+	}
+	if result.Province != nil {
+		if province := index.UnitAt(models.AdminLevelProvince, result.Province.Code); province != nil {
+			resp.Province = toProtoUnit(province, false)
+		} else {
+			// Chỉ mục chỉ có xã/phường: tỉnh chỉ có mã, như province của GET /lookup
+			resp.Province = &lookuppb.Unit{Level: lookuppb.Level_LEVEL_PROVINCE, Code: result.Province.Code, MaTt: result.Province.Code}
+		}
+	}
+	return resp
+}
+
+// toProtoUnit chuyển đơn vị của chỉ mục sang lookuppb.Unit; tọa độ đổi từ [lat, lon] sang thứ tự GeoJSON
+func toProtoUnit(unit *services.IndexedUnit, includeGeometry bool) *lookuppb.Unit {
+	bbox := unit.BBox
+	result := &lookuppb.Unit{
+		Level: lookuppb.Level(unit.Level),
+		Code:  unit.Code,
+		Name:  unit.Name,
+		MaTt:  unit.MaTT,
+		Bbox:  &lookuppb.BBox{MinLon: bbox[0], MinLat: bbox[1], MaxLon: bbox[2], MaxLat: bbox[3]},
+	}
+	if !includeGeometry {
+		return result
+	}
+	for _, polygon := range unit.Polygons() {
+		rings := make([]*lookuppb.Ring, 0, len(polygon))
+		for _, ring := range polygon {
+			coordinates := make([]float64, 0, 2*len(ring))
+			for _, point := range ring {
+				coordinates = append(coordinates, point[1], point[0])
+			}
+			rings = append(rings, &lookuppb.Ring{Coordinates: coordinates})
+		}
+		result.Polygons = append(result.Polygons, &lookuppb.Polygon{Rings: rings})
+	}
+	return result
+}
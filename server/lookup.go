@@ -0,0 +1,254 @@
+package server
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"tool-map/services"
+)
+
+const (
+	// maxBatchPoints là số điểm tối đa của một request /lookup/batch
+	maxBatchPoints = 10000
+	// maxBatchBytes giới hạn kích thước body của /lookup/batch
+	maxBatchBytes = 16 << 20
+)
+
+// batchPoint là một điểm trong request /lookup/batch; err là lỗi đọc dòng tương ứng
+type batchPoint struct {
+	id       string
+	lat, lon float64
+	err      string
+}
+
+func (s *Server) handleLookup(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	lat, latErr := strconv.ParseFloat(query.Get("lat"), 64)
+	lon, lonErr := strconv.ParseFloat(query.Get("lon"), 64)
+	if latErr != nil || lonErr != nil {
+		writeError(w, http.StatusBadRequest, "lat và lon phải là số")
+		return
+	}
+
+	index, err := s.index.Get(r.Context())
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	result, err := services.LookupInIndex(index, query.Get("province"), lat, lon)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// handleLookupBatch tra cứu nhiều điểm gửi dạng NDJSON ({"id", "lat", "lon"} mỗi dòng) hoặc CSV
+// (Content-Type text/csv, header có cột lat, lon và tùy chọn id). Kết quả theo đúng thứ tự và định dạng
+// của input; lỗi của từng điểm nằm trong trường error thay vì làm hỏng cả lô.
+func (s *Server) handleLookupBatch(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	isCSV := mediaType == "text/csv"
+
+	body := http.MaxBytesReader(w, r.Body, maxBatchBytes)
+	var points []batchPoint
+	var err error
+	if isCSV {
+		points, err = readCSVPoints(body)
+	} else {
+		points, err = readNDJSONPoints(body)
+	}
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("body vượt quá %d byte", maxBatchBytes))
+		return
+	case err != nil:
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	case len(points) > maxBatchPoints:
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("tối đa %d điểm mỗi request", maxBatchPoints))
+		return
+	}
+
+	index, err := s.index.Get(r.Context())
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	province := r.URL.Query().Get("province")
+	results := make([]*services.LookupResult, 0, len(points))
+	for _, point := range points {
+		if r.Context().Err() != nil {
+			return
+		}
+		result := &services.LookupResult{ID: point.id, Lat: point.lat, Lon: point.lon, Error: point.err}
+		if point.err == "" {
+			found, err := services.LookupInIndex(index, province, point.lat, point.lon)
+			if err != nil {
+				result.Error = lookupErrorMessage(r, err)
+			} else {
+				result = found
+				result.ID = point.id
+			}
+		}
+		results = append(results, result)
+	}
+
+	if isCSV {
+		writeCSVResults(w, results)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	encoder := json.NewEncoder(w)
+	for _, result := range results {
+		_ = encoder.Encode(result)
+	}
+}
+
+// lookupErrorMessage là thông báo lỗi của một điểm trong lô; lỗi nội bộ được log và không trả chi tiết
+func lookupErrorMessage(r *http.Request, err error) string {
+	if errors.Is(err, services.ErrInvalidCoordinate) {
+		return err.Error()
+	}
+	slog.Error("Lỗi khi tra cứu tọa độ", "path", r.URL.Path, "error", err)
+	return "lỗi nội bộ"
+}
+
+// readNDJSONPoints đọc mỗi dòng một object {"id", "lat", "lon"}; id có thể là chuỗi hoặc số
+func readNDJSONPoints(r io.Reader) ([]batchPoint, error) {
+	var points []batchPoint
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var raw struct {
+			ID  any      `json:"id"`
+			Lat *float64 `json:"lat"`
+			Lon *float64 `json:"lon"`
+		}
+		point := batchPoint{}
+		if err := json.Unmarshal([]byte(line), &raw); err != nil {
+			point.err = "dòng không phải JSON hợp lệ"
+		} else {
+			point.id = formatID(raw.ID)
+			if raw.Lat == nil || raw.Lon == nil {
+				point.err = "thiếu lat hoặc lon"
+			} else {
+				point.lat, point.lon = *raw.Lat, *raw.Lon
+			}
+		}
+		points = append(points, point)
+	}
+	return points, scanner.Err()
+}
+
+func formatID(id any) string {
+	switch v := id.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// readCSVPoints đọc CSV có header; cột lat, lon bắt buộc, id tùy chọn (không phân biệt hoa thường)
+func readCSVPoints(r io.Reader) ([]batchPoint, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	columns := map[string]int{"id": -1, "lat": -1, "lon": -1}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if _, ok := columns[name]; ok {
+			columns[name] = i
+		}
+	}
+	if columns["lat"] < 0 || columns["lon"] < 0 {
+		return nil, fmt.Errorf("header CSV phải có cột lat và lon")
+	}
+
+	field := func(record []string, name string) string {
+		if i := columns[name]; i >= 0 && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	var points []batchPoint
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return points, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		point := batchPoint{id: field(record, "id")}
+		lat, latErr := strconv.ParseFloat(field(record, "lat"), 64)
+		lon, lonErr := strconv.ParseFloat(field(record, "lon"), 64)
+		if latErr != nil || lonErr != nil {
+			point.err = "lat và lon phải là số"
+		} else {
+			point.lat, point.lon = lat, lon
+		}
+		points = append(points, point)
+	}
+}
+
+func writeCSVResults(w http.ResponseWriter, results []*services.LookupResult) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	writer := csv.NewWriter(w)
+	_ = writer.Write([]string{"id", "lat", "lon", "found", "commune_code", "commune_name", "province_code", "province_name",
+		"nearest_code", "nearest_name", "distance_to_boundary_m", "error"})
+	ref := func(unit *services.UnitRef) (string, string) {
+		if unit == nil {
+			return "", ""
+		}
+		return unit.Code, unit.Name
+	}
+	for _, result := range results {
+		communeCode, communeName := ref(result.Commune)
+		provinceCode, provinceName := ref(result.Province)
+		nearestCode, nearestName := ref(result.Nearest)
+		distance := ""
+		if result.DistanceToBoundaryMeters != nil {
+			distance = strconv.FormatFloat(*result.DistanceToBoundaryMeters, 'f', 1, 64)
+		}
+		_ = writer.Write([]string{
+			result.ID,
+			strconv.FormatFloat(result.Lat, 'f', -1, 64),
+			strconv.FormatFloat(result.Lon, 'f', -1, 64),
+			strconv.FormatBool(result.Found),
+			communeCode, communeName,
+			provinceCode, provinceName,
+			nearestCode, nearestName,
+			distance,
+			result.Error,
+		})
+	}
+	writer.Flush()
+}
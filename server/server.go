@@ -0,0 +1,147 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+	"tool-map/metrics"
+	"tool-map/services"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// shutdownTimeout là thời gian chờ các request đang xử lý khi dừng server
+const shutdownTimeout = 15 * time.Second
+
+// Server là HTTP server của tool-map
+type Server struct {
+	osmService *services.OSMService
+	index      *services.SharedPolygonIndex
+	tiles      *services.TileServer
+	jobs       *services.JobQueue
+	access     *services.AccessControl
+	mux        *http.ServeMux
+}
+
+// New tạo server dùng OSMService đã kết nối database; access kiểm soát API key, rate limit và audit log
+func New(osmService *services.OSMService, access *services.AccessControl) *Server {
+	index := osmService.NewSharedPolygonIndex()
+	s := &Server{
+		osmService: osmService,
+		index:      index,
+		tiles:      services.NewTileServer(index),
+		jobs:       osmService.NewJobQueue(),
+		access:     access,
+		mux:        http.NewServeMux(),
+	}
+	// Dữ liệu job import vừa publish phải có trong tra cứu và vector tile mà không cần restart serve
+	s.jobs.OnPublished(index.Invalidate)
+	s.routes()
+	return s
+}
+
+func (s *Server) routes() {
+	s.mux.HandleFunc("GET /healthz", s.handleHealth)
+	s.mux.Handle("GET /metrics", metrics.Handler())
+	s.mux.HandleFunc("GET /{$}", s.handleViewer)
+	s.mux.HandleFunc("GET /openapi.json", s.handleOpenAPI)
+
+	lookup := func(pattern string, handler http.HandlerFunc) {
+		s.mux.Handle(pattern, s.protect(services.ScopeLookup, handler))
+	}
+	lookup("GET /provinces", s.handleProvinces)
+	lookup("GET /provinces/{matt}", s.handleProvince)
+	lookup("GET /provinces/{matt}/communes", s.handleProvinceCommunes)
+	lookup("GET /communes/{ma}", s.handleCommune)
+	lookup("GET /lookup", s.handleLookup)
+	lookup("POST /lookup/batch", s.handleLookupBatch)
+	lookup("GET /tiles/{layer}/{z}/{x}/{y}", s.handleTile)
+	lookup("GET /debug/relations/{id}/ways", s.handleRelationWays)
+
+	admin := func(pattern string, handler http.HandlerFunc) {
+		s.mux.Handle(pattern, s.protect(services.ScopeAdmin, handler))
+	}
+	admin("POST /jobs/import", s.handleImportJob)
+	admin("GET /jobs/{id}", s.handleJob)
+	admin("POST /jobs/{id}/cancel", s.handleCancelJob)
+}
+
+// Handler trả về http.Handler của server
+func (s *Server) Handler() http.Handler {
+	return s.mux
+}
+
+// ListenAndServe chạy HTTP server tại addr, và gRPC LookupService tại grpcAddr nếu khác rỗng, cho tới khi
+// ctx bị hủy hoặc một trong hai dừng vì lỗi, sau đó dừng an toàn cả hai.
+// Hàng đợi job import và việc nạp lại API key chạy cùng server và dừng theo ctx; chỉ mục polygon được nạp
+// ngay khi khởi động.
+func (s *Server) ListenAndServe(ctx context.Context, addr, grpcAddr string) error {
+	go s.jobs.Run(ctx)
+	go s.access.Run(ctx)
+	defer s.access.Close()
+
+	// Nạp trước chỉ mục để lời gọi tra cứu đầu tiên không phải chờ; lỗi được thử lại ở lời gọi sau
+	go func() {
+		if _, err := s.index.Get(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("Không nạp trước được chỉ mục polygon", "error", err)
+		}
+	}()
+
+	group, ctx := errgroup.WithContext(ctx)
+	group.Go(func() error { return s.serveHTTP(ctx, addr) })
+	if grpcAddr != "" {
+		group.Go(func() error { return s.serveGRPC(ctx, grpcAddr) })
+	}
+	return group.Wait()
+}
+
+func (s *Server) serveHTTP(ctx context.Context, addr string) error {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		slog.Info("HTTP server lắng nghe", "addr", addr)
+		errCh <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("Đang dừng HTTP server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("lỗi khi dừng HTTP server: %w", err)
+	}
+	if err := <-errCh; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// writeError ghi lỗi dạng {"error": message}
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+// writeJSON ghi v dạng JSON với status
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"tool-map/services"
+)
+
+// handleTile trả về vector tile GET /tiles/{layer}/{z}/{x}/{y}.mvt. Tile có ETag theo nội dung và
+// Cache-Control theo tiles.max_age; If-None-Match khớp thì trả 304, tile không có feature trả 204.
+func (s *Server) handleTile(w http.ResponseWriter, r *http.Request) {
+	name, ok := strings.CutSuffix(r.PathValue("y"), ".mvt")
+	z, zErr := strconv.Atoi(r.PathValue("z"))
+	x, xErr := strconv.Atoi(r.PathValue("x"))
+	y, yErr := strconv.Atoi(name)
+	if !ok || zErr != nil || xErr != nil || yErr != nil {
+		writeError(w, http.StatusBadRequest, "đường dẫn tile phải có dạng /tiles/{layer}/{z}/{x}/{y}.mvt")
+		return
+	}
+
+	tile, err := s.tiles.Tile(r.Context(), r.PathValue("layer"), z, x, y)
+	if errors.Is(err, services.ErrInvalidTile) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	header := w.Header()
+	header.Set("ETag", tile.ETag)
+	header.Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(s.tiles.MaxAge().Seconds())))
+	header.Set("Access-Control-Allow-Origin", "*")
+	if match := r.Header.Get("If-None-Match"); match != "" && etagMatches(match, tile.ETag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	if len(tile.Data) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	header.Set("Content-Type", "application/vnd.mapbox-vector-tile")
+	header.Set("Content-Length", strconv.Itoa(len(tile.Data)))
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(tile.Data)
+}
+
+// etagMatches kiểm tra header If-None-Match (danh sách ETag hoặc *) có chứa etag không
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
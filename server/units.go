@@ -0,0 +1,97 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"tool-map/services"
+)
+
+func (s *Server) handleProvinces(w http.ResponseWriter, r *http.Request) {
+	provinces, err := s.osmService.ListProvinces(r.Context())
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, provinces)
+}
+
+func (s *Server) handleProvinceCommunes(w http.ResponseWriter, r *http.Request) {
+	communes, err := s.osmService.ListCommunes(r.Context(), r.PathValue("matt"))
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, communes)
+}
+
+func (s *Server) handleProvince(w http.ResponseWriter, r *http.Request) {
+	tolerance, err := services.SimplifyTolerance(r.URL.Query().Get("simplify"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	unit, err := s.osmService.ProvinceGeometry(r.Context(), r.PathValue("matt"))
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeFeature(w, unit, tolerance)
+}
+
+func (s *Server) handleCommune(w http.ResponseWriter, r *http.Request) {
+	tolerance, err := services.SimplifyTolerance(r.URL.Query().Get("simplify"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	unit, err := s.osmService.CommuneGeometry(r.Context(), r.PathValue("ma"))
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeFeature(w, unit, tolerance)
+}
+
+// writeFeature ghi đơn vị dạng Feature GeoJSON, đơn giản hóa theo tolerance (mét) nếu > 0
+func writeFeature(w http.ResponseWriter, unit *services.UnitGeometry, tolerance float64) {
+	feature := unit.Simplify(tolerance).ToFeature()
+	if tolerance > 0 {
+		feature.Properties["simplifyMeters"] = tolerance
+	}
+	w.Header().Set("Content-Type", "application/geo+json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(feature)
+}
+
+// writeServiceError trả 404 khi không tìm thấy đơn vị, 400 khi tọa độ sai, 503 khi chỉ mục polygon chưa nạp
+// được; lỗi khác được log và trả 500 không kèm chi tiết
+func writeServiceError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, services.ErrUnitNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	case errors.Is(err, services.ErrInvalidCoordinate), errors.Is(err, services.ErrInvalidJob):
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, services.ErrJobNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	case errors.Is(err, services.ErrJobFinished):
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	case errors.Is(err, services.ErrJobQueueFull):
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	case errors.Is(err, services.ErrIndexUnavailable):
+		slog.Error("Lỗi khi xử lý request", "method", r.Method, "path", r.URL.Path, "error", err)
+		writeError(w, http.StatusServiceUnavailable, services.ErrIndexUnavailable.Error())
+		return
+	}
+	if r.Context().Err() != nil {
+		return
+	}
+	slog.Error("Lỗi khi xử lý request", "method", r.Method, "path", r.URL.Path, "error", err)
+	writeError(w, http.StatusInternalServerError, "lỗi nội bộ")
+}
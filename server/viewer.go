@@ -0,0 +1,48 @@
+package server
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// viewerHTML là trang xem ranh giới (Leaflet) nhúng vào binary; mọi dữ liệu lấy qua API của server
+//
+//go:embed viewer/index.html
+var viewerHTML []byte
+
+func (s *Server) handleViewer(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(viewerHTML)
+}
+
+// handleRelationWays trả về các way thành viên (LineString) và node đầu mút (Point) của relation để debug
+// việc nối vòng polygon. Chỉ nhận relation đã import vào DMTT/DM_PHUONG_XA, để client scope lookup không dùng
+// endpoint này kéo relation bất kỳ từ OSM API.
+func (s *Server) handleRelationWays(w http.ResponseWriter, r *http.Request) {
+	relationID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || relationID <= 0 {
+		writeError(w, http.StatusBadRequest, "relation id không hợp lệ")
+		return
+	}
+	unit, err := s.osmService.FindImportedUnit(r.Context(), relationID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	if unit == nil {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("relation %d chưa được import", relationID))
+		return
+	}
+	ways, err := s.osmService.RelationWays(r.Context(), relationID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/geo+json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(ways.ToGeoJSON())
+}
@@ -0,0 +1,367 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// Phạm vi quyền của API key; admin bao gồm lookup
+const (
+	ScopeLookup = "lookup"
+	ScopeAdmin  = "admin"
+)
+
+const (
+	// AnonymousClient là tên client của request không có API key (khi auth.required = false)
+	AnonymousClient = "anonymous"
+	// adminTokenClient là tên client của http.admin_token
+	adminTokenClient = "admin-token"
+	// apiKeyPrefix giúp nhận ra key của tool-map khi bị lộ trong log hay mã nguồn
+	apiKeyPrefix = "tm_"
+
+	// redisRateLimitKey đếm request của một client trong một cửa sổ rate limit (unix giây đầu cửa sổ)
+	redisRateLimitKey = "tool_map:ratelimit:%s:%d"
+	// maxMemoryCounters giới hạn số bộ đếm rate limit giữ trong bộ nhớ khi không dùng được Redis
+	maxMemoryCounters = 10000
+)
+
+var (
+	// ErrUnauthorized là lỗi khi thiếu API key hoặc key không hợp lệ
+	ErrUnauthorized = errors.New("API key không hợp lệ")
+	// ErrForbidden là lỗi khi API key không có quyền với endpoint
+	ErrForbidden = errors.New("API key không có quyền")
+)
+
+// APIKey là một key đã nạp từ auth.keys_file, bảng API_KEY hoặc http.admin_token.
+// Cấu trúc JSON trùng với một phần tử của auth.keys_file.
+type APIKey struct {
+	Client    string `json:"client"`
+	KeySHA256 string `json:"key_sha256"`
+	Scope     string `json:"scope"`
+	RateLimit *int   `json:"rate_limit,omitempty"` // nil = auth.rate_limit, 0 = không giới hạn
+}
+
+// Allows cho biết key được gọi endpoint cần scope
+func (k *APIKey) Allows(scope string) bool {
+	return k.Scope == ScopeAdmin || k.Scope == scope
+}
+
+// RateDecision là kết quả kiểm tra rate limit của một request
+type RateDecision struct {
+	Allowed   bool
+	Limit     int // 0 = không giới hạn
+	Remaining int
+	Reset     time.Duration // thời gian tới khi cửa sổ hiện tại kết thúc
+}
+
+// AuditRecord là một dòng audit log của HTTP server; lời gọi gRPC có Method = "GRPC", Path là tên method
+// đầy đủ và Status là mã status gRPC (0 = OK)
+type AuditRecord struct {
+	Time       time.Time `json:"time"`
+	Client     string    `json:"client"`
+	Scope      string    `json:"scope,omitempty"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	Route      string    `json:"route,omitempty"`
+	Query      string    `json:"query,omitempty"`
+	Status     int       `json:"status"`
+	Bytes      int64     `json:"bytes"`
+	DurationMs float64   `json:"durationMs"`
+	Remote     string    `json:"remote"`
+	UserAgent  string    `json:"userAgent,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// AccessControl xác thực API key, đếm rate limit theo client và ghi audit log cho HTTP server.
+// Key được nạp từ auth.keys_file, bảng API_KEY (auth.oracle) và http.admin_token, nạp lại theo
+// auth.reload_interval. Bộ đếm rate limit dùng Redis (INCR/EXPIRE theo cửa sổ cố định) để mọi
+// instance dùng chung hạn mức; không có Redis hoặc Redis lỗi thì đếm trong bộ nhớ của instance.
+type AccessControl struct {
+	service *OSMService
+
+	mu   sync.RWMutex
+	keys map[string]*APIKey // theo SHA-256 (hex) của key
+
+	limiter *rateLimiter
+
+	auditMu sync.Mutex
+	audit   *os.File // nil = ghi qua slog
+}
+
+// NewAccessControl nạp API key và mở audit log theo cấu hình auth
+func (s *OSMService) NewAccessControl(ctx context.Context) (*AccessControl, error) {
+	cfg := appConfig.Auth
+	a := &AccessControl{
+		service: s,
+		limiter: &rateLimiter{window: cfg.RateWindow, counters: make(map[string]*rateCounter)},
+	}
+	if err := a.Reload(ctx); err != nil {
+		return nil, err
+	}
+	if cfg.AuditLog != "" {
+		file, err := os.OpenFile(cfg.AuditLog, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+		if err != nil {
+			return nil, fmt.Errorf("không mở được audit log %s: %w", cfg.AuditLog, err)
+		}
+		a.audit = file
+	}
+	return a, nil
+}
+
+// Run nạp lại API key theo auth.reload_interval cho tới khi ctx bị hủy; lỗi khi nạp lại giữ nguyên danh sách cũ
+func (a *AccessControl) Run(ctx context.Context) {
+	interval := appConfig.Auth.ReloadInterval
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := a.Reload(ctx); err != nil && ctx.Err() == nil {
+			slog.Warn("Không nạp lại được API key, giữ danh sách cũ", "error", err)
+		}
+	}
+}
+
+// Reload nạp lại toàn bộ API key
+func (a *AccessControl) Reload(ctx context.Context) error {
+	cfg := appConfig.Auth
+	keys := make(map[string]*APIKey)
+	add := func(source string, key *APIKey) error {
+		if key.Client == "" || len(key.KeySHA256) != sha256.Size*2 {
+			return fmt.Errorf("%s: key của client '%s' cần client và key_sha256 (64 ký tự hex)", source, key.Client)
+		}
+		if key.Scope != ScopeLookup && key.Scope != ScopeAdmin {
+			return fmt.Errorf("%s: scope '%s' của client '%s' không hợp lệ (lookup, admin)", source, key.Scope, key.Client)
+		}
+		if key.RateLimit != nil && *key.RateLimit < 0 {
+			return fmt.Errorf("%s: rate_limit của client '%s' không được âm", source, key.Client)
+		}
+		keys[key.KeySHA256] = key
+		return nil
+	}
+
+	if token := appConfig.HTTP.AdminToken; token != "" {
+		_ = add("http.admin_token", &APIKey{Client: adminTokenClient, KeySHA256: HashAPIKey(token), Scope: ScopeAdmin})
+	}
+	if cfg.KeysFile != "" {
+		data, err := os.ReadFile(cfg.KeysFile)
+		if err != nil {
+			return fmt.Errorf("không đọc được auth.keys_file: %w", err)
+		}
+		var fileKeys []*APIKey
+		if err := json.Unmarshal(data, &fileKeys); err != nil {
+			return fmt.Errorf("auth.keys_file %s không hợp lệ: %w", cfg.KeysFile, err)
+		}
+		for _, key := range fileKeys {
+			if err := add(cfg.KeysFile, key); err != nil {
+				return err
+			}
+		}
+	}
+	if cfg.Oracle {
+		if a.service.apiKeyRepo == nil {
+			return fmt.Errorf("database repositories not initialized, use NewOSMServiceWithDB()")
+		}
+		rows, err := a.service.apiKeyRepo.GetActive(ctx)
+		if err != nil {
+			return fmt.Errorf("lỗi khi đọc bảng API_KEY: %w", err)
+		}
+		for _, row := range rows {
+			key := &APIKey{Client: row.Client, KeySHA256: row.KeyHash, Scope: row.Scope, RateLimit: row.RateLimit}
+			if err := add("API_KEY", key); err != nil {
+				// Một dòng sai trong bảng không được làm mất quyền của mọi client khác
+				slog.Warn("Bỏ qua API key không hợp lệ", "error", err)
+			}
+		}
+	}
+
+	a.mu.Lock()
+	a.keys = keys
+	a.mu.Unlock()
+	slog.Debug("Đã nạp API key", "keys", len(keys))
+	return nil
+}
+
+// Required cho biết API đọc có bắt buộc API key không (auth.required)
+func (a *AccessControl) Required() bool {
+	return appConfig.Auth.Required
+}
+
+// Authenticate tìm API key theo giá trị client gửi lên
+func (a *AccessControl) Authenticate(key string) (*APIKey, error) {
+	if key == "" {
+		return nil, ErrUnauthorized
+	}
+	hash := HashAPIKey(key)
+	a.mu.RLock()
+	apiKey, ok := a.keys[hash]
+	a.mu.RUnlock()
+	if !ok {
+		return nil, ErrUnauthorized
+	}
+	return apiKey, nil
+}
+
+// Allow đếm một request của key; key = nil là request anonymous, được đếm theo địa chỉ IP remote
+func (a *AccessControl) Allow(ctx context.Context, key *APIKey, remote string) RateDecision {
+	cfg := appConfig.Auth
+	if key == nil {
+		return a.limiter.allow(ctx, "ip:"+remote, cfg.AnonymousRateLimit)
+	}
+	limit := cfg.RateLimit
+	if key.RateLimit != nil {
+		limit = *key.RateLimit
+	}
+	return a.limiter.allow(ctx, "key:"+key.Client, limit)
+}
+
+// Audit ghi một dòng audit log
+func (a *AccessControl) Audit(record AuditRecord) {
+	if a.audit == nil {
+		slog.Info("HTTP request",
+			"audit", true,
+			"client", record.Client,
+			"method", record.Method,
+			"path", record.Path,
+			"status", record.Status,
+			"bytes", record.Bytes,
+			"duration_ms", record.DurationMs,
+			"remote", record.Remote)
+		return
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return
+	}
+	a.auditMu.Lock()
+	defer a.auditMu.Unlock()
+	if _, err := a.audit.Write(append(data, '\n')); err != nil {
+		slog.Warn("Không ghi được audit log", "error", err)
+	}
+}
+
+// Close đóng file audit log
+func (a *AccessControl) Close() error {
+	a.auditMu.Lock()
+	defer a.auditMu.Unlock()
+	if a.audit == nil {
+		return nil
+	}
+	err := a.audit.Close()
+	a.audit = nil
+	return err
+}
+
+// HashAPIKey trả về SHA-256 (hex) của key, giá trị lưu trong auth.keys_file và bảng API_KEY
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewAPIKey sinh một API key ngẫu nhiên
+func NewAPIKey() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("không sinh được API key: %w", err)
+	}
+	return apiKeyPrefix + hex.EncodeToString(buf), nil
+}
+
+// rateLimiter đếm request theo cửa sổ cố định; Redis khi có, bộ nhớ khi không
+type rateLimiter struct {
+	window time.Duration
+
+	mu          sync.Mutex
+	counters    map[string]*rateCounter
+	redisWarned time.Time
+}
+
+type rateCounter struct {
+	start time.Time
+	count int64
+}
+
+func (l *rateLimiter) allow(ctx context.Context, client string, limit int) RateDecision {
+	if limit <= 0 {
+		return RateDecision{Allowed: true}
+	}
+	now := time.Now()
+	start := now.Truncate(l.window)
+	reset := start.Add(l.window).Sub(now)
+
+	count, err := l.incrRedis(ctx, client, start)
+	if err != nil {
+		count = l.incrMemory(client, start)
+	}
+	remaining := limit - int(count)
+	if remaining < 0 {
+		remaining = 0
+	}
+	return RateDecision{Allowed: count <= int64(limit), Limit: limit, Remaining: remaining, Reset: reset}
+}
+
+var errRedisDisabled = errors.New("redis chưa được cấu hình")
+
+// incrRedis tăng bộ đếm của cửa sổ trên Redis; key hết hạn ngay sau khi cửa sổ kết thúc
+func (l *rateLimiter) incrRedis(ctx context.Context, client string, start time.Time) (int64, error) {
+	if !RedisEnabled() {
+		return 0, errRedisDisabled
+	}
+	key := fmt.Sprintf(redisRateLimitKey, client, start.Unix())
+	count, err := Incr(ctx, key)
+	if err == nil && count == 1 {
+		err = Expire(ctx, key, l.window+time.Second)
+	}
+	if err != nil {
+		l.mu.Lock()
+		warn := time.Since(l.redisWarned) > time.Minute
+		if warn {
+			l.redisWarned = time.Now()
+		}
+		l.mu.Unlock()
+		if warn {
+			slog.Warn("Không đếm được rate limit trên Redis, dùng bộ đếm trong bộ nhớ", "error", err)
+		}
+		return 0, err
+	}
+	return count, nil
+}
+
+// incrMemory tăng bộ đếm của cửa sổ trong bộ nhớ
+func (l *rateLimiter) incrMemory(client string, start time.Time) int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	counter, ok := l.counters[client]
+	if !ok {
+		if len(l.counters) >= maxMemoryCounters {
+			for name, c := range l.counters {
+				if c.start.Before(start) {
+					delete(l.counters, name)
+				}
+			}
+		}
+		counter = &rateCounter{start: start}
+		l.counters[client] = counter
+	}
+	if !counter.start.Equal(start) {
+		counter.start = start
+		counter.count = 0
+	}
+	counter.count++
+	return counter.count
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterIncrMemory(t *testing.T) {
+	window := time.Minute
+	first := time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC)
+	second := first.Add(window)
+
+	tests := []struct {
+		name   string
+		client string
+		start  time.Time
+		want   int64
+	}{
+		{"first request", "key:a", first, 1},
+		{"same window", "key:a", first, 2},
+		{"other client counted separately", "key:b", first, 1},
+		{"same window again", "key:a", first, 3},
+		{"window rollover resets", "key:a", second, 1},
+		{"next window continues", "key:a", second, 2},
+		{"other client rolls over on its own", "key:b", second, 1},
+	}
+	limiter := &rateLimiter{window: window, counters: make(map[string]*rateCounter)}
+	for _, tt := range tests {
+		if got := limiter.incrMemory(tt.client, tt.start); got != tt.want {
+			t.Errorf("%s: incrMemory(%s) = %d, want %d", tt.name, tt.client, got, tt.want)
+		}
+	}
+}
+
+func TestRateLimiterIncrMemoryEvictsStaleCounters(t *testing.T) {
+	window := time.Minute
+	old := time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC)
+	limiter := &rateLimiter{window: window, counters: make(map[string]*rateCounter)}
+	for i := 0; i < maxMemoryCounters; i++ {
+		limiter.incrMemory(fmt.Sprintf("ip:%d", i), old)
+	}
+
+	if got := limiter.incrMemory("ip:new", old.Add(window)); got != 1 {
+		t.Fatalf("incrMemory = %d, want 1", got)
+	}
+	if len(limiter.counters) != 1 {
+		t.Errorf("kept %d counters, want only the current window", len(limiter.counters))
+	}
+}
+
+func TestRateLimiterAllow(t *testing.T) {
+	// Không có Redis: allow đếm bằng bộ nhớ
+	oldRd, oldCluster := rd, rdCluster
+	rd, rdCluster = nil, nil
+	t.Cleanup(func() { rd, rdCluster = oldRd, oldCluster })
+
+	window := time.Hour
+	tests := []struct {
+		name          string
+		client        string
+		limit         int
+		wantAllowed   bool
+		wantRemaining int
+	}{
+		{"unlimited", "key:free", 0, true, 0},
+		{"unlimited again", "key:free", 0, true, 0},
+		{"first of two", "key:a", 2, true, 1},
+		{"second of two", "key:a", 2, true, 0},
+		{"over the limit", "key:a", 2, false, 0},
+		{"still over the limit", "key:a", 2, false, 0},
+		{"other client", "ip:10.0.0.1", 2, true, 1},
+	}
+	limiter := &rateLimiter{window: window, counters: make(map[string]*rateCounter)}
+	for _, tt := range tests {
+		decision := limiter.allow(context.Background(), tt.client, tt.limit)
+		if decision.Allowed != tt.wantAllowed || decision.Remaining != tt.wantRemaining || decision.Limit != tt.limit {
+			t.Errorf("%s: allow = %+v, want allowed=%v remaining=%d limit=%d", tt.name, decision, tt.wantAllowed, tt.wantRemaining, tt.limit)
+		}
+		if tt.limit > 0 && (decision.Reset <= 0 || decision.Reset > window) {
+			t.Errorf("%s: reset = %v, want within the window", tt.name, decision.Reset)
+		}
+	}
+	if _, ok := limiter.counters["key:free"]; ok {
+		t.Error("unlimited client got a counter")
+	}
+}
@@ -0,0 +1,248 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"tool-map/models"
+	"tool-map/util"
+)
+
+const (
+	// relationBatchSize giới hạn số relation trong một request /relations?relations=...
+	relationBatchSize = 100
+	// maxAdminTreeDepth chặn vòng lặp nếu dữ liệu subarea bị lỗi (tự tham chiếu)
+	maxAdminTreeDepth = 4
+)
+
+// AdminTreeCoverage so sánh cây relation OSM với DM_PHUONG_XA của một tỉnh
+type AdminTreeCoverage struct {
+	ProvinceRelationID int64                   `json:"provinceRelationId"`
+	MaTT               string                  `json:"maTT"`
+	Matched            []CoverageMatch         `json:"matched"`
+	MissingInOSM       []CoverageMatch         `json:"missingInOsm"` // có trong DB nhưng không có relation OSM
+	MissingInDB        []*models.AdminTreeNode `json:"missingInDb"`  // có relation OSM nhưng không có trong DB
+}
+
+// CoverageMatch là một cặp xã/phường DB ↔ relation OSM
+type CoverageMatch struct {
+	RelationID  int64  `json:"relationId,omitempty"`
+	Name        string `json:"name,omitempty"`
+	MaPhuongXa  string `json:"maPhuongXa,omitempty"`
+	TenPhuongXa string `json:"tenPhuongXa,omitempty"`
+}
+
+// BuildAdminTree duyệt đệ quy các member subarea của relation tỉnh, lấy relation con theo từng
+// cấp (mỗi cấp một loạt request /relations) và dựng cây tỉnh → (huyện) → xã/phường
+func (s *OSMService) BuildAdminTree(ctx context.Context, provinceRelationID int64) (*models.AdminTreeNode, error) {
+	osm, err := s.client.FetchRelation(ctx, provinceRelationID)
+	if err != nil {
+		return nil, fmt.Errorf("không thể lấy relation %d từ OSM API: %w", provinceRelationID, err)
+	}
+	relation, found := osm.FindRelationByID(provinceRelationID)
+	if !found {
+		return nil, fmt.Errorf("OSM API không trả về relation %d", provinceRelationID)
+	}
+
+	root := models.NewAdminTreeNode(relation)
+	visited := map[int64]bool{provinceRelationID: true}
+	level := []*models.AdminTreeNode{root}
+
+	for depth := 0; len(level) > 0 && depth < maxAdminTreeDepth; depth++ {
+		var childIDs []int64
+		for _, node := range level {
+			for _, id := range node.PendingSubareas() {
+				if !visited[id] {
+					visited[id] = true
+					childIDs = append(childIDs, id)
+				}
+			}
+		}
+
+		children, err := s.fetchRelationsByID(ctx, childIDs)
+		if err != nil {
+			return nil, err
+		}
+
+		var next []*models.AdminTreeNode
+		for _, parent := range level {
+			for _, id := range parent.PendingSubareas() {
+				child, found := children[id]
+				if !found {
+					continue
+				}
+				delete(children, id) // một relation chỉ gắn vào một cha
+
+				node := models.NewAdminTreeNode(child)
+				if node.AdminLevel <= parent.AdminLevel || node.AdminLevel > models.OSMCommuneLevel {
+					slog.Debug("Bỏ qua subarea", "relation", node.RelationID, "name", node.Name, "admin_level", node.AdminLevel, "parent", parent.RelationID)
+					continue
+				}
+				parent.Children = append(parent.Children, node)
+				if node.AdminLevel < models.OSMCommuneLevel {
+					next = append(next, node)
+				} else {
+					node.ClearPendingSubareas()
+				}
+			}
+			parent.ClearPendingSubareas()
+		}
+		level = next
+	}
+
+	slog.Info("Đã dựng cây hành chính", "relation", provinceRelationID, "communes", len(root.Communes()))
+	return root, nil
+}
+
+// fetchRelationsByID lấy các relation theo lô relationBatchSize, trả về map theo ID
+func (s *OSMService) fetchRelationsByID(ctx context.Context, ids []int64) (map[int64]*models.Relation, error) {
+	result := make(map[int64]*models.Relation, len(ids))
+	for start := 0; start < len(ids); start += relationBatchSize {
+		end := start + relationBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		osm, err := s.client.FetchRelations(ctx, ids[start:end])
+		if err != nil {
+			return nil, fmt.Errorf("không thể lấy %d relation con từ OSM API: %w", end-start, err)
+		}
+		for i := range osm.Relations {
+			result[osm.Relations[i].ID] = &osm.Relations[i]
+		}
+	}
+	return result, nil
+}
+
+// SaveAdminTree ghi cây hành chính ra file JSON {dir}/{relationID}.json
+func SaveAdminTree(tree *models.AdminTreeNode, dir string) (string, error) {
+	return writeJSONFile(dir, fmt.Sprintf("%d.json", tree.RelationID), tree)
+}
+
+// SaveAdminTreeCoverage ghi kết quả đối chiếu ra file JSON {dir}/{relationID}_coverage.json
+func SaveAdminTreeCoverage(coverage *AdminTreeCoverage, dir string) (string, error) {
+	return writeJSONFile(dir, fmt.Sprintf("%d_coverage.json", coverage.ProvinceRelationID), coverage)
+}
+
+// writeJSONFile ghi v dạng JSON (có thụt lề) vào dir/name, tạo thư mục nếu chưa có
+func writeJSONFile(dir, name string, v any) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory %s: %w", dir, err)
+	}
+
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal %s: %w", name, err)
+	}
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return path, nil
+}
+
+// LoadAdminTree đọc cây hành chính đã lưu bởi SaveAdminTree
+func LoadAdminTree(dir string, relationID int64) (*models.AdminTreeNode, error) {
+	path := filepath.Join(dir, fmt.Sprintf("%d.json", relationID))
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read admin tree %s: %w", path, err)
+	}
+
+	var tree models.AdminTreeNode
+	if err := json.Unmarshal(data, &tree); err != nil {
+		return nil, fmt.Errorf("failed to parse admin tree %s: %w", path, err)
+	}
+	return &tree, nil
+}
+
+// CheckTreeCoverage đối chiếu các xã/phường trong cây với DM_PHUONG_XA của tỉnh maTT: trước hết theo
+// OSM_RELATION_ID đã lưu, sau đó theo tên (bỏ dấu). Mỗi xã trong cây chỉ khớp với một dòng, nên các
+// xã trùng tên được ghép lần lượt thay vì ghi đè nhau.
+func (s *OSMService) CheckTreeCoverage(ctx context.Context, tree *models.AdminTreeNode, maTT string) (*AdminTreeCoverage, error) {
+	if s.dmPhuongXaRepo == nil {
+		return nil, fmt.Errorf("database repositories not initialized, use NewOSMServiceWithDB()")
+	}
+
+	rows, err := s.dmPhuongXaRepo.GetAllByMaTT(ctx, maTT)
+	if err != nil {
+		return nil, fmt.Errorf("không thể lấy danh sách xã/phường của tỉnh %s: %w", maTT, err)
+	}
+
+	coverage := &AdminTreeCoverage{
+		ProvinceRelationID: tree.RelationID,
+		MaTT:               maTT,
+	}
+
+	communes := tree.Communes()
+	byRelation := make(map[int64]*models.AdminTreeNode, len(communes))
+	for _, commune := range communes {
+		byRelation[commune.RelationID] = commune
+	}
+	matched := make(map[*models.AdminTreeNode]bool, len(communes))
+	rowMatch := make([]*models.AdminTreeNode, len(rows))
+
+	// Dòng đã gắn relation khớp trước, để việc ghép theo tên không lấy mất xã của dòng khác
+	for i, row := range rows {
+		if row.OsmRelationID == nil {
+			continue
+		}
+		if commune, found := byRelation[*row.OsmRelationID]; found && !matched[commune] {
+			rowMatch[i] = commune
+			matched[commune] = true
+		}
+	}
+
+	byName := make(map[string][]*models.AdminTreeNode)
+	for _, commune := range communes {
+		if !matched[commune] {
+			key := normalizeAdminName(commune.Name)
+			byName[key] = append(byName[key], commune)
+		}
+	}
+	for i, row := range rows {
+		if rowMatch[i] != nil {
+			continue
+		}
+		key := normalizeAdminName(row.TenPhuongXa)
+		if candidates := byName[key]; len(candidates) > 0 {
+			rowMatch[i] = candidates[0]
+			matched[candidates[0]] = true
+			byName[key] = candidates[1:]
+		}
+	}
+
+	for i, row := range rows {
+		if commune := rowMatch[i]; commune != nil {
+			coverage.Matched = append(coverage.Matched, CoverageMatch{
+				RelationID:  commune.RelationID,
+				Name:        commune.Name,
+				MaPhuongXa:  row.MaPhuongXa,
+				TenPhuongXa: row.TenPhuongXa,
+			})
+			continue
+		}
+		coverage.MissingInOSM = append(coverage.MissingInOSM, CoverageMatch{
+			MaPhuongXa:  row.MaPhuongXa,
+			TenPhuongXa: row.TenPhuongXa,
+		})
+	}
+
+	for _, commune := range communes {
+		if !matched[commune] {
+			coverage.MissingInDB = append(coverage.MissingInDB, commune)
+		}
+	}
+
+	return coverage, nil
+}
+
+// normalizeAdminName chuẩn hóa tên đơn vị hành chính để so khớp (chữ thường, bỏ dấu)
+func normalizeAdminName(name string) string {
+	return util.RemoveVietnameseAccent(strings.ToLower(strings.TrimSpace(name)))
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"tool-map/entities"
+	"tool-map/models"
+)
+
+func TestCheckTreeCoverage(t *testing.T) {
+	claimed := entities.DmPhuongXa{MaPhuongXa: "00002", TenPhuongXa: "Xã A", TrucThuocTinh: "01"}
+	claimed.OsmRelationID = new(int64)
+	*claimed.OsmRelationID = 1001
+	repo := newFakeCommuneRepo(
+		entities.DmPhuongXa{MaPhuongXa: "00001", TenPhuongXa: "Xã A", TrucThuocTinh: "01"},
+		// Dòng đã gắn relation 1001 phải giữ relation đó dù xếp sau dòng cùng tên chưa gắn
+		claimed,
+		entities.DmPhuongXa{MaPhuongXa: "00003", TenPhuongXa: "Phuong B", TrucThuocTinh: "01"},
+		entities.DmPhuongXa{MaPhuongXa: "00004", TenPhuongXa: "Xã D", TrucThuocTinh: "01"},
+		entities.DmPhuongXa{MaPhuongXa: "00005", TenPhuongXa: "Xã A", TrucThuocTinh: "02"},
+	)
+	s := NewOSMService()
+	s.dmPhuongXaRepo = repo
+
+	commune := func(id int64, name string) *models.AdminTreeNode {
+		return &models.AdminTreeNode{RelationID: id, Name: name, AdminLevel: models.OSMCommuneLevel}
+	}
+	tree := &models.AdminTreeNode{RelationID: 1, Name: "Tỉnh X", AdminLevel: models.OSMProvinceLevel, Children: []*models.AdminTreeNode{
+		commune(1001, "Xã A"),
+		commune(1002, "Xã A"),
+		commune(1003, "Phường B"),
+		commune(1004, "Xã C"),
+	}}
+
+	coverage, err := s.CheckTreeCoverage(context.Background(), tree, "01")
+	if err != nil {
+		t.Fatalf("CheckTreeCoverage: %v", err)
+	}
+
+	want := map[string]int64{"00001": 1002, "00002": 1001, "00003": 1003}
+	if len(coverage.Matched) != len(want) {
+		t.Errorf("matched = %+v, want %d rows", coverage.Matched, len(want))
+	}
+	for _, match := range coverage.Matched {
+		if want[match.MaPhuongXa] != match.RelationID {
+			t.Errorf("%s matched relation %d, want %d", match.MaPhuongXa, match.RelationID, want[match.MaPhuongXa])
+		}
+	}
+	if len(coverage.MissingInOSM) != 1 || coverage.MissingInOSM[0].MaPhuongXa != "00004" {
+		t.Errorf("missing in OSM = %+v, want only 00004", coverage.MissingInOSM)
+	}
+	if len(coverage.MissingInDB) != 1 || coverage.MissingInDB[0].RelationID != 1004 {
+		t.Errorf("missing in DB = %+v, want only relation 1004", coverage.MissingInDB)
+	}
+}
@@ -0,0 +1,345 @@
+package services
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"tool-map/models"
+)
+
+// BoundaryStore giữ bản sao cục bộ các node/way/relation biên giới đã import,
+// để cập nhật bằng OsmChange (.osc) thay vì fetch lại /full cho từng relation
+type BoundaryStore struct {
+	path string
+
+	mu        sync.Mutex
+	nodes     map[int64]models.Node
+	ways      map[int64]models.Way
+	relations map[int64]models.Relation
+}
+
+// BoundaryStoreChange tổng hợp kết quả áp dụng một OsmChange vào store
+type BoundaryStoreChange struct {
+	Nodes     int `json:"nodes"`     // số node đã cập nhật/xóa
+	Ways      int `json:"ways"`      // số way đã cập nhật/xóa
+	Relations int `json:"relations"` // số relation đã cập nhật/xóa
+
+	Affected   []int64 `json:"affected"`   // relation hành chính bị ảnh hưởng, cần dựng lại polygon
+	Incomplete []int64 `json:"incomplete"` // relation thiếu way/node trong store, cần fetch lại /full
+	Deleted    []int64 `json:"deleted"`    // relation hành chính đã bị xóa trên OSM
+}
+
+// IsIncomplete cho biết relation có cần fetch lại /full sau khi áp dụng diff không
+func (c *BoundaryStoreChange) IsIncomplete(relationID int64) bool {
+	for _, id := range c.Incomplete {
+		if id == relationID {
+			return true
+		}
+	}
+	return false
+}
+
+// NewBoundaryStore tạo store rỗng lưu tại path
+func NewBoundaryStore(path string) *BoundaryStore {
+	return &BoundaryStore{
+		path:      path,
+		nodes:     make(map[int64]models.Node),
+		ways:      make(map[int64]models.Way),
+		relations: make(map[int64]models.Relation),
+	}
+}
+
+// LoadBoundaryStore đọc store từ file OSM XML; file chưa tồn tại thì trả về store rỗng
+func LoadBoundaryStore(path string) (*BoundaryStore, error) {
+	store := NewBoundaryStore(path)
+
+	osm, err := models.ParseOSMFromFile(path)
+	if err != nil {
+		if _, statErr := os.Stat(path); os.IsNotExist(statErr) {
+			return store, nil
+		}
+		return nil, fmt.Errorf("không thể đọc boundary store %s: %w", path, err)
+	}
+
+	store.Merge(osm)
+	return store, nil
+}
+
+// Path trả về đường dẫn file của store
+func (st *BoundaryStore) Path() string {
+	return st.path
+}
+
+// Len trả về số node, way, relation đang lưu
+func (st *BoundaryStore) Len() (nodes, ways, relations int) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return len(st.nodes), len(st.ways), len(st.relations)
+}
+
+// Merge thêm hoặc thay thế các phần tử từ một lần fetch /full
+func (st *BoundaryStore) Merge(osm *models.OSM) {
+	if osm == nil {
+		return
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	for _, node := range osm.Nodes {
+		st.nodes[node.ID] = node
+	}
+	for _, way := range osm.Ways {
+		st.ways[way.ID] = way
+	}
+	for _, relation := range osm.Relations {
+		st.relations[relation.ID] = relation
+	}
+}
+
+// Save ghi store ra file (ghi file tạm rồi rename để không làm hỏng store khi bị ngắt giữa chừng)
+func (st *BoundaryStore) Save() error {
+	st.mu.Lock()
+	osm := &models.OSM{Version: "0.6", Generator: "tool-map boundary store"}
+	for _, node := range st.nodes {
+		osm.Nodes = append(osm.Nodes, node)
+	}
+	for _, way := range st.ways {
+		osm.Ways = append(osm.Ways, way)
+	}
+	for _, relation := range st.relations {
+		osm.Relations = append(osm.Relations, relation)
+	}
+	st.mu.Unlock()
+
+	sort.Slice(osm.Nodes, func(i, j int) bool { return osm.Nodes[i].ID < osm.Nodes[j].ID })
+	sort.Slice(osm.Ways, func(i, j int) bool { return osm.Ways[i].ID < osm.Ways[j].ID })
+	sort.Slice(osm.Relations, func(i, j int) bool { return osm.Relations[i].ID < osm.Relations[j].ID })
+
+	data, err := xml.MarshalIndent(osm, "", "  ")
+	if err != nil {
+		return fmt.Errorf("không thể encode boundary store: %w", err)
+	}
+
+	if dir := filepath.Dir(st.path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("không thể tạo thư mục %s: %w", dir, err)
+		}
+	}
+	tmp := st.path + ".tmp"
+	if err := os.WriteFile(tmp, append([]byte(xml.Header), data...), 0644); err != nil {
+		return fmt.Errorf("không thể ghi boundary store: %w", err)
+	}
+	return os.Rename(tmp, st.path)
+}
+
+// RelationOSM dựng lại tài liệu OSM tương đương /relation/{id}/full từ store:
+// relation, các node/way thành viên, node của các way và relation con (không đệ quy)
+func (st *BoundaryStore) RelationOSM(relationID int64) (*models.OSM, bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	relation, ok := st.relations[relationID]
+	if !ok {
+		return nil, false
+	}
+
+	osm := &models.OSM{Version: "0.6", Generator: "tool-map boundary store"}
+	seenNodes := make(map[int64]bool)
+	addNode := func(id int64) {
+		if seenNodes[id] {
+			return
+		}
+		if node, ok := st.nodes[id]; ok {
+			osm.Nodes = append(osm.Nodes, node)
+			seenNodes[id] = true
+		}
+	}
+
+	for _, member := range relation.Members {
+		switch member.Type {
+		case "node":
+			addNode(member.Ref)
+		case "way":
+			way, ok := st.ways[member.Ref]
+			if !ok {
+				continue
+			}
+			osm.Ways = append(osm.Ways, way)
+			for _, nd := range way.Nodes {
+				addNode(nd.Ref)
+			}
+		case "relation":
+			if child, ok := st.relations[member.Ref]; ok && child.ID != relationID {
+				osm.Relations = append(osm.Relations, child)
+			}
+		}
+	}
+	osm.Relations = append(osm.Relations, relation)
+
+	return osm, true
+}
+
+// Apply áp dụng OsmChange vào store theo đúng thứ tự các section.
+// Chỉ giữ lại phần tử thuộc relation đang theo dõi: modify/delete chỉ tác động lên phần tử đã có,
+// phần tử create chỉ được nhận khi một way/relation trong store tham chiếu tới nó.
+// Phần tử có version không mới hơn bản đang lưu sẽ bị bỏ qua (áp dụng lại cùng một diff là an toàn).
+func (st *BoundaryStore) Apply(change *models.OsmChange) *BoundaryStoreChange {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	summary := &BoundaryStoreChange{}
+	touchedNodes := make(map[int64]bool)
+	touchedWays := make(map[int64]bool)
+	touchedRelations := make(map[int64]bool)
+
+	createdNodes := make(map[int64]models.Node)
+	createdWays := make(map[int64]models.Way)
+
+	for _, action := range change.Actions {
+		for _, node := range action.Nodes {
+			existing, tracked := st.nodes[node.ID]
+			switch {
+			case action.Type == models.ChangeActionCreate:
+				createdNodes[node.ID] = node
+			case !tracked:
+				if _, pending := createdNodes[node.ID]; pending {
+					if action.Type == models.ChangeActionDelete {
+						delete(createdNodes, node.ID)
+					} else {
+						createdNodes[node.ID] = node
+					}
+				}
+			case node.Version != 0 && node.Version <= existing.Version:
+				continue
+			case action.Type == models.ChangeActionDelete:
+				delete(st.nodes, node.ID)
+				touchedNodes[node.ID] = true
+			default:
+				st.nodes[node.ID] = node
+				touchedNodes[node.ID] = true
+			}
+		}
+
+		for _, way := range action.Ways {
+			existing, tracked := st.ways[way.ID]
+			switch {
+			case action.Type == models.ChangeActionCreate:
+				createdWays[way.ID] = way
+			case !tracked:
+				if _, pending := createdWays[way.ID]; pending {
+					if action.Type == models.ChangeActionDelete {
+						delete(createdWays, way.ID)
+					} else {
+						createdWays[way.ID] = way
+					}
+				}
+			case way.Version != 0 && way.Version <= existing.Version:
+				continue
+			case action.Type == models.ChangeActionDelete:
+				delete(st.ways, way.ID)
+				touchedWays[way.ID] = true
+			default:
+				st.ways[way.ID] = way
+				touchedWays[way.ID] = true
+			}
+		}
+
+		for _, relation := range action.Relations {
+			existing, tracked := st.relations[relation.ID]
+			if !tracked || action.Type == models.ChangeActionCreate {
+				// Relation mới chưa gắn với DM nào, sẽ được thêm khi import đầy đủ
+				continue
+			}
+			if relation.Version != 0 && relation.Version <= existing.Version {
+				continue
+			}
+			if action.Type == models.ChangeActionDelete {
+				delete(st.relations, relation.ID)
+				if existing.IsAdministrativeBoundary() {
+					summary.Deleted = append(summary.Deleted, relation.ID)
+				}
+			} else {
+				st.relations[relation.ID] = relation
+			}
+			touchedRelations[relation.ID] = true
+		}
+	}
+
+	// Nhận các way/node mới được tham chiếu bởi relation/way đang theo dõi
+	for _, relation := range st.relations {
+		for _, member := range relation.Members {
+			if member.Type != "way" {
+				continue
+			}
+			if way, ok := createdWays[member.Ref]; ok {
+				delete(createdWays, way.ID)
+				if existing, tracked := st.ways[way.ID]; tracked && existing.Version >= way.Version {
+					continue
+				}
+				st.ways[way.ID] = way
+				touchedWays[way.ID] = true
+			}
+		}
+	}
+	for _, way := range st.ways {
+		for _, nd := range way.Nodes {
+			if node, ok := createdNodes[nd.Ref]; ok {
+				delete(createdNodes, node.ID)
+				if existing, tracked := st.nodes[node.ID]; tracked && existing.Version >= node.Version {
+					continue
+				}
+				st.nodes[node.ID] = node
+				touchedNodes[node.ID] = true
+			}
+		}
+	}
+
+	summary.Nodes = len(touchedNodes)
+	summary.Ways = len(touchedWays)
+	summary.Relations = len(touchedRelations)
+
+	for id, relation := range st.relations {
+		if !relation.IsAdministrativeBoundary() {
+			continue
+		}
+
+		affected := touchedRelations[id]
+		incomplete := false
+		for _, member := range relation.Members {
+			switch member.Type {
+			case "node":
+				affected = affected || touchedNodes[member.Ref]
+			case "way":
+				way, ok := st.ways[member.Ref]
+				if !ok {
+					incomplete = true
+					affected = affected || touchedWays[member.Ref]
+					continue
+				}
+				affected = affected || touchedWays[member.Ref]
+				for _, nd := range way.Nodes {
+					if _, ok := st.nodes[nd.Ref]; !ok {
+						incomplete = true
+					}
+					affected = affected || touchedNodes[nd.Ref]
+				}
+			}
+		}
+
+		if affected {
+			summary.Affected = append(summary.Affected, id)
+			if incomplete {
+				summary.Incomplete = append(summary.Incomplete, id)
+			}
+		}
+	}
+
+	sort.Slice(summary.Affected, func(i, j int) bool { return summary.Affected[i] < summary.Affected[j] })
+	sort.Slice(summary.Incomplete, func(i, j int) bool { return summary.Incomplete[i] < summary.Incomplete[j] })
+	sort.Slice(summary.Deleted, func(i, j int) bool { return summary.Deleted[i] < summary.Deleted[j] })
+	return summary
+}
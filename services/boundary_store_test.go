@@ -0,0 +1,133 @@
+package services
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"tool-map/models"
+)
+
+// boundaryStoreBase là store ban đầu: relation 100 gồm way 10 và 11, relation 200 gồm way 20
+const boundaryStoreBase = `<?xml version="1.0" encoding="UTF-8"?>
+<osm version="0.6" generator="test">
+  <node id="1" version="1" lat="21.0" lon="105.0"/>
+  <node id="2" version="1" lat="21.0" lon="105.1"/>
+  <node id="3" version="1" lat="21.1" lon="105.1"/>
+  <node id="4" version="1" lat="21.1" lon="105.0"/>
+  <node id="5" version="1" lat="22.0" lon="106.0"/>
+  <node id="6" version="1" lat="22.0" lon="106.1"/>
+  <node id="8" version="1" lat="22.1" lon="106.1"/>
+  <way id="10" version="1"><nd ref="1"/><nd ref="2"/><nd ref="3"/></way>
+  <way id="11" version="1"><nd ref="3"/><nd ref="4"/><nd ref="1"/></way>
+  <way id="20" version="1"><nd ref="5"/><nd ref="6"/><nd ref="8"/><nd ref="5"/></way>
+  <relation id="100" version="1">
+    <member type="way" ref="10" role="outer"/>
+    <member type="way" ref="11" role="outer"/>
+    <tag k="boundary" v="administrative"/><tag k="admin_level" v="6"/>
+  </relation>
+  <relation id="200" version="1">
+    <member type="way" ref="20" role="outer"/>
+    <tag k="boundary" v="administrative"/><tag k="admin_level" v="6"/>
+  </relation>
+</osm>`
+
+// boundaryStoreDiff tạo rồi sửa node 7 và nối nó vào way 10, xóa way 11 khỏi relation 100,
+// sửa way 20 trỏ tới node 99 không có trong store, và sửa node 500 không được theo dõi
+const boundaryStoreDiff = `<?xml version="1.0" encoding="UTF-8"?>
+<osmChange version="0.6" generator="test">
+  <create>
+    <node id="7" version="1" lat="21.2" lon="105.2"/>
+  </create>
+  <modify>
+    <node id="7" version="2" lat="21.05" lon="105.05"/>
+    <node id="500" version="3" lat="10.0" lon="100.0"/>
+    <way id="10" version="2"><nd ref="1"/><nd ref="2"/><nd ref="7"/><nd ref="3"/><nd ref="1"/></way>
+    <way id="20" version="2"><nd ref="5"/><nd ref="6"/><nd ref="99"/><nd ref="8"/><nd ref="5"/></way>
+    <relation id="100" version="2">
+      <member type="way" ref="10" role="outer"/>
+      <tag k="boundary" v="administrative"/><tag k="admin_level" v="6"/>
+    </relation>
+  </modify>
+  <delete>
+    <way id="11" version="2"/>
+  </delete>
+</osmChange>`
+
+func TestBoundaryStoreApply(t *testing.T) {
+	base, err := models.ParseOSMFromBytes([]byte(boundaryStoreBase))
+	if err != nil {
+		t.Fatalf("ParseOSMFromBytes: %v", err)
+	}
+	diff, err := models.ParseOsmChangeFromReader(strings.NewReader(boundaryStoreDiff))
+	if err != nil {
+		t.Fatalf("ParseOsmChangeFromReader: %v", err)
+	}
+
+	st := NewBoundaryStore("")
+	st.Merge(base)
+	first := st.Apply(diff)
+	second := st.Apply(diff)
+
+	tests := []struct {
+		name  string
+		check func(t *testing.T)
+	}{
+		{"create then modify node", func(t *testing.T) {
+			node, ok := st.nodes[7]
+			if !ok {
+				t.Fatal("node 7 not accepted although way 10 references it")
+			}
+			if node.Version != 2 || node.Lat != 21.05 {
+				t.Errorf("node 7 = version %d lat %g, want the modified version 2", node.Version, node.Lat)
+			}
+			if len(st.ways[10].Nodes) != 5 {
+				t.Errorf("way 10 has %d nodes, want 5", len(st.ways[10].Nodes))
+			}
+		}},
+		{"delete tracked way", func(t *testing.T) {
+			if _, ok := st.ways[11]; ok {
+				t.Error("way 11 still in store")
+			}
+			if members := st.relations[100].Members; len(members) != 1 || members[0].Ref != 10 {
+				t.Errorf("relation 100 members = %+v, want only way 10", members)
+			}
+			if first.IsIncomplete(100) {
+				t.Error("relation 100 reported incomplete after dropping the deleted way")
+			}
+		}},
+		{"untracked modify ignored", func(t *testing.T) {
+			if _, ok := st.nodes[500]; ok {
+				t.Error("untracked node 500 added to store")
+			}
+			// node 7 (nhận từ create) + way 10, 11, 20 + relation 100
+			if first.Nodes != 1 || first.Ways != 3 || first.Relations != 1 {
+				t.Errorf("first apply touched %d nodes, %d ways, %d relations, want 1, 3, 1", first.Nodes, first.Ways, first.Relations)
+			}
+		}},
+		{"incomplete way", func(t *testing.T) {
+			if !reflect.DeepEqual(first.Incomplete, []int64{200}) {
+				t.Errorf("incomplete = %v, want [200]", first.Incomplete)
+			}
+		}},
+		{"affected relations", func(t *testing.T) {
+			if !reflect.DeepEqual(first.Affected, []int64{100, 200}) {
+				t.Errorf("affected = %v, want [100 200]", first.Affected)
+			}
+			if len(first.Deleted) != 0 {
+				t.Errorf("deleted = %v, want none", first.Deleted)
+			}
+		}},
+		{"replay is a no-op", func(t *testing.T) {
+			want := &BoundaryStoreChange{}
+			if !reflect.DeepEqual(second, want) {
+				t.Errorf("second apply = %+v, want %+v", second, want)
+			}
+			if nodes, ways, relations := st.Len(); nodes != 8 || ways != 2 || relations != 2 {
+				t.Errorf("store has %d nodes, %d ways, %d relations, want 8, 2, 2", nodes, ways, relations)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, tt.check)
+	}
+}
@@ -0,0 +1,184 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+	"tool-map/entities"
+	"tool-map/metrics"
+	"tool-map/models"
+)
+
+const (
+	ChangeStatusNew             = "new"              // chưa từng import (không có OSM_VERSION/GEOMETRY_HASH)
+	ChangeStatusGeometryChanged = "geometry_changed" // hình học thay đổi, cần build lại polygon
+	ChangeStatusVersionChanged  = "version_changed"  // relation được sửa (tag/member) nhưng hình học giữ nguyên
+	ChangeStatusUnchanged       = "unchanged"
+)
+
+// UnitChange mô tả thay đổi của một đơn vị hành chính so với lần import trước
+type UnitChange struct {
+	Level        int     `json:"level"` // 4 = tỉnh, 6 = xã/phường
+	Code         string  `json:"code"`  // MATT hoặc MA_PHUONG_XA
+	Name         string  `json:"name"`
+	RelationID   int64   `json:"relationId"`
+	Status       string  `json:"status"`
+	OldVersion   *int    `json:"oldVersion,omitempty"`
+	NewVersion   int     `json:"newVersion"`
+	NewChangeset int64   `json:"newChangeset"`
+	NewTimestamp string  `json:"newTimestamp"`
+	OldHash      string  `json:"oldHash,omitempty"`
+	NewHash      string  `json:"newHash"`
+	Changesets   []int64 `json:"changesets,omitempty"` // changeset của relation giữa hai lần import (từ /history)
+	DetectedAt   string  `json:"detectedAt"`
+}
+
+// NeedsReprocess cho biết có cần build lại và ghi polygon/boundary hay không
+func (c *UnitChange) NeedsReprocess() bool {
+	return c.Status == ChangeStatusNew || c.Status == ChangeStatusGeometryChanged
+}
+
+// DetectChange so sánh kết quả OSM vừa lấy với phiên bản đã lưu của dòng DMTT (level 4)
+// hoặc DM_PHUONG_XA (level 6). code là MATT hoặc MA_PHUONG_XA đã tra từ trước; tỉnh có thể để trống code
+// để tra theo tên. Khi có thay đổi, lấy thêm /history để liệt kê các changeset.
+func (s *OSMService) DetectChange(ctx context.Context, code, name string, level int, result *models.OSMProcessingResult) (*UnitChange, error) {
+	if s.dmTTRepo == nil || s.dmPhuongXaRepo == nil {
+		return nil, fmt.Errorf("database repositories not initialized, use NewOSMServiceWithDB()")
+	}
+	if result.Relation == nil {
+		return nil, fmt.Errorf("kết quả OSM không chứa relation gốc")
+	}
+
+	var stored entities.AddressBase
+	switch level {
+	case 4: // Tỉnh/thành phố
+		var tt *entities.DmTT
+		var err error
+		if code != "" {
+			tt, err = s.dmTTRepo.GetByMaTT(ctx, code)
+		} else {
+			tt, err = s.dmTTRepo.GetByName(ctx, name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("không thể lấy dữ liệu tỉnh/thành phố từ database: %w", err)
+		}
+		if tt == nil {
+			return nil, fmt.Errorf("không tìm thấy tỉnh/thành phố '%s' trong database", name)
+		}
+		code, stored = tt.MaTT, tt.AddressBase
+	case 6: // Xã/phường
+		px, err := s.dmPhuongXaRepo.GetByMaPhuongXa(ctx, code)
+		if err != nil {
+			return nil, fmt.Errorf("không thể lấy dữ liệu xã/phường từ database: %w", err)
+		}
+		if px == nil {
+			return nil, fmt.Errorf("không tìm thấy xã/phường '%s' (%s) trong database", name, code)
+		}
+		code, stored = px.MaPhuongXa, px.AddressBase
+	default:
+		return nil, fmt.Errorf("level '%d' không được hỗ trợ", level)
+	}
+
+	relation := result.Relation
+	change := &UnitChange{
+		Level:        level,
+		Code:         code,
+		Name:         name,
+		RelationID:   relation.ID,
+		OldVersion:   stored.OsmVersion,
+		NewVersion:   relation.Version,
+		NewChangeset: relation.Changeset,
+		NewTimestamp: relation.Timestamp,
+		NewHash:      result.GeometryHash,
+		DetectedAt:   time.Now().Format(time.RFC3339),
+	}
+	if stored.GeometryHash != nil {
+		change.OldHash = *stored.GeometryHash
+	}
+
+	switch {
+	case stored.OsmVersion == nil || stored.GeometryHash == nil:
+		change.Status = ChangeStatusNew
+	case *stored.GeometryHash != result.GeometryHash:
+		change.Status = ChangeStatusGeometryChanged
+	case *stored.OsmVersion != relation.Version:
+		change.Status = ChangeStatusVersionChanged
+	default:
+		change.Status = ChangeStatusUnchanged
+	}
+
+	if stored.OsmVersion != nil && *stored.OsmVersion < relation.Version {
+		history, err := s.client.FetchRelationHistory(ctx, relation.ID)
+		if err != nil {
+			slog.Warn("Không lấy được history relation", "relation", relation.ID, "error", err)
+		} else {
+			change.Changesets = history.ChangesetsSince(relation.ID, *stored.OsmVersion)
+		}
+	}
+
+	return change, nil
+}
+
+// RecordOsmVersion lưu phiên bản relation và fingerprint hình học vào dòng DM tương ứng
+func (s *OSMService) RecordOsmVersion(ctx context.Context, change *UnitChange, result *models.OSMProcessingResult) error {
+	relation := result.Relation
+	switch change.Level {
+	case 4:
+		return metrics.CountWriteError(metrics.TargetOracle, s.dmTTRepo.UpdateOsmVersionByMaTT(ctx, change.Code, &relation.ID, &relation.Version, &relation.Changeset, &relation.Timestamp, &result.GeometryHash))
+	case 6:
+		return metrics.CountWriteError(metrics.TargetOracle, s.dmPhuongXaRepo.UpdateOsmVersionByMaPhuongXa(ctx, change.Code, &relation.ID, &relation.Version, &relation.Changeset, &relation.Timestamp, &result.GeometryHash))
+	default:
+		return fmt.Errorf("level '%d' không được hỗ trợ", change.Level)
+	}
+}
+
+// RecordImport lưu phiên bản OSM đã import vào dòng DM và ghi changelog nếu đơn vị có thay đổi.
+// Ở chế độ dry-run chỉ gắn trạng thái thay đổi vào plan.
+func (s *OSMService) RecordImport(ctx context.Context, change *UnitChange, result *models.OSMProcessingResult, changelogPath string) error {
+	if change == nil {
+		return nil
+	}
+	if s.plan != nil {
+		s.plan.recordChange(change)
+		return nil
+	}
+	if err := s.RecordOsmVersion(ctx, change, result); err != nil {
+		return fmt.Errorf("không thể lưu phiên bản OSM cho '%s': %w", change.Name, err)
+	}
+	if change.Status == ChangeStatusUnchanged {
+		return nil
+	}
+	if err := AppendChangelog(changelogPath, change); err != nil {
+		return fmt.Errorf("không thể ghi changelog: %w", err)
+	}
+	return nil
+}
+
+// changelogMu tuần tự hóa việc ghi changelog khi nhiều worker cùng ghi
+var changelogMu sync.Mutex
+
+// AppendChangelog ghi thêm một dòng JSON vào file changelog (JSON Lines)
+func AppendChangelog(path string, change *UnitChange) error {
+	data, err := json.Marshal(change)
+	if err != nil {
+		return fmt.Errorf("failed to marshal change: %w", err)
+	}
+
+	changelogMu.Lock()
+	defer changelogMu.Unlock()
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open changelog %s: %w", path, err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write changelog %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,421 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	StepPending   = "pending"   // đã lên lịch, chưa xử lý
+	StepFetched   = "fetched"   // đã fetch dữ liệu OSM
+	StepBuilt     = "built"     // tỉnh: đã publish polygon tỉnh và dựng cây, đang xử lý xã/phường
+	StepPublished = "published" // đã ghi xong DB/Redis/MinIO
+	StepFailed    = "failed"
+)
+
+const (
+	checkpointKindRelation = "relation"
+	checkpointKindCommune  = "commune"
+
+	// redisCheckpointKey là hash lưu checkpoint khi dùng Redis, field "<kind>:<relation id>"
+	redisCheckpointKey = "tool_map:checkpoint"
+	// redisRelationLockKey là key khóa một relation tỉnh giữa các tiến trình
+	redisRelationLockKey = "tool_map:lock:relation:%d"
+
+	// checkpointFlushEvery là số lần cập nhật xã/phường giữa hai lần ghi file checkpoint
+	checkpointFlushEvery = 20
+)
+
+// CheckpointEntry là tiến độ của một relation tỉnh hoặc xã/phường
+type CheckpointEntry struct {
+	Status    string `json:"status"`
+	Name      string `json:"name,omitempty"`
+	Error     string `json:"error,omitempty"`
+	Attempts  int    `json:"attempts"` // số lần đã chuyển sang failed
+	UpdatedAt string `json:"updatedAt"`
+}
+
+// checkpointBackend lưu checkpoint vào file cục bộ hoặc Redis
+type checkpointBackend interface {
+	load(ctx context.Context) (map[string]CheckpointEntry, error)
+	put(ctx context.Context, key string, entry CheckpointEntry, all map[string]CheckpointEntry) error
+	flush(ctx context.Context, all map[string]CheckpointEntry) error
+	reset(ctx context.Context) error
+	describe() string
+}
+
+// Checkpoint lưu tiến độ từng relation tỉnh và xã/phường để chạy lại được từ chỗ bị dừng.
+// Mọi method đều an toàn khi gọi trên Checkpoint nil (không checkpoint).
+type Checkpoint struct {
+	mu      sync.Mutex
+	backend checkpointBackend
+	entries map[string]CheckpointEntry
+	dirty   int
+}
+
+// OpenFileCheckpoint mở checkpoint lưu trong file JSON (file chưa tồn tại = checkpoint rỗng)
+func OpenFileCheckpoint(ctx context.Context, path string) (*Checkpoint, error) {
+	return openCheckpoint(ctx, &fileCheckpoint{path: path})
+}
+
+// OpenRedisCheckpoint mở checkpoint lưu trong hash Redis, dùng chung được giữa nhiều máy
+func OpenRedisCheckpoint(ctx context.Context) (*Checkpoint, error) {
+	if !RedisEnabled() {
+		return nil, fmt.Errorf("chưa cấu hình Redis (REDIS_ADDRESS hoặc REDIS_CLUSTER)")
+	}
+	return openCheckpoint(ctx, redisCheckpoint{})
+}
+
+func openCheckpoint(ctx context.Context, backend checkpointBackend) (*Checkpoint, error) {
+	entries, err := backend.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if entries == nil {
+		entries = make(map[string]CheckpointEntry)
+	}
+	return &Checkpoint{backend: backend, entries: entries}, nil
+}
+
+// String mô tả nơi lưu checkpoint
+func (c *Checkpoint) String() string {
+	if c == nil {
+		return "không dùng checkpoint"
+	}
+	return c.backend.describe()
+}
+
+// Relation trả về tiến độ đã lưu của relation tỉnh
+func (c *Checkpoint) Relation(relationID int64) (CheckpointEntry, bool) {
+	return c.get(checkpointKey(checkpointKindRelation, relationID))
+}
+
+// Commune trả về tiến độ đã lưu của xã/phường
+func (c *Checkpoint) Commune(relationID int64) (CheckpointEntry, bool) {
+	return c.get(checkpointKey(checkpointKindCommune, relationID))
+}
+
+// ShouldSkipRelation cho biết relation đã xong (hoặc đã lỗi mà không yêu cầu retryFailed)
+func (c *Checkpoint) ShouldSkipRelation(relationID int64, retryFailed bool) bool {
+	entry, ok := c.Relation(relationID)
+	return ok && shouldSkip(entry, retryFailed)
+}
+
+// ShouldSkipCommune cho biết xã/phường đã xong (hoặc đã lỗi mà không yêu cầu retryFailed)
+func (c *Checkpoint) ShouldSkipCommune(relationID int64, retryFailed bool) bool {
+	entry, ok := c.Commune(relationID)
+	return ok && shouldSkip(entry, retryFailed)
+}
+
+func shouldSkip(entry CheckpointEntry, retryFailed bool) bool {
+	switch entry.Status {
+	case StepPublished:
+		return true
+	case StepFailed:
+		return !retryFailed
+	default:
+		// pending/fetched/built: lần chạy trước bị ngắt giữa chừng, làm lại
+		return false
+	}
+}
+
+// MarkRelation lưu tiến độ relation tỉnh và ghi ngay xuống backend
+func (c *Checkpoint) MarkRelation(ctx context.Context, relationID int64, name, status string, err error) {
+	c.mark(ctx, checkpointKey(checkpointKindRelation, relationID), name, status, err, true)
+}
+
+// MarkCommune lưu tiến độ xã/phường; với file, backend chỉ được ghi sau mỗi checkpointFlushEvery lần
+func (c *Checkpoint) MarkCommune(ctx context.Context, relationID int64, name, status string, err error) {
+	c.mark(ctx, checkpointKey(checkpointKindCommune, relationID), name, status, err, false)
+}
+
+// MarkCommunesPending đánh dấu pending cho các xã/phường chưa có trong checkpoint
+func (c *Checkpoint) MarkCommunesPending(ctx context.Context, jobs []CommuneJob) {
+	if c == nil {
+		return
+	}
+	for _, job := range jobs {
+		if _, ok := c.Commune(job.RelationID); !ok {
+			c.MarkCommune(ctx, job.RelationID, job.Name, StepPending, nil)
+		}
+	}
+	c.Flush(ctx)
+}
+
+func (c *Checkpoint) get(key string) (CheckpointEntry, bool) {
+	if c == nil {
+		return CheckpointEntry{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	return entry, ok
+}
+
+func (c *Checkpoint) mark(ctx context.Context, key, name, status string, err error, flush bool) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := c.entries[key]
+	if name != "" {
+		entry.Name = name
+	}
+	entry.Status = status
+	entry.Error = ""
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	if status == StepFailed {
+		entry.Attempts++
+	}
+	entry.UpdatedAt = time.Now().Format(time.RFC3339)
+	c.entries[key] = entry
+
+	c.dirty++
+	// Redis ghi từng field nên luôn ghi ngay; file phải ghi lại toàn bộ nên chỉ ghi theo lô
+	if _, isFile := c.backend.(*fileCheckpoint); isFile && !flush && c.dirty < checkpointFlushEvery {
+		return
+	}
+	if err := c.backend.put(ctx, key, entry, c.entries); err != nil {
+		slog.Error("Lỗi khi lưu checkpoint", "checkpoint", c.backend.describe(), "error", err)
+		return
+	}
+	c.dirty = 0
+}
+
+// Flush ghi các cập nhật còn lại xuống backend
+func (c *Checkpoint) Flush(ctx context.Context) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.dirty == 0 {
+		return
+	}
+	if err := c.backend.flush(ctx, c.entries); err != nil {
+		slog.Error("Lỗi khi lưu checkpoint", "checkpoint", c.backend.describe(), "error", err)
+		return
+	}
+	c.dirty = 0
+}
+
+// Reset xóa toàn bộ tiến độ (bắt đầu lượt chạy mới)
+func (c *Checkpoint) Reset(ctx context.Context) error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]CheckpointEntry)
+	c.dirty = 0
+	return c.backend.reset(ctx)
+}
+
+// Counts đếm số relation tỉnh và xã/phường theo trạng thái
+func (c *Checkpoint) Counts() (relations, communes map[string]int) {
+	relations, communes = make(map[string]int), make(map[string]int)
+	if c == nil {
+		return relations, communes
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, entry := range c.entries {
+		if strings.HasPrefix(key, checkpointKindRelation+":") {
+			relations[entry.Status]++
+		} else {
+			communes[entry.Status]++
+		}
+	}
+	return relations, communes
+}
+
+func checkpointKey(kind string, relationID int64) string {
+	return kind + ":" + strconv.FormatInt(relationID, 10)
+}
+
+// fileCheckpoint lưu checkpoint trong một file JSON, ghi bằng file tạm + rename
+type fileCheckpoint struct {
+	path string
+}
+
+func (f *fileCheckpoint) describe() string { return f.path }
+
+func (f *fileCheckpoint) load(ctx context.Context) (map[string]CheckpointEntry, error) {
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("không thể đọc checkpoint %s: %w", f.path, err)
+	}
+	var entries map[string]CheckpointEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("checkpoint %s không hợp lệ: %w", f.path, err)
+	}
+	return entries, nil
+}
+
+func (f *fileCheckpoint) put(ctx context.Context, key string, entry CheckpointEntry, all map[string]CheckpointEntry) error {
+	return f.flush(ctx, all)
+}
+
+func (f *fileCheckpoint) flush(ctx context.Context, all map[string]CheckpointEntry) error {
+	data, err := json.MarshalIndent(all, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoint: %w", err)
+	}
+	tmp := f.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, f.path)
+}
+
+func (f *fileCheckpoint) reset(ctx context.Context) error {
+	if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// redisCheckpoint lưu checkpoint trong hash redisCheckpointKey
+type redisCheckpoint struct{}
+
+func (redisCheckpoint) describe() string { return "redis:" + redisCheckpointKey }
+
+func (redisCheckpoint) load(ctx context.Context) (map[string]CheckpointEntry, error) {
+	entries, err := HGetAll[CheckpointEntry](ctx, redisCheckpointKey)
+	if err != nil {
+		return nil, fmt.Errorf("không thể đọc checkpoint từ redis: %w", err)
+	}
+	return entries, nil
+}
+
+func (redisCheckpoint) put(ctx context.Context, key string, entry CheckpointEntry, all map[string]CheckpointEntry) error {
+	return HSetStruct(ctx, redisCheckpointKey, key, entry)
+}
+
+func (r redisCheckpoint) flush(ctx context.Context, all map[string]CheckpointEntry) error {
+	data := make(map[string]interface{}, len(all))
+	for key, entry := range all {
+		data[key] = entry
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	return HMSetStruct(ctx, redisCheckpointKey, data)
+}
+
+func (redisCheckpoint) reset(ctx context.Context) error {
+	return Del(ctx, redisCheckpointKey)
+}
+
+// AcquireRelationLock khóa relation tỉnh bằng SetNX để hai tiến trình không cùng import một tỉnh.
+// Trả về ok=false nếu tiến trình khác đang giữ khóa. Khi chưa cấu hình Redis thì luôn thành công.
+func AcquireRelationLock(ctx context.Context, relationID int64, ttl time.Duration) (release func(), ok bool, err error) {
+	if !RedisEnabled() {
+		return func() {}, true, nil
+	}
+
+	key, owner, ok, err := acquireRelationKey(ctx, relationID, ttl)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	return func() { releaseRelationKey(key, owner) }, true, nil
+}
+
+// ErrLeaseLost là nguyên nhân hủy context của LeaseRelation khi khóa hết hạn hoặc bị tiến trình khác lấy
+var ErrLeaseLost = errors.New("mất khóa relation")
+
+// LeaseRelation khóa relation như AcquireRelationLock nhưng với TTL ngắn, được gia hạn mỗi ttl/3 cho tới khi
+// release. Tiến trình chết giữa chừng thì khóa tự hết hạn sau tối đa ttl thay vì chờ hết timeout của cả relation.
+// Việc import phải chạy dưới leaseCtx: khi khóa bị mất (không gia hạn được trong ttl, hoặc đã thuộc về tiến trình
+// khác), leaseCtx bị hủy với nguyên nhân ErrLeaseLost (context.Cause) để hai nơi không cùng ghi một tỉnh.
+func LeaseRelation(ctx context.Context, relationID int64, ttl time.Duration) (leaseCtx context.Context, release func(), ok bool, err error) {
+	if !RedisEnabled() {
+		return ctx, func() {}, true, nil
+	}
+
+	key, owner, ok, err := acquireRelationKey(ctx, relationID, ttl)
+	if err != nil || !ok {
+		return nil, nil, false, err
+	}
+
+	leaseCtx, cancel := context.WithCancelCause(ctx)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(ttl / 3)
+		defer ticker.Stop()
+		renewed := time.Now()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-leaseCtx.Done():
+				return
+			case <-ticker.C:
+			}
+			renewCtx, cancelRenew := context.WithTimeout(context.Background(), 5*time.Second)
+			held, err := ExpireIfEqual(renewCtx, key, owner, ttl)
+			cancelRenew()
+			switch {
+			case err == nil && !held:
+				slog.Error("Khóa relation đã hết hạn hoặc thuộc về tiến trình khác, dừng import", "relation", relationID)
+				cancel(fmt.Errorf("%w %d: khóa đã hết hạn hoặc thuộc về tiến trình khác", ErrLeaseLost, relationID))
+				return
+			case err == nil:
+				renewed = time.Now()
+			case time.Since(renewed) >= ttl:
+				slog.Error("Không gia hạn được khóa relation trong TTL, dừng import", "relation", relationID, "error", err)
+				cancel(fmt.Errorf("%w %d: không gia hạn được trong %s: %w", ErrLeaseLost, relationID, ttl, err))
+				return
+			default:
+				slog.Warn("Không gia hạn được khóa relation, thử lại", "relation", relationID, "error", err)
+			}
+		}
+	}()
+
+	return leaseCtx, func() {
+		close(stop)
+		<-done
+		cancel(nil)
+		releaseRelationKey(key, owner)
+	}, true, nil
+}
+
+// acquireRelationKey đặt key khóa relation bằng SetNX với giá trị định danh tiến trình
+func acquireRelationKey(ctx context.Context, relationID int64, ttl time.Duration) (key, owner string, ok bool, err error) {
+	key = fmt.Sprintf(redisRelationLockKey, relationID)
+	hostname, _ := os.Hostname()
+	owner = fmt.Sprintf("%s:%d:%d", hostname, os.Getpid(), time.Now().UnixNano())
+
+	ok, err = SetNX(ctx, key, owner, ttl)
+	if err != nil {
+		return "", "", false, fmt.Errorf("không thể khóa relation %d: %w", relationID, err)
+	}
+	return key, owner, ok, nil
+}
+
+// releaseRelationKey chỉ xóa khóa nếu vẫn là của mình (khóa có thể đã hết hạn và bị tiến trình khác lấy);
+// so sánh và xóa là một thao tác nguyên tử
+func releaseRelationKey(key, owner string) {
+	releaseCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := DelIfEqual(releaseCtx, key, owner); err != nil {
+		slog.Warn("Không xóa được khóa relation, khóa sẽ tự hết hạn", "key", key, "error", err)
+	}
+}
@@ -0,0 +1,368 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+	"tool-map/entities"
+	"tool-map/metrics"
+	"tool-map/models"
+)
+
+const (
+	CommuneStatusWritten   = "written"   // đã lưu boundary + polygon
+	CommuneStatusUnchanged = "unchanged" // hình học không đổi, chỉ cập nhật phiên bản
+	CommuneStatusNotFound  = "not_found" // không có dòng DM_PHUONG_XA tương ứng
+	CommuneStatusFailed    = "failed"
+	CommuneStatusSkipped   = "skipped" // đã xong ở lần chạy trước theo checkpoint
+)
+
+const (
+	defaultCommuneFetchWorkers = 4
+	defaultCommuneWriteWorkers = 2
+)
+
+// CommunePipelineConfig cấu hình số worker và kích thước hàng đợi của từng stage
+type CommunePipelineConfig struct {
+	FetchWorkers  int    // số worker fetch OSM + dựng polygon (vẫn chịu rate limit chung của OSM client)
+	WriteWorkers  int    // số worker ghi DB/Redis
+	QueueSize     int    // kích thước hàng đợi giữa các stage
+	ChangelogPath string // file changelog JSON Lines
+
+	Checkpoint  *Checkpoint // nil = không lưu tiến độ
+	RetryFailed bool        // chạy lại cả xã/phường đã lỗi ở lần trước
+
+	// OnCommunes và OnCommune (tùy chọn) của ImportRelation được gọi khi biết danh sách xã/phường của tỉnh
+	// và sau khi xử lý xong từng xã/phường, để theo dõi tiến độ
+	OnCommunes func(relationID int64, maTT string, jobs []CommuneJob)
+	OnCommune  func(relationID int64, result CommuneResult)
+}
+
+// WithDefaults điền giá trị mặc định cho các trường chưa cấu hình
+func (cfg CommunePipelineConfig) WithDefaults() CommunePipelineConfig {
+	if cfg.FetchWorkers <= 0 {
+		cfg.FetchWorkers = defaultCommuneFetchWorkers
+	}
+	if cfg.WriteWorkers <= 0 {
+		cfg.WriteWorkers = defaultCommuneWriteWorkers
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 2 * (cfg.FetchWorkers + cfg.WriteWorkers)
+	}
+	return cfg
+}
+
+// CommuneJob là một xã/phường cần xử lý
+type CommuneJob struct {
+	Seq        int // thứ tự trong danh sách, kết quả được báo theo đúng thứ tự này
+	RelationID int64
+	Name       string
+	AdminLevel int
+	MaTT       string
+}
+
+// CommuneResult là kết quả xử lý một xã/phường
+type CommuneResult struct {
+	Job        CommuneJob
+	MaPhuongXa string
+	Status     string
+	Change     *UnitChange
+	Err        error
+
+	FetchDuration time.Duration
+	WriteDuration time.Duration
+}
+
+// communeWrite là job đã fetch xong, chờ stage ghi
+type communeWrite struct {
+	result     CommuneResult
+	data       *models.OSMProcessingResult
+	recordOnly bool // hình học không đổi: chỉ lưu phiên bản OSM, không ghi lại polygon
+}
+
+// keyedMutex khóa theo key (MA_PHUONG_XA) để không có hai writer cùng ghi một dòng
+type keyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*keyedLock
+}
+
+type keyedLock struct {
+	sync.Mutex
+	refs int
+}
+
+func newKeyedMutex() *keyedMutex {
+	return &keyedMutex{locks: make(map[string]*keyedLock)}
+}
+
+// Lock khóa key và trả về hàm mở khóa
+func (k *keyedMutex) Lock(key string) func() {
+	k.mu.Lock()
+	lock, ok := k.locks[key]
+	if !ok {
+		lock = &keyedLock{}
+		k.locks[key] = lock
+	}
+	lock.refs++
+	k.mu.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		k.mu.Lock()
+		lock.refs--
+		if lock.refs == 0 {
+			delete(k.locks, key)
+		}
+		k.mu.Unlock()
+	}
+}
+
+// RunCommunePipeline xử lý các xã/phường qua hai stage song song:
+// fetch (tìm dòng DM, fetch /full, phát hiện thay đổi) và write (lưu boundary, polygon, phiên bản).
+// report được gọi tuần tự theo đúng thứ tự jobs, bất kể job nào xong trước.
+func (s *OSMService) RunCommunePipeline(ctx context.Context, cfg CommunePipelineConfig, jobs []CommuneJob, report func(CommuneResult)) {
+	cfg = cfg.WithDefaults()
+
+	fetchQueue := make(chan CommuneJob, cfg.QueueSize)
+	writeQueue := make(chan communeWrite, cfg.QueueSize)
+	results := make(chan CommuneResult, cfg.QueueSize)
+
+	// Stage 0: đưa mọi job vào hàng đợi fetch; khi ctx bị hủy, worker fetch trả lỗi ngay
+	// nên mỗi job luôn có đúng một kết quả và báo cáo theo thứ tự không bị kẹt
+	go func() {
+		defer close(fetchQueue)
+		for i, job := range jobs {
+			job.Seq = i
+			fetchQueue <- job
+		}
+	}()
+
+	// Stage 1: fetch
+	var fetchWG sync.WaitGroup
+	for w := 0; w < cfg.FetchWorkers; w++ {
+		fetchWG.Add(1)
+		go func() {
+			defer fetchWG.Done()
+			for job := range fetchQueue {
+				if write, ok := s.fetchCommune(ctx, cfg, job, results); ok {
+					writeQueue <- write
+				}
+			}
+		}()
+	}
+	go func() {
+		fetchWG.Wait()
+		close(writeQueue)
+	}()
+
+	// Stage 2: write, khóa theo MA_PHUONG_XA
+	locks := newKeyedMutex()
+	owners := &sync.Map{}
+	var writeWG sync.WaitGroup
+	for w := 0; w < cfg.WriteWorkers; w++ {
+		writeWG.Add(1)
+		go func() {
+			defer writeWG.Done()
+			for write := range writeQueue {
+				results <- s.writeCommune(ctx, cfg, locks, owners, write)
+			}
+		}()
+	}
+	// writeQueue chỉ đóng sau khi mọi worker fetch xong, nên lúc này không còn ai gửi vào results
+	go func() {
+		writeWG.Wait()
+		close(results)
+	}()
+
+	// Báo cáo theo thứ tự Seq
+	pending := make(map[int]CommuneResult)
+	next := 0
+	for result := range results {
+		s.checkpointCommuneResult(ctx, cfg, result)
+		metrics.Commune(communeMetricResult(result.Status))
+		pending[result.Job.Seq] = result
+		for {
+			r, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			s.planCommuneResult(r)
+			report(r)
+			next++
+		}
+	}
+	// Ghi nốt tiến độ còn lại kể cả khi ctx đã bị hủy
+	cfg.Checkpoint.Flush(context.WithoutCancel(ctx))
+}
+
+// findCommune tìm dòng DM_PHUONG_XA của relation xã/phường: dòng đã mang OSM_RELATION_ID của relation (import
+// trước đó) được ưu tiên, chỉ khi chưa có mới tra theo tên trong tỉnh maTT, bỏ qua các dòng đã thuộc relation
+// khác. Hai xã trùng tên fetch song song vẫn có thể cùng nhận một dòng chưa gán; stage ghi giành dòng bằng
+// claimCommune nên chỉ một relation được ghi. nil nếu không tìm thấy.
+func (s *OSMService) findCommune(ctx context.Context, relationID int64, name, maTT string) (*entities.DmPhuongXa, error) {
+	px, err := s.dmPhuongXaRepo.GetByOsmRelationID(ctx, relationID)
+	if err != nil || px != nil {
+		return px, err
+	}
+	px, err = s.dmPhuongXaRepo.GetByName(ctx, name, maTT)
+	if err != nil || px == nil || px.MaPhuongXa == "" {
+		return nil, err
+	}
+	return px, nil
+}
+
+// fetchCommune chạy stage fetch cho một job; trả về ok=false khi job đã có kết quả cuối cùng
+func (s *OSMService) fetchCommune(ctx context.Context, cfg CommunePipelineConfig, job CommuneJob, results chan<- CommuneResult) (communeWrite, bool) {
+	result := CommuneResult{Job: job}
+	finish := func(status string, err error) (communeWrite, bool) {
+		result.Status, result.Err = status, err
+		results <- result
+		return communeWrite{}, false
+	}
+
+	if err := ctx.Err(); err != nil {
+		return finish(CommuneStatusFailed, err)
+	}
+	if cfg.Checkpoint.ShouldSkipCommune(job.RelationID, cfg.RetryFailed) {
+		return finish(CommuneStatusSkipped, nil)
+	}
+
+	px, err := s.findCommune(ctx, job.RelationID, job.Name, job.MaTT)
+	if err != nil || px == nil {
+		return finish(CommuneStatusNotFound, fmt.Errorf("không tìm thấy phường xã '%s' trong database", job.Name))
+	}
+	result.MaPhuongXa = px.MaPhuongXa
+
+	started := time.Now()
+	data, err := s.FetchAndProcessRelation(ctx, job.RelationID)
+	result.FetchDuration = time.Since(started)
+	if err != nil {
+		return finish(CommuneStatusFailed, fmt.Errorf("lỗi khi lấy dữ liệu OSM (ID %d): %w", job.RelationID, err))
+	}
+	cfg.Checkpoint.MarkCommune(ctx, job.RelationID, job.Name, StepFetched, nil)
+
+	change, err := s.DetectChange(ctx, px.MaPhuongXa, job.Name, job.AdminLevel, data)
+	if err != nil {
+		slog.Warn("Không xác định được thay đổi", "relation", job.RelationID, "commune", job.Name, "error", err)
+	}
+	result.Change = change
+
+	// Lưu phiên bản cũng là ghi vào dòng DM_PHUONG_XA nên vẫn đi qua stage write
+	recordOnly := change != nil && !change.NeedsReprocess()
+	return communeWrite{result: result, data: data, recordOnly: recordOnly}, true
+}
+
+// claimCommune giành dòng MA_PHUONG_XA cho relation trước khi ghi; false nếu dòng đã thuộc relation khác.
+// owners ghi relation đã giành từng dòng trong lần chạy này, để dry-run (không ghi DB) cũng phát hiện trùng.
+// Phải gọi khi đang giữ khóa MA_PHUONG_XA.
+func (s *OSMService) claimCommune(ctx context.Context, owners *sync.Map, maPhuongXa string, relationID int64) (bool, error) {
+	if owner, ok := owners.Load(maPhuongXa); ok && owner.(int64) != relationID {
+		return false, nil
+	}
+	if s.plan == nil {
+		claimed, err := s.dmPhuongXaRepo.ClaimOsmRelationByMaPhuongXa(ctx, maPhuongXa, relationID)
+		if err != nil || !claimed {
+			return false, metrics.CountWriteError(metrics.TargetOracle, err)
+		}
+	}
+	owners.Store(maPhuongXa, relationID)
+	return true, nil
+}
+
+// writeCommune chạy stage ghi cho một xã/phường, giữ khóa MA_PHUONG_XA trong suốt quá trình ghi
+func (s *OSMService) writeCommune(ctx context.Context, cfg CommunePipelineConfig, locks *keyedMutex, owners *sync.Map, write communeWrite) CommuneResult {
+	result := write.result
+	if err := ctx.Err(); err != nil {
+		result.Status, result.Err = CommuneStatusFailed, err
+		return result
+	}
+
+	unlock := locks.Lock(result.MaPhuongXa)
+	defer unlock()
+
+	started := time.Now()
+	job := result.Job
+	claimed, err := s.claimCommune(ctx, owners, result.MaPhuongXa, job.RelationID)
+	if err != nil {
+		result.Status, result.Err = CommuneStatusFailed, err
+		return result
+	}
+	if !claimed {
+		// Relation khác cùng tên đã nhận dòng này: xã này không có dòng DM_PHUONG_XA tương ứng
+		result.Status = CommuneStatusNotFound
+		result.Err = fmt.Errorf("dòng DM_PHUONG_XA %s của '%s' đã thuộc relation khác", result.MaPhuongXa, job.Name)
+		return result
+	}
+	if !write.recordOnly {
+		err = s.PublishCommune(ctx, result.MaPhuongXa, job.Name, job.AdminLevel, job.MaTT, write.data)
+	}
+	if err == nil {
+		err = s.RecordImport(ctx, result.Change, write.data, cfg.ChangelogPath)
+	}
+	result.WriteDuration = time.Since(started)
+
+	switch {
+	case err != nil:
+		result.Status, result.Err = CommuneStatusFailed, err
+	case write.recordOnly:
+		result.Status = CommuneStatusUnchanged
+	default:
+		result.Status = CommuneStatusWritten
+	}
+	return result
+}
+
+// checkpointCommuneResult lưu kết quả cuối của xã/phường vào checkpoint ngay khi có.
+// Job dừng vì ctx bị hủy giữ nguyên trạng thái cũ để lần chạy sau làm lại.
+func (s *OSMService) checkpointCommuneResult(ctx context.Context, cfg CommunePipelineConfig, r CommuneResult) {
+	if cfg.Checkpoint == nil || ctx.Err() != nil {
+		return
+	}
+	switch r.Status {
+	case CommuneStatusWritten, CommuneStatusUnchanged:
+		cfg.Checkpoint.MarkCommune(ctx, r.Job.RelationID, r.Job.Name, StepPublished, nil)
+	case CommuneStatusNotFound, CommuneStatusFailed:
+		cfg.Checkpoint.MarkCommune(ctx, r.Job.RelationID, r.Job.Name, StepFailed, r.Err)
+	}
+}
+
+// communeMetricResult quy trạng thái xử lý về kết quả đối chiếu của metric communes_total
+func communeMetricResult(status string) string {
+	switch status {
+	case CommuneStatusWritten, CommuneStatusUnchanged:
+		return metrics.CommuneMatched
+	case CommuneStatusNotFound:
+		return metrics.CommuneUnmatched
+	case CommuneStatusSkipped:
+		return metrics.CommuneSkipped
+	default:
+		return metrics.CommuneFailed
+	}
+}
+
+// planCommuneResult ghi xã/phường không tìm thấy hoặc lỗi vào plan khi chạy dry-run
+func (s *OSMService) planCommuneResult(r CommuneResult) {
+	if s.plan == nil || (r.Status != CommuneStatusNotFound && r.Status != CommuneStatusFailed) {
+		return
+	}
+	planned := PlannedChange{
+		Level:      6,
+		Table:      tableForLevel(6),
+		Code:       r.MaPhuongXa,
+		Name:       r.Job.Name,
+		MaTT:       r.Job.MaTT,
+		RelationID: r.Job.RelationID,
+		Action:     PlanActionFailed,
+	}
+	if r.Status == CommuneStatusNotFound {
+		planned.Action = PlanActionNotFound
+	}
+	if r.Err != nil {
+		planned.Warning = r.Err.Error()
+	}
+	s.plan.Add(planned)
+}
@@ -0,0 +1,233 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"tool-map/entities"
+	"tool-map/repositories"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// fakeCommuneRepo giữ DM_PHUONG_XA trong bộ nhớ; method không dùng tới trong test sẽ panic qua interface nhúng
+type fakeCommuneRepo struct {
+	repositories.DmPhuongXaRepositoryInterface
+
+	mu   sync.Mutex
+	rows map[string]*entities.DmPhuongXa
+	// polygonWrites đếm số lần ghi POLYGON_DATA theo MA_PHUONG_XA
+	polygonWrites map[string]int
+
+	// nameLookups (nếu có) chặn GetByName cho tới khi đủ số lượt gọi, để các worker fetch cùng thấy dòng chưa gán
+	nameLookups *sync.WaitGroup
+}
+
+func newFakeCommuneRepo(rows ...entities.DmPhuongXa) *fakeCommuneRepo {
+	repo := &fakeCommuneRepo{rows: make(map[string]*entities.DmPhuongXa), polygonWrites: make(map[string]int)}
+	for i := range rows {
+		repo.rows[rows[i].MaPhuongXa] = &rows[i]
+	}
+	return repo
+}
+
+func (r *fakeCommuneRepo) GetByOsmRelationID(ctx context.Context, relationID int64) (*entities.DmPhuongXa, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, row := range r.rows {
+		if row.OsmRelationID != nil && *row.OsmRelationID == relationID {
+			copied := *row
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeCommuneRepo) GetByName(ctx context.Context, name, maTT string) (*entities.DmPhuongXa, error) {
+	if r.nameLookups != nil {
+		r.nameLookups.Done()
+		r.nameLookups.Wait()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, row := range r.rows {
+		if row.TenPhuongXa == name && row.TrucThuocTinh == maTT && row.OsmRelationID == nil {
+			copied := *row
+			return &copied, nil
+		}
+	}
+	return &entities.DmPhuongXa{}, nil
+}
+
+func (r *fakeCommuneRepo) GetByMaPhuongXa(ctx context.Context, maPhuongXa string) (*entities.DmPhuongXa, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if row, ok := r.rows[maPhuongXa]; ok {
+		copied := *row
+		return &copied, nil
+	}
+	return nil, nil
+}
+
+func (r *fakeCommuneRepo) GetAllByMaTT(ctx context.Context, maTT string) ([]entities.DmPhuongXa, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var rows []entities.DmPhuongXa
+	for _, row := range r.rows {
+		if row.TrucThuocTinh == maTT {
+			rows = append(rows, *row)
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].MaPhuongXa < rows[j].MaPhuongXa })
+	return rows, nil
+}
+
+func (r *fakeCommuneRepo) ClaimOsmRelationByMaPhuongXa(ctx context.Context, id string, relationID int64) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	row, ok := r.rows[id]
+	if !ok || (row.OsmRelationID != nil && *row.OsmRelationID != relationID) {
+		return false, nil
+	}
+	row.OsmRelationID = &relationID
+	return true, nil
+}
+
+func (r *fakeCommuneRepo) UpdateDataAddressByMaPhuongXa(ctx context.Context, id string, maxLat, minLat, maxLon, minLon, lonCenter, latCenter *float64) error {
+	return nil
+}
+
+func (r *fakeCommuneRepo) UpdatePolygonDataByMaPhuongXa(ctx context.Context, id string, polygonData *string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.polygonWrites[id]++
+	r.rows[id].Polygon = polygonData
+	return nil
+}
+
+func (r *fakeCommuneRepo) UpdateOsmVersionByMaPhuongXa(ctx context.Context, id string, relationID *int64, version *int, changeset *int64, timestamp, geometryHash *string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	row := r.rows[id]
+	row.OsmRelationID, row.OsmVersion, row.OsmChangeset, row.OsmTimestamp = relationID, version, changeset, timestamp
+	return nil
+}
+
+// fakeProvinceRepo đứng thay DMTT trong các test chỉ ghi xã/phường
+type fakeProvinceRepo struct {
+	repositories.DmTTRepositoryInterface
+}
+
+// startTestRedis trỏ client Redis của package vào một miniredis riêng cho test
+func startTestRedis(t *testing.T) *miniredis.Miniredis {
+	t.Helper()
+	server := miniredis.RunT(t)
+	oldRd, oldCluster, oldPrefix := rd, rdCluster, prefix
+	rd, rdCluster, prefix = redis.NewClient(&redis.Options{Addr: server.Addr()}), nil, ""
+	t.Cleanup(func() {
+		_ = rd.Close()
+		rd, rdCluster, prefix = oldRd, oldCluster, oldPrefix
+	})
+	return server
+}
+
+// relationFullXML là /relation/{id}/full của một xã hình vuông; offset dịch polygon để mỗi relation khác nhau
+func relationFullXML(relationID int64, name string, offset float64) string {
+	var sb strings.Builder
+	sb.WriteString(`<?xml version="1.0" encoding="UTF-8"?><osm version="0.6" generator="test">`)
+	corners := [][2]float64{{21, 105}, {21, 105.1}, {21.1, 105.1}, {21.1, 105}}
+	for i, c := range corners {
+		fmt.Fprintf(&sb, `<node id="%d" version="1" lat="%g" lon="%g"/>`, relationID*10+int64(i), c[0]+offset, c[1]+offset)
+	}
+	fmt.Fprintf(&sb, `<way id="%d" version="1">`, relationID)
+	for _, i := range []int{0, 1, 2, 3, 0} {
+		fmt.Fprintf(&sb, `<nd ref="%d"/>`, relationID*10+int64(i))
+	}
+	sb.WriteString(`</way>`)
+	fmt.Fprintf(&sb, `<relation id="%d" version="3" changeset="7" timestamp="2026-01-01T00:00:00Z">`, relationID)
+	fmt.Fprintf(&sb, `<member type="way" ref="%d" role="outer"/>`, relationID)
+	fmt.Fprintf(&sb, `<tag k="boundary" v="administrative"/><tag k="admin_level" v="6"/><tag k="name" v="%s"/>`, name)
+	sb.WriteString(`</relation></osm>`)
+	return sb.String()
+}
+
+// newTestOSMService tạo service dùng repo giả và OSM API giả trả về các relation trong relations
+func newTestOSMService(t *testing.T, repo repositories.DmPhuongXaRepositoryInterface, relations map[int64]string) *OSMService {
+	t.Helper()
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var id int64
+		if _, err := fmt.Sscanf(r.URL.Path, "/relation/%d/full", &id); err != nil || relations[id] == "" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		_, _ = w.Write([]byte(relations[id]))
+	}))
+	t.Cleanup(api.Close)
+
+	s := NewOSMService()
+	s.client.BaseURL = api.URL
+	s.client.Limiter = nil
+	s.dmPhuongXaRepo = repo
+	s.dmTTRepo = fakeProvinceRepo{}
+	return s
+}
+
+func TestRunCommunePipelineSameNameClaimsRowOnce(t *testing.T) {
+	startTestRedis(t)
+	repo := newFakeCommuneRepo(entities.DmPhuongXa{MaPhuongXa: "00001", TenPhuongXa: "Xã A", TrucThuocTinh: "01"})
+	repo.nameLookups = &sync.WaitGroup{}
+	repo.nameLookups.Add(2)
+	s := newTestOSMService(t, repo, map[int64]string{
+		101: relationFullXML(101, "Xã A", 0),
+		102: relationFullXML(102, "Xã A", 1),
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	jobs := []CommuneJob{
+		{RelationID: 101, Name: "Xã A", AdminLevel: 6, MaTT: "01"},
+		{RelationID: 102, Name: "Xã A", AdminLevel: 6, MaTT: "01"},
+	}
+	cfg := CommunePipelineConfig{FetchWorkers: 2, WriteWorkers: 2, ChangelogPath: filepath.Join(t.TempDir(), "changelog.jsonl")}
+	var results []CommuneResult
+	s.RunCommunePipeline(ctx, cfg, jobs, func(r CommuneResult) { results = append(results, r) })
+
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2", len(results))
+	}
+	statuses := map[string]int{}
+	var winner int64
+	for _, r := range results {
+		statuses[r.Status]++
+		if r.MaPhuongXa != "00001" {
+			t.Errorf("relation %d resolved to %q, want both fetches to see the unclaimed row", r.Job.RelationID, r.MaPhuongXa)
+		}
+		if r.Status == CommuneStatusWritten {
+			winner = r.Job.RelationID
+		}
+	}
+	if statuses[CommuneStatusWritten] != 1 || statuses[CommuneStatusNotFound] != 1 {
+		t.Fatalf("statuses = %v, want one written and one not_found", statuses)
+	}
+
+	row, _ := repo.GetByMaPhuongXa(ctx, "00001")
+	if row.OsmRelationID == nil || *row.OsmRelationID != winner {
+		t.Errorf("OSM_RELATION_ID = %v, want %d", row.OsmRelationID, winner)
+	}
+	if repo.polygonWrites["00001"] != 1 {
+		t.Errorf("POLYGON_DATA written %d times, want 1", repo.polygonWrites["00001"])
+	}
+	// Polygon đã lưu là của relation thắng (relation 102 bị dịch sang kinh độ 106)
+	if row.Polygon == nil || strings.Contains(*row.Polygon, "106") != (winner == 102) {
+		t.Errorf("polygon = %v, want the geometry of relation %d", row.Polygon, winner)
+	}
+}
@@ -0,0 +1,21 @@
+package services
+
+import (
+	"tool-map/config"
+	"tool-map/models"
+)
+
+// appConfig là cấu hình dùng cho Redis, MinIO và OSM client; main gọi SetConfig sau khi load
+var appConfig = config.Default()
+
+// SetConfig đặt cấu hình cho package services và ánh xạ admin_level OSM của models.
+// Phải gọi trước InitRedis, InitMinioClient và NewOSMService.
+func SetConfig(cfg *config.Config) {
+	appConfig = cfg
+	models.SetOSMAdminLevels(cfg.AdminLevels.Province, cfg.AdminLevels.Commune)
+}
+
+// polygonBucket là bucket MinIO chứa polygon, dùng chung cho upload và download
+func polygonBucket() string {
+	return appConfig.MinIO.Bucket
+}
@@ -0,0 +1,240 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log/slog"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// geocodeChunkSize là số dòng mỗi lô gửi cho worker, đủ lớn để giảm chi phí channel
+	geocodeChunkSize = 1024
+	// geocodeProgressRows là số dòng giữa hai lần log tiến độ
+	geocodeProgressRows = 1_000_000
+)
+
+// GeocodeConfig cấu hình GeocodeCSV
+type GeocodeConfig struct {
+	Workers   int    // số worker point-in-polygon, mặc định số CPU
+	Comma     rune   // ký tự phân cách, mặc định ','
+	LatColumn string // tên cột vĩ độ, mặc định "lat"
+	LonColumn string // tên cột kinh độ, mặc định "lon"
+}
+
+// WithDefaults điền giá trị mặc định cho các trường chưa cấu hình
+func (cfg GeocodeConfig) WithDefaults() GeocodeConfig {
+	if cfg.Workers <= 0 {
+		cfg.Workers = runtime.NumCPU()
+	}
+	if cfg.Comma == 0 {
+		cfg.Comma = ','
+	}
+	if cfg.LatColumn == "" {
+		cfg.LatColumn = "lat"
+	}
+	if cfg.LonColumn == "" {
+		cfg.LonColumn = "lon"
+	}
+	return cfg
+}
+
+// GeocodeStats đếm kết quả của GeocodeCSV
+type GeocodeStats struct {
+	Rows      int // số dòng dữ liệu (không tính header)
+	Matched   int // có MA_PHUONG_XA
+	Province  int // chỉ xác định được MATT (điểm ngoài mọi xã/phường hoặc chỉ mục chỉ có tỉnh)
+	Unmatched int // tọa độ hợp lệ nhưng không thuộc đơn vị nào
+	Invalid   int // lat/lon không đọc được hoặc ngoài phạm vi
+}
+
+// geocodeChunk là một lô dòng CSV; seq giữ thứ tự để ghi ra đúng thứ tự đọc vào
+type geocodeChunk struct {
+	seq     int
+	records [][]string
+	stats   GeocodeStats
+}
+
+// GeocodeCSV đọc CSV có header từ r, tra cứu xã/phường của từng dòng trong index bằng một pool worker
+// và ghi ra w các dòng theo đúng thứ tự kèm hai cột MATT, MA_PHUONG_XA. Dữ liệu được xử lý theo luồng
+// nên file lớn không cần nạp hết vào bộ nhớ.
+func GeocodeCSV(ctx context.Context, index *PolygonIndex, r io.Reader, w io.Writer, cfg GeocodeConfig) (GeocodeStats, error) {
+	cfg = cfg.WithDefaults()
+	var stats GeocodeStats
+
+	reader := csv.NewReader(r)
+	reader.Comma = cfg.Comma
+	reader.FieldsPerRecord = -1
+	writer := csv.NewWriter(w)
+	writer.Comma = cfg.Comma
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return stats, fmt.Errorf("file CSV rỗng")
+	}
+	if err != nil {
+		return stats, fmt.Errorf("lỗi khi đọc header CSV: %w", err)
+	}
+	latColumn, lonColumn := -1, -1
+	for i, name := range header {
+		name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+		switch {
+		case strings.EqualFold(name, cfg.LatColumn):
+			latColumn = i
+		case strings.EqualFold(name, cfg.LonColumn):
+			lonColumn = i
+		}
+	}
+	if latColumn < 0 || lonColumn < 0 {
+		return stats, fmt.Errorf("header CSV thiếu cột %s hoặc %s", cfg.LatColumn, cfg.LonColumn)
+	}
+	if err := writer.Write(append(header, "MATT", "MA_PHUONG_XA")); err != nil {
+		return stats, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	jobs := make(chan *geocodeChunk, cfg.Workers)
+	done := make(chan *geocodeChunk, cfg.Workers)
+
+	// Reader: chia file thành các lô
+	readErr := make(chan error, 1)
+	go func() {
+		defer close(jobs)
+		seq := 0
+		for {
+			chunk := &geocodeChunk{seq: seq, records: make([][]string, 0, geocodeChunkSize)}
+			var err error
+			for len(chunk.records) < geocodeChunkSize {
+				var record []string
+				record, err = reader.Read()
+				if err != nil {
+					break
+				}
+				chunk.records = append(chunk.records, record)
+			}
+			if len(chunk.records) > 0 {
+				select {
+				case jobs <- chunk:
+				case <-ctx.Done():
+					readErr <- ctx.Err()
+					return
+				}
+				seq++
+			}
+			if err == io.EOF {
+				readErr <- nil
+				return
+			}
+			if err != nil {
+				readErr <- fmt.Errorf("lỗi khi đọc CSV: %w", err)
+				return
+			}
+		}
+	}()
+
+	// Worker: tra cứu từng dòng của lô
+	var workers sync.WaitGroup
+	for i := 0; i < cfg.Workers; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for chunk := range jobs {
+				for j, record := range chunk.records {
+					chunk.records[j] = append(record, geocodeRecord(index, record, latColumn, lonColumn, &chunk.stats)...)
+				}
+				select {
+				case done <- chunk:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		workers.Wait()
+		close(done)
+	}()
+
+	// Writer: ghi các lô theo đúng thứ tự seq
+	pending := make(map[int]*geocodeChunk)
+	next := 0
+	var writeErr error
+	for chunk := range done {
+		if writeErr != nil {
+			continue
+		}
+		pending[chunk.seq] = chunk
+		for {
+			ready, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			if err := writer.WriteAll(ready.records); err != nil {
+				writeErr = fmt.Errorf("lỗi khi ghi CSV: %w", err)
+				cancel()
+				break
+			}
+			before := stats.Rows
+			stats.add(ready.stats)
+			if stats.Rows/geocodeProgressRows > before/geocodeProgressRows {
+				slog.Info("Tiến độ geocode", "rows", stats.Rows, "matched", stats.Matched)
+			}
+		}
+	}
+
+	if writeErr != nil {
+		return stats, writeErr
+	}
+	if err := ctx.Err(); err != nil {
+		return stats, err
+	}
+	if err := <-readErr; err != nil {
+		return stats, err
+	}
+	writer.Flush()
+	return stats, writer.Error()
+}
+
+// geocodeRecord trả về giá trị hai cột MATT, MA_PHUONG_XA cho một dòng và cập nhật thống kê
+func geocodeRecord(index *PolygonIndex, record []string, latColumn, lonColumn int, stats *GeocodeStats) []string {
+	stats.Rows++
+	if latColumn >= len(record) || lonColumn >= len(record) {
+		stats.Invalid++
+		return []string{"", ""}
+	}
+	lat, latErr := strconv.ParseFloat(strings.TrimSpace(record[latColumn]), 64)
+	lon, lonErr := strconv.ParseFloat(strings.TrimSpace(record[lonColumn]), 64)
+	if latErr != nil || lonErr != nil || !(lat >= -90 && lat <= 90) || !(lon >= -180 && lon <= 180) {
+		stats.Invalid++
+		return []string{"", ""}
+	}
+
+	commune, province := index.Locate(lat, lon)
+	switch {
+	case commune != nil:
+		stats.Matched++
+		return []string{commune.MaTT, commune.Code}
+	case province != nil:
+		stats.Province++
+		return []string{province.Code, ""}
+	default:
+		stats.Unmatched++
+		return []string{"", ""}
+	}
+}
+
+func (s *GeocodeStats) add(other GeocodeStats) {
+	s.Rows += other.Rows
+	s.Matched += other.Matched
+	s.Province += other.Province
+	s.Unmatched += other.Unmatched
+	s.Invalid += other.Invalid
+}
@@ -0,0 +1,313 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"tool-map/entities"
+	"tool-map/models"
+)
+
+const (
+	FormatGeoJSON = "geojson"
+	FormatJSON    = "json"
+	FormatCSV     = "csv"
+	FormatIndex   = "index" // snapshot nhị phân cho LoadPolygonIndex
+)
+
+// UnitGeometry là hình học của một đơn vị hành chính (tỉnh hoặc xã/phường)
+type UnitGeometry struct {
+	Level      int            `json:"level"` // 4 = tỉnh/thành phố, 6 = xã/phường
+	Code       string         `json:"code"`  // MATT hoặc MA_PHUONG_XA (rỗng khi build từ file OSM)
+	Name       string         `json:"name"`
+	MaTT       string         `json:"maTT,omitempty"`
+	RelationID *int64         `json:"osmRelationId,omitempty"`
+	LatCenter  *float64       `json:"latCenter,omitempty"`
+	LonCenter  *float64       `json:"lonCenter,omitempty"`
+	Polygons   [][][2]float64 `json:"polygons"` // mỗi polygon là một vòng [lat, lon] như lưu trong DB
+}
+
+// VertexCount trả về tổng số đỉnh của mọi polygon
+func (u *UnitGeometry) VertexCount() int {
+	count := 0
+	for _, ring := range u.Polygons {
+		count += len(ring)
+	}
+	return count
+}
+
+// ParsePolygonData đọc một vòng polygon JSON [[lat, lon], ...] (định dạng POLYGON_DATA của xã/phường)
+func ParsePolygonData(data string) ([][2]float64, error) {
+	var ring [][2]float64
+	if err := json.Unmarshal([]byte(data), &ring); err != nil {
+		return nil, fmt.Errorf("failed to parse polygon data: %w", err)
+	}
+	return ring, nil
+}
+
+// BuildUnitGeometry dựng polygon cho relation từ tài liệu OSM (file, API hoặc boundary store), không ghi DB
+func (s *OSMService) BuildUnitGeometry(osm *models.OSM, relationID int64) (*UnitGeometry, error) {
+	relation, found := osm.FindRelationByID(relationID)
+	if !found {
+		return nil, fmt.Errorf("relation %d không có trong dữ liệu OSM", relationID)
+	}
+
+	result, err := s.ProcessOSM(osm, relationID)
+	if err != nil {
+		return nil, err
+	}
+	polygons, err := s.CreatePolygonFromWaysAndNodes(result.Ways, result.Nodes)
+	if err != nil {
+		return nil, fmt.Errorf("lỗi khi tạo polygon cho relation %d: %w", relationID, err)
+	}
+
+	unit := &UnitGeometry{
+		Level:      models.UnitLevel(relation.GetAdminLevel()),
+		Name:       relation.GetName(),
+		RelationID: &relation.ID,
+	}
+	if len(result.CenterPoints) > 0 {
+		unit.LatCenter = &result.CenterPoints[0].Lat
+		unit.LonCenter = &result.CenterPoints[0].Lon
+	}
+	unit.Polygons = toRings(polygons)
+	return unit, nil
+}
+
+// toRings chuyển polygon dạng [][][]float64 (kết quả CreatePolygonFromWaysAndNodes) sang các vòng [lat, lon]
+func toRings(polygons [][][]float64) [][][2]float64 {
+	rings := make([][][2]float64, 0, len(polygons))
+	for _, polygon := range polygons {
+		ring := make([][2]float64, 0, len(polygon))
+		for _, point := range polygon {
+			if len(point) >= 2 {
+				ring = append(ring, [2]float64{point[0], point[1]})
+			}
+		}
+		rings = append(rings, ring)
+	}
+	return rings
+}
+
+// CommuneGeometries lấy hình học các xã/phường đã có polygon của tỉnh maTT từ DB
+func (s *OSMService) CommuneGeometries(ctx context.Context, maTT string) ([]UnitGeometry, error) {
+	if s.dmPhuongXaRepo == nil {
+		return nil, fmt.Errorf("database repositories not initialized, use NewOSMServiceWithDB()")
+	}
+
+	rows, err := s.dmPhuongXaRepo.GetWithPolygonByMaTT(ctx, maTT)
+	if err != nil {
+		return nil, fmt.Errorf("không thể lấy xã/phường của tỉnh %s: %w", maTT, err)
+	}
+
+	units := make([]UnitGeometry, 0, len(rows))
+	for _, row := range rows {
+		ring, err := ParsePolygonData(*row.Polygon)
+		if err != nil {
+			slog.Warn("Bỏ qua xã/phường", "commune", row.MaPhuongXa, "error", err)
+			continue
+		}
+		units = append(units, UnitGeometry{
+			Level:      6,
+			Code:       row.MaPhuongXa,
+			Name:       row.TenPhuongXa,
+			MaTT:       row.TrucThuocTinh,
+			RelationID: row.OsmRelationID,
+			LatCenter:  row.LatCenter,
+			LonCenter:  row.LonCenter,
+			Polygons:   [][][2]float64{ring},
+		})
+	}
+	return units, nil
+}
+
+// ProvinceGeometries lấy hình học các tỉnh theo mã (rỗng = tất cả); polygon tỉnh được tải từ MinIO
+func (s *OSMService) ProvinceGeometries(ctx context.Context, maTTs []string) ([]UnitGeometry, error) {
+	if s.dmTTRepo == nil {
+		return nil, fmt.Errorf("database repositories not initialized, use NewOSMServiceWithDB()")
+	}
+
+	var rows []entities.DmTT
+	if len(maTTs) == 0 {
+		all, err := s.dmTTRepo.GetAll(ctx)
+		if err != nil {
+			return nil, err
+		}
+		rows = all
+	} else {
+		for _, maTT := range maTTs {
+			tt, err := s.dmTTRepo.GetByMaTT(ctx, maTT)
+			if err != nil {
+				return nil, err
+			}
+			if tt == nil {
+				return nil, fmt.Errorf("không tìm thấy tỉnh/thành phố %s", maTT)
+			}
+			rows = append(rows, *tt)
+		}
+	}
+
+	units := make([]UnitGeometry, 0, len(rows))
+	for i := range rows {
+		unit, err := s.provinceGeometry(ctx, &rows[i])
+		if err != nil {
+			slog.Warn("Bỏ qua tỉnh", "ma_tt", rows[i].MaTT, "error", err)
+			continue
+		}
+		units = append(units, *unit)
+	}
+	return units, nil
+}
+
+// provinceGeometry tải các polygon của tỉnh từ danh sách URL MinIO lưu trong POLYGON_DATA
+func (s *OSMService) provinceGeometry(ctx context.Context, tt *entities.DmTT) (*UnitGeometry, error) {
+	if tt.Polygon == nil || *tt.Polygon == "" {
+		return nil, fmt.Errorf("chưa có polygon")
+	}
+
+	var urls []string
+	if err := json.Unmarshal([]byte(*tt.Polygon), &urls); err != nil {
+		return nil, fmt.Errorf("POLYGON_DATA không phải danh sách URL: %w", err)
+	}
+
+	unit := &UnitGeometry{
+		Level:      4,
+		Code:       tt.MaTT,
+		Name:       tt.TenTT,
+		RelationID: tt.OsmRelationID,
+		LatCenter:  tt.LatCenter,
+		LonCenter:  tt.LonCenter,
+	}
+	for _, url := range urls {
+		data, err := DownloadPolygonData(ctx, url)
+		if err != nil {
+			return nil, err
+		}
+		ring, err := ParsePolygonData(string(data))
+		if err != nil {
+			return nil, err
+		}
+		unit.Polygons = append(unit.Polygons, ring)
+	}
+	return unit, nil
+}
+
+// WriteGeometries ghi danh sách đơn vị theo format geojson, json, csv (không kèm hình học)
+// hoặc index (snapshot nhị phân của PolygonIndex)
+func WriteGeometries(w io.Writer, format string, units []UnitGeometry) error {
+	switch format {
+	case FormatGeoJSON, "":
+		encoder := json.NewEncoder(w)
+		return encoder.Encode(ToFeatureCollection(units))
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(units)
+	case FormatCSV:
+		writer := csv.NewWriter(w)
+		_ = writer.Write([]string{"level", "code", "name", "ma_tt", "osm_relation_id", "lat_center", "lon_center", "polygons", "vertices"})
+		for i := range units {
+			unit := &units[i]
+			_ = writer.Write([]string{
+				strconv.Itoa(unit.Level),
+				unit.Code,
+				unit.Name,
+				unit.MaTT,
+				formatOptionalInt(unit.RelationID),
+				formatOptionalFloat(unit.LatCenter),
+				formatOptionalFloat(unit.LonCenter),
+				strconv.Itoa(len(unit.Polygons)),
+				strconv.Itoa(unit.VertexCount()),
+			})
+		}
+		writer.Flush()
+		return writer.Error()
+	case FormatIndex:
+		return writeIndexSnapshot(w, units)
+	default:
+		return fmt.Errorf("format '%s' không được hỗ trợ (geojson, json, csv, index)", format)
+	}
+}
+
+// GeoJSONFeatureCollection là FeatureCollection GeoJSON (RFC 7946)
+type GeoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []GeoJSONFeature `json:"features"`
+}
+
+// GeoJSONFeature là một Feature có hình học MultiPolygon
+type GeoJSONFeature struct {
+	Type       string          `json:"type"`
+	Properties map[string]any  `json:"properties"`
+	Geometry   GeoJSONGeometry `json:"geometry"`
+}
+
+// GeoJSONGeometry là hình học MultiPolygon, tọa độ [lon, lat]
+type GeoJSONGeometry struct {
+	Type        string           `json:"type"`
+	Coordinates [][][][2]float64 `json:"coordinates"`
+}
+
+// ToFeatureCollection chuyển các đơn vị sang GeoJSON; tọa độ đổi từ [lat, lon] sang [lon, lat]
+// và mỗi vòng được đóng lại nếu điểm đầu khác điểm cuối
+func ToFeatureCollection(units []UnitGeometry) GeoJSONFeatureCollection {
+	collection := GeoJSONFeatureCollection{Type: "FeatureCollection", Features: make([]GeoJSONFeature, 0, len(units))}
+	for i := range units {
+		collection.Features = append(collection.Features, units[i].ToFeature())
+	}
+	return collection
+}
+
+// ToFeature chuyển đơn vị sang một Feature GeoJSON
+func (u *UnitGeometry) ToFeature() GeoJSONFeature {
+	properties := map[string]any{
+		"level": u.Level,
+		"code":  u.Code,
+		"name":  u.Name,
+	}
+	if u.MaTT != "" {
+		properties["maTT"] = u.MaTT
+	}
+	if u.RelationID != nil {
+		properties["osmRelationId"] = *u.RelationID
+	}
+	if u.LatCenter != nil && u.LonCenter != nil {
+		properties["latCenter"] = *u.LatCenter
+		properties["lonCenter"] = *u.LonCenter
+	}
+
+	geometry := GeoJSONGeometry{Type: "MultiPolygon", Coordinates: make([][][][2]float64, 0, len(u.Polygons))}
+	for _, ring := range u.Polygons {
+		if len(ring) == 0 {
+			continue
+		}
+		coords := make([][2]float64, 0, len(ring)+1)
+		for _, point := range ring {
+			coords = append(coords, [2]float64{point[1], point[0]})
+		}
+		if coords[0] != coords[len(coords)-1] {
+			coords = append(coords, coords[0])
+		}
+		geometry.Coordinates = append(geometry.Coordinates, [][][2]float64{coords})
+	}
+
+	return GeoJSONFeature{Type: "Feature", Properties: properties, Geometry: geometry}
+}
+
+func formatOptionalInt(v *int64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatInt(*v, 10)
+}
+
+func formatOptionalFloat(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*v, 'f', 7, 64)
+}
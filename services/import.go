@@ -0,0 +1,218 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+	"tool-map/models"
+)
+
+// ImportRelation fetch, build polygon và lưu DB/Redis/MinIO cho một relation tỉnh và các xã/phường con
+// (luồng của lệnh publish và job import). ctx mang deadline riêng của relation nên mọi lời gọi
+// OSM/DB/Redis/MinIO bên trong đều bị hủy theo. Trả về tên tỉnh, và lỗi khi tỉnh hoặc một xã/phường con
+// không publish được.
+func (s *OSMService) ImportRelation(ctx context.Context, relationID int64, withCommunes bool, pipelineCfg CommunePipelineConfig) (string, error) {
+	checkpoint := pipelineCfg.Checkpoint
+	report := s.Report()
+	logger := slog.With("relation", relationID)
+	logger.Info("Đang xử lý relation")
+
+	started := time.Now()
+	result, err := s.FetchAndProcessRelation(ctx, relationID)
+	report.Stage(relationID, StageFetch, started)
+	if err != nil {
+		return "", fmt.Errorf("lỗi khi xử lý dữ liệu OSM (ID %d): %w", relationID, err)
+	}
+	checkpoint.MarkRelation(ctx, relationID, "", StepFetched, nil)
+
+	var provinceName string
+	var provinceErr error
+	// Nếu có provinces, thao tác thêm cho từng commune trong mỗi province
+	if result.Administrative != nil {
+		if provinces, exists := result.Administrative["provinces"]; exists && len(provinces) > 0 {
+			for _, province := range provinces {
+				if province.Boundary == "" {
+					continue
+				}
+				if strings.Contains(province.Name, "Thành phố") || strings.Contains(province.Name, "Tỉnh") {
+					province.Name = strings.ReplaceAll(province.Name, "Thành phố ", "")
+					province.Name = strings.ReplaceAll(province.Name, "Tỉnh ", "")
+					province.Name = strings.TrimSpace(province.Name)
+				}
+				name := province.Name
+				provinceName = name
+				adminLevel := models.UnitLevel(province.AdminLevel)
+				report.SetProvince(relationID, name, "")
+				started := time.Now()
+
+				// Chỉ xử lý lại khi hình học thực sự thay đổi so với lần import trước
+				change, err := s.DetectChange(ctx, "", name, adminLevel, result)
+				if err != nil {
+					logger.Warn("Không xác định được thay đổi", "province", name, "error", err)
+				} else {
+					logger.Info("Trạng thái thay đổi", "province", name, "admin_level", adminLevel, "status", change.Status)
+					if !change.NeedsReprocess() {
+						s.recordImport(ctx, change, result, pipelineCfg.ChangelogPath)
+						report.Stage(relationID, StageProvince, started)
+						continue
+					}
+				}
+
+				// Ghi theo MATT mà DetectChange đã tra được để không tra lại theo tên
+				var maTT string
+				if change != nil {
+					maTT = change.Code
+				}
+				err = s.PublishProvince(ctx, relationID, maTT, name, adminLevel, result)
+				if err == nil {
+					s.recordImport(ctx, change, result, pipelineCfg.ChangelogPath)
+				}
+				report.Stage(relationID, StageProvince, started)
+				if err != nil {
+					provinceErr = fmt.Errorf("lỗi khi lưu province '%s': %w", name, err)
+				}
+			}
+		}
+	}
+
+	var communesErr error
+	if withCommunes {
+		checkpoint.MarkRelation(ctx, relationID, provinceName, StepBuilt, nil)
+		communesErr = s.importCommunes(ctx, relationID, provinceName, pipelineCfg)
+	}
+
+	logger.Info("Đã xử lý relation",
+		"province", provinceName,
+		"nodes", len(result.Nodes),
+		"ways", len(result.Ways),
+		"relations", len(result.Relations),
+		"center_points", len(result.CenterPoints))
+	if provinceErr != nil {
+		return provinceName, provinceErr
+	}
+	return provinceName, communesErr
+}
+
+// importCommunes dựng cây hành chính của tỉnh, đối chiếu với DM_PHUONG_XA và publish các xã/phường con.
+// Trả về lỗi nếu không xử lý được tỉnh hoặc có xã/phường không publish được.
+func (s *OSMService) importCommunes(ctx context.Context, relationID int64, provinceName string, pipelineCfg CommunePipelineConfig) error {
+	if s.dmTTRepo == nil {
+		return fmt.Errorf("database repositories not initialized, use NewOSMServiceWithDB()")
+	}
+	TinhThanhInDb, err := s.dmTTRepo.GetByName(ctx, provinceName)
+	if err != nil {
+		return fmt.Errorf("lỗi khi lấy dữ liệu tỉnh/thành phố từ database: %w", err)
+	}
+	if TinhThanhInDb == nil {
+		return fmt.Errorf("không tìm thấy tỉnh/thành phố '%s' trong database", provinceName)
+	}
+	report := s.Report()
+	report.SetProvince(relationID, TinhThanhInDb.TenTT, TinhThanhInDb.MaTT)
+
+	// Dựng cây tỉnh → (huyện) → xã/phường từ các member subarea thay vì dựa vào /full
+	started := time.Now()
+	tree, err := s.BuildAdminTree(ctx, relationID)
+	if err != nil {
+		return fmt.Errorf("lỗi khi dựng cây hành chính cho relation %d: %w", relationID, err)
+	}
+	logger := slog.With("relation", relationID, "ma_tt", TinhThanhInDb.MaTT)
+	// Dry-run vẫn đối chiếu để log nhưng không ghi file cây hành chính
+	adminTreeDir := appConfig.Storage.AdminTreeDir
+	if !s.DryRun() {
+		if path, err := SaveAdminTree(tree, adminTreeDir); err != nil {
+			logger.Error("Lỗi khi lưu cây hành chính", "error", err)
+		} else {
+			logger.Debug("Đã lưu cây hành chính", "path", path)
+		}
+	}
+	if coverage, err := s.CheckTreeCoverage(ctx, tree, TinhThanhInDb.MaTT); err != nil {
+		logger.Error("Lỗi khi đối chiếu cây hành chính với DM_PHUONG_XA", "error", err)
+	} else {
+		logger.Info("Đối chiếu DM_PHUONG_XA",
+			"matched", len(coverage.Matched),
+			"missing_in_osm", len(coverage.MissingInOSM),
+			"missing_in_db", len(coverage.MissingInDB))
+		if !s.DryRun() {
+			if _, err := SaveAdminTreeCoverage(coverage, adminTreeDir); err != nil {
+				logger.Error("Lỗi khi lưu kết quả đối chiếu", "error", err)
+			}
+		}
+	}
+	report.Stage(relationID, StageAdminTree, started)
+
+	// Xử lý các xã/phường song song: fetch (chịu rate limit OSM) và ghi DB/Redis ở hai stage riêng
+	communes := tree.Communes()
+	jobs := make([]CommuneJob, 0, len(communes))
+	for _, commune := range communes {
+		jobs = append(jobs, CommuneJob{
+			RelationID: commune.RelationID,
+			Name:       commune.Name,
+			AdminLevel: models.UnitLevel(commune.AdminLevel),
+			MaTT:       TinhThanhInDb.MaTT,
+		})
+	}
+	logger.Info("Xử lý xã/phường", "communes", len(jobs), "fetch_workers", pipelineCfg.FetchWorkers, "write_workers", pipelineCfg.WriteWorkers)
+	pipelineCfg.Checkpoint.MarkCommunesPending(ctx, jobs)
+	if pipelineCfg.OnCommunes != nil {
+		pipelineCfg.OnCommunes(relationID, TinhThanhInDb.MaTT, jobs)
+	}
+	failed := 0
+	started = time.Now()
+	s.RunCommunePipeline(ctx, pipelineCfg, jobs, func(r CommuneResult) {
+		report.AddCommune(relationID, r)
+		if pipelineCfg.OnCommune != nil {
+			pipelineCfg.OnCommune(relationID, r)
+		}
+		if r.Status == CommuneStatusFailed || r.Status == CommuneStatusNotFound {
+			failed++
+		}
+		attrs := []any{
+			"commune", r.Job.Name,
+			"commune_relation", r.Job.RelationID,
+			"ma_phuong_xa", r.MaPhuongXa,
+			"status", r.Status,
+			"seq", r.Job.Seq + 1,
+			"total", len(jobs),
+		}
+		if r.Err != nil {
+			logger.Error("Xã/phường lỗi", append(attrs, "error", r.Err)...)
+			return
+		}
+		logger.Debug("Xã/phường", append(attrs, "fetch", r.FetchDuration.Round(time.Millisecond), "write", r.WriteDuration.Round(time.Millisecond))...)
+	})
+	report.Stage(relationID, StageCommunes, started)
+	if failed > 0 {
+		return fmt.Errorf("%d/%d xã/phường không publish được", failed, len(jobs))
+	}
+	return ctx.Err()
+}
+
+// recordImport lưu phiên bản OSM đã import vào dòng DM và ghi changelog nếu có thay đổi
+func (s *OSMService) recordImport(ctx context.Context, change *UnitChange, result *models.OSMProcessingResult, changelogPath string) {
+	if err := s.RecordImport(ctx, change, result, changelogPath); err != nil {
+		slog.Error("Lỗi khi ghi nhận import", "error", err)
+	}
+}
+
+// ResolveProvinceRelation tra OSM_RELATION_ID của tỉnh theo MATT hoặc tên trong DMTT
+func (s *OSMService) ResolveProvinceRelation(ctx context.Context, province string) (int64, error) {
+	if s.dmTTRepo == nil {
+		return 0, fmt.Errorf("database repositories not initialized, use NewOSMServiceWithDB()")
+	}
+	tt, err := s.dmTTRepo.GetByMaTT(ctx, province)
+	if err == nil && tt == nil {
+		tt, err = s.dmTTRepo.GetByName(ctx, province)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("lỗi khi tra tỉnh '%s': %w", province, err)
+	}
+	if tt == nil {
+		return 0, fmt.Errorf("tỉnh '%s' trong DMTT: %w", province, ErrUnitNotFound)
+	}
+	if tt.OsmRelationID == nil {
+		return 0, fmt.Errorf("tỉnh '%s' (%s) chưa có OSM_RELATION_ID", tt.TenTT, tt.MaTT)
+	}
+	return *tt.OsmRelationID, nil
+}
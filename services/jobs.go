@@ -0,0 +1,520 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+	"tool-map/metrics"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	JobStatusQueued    = "queued"
+	JobStatusRunning   = "running"
+	JobStatusSucceeded = "succeeded"
+	JobStatusFailed    = "failed"
+	JobStatusCanceled  = "canceled"
+)
+
+const (
+	// jobQueueSize là số job tối đa đang chờ trên một instance
+	jobQueueSize = 100
+	// jobLeaseTTL là TTL khóa relation của job, được gia hạn trong lúc chạy (xem LeaseRelation)
+	jobLeaseTTL = 2 * time.Minute
+	// jobRetention là thời gian giữ trạng thái job (trong bộ nhớ và Redis) sau khi tạo
+	jobRetention = 7 * 24 * time.Hour
+	// jobSaveInterval là khoảng tối thiểu giữa hai lần ghi tiến độ xã/phường lên Redis
+	jobSaveInterval = 2 * time.Second
+	// jobCancelPoll là chu kỳ kiểm tra yêu cầu hủy gửi tới instance khác
+	jobCancelPoll = 5 * time.Second
+
+	// redisJobKey là key lưu trạng thái job (JSON) để instance nào cũng trả lời được GET /jobs/{id}
+	redisJobKey = "tool_map:job:%s"
+	// redisJobCancelKey đánh dấu job cần hủy khi yêu cầu hủy tới instance không chạy job đó
+	redisJobCancelKey = "tool_map:job:%s:cancel"
+)
+
+var (
+	// ErrJobNotFound là lỗi khi không có job với id đã cho
+	ErrJobNotFound = errors.New("không tìm thấy job")
+	// ErrInvalidJob là lỗi khi yêu cầu tạo job không hợp lệ
+	ErrInvalidJob = errors.New("yêu cầu job không hợp lệ")
+	// ErrJobFinished là lỗi khi hủy job đã kết thúc
+	ErrJobFinished = errors.New("job đã kết thúc")
+	// ErrJobQueueFull là lỗi khi hàng đợi job đã đầy
+	ErrJobQueueFull = errors.New("hàng đợi job đã đầy")
+)
+
+// ImportJobRequest là yêu cầu import lại các tỉnh theo relation ID và/hoặc MATT/tên tỉnh
+type ImportJobRequest struct {
+	RelationIDs []int64  `json:"relationIds,omitempty"`
+	Provinces   []string `json:"provinces,omitempty"`
+	Communes    *bool    `json:"communes,omitempty"` // xử lý cả xã/phường con, mặc định true
+}
+
+// Job là một lần import chạy nền qua API quản trị
+type Job struct {
+	ID              string           `json:"id"`
+	Status          string           `json:"status"`
+	Request         ImportJobRequest `json:"request"`
+	Instance        string           `json:"instance"` // host:pid của instance chạy job
+	CreatedAt       time.Time        `json:"createdAt"`
+	StartedAt       *time.Time       `json:"startedAt,omitempty"`
+	FinishedAt      *time.Time       `json:"finishedAt,omitempty"`
+	CancelRequested bool             `json:"cancelRequested,omitempty"`
+	Error           string           `json:"error,omitempty"`
+	Provinces       []*JobProvince   `json:"provinces"`
+}
+
+// JobProvince là tiến độ một relation tỉnh của job. Status là pending, running hoặc một ReportStatus*.
+type JobProvince struct {
+	RelationID    int64        `json:"relationId"`
+	Name          string       `json:"name,omitempty"`
+	MaTT          string       `json:"maTT,omitempty"`
+	Status        string       `json:"status"`
+	Error         string       `json:"error,omitempty"`
+	CommunesTotal int          `json:"communesTotal"`
+	CommunesDone  int          `json:"communesDone"`
+	Communes      []JobCommune `json:"communes,omitempty"`
+}
+
+// JobCommune là kết quả một xã/phường của job; Status là pending hoặc một CommuneStatus*
+type JobCommune struct {
+	RelationID int64  `json:"relationId"`
+	Name       string `json:"name"`
+	MaPhuongXa string `json:"maPhuongXa,omitempty"`
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+}
+
+// finished cho biết job đã kết thúc
+func (j *Job) finished() bool {
+	return j.Status == JobStatusSucceeded || j.Status == JobStatusFailed || j.Status == JobStatusCanceled
+}
+
+// JobQueue chạy tuần tự các job import trên instance hiện tại bằng luồng của lệnh publish (ImportRelation).
+// Mỗi relation được khóa qua LeaseRelation nên nhiều instance cùng chạy thì một tỉnh chỉ được import ở một nơi;
+// tỉnh đang bị khóa được đánh dấu skipped. Trạng thái job được ghi lên Redis (nếu có) để instance khác đọc
+// và yêu cầu hủy được.
+type JobQueue struct {
+	service  *OSMService
+	instance string
+	queue    chan *Job
+
+	mu          sync.Mutex
+	jobs        map[string]*Job
+	cancels     map[string]context.CancelFunc // job đang chạy trên instance này
+	lastSaved   time.Time
+	onPublished []func(ctx context.Context)
+}
+
+// NewJobQueue tạo hàng đợi job; gọi Run để bắt đầu xử lý
+func (s *OSMService) NewJobQueue() *JobQueue {
+	hostname, _ := os.Hostname()
+	return &JobQueue{
+		service:  s,
+		instance: fmt.Sprintf("%s:%d", hostname, os.Getpid()),
+		queue:    make(chan *Job, jobQueueSize),
+		jobs:     make(map[string]*Job),
+		cancels:  make(map[string]context.CancelFunc),
+	}
+}
+
+// OnPublished đăng ký fn được gọi sau mỗi job (trên instance này) publish được ít nhất một tỉnh, kể cả khi
+// job có tỉnh lỗi, ví dụ để dựng lại chỉ mục polygon của serve
+func (q *JobQueue) OnPublished(fn func(ctx context.Context)) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.onPublished = append(q.onPublished, fn)
+}
+
+// Run xử lý lần lượt các job cho tới khi ctx bị hủy; job đang chạy khi đó kết thúc với trạng thái canceled
+func (q *JobQueue) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case job := <-q.queue:
+			q.run(ctx, job)
+		}
+	}
+}
+
+// Submit kiểm tra yêu cầu, tra relation của các tỉnh và đưa job vào hàng đợi
+func (q *JobQueue) Submit(ctx context.Context, req ImportJobRequest) (*Job, error) {
+	var relationIDs []int64
+	seen := make(map[int64]bool)
+	add := func(id int64) {
+		if !seen[id] {
+			seen[id] = true
+			relationIDs = append(relationIDs, id)
+		}
+	}
+	for _, id := range req.RelationIDs {
+		if id <= 0 {
+			return nil, fmt.Errorf("%w: relation id %d", ErrInvalidJob, id)
+		}
+		add(id)
+	}
+	for _, province := range req.Provinces {
+		id, err := q.service.ResolveProvinceRelation(ctx, province)
+		if errors.Is(err, ErrUnitNotFound) {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidJob, err)
+		}
+		if err != nil {
+			return nil, err
+		}
+		add(id)
+	}
+	if len(relationIDs) == 0 {
+		return nil, fmt.Errorf("%w: cần relationIds hoặc provinces", ErrInvalidJob)
+	}
+
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	job := &Job{
+		ID:        hex.EncodeToString(id),
+		Status:    JobStatusQueued,
+		Request:   req,
+		Instance:  q.instance,
+		CreatedAt: time.Now(),
+	}
+	for _, relationID := range relationIDs {
+		job.Provinces = append(job.Provinces, &JobProvince{RelationID: relationID, Status: StepPending})
+	}
+
+	q.mu.Lock()
+	q.prune()
+	select {
+	case q.queue <- job:
+	default:
+		q.mu.Unlock()
+		return nil, ErrJobQueueFull
+	}
+	q.jobs[job.ID] = job
+	q.mu.Unlock()
+
+	slog.Info("Đã nhận job import", "job", job.ID, "relations", relationIDs)
+	q.save(ctx, job, true)
+	return q.snapshot(job), nil
+}
+
+// Get trả về trạng thái job; job của instance khác được đọc từ Redis
+func (q *JobQueue) Get(ctx context.Context, id string) (*Job, error) {
+	q.mu.Lock()
+	job, ok := q.jobs[id]
+	q.mu.Unlock()
+	if ok {
+		return q.snapshot(job), nil
+	}
+	return loadJob(ctx, id)
+}
+
+// Cancel hủy job: job đang chờ kết thúc ngay, job đang chạy dừng sau khi hủy các lời gọi đang dở.
+// Job của instance khác được đánh dấu qua Redis và bị hủy trong vòng vài giây.
+func (q *JobQueue) Cancel(ctx context.Context, id string) (*Job, error) {
+	q.mu.Lock()
+	job, ok := q.jobs[id]
+	if ok {
+		if job.finished() {
+			q.mu.Unlock()
+			return nil, fmt.Errorf("%w (%s)", ErrJobFinished, job.Status)
+		}
+		job.CancelRequested = true
+		if cancel, running := q.cancels[id]; running {
+			cancel()
+		} else {
+			q.finish(job, JobStatusCanceled, nil)
+		}
+		q.mu.Unlock()
+		slog.Info("Đã yêu cầu hủy job", "job", id)
+		q.save(ctx, job, true)
+		return q.snapshot(job), nil
+	}
+	q.mu.Unlock()
+
+	remote, err := loadJob(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if remote.finished() {
+		return nil, fmt.Errorf("%w (%s)", ErrJobFinished, remote.Status)
+	}
+	if err := Set(ctx, fmt.Sprintf(redisJobCancelKey, id), q.instance, jobRetention); err != nil {
+		return nil, fmt.Errorf("không thể ghi yêu cầu hủy job %s: %w", id, err)
+	}
+	slog.Info("Đã gửi yêu cầu hủy job tới instance khác", "job", id, "instance", remote.Instance)
+	remote.CancelRequested = true
+	return remote, nil
+}
+
+// run chạy một job; trạng thái được cập nhật dưới q.mu và ghi lên Redis sau mỗi bước
+func (q *JobQueue) run(ctx context.Context, job *Job) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	q.mu.Lock()
+	if job.finished() {
+		q.mu.Unlock()
+		return
+	}
+	now := time.Now()
+	job.Status = JobStatusRunning
+	job.StartedAt = &now
+	q.cancels[job.ID] = cancel
+	q.mu.Unlock()
+	defer func() {
+		q.mu.Lock()
+		delete(q.cancels, job.ID)
+		q.mu.Unlock()
+	}()
+	q.save(ctx, job, true)
+	go q.watchCancel(ctx, job.ID, cancel)
+
+	logger := slog.With("job", job.ID)
+	logger.Info("Bắt đầu job import", "relations", len(job.Provinces))
+
+	withCommunes := job.Request.Communes == nil || *job.Request.Communes
+	pipelineCfg := CommunePipelineConfig{
+		FetchWorkers:  appConfig.Concurrency.FetchWorkers,
+		WriteWorkers:  appConfig.Concurrency.WriteWorkers,
+		QueueSize:     appConfig.Concurrency.QueueSize,
+		ChangelogPath: appConfig.Storage.ChangelogFile,
+	}.WithDefaults()
+
+	published, failed := 0, 0
+	for _, province := range job.Provinces {
+		if ctx.Err() != nil {
+			q.update(ctx, job, func() { province.Status = ReportStatusInterrupted })
+			continue
+		}
+		status, err := q.runProvince(ctx, job, province, withCommunes, pipelineCfg)
+		switch status {
+		case ReportStatusPublished:
+			published++
+		case ReportStatusFailed:
+			failed++
+			logger.Error("Relation lỗi", "relation", province.RelationID, "error", err)
+		}
+	}
+
+	if published > 0 && ctx.Err() == nil {
+		if err := q.service.UpdateLatLonCenterForPhuongXa(ctx); err != nil {
+			logger.Error("Lỗi khi cập nhật tọa độ trung tâm của xã/phường", "error", err)
+		}
+	}
+	if store := q.service.BoundaryStore(); store != nil {
+		if err := store.Save(); err != nil {
+			logger.Error("Lỗi khi lưu boundary store", "error", err)
+		}
+	}
+
+	q.mu.Lock()
+	switch {
+	case ctx.Err() != nil:
+		q.finish(job, JobStatusCanceled, nil)
+	case failed > 0:
+		q.finish(job, JobStatusFailed, fmt.Errorf("%d/%d relation không publish được", failed, len(job.Provinces)))
+	default:
+		q.finish(job, JobStatusSucceeded, nil)
+	}
+	hooks := q.onPublished
+	q.mu.Unlock()
+	q.save(context.WithoutCancel(ctx), job, true)
+	logger.Info("Kết thúc job import", "status", job.Status, "published", published, "failed", failed)
+
+	if published > 0 {
+		for _, hook := range hooks {
+			hook(context.WithoutCancel(ctx))
+		}
+	}
+}
+
+// runProvince khóa và import một relation tỉnh, trả về trạng thái cuối của tỉnh trong job
+func (q *JobQueue) runProvince(ctx context.Context, job *Job, province *JobProvince, withCommunes bool, pipelineCfg CommunePipelineConfig) (string, error) {
+	relationID := province.RelationID
+	finish := func(status string, err error) (string, error) {
+		q.update(ctx, job, func() {
+			province.Status = status
+			if err != nil {
+				province.Error = err.Error()
+			}
+		})
+		metrics.Province(status)
+		return status, err
+	}
+
+	leaseCtx, release, ok, err := LeaseRelation(ctx, relationID, jobLeaseTTL)
+	if err != nil {
+		return finish(ReportStatusFailed, err)
+	}
+	if !ok {
+		return finish(ReportStatusSkipped, fmt.Errorf("đang được tiến trình khác xử lý"))
+	}
+	defer release()
+
+	q.update(ctx, job, func() { province.Status = JobStatusRunning })
+	pipelineCfg.OnCommunes = func(_ int64, maTT string, jobs []CommuneJob) {
+		q.update(ctx, job, func() {
+			province.MaTT = maTT
+			province.CommunesTotal = len(jobs)
+			province.Communes = make([]JobCommune, len(jobs))
+			for i, commune := range jobs {
+				province.Communes[i] = JobCommune{RelationID: commune.RelationID, Name: commune.Name, Status: StepPending}
+			}
+		})
+	}
+	pipelineCfg.OnCommune = func(_ int64, r CommuneResult) {
+		q.mu.Lock()
+		if r.Job.Seq < len(province.Communes) {
+			commune := &province.Communes[r.Job.Seq]
+			commune.MaPhuongXa = r.MaPhuongXa
+			commune.Status = r.Status
+			if r.Err != nil {
+				commune.Error = r.Err.Error()
+			}
+		}
+		province.CommunesDone++
+		save := time.Since(q.lastSaved) >= jobSaveInterval
+		q.mu.Unlock()
+		if save {
+			q.save(ctx, job, false)
+		}
+	}
+
+	relationCtx, cancel := context.WithTimeout(leaseCtx, appConfig.OSM.RelationTimeout)
+	name, err := q.service.ImportRelation(relationCtx, relationID, withCommunes, pipelineCfg)
+	cancel()
+	q.update(ctx, job, func() { province.Name = name })
+
+	switch {
+	case ctx.Err() != nil:
+		return finish(ReportStatusInterrupted, ctx.Err())
+	case errors.Is(context.Cause(leaseCtx), ErrLeaseLost):
+		return finish(ReportStatusFailed, context.Cause(leaseCtx))
+	case err != nil:
+		return finish(ReportStatusFailed, err)
+	}
+	metrics.ProvinceSucceeded(relationID, name, time.Now())
+	return finish(ReportStatusPublished, nil)
+}
+
+// watchCancel hủy job khi instance khác ghi yêu cầu hủy lên Redis
+func (q *JobQueue) watchCancel(ctx context.Context, id string, cancel context.CancelFunc) {
+	if !RedisEnabled() {
+		return
+	}
+	key := fmt.Sprintf(redisJobCancelKey, id)
+	ticker := time.NewTicker(jobCancelPoll)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		requested, err := Exists(ctx, key)
+		if err != nil {
+			slog.Warn("Không đọc được yêu cầu hủy job", "job", id, "error", err)
+			continue
+		}
+		if requested {
+			slog.Info("Nhận yêu cầu hủy job từ instance khác", "job", id)
+			q.mu.Lock()
+			if job, ok := q.jobs[id]; ok {
+				job.CancelRequested = true
+			}
+			q.mu.Unlock()
+			cancel()
+			return
+		}
+	}
+}
+
+// update sửa job dưới q.mu rồi ghi lên Redis
+func (q *JobQueue) update(ctx context.Context, job *Job, apply func()) {
+	q.mu.Lock()
+	apply()
+	q.mu.Unlock()
+	q.save(ctx, job, true)
+}
+
+// finish chốt trạng thái cuối của job; gọi khi đang giữ q.mu
+func (q *JobQueue) finish(job *Job, status string, err error) {
+	now := time.Now()
+	job.Status = status
+	job.FinishedAt = &now
+	if err != nil {
+		job.Error = err.Error()
+	}
+}
+
+// save ghi trạng thái job lên Redis; force = false thì bỏ qua nếu vừa ghi trong jobSaveInterval
+func (q *JobQueue) save(ctx context.Context, job *Job, force bool) {
+	q.mu.Lock()
+	if !force && time.Since(q.lastSaved) < jobSaveInterval {
+		q.mu.Unlock()
+		return
+	}
+	q.lastSaved = time.Now()
+	data, err := json.Marshal(job)
+	q.mu.Unlock()
+	if err != nil || !RedisEnabled() {
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+	if err := Set(ctx, fmt.Sprintf(redisJobKey, job.ID), data, jobRetention); err != nil {
+		slog.Warn("Không ghi được trạng thái job lên Redis", "job", job.ID, "error", err)
+	}
+}
+
+// snapshot trả về bản sao của job để đọc ngoài q.mu
+func (q *JobQueue) snapshot(job *Job) *Job {
+	q.mu.Lock()
+	data, err := json.Marshal(job)
+	q.mu.Unlock()
+	var copied Job
+	if err == nil {
+		err = json.Unmarshal(data, &copied)
+	}
+	if err != nil {
+		return &Job{ID: job.ID, Status: job.Status}
+	}
+	return &copied
+}
+
+// prune bỏ các job đã kết thúc quá jobRetention khỏi bộ nhớ; gọi khi đang giữ q.mu
+func (q *JobQueue) prune() {
+	for id, job := range q.jobs {
+		if job.finished() && time.Since(job.CreatedAt) > jobRetention {
+			delete(q.jobs, id)
+		}
+	}
+}
+
+// loadJob đọc trạng thái job do instance khác ghi lên Redis
+func loadJob(ctx context.Context, id string) (*Job, error) {
+	if !RedisEnabled() {
+		return nil, fmt.Errorf("job %s: %w", id, ErrJobNotFound)
+	}
+	data, err := Get(ctx, fmt.Sprintf(redisJobKey, id))
+	if errors.Is(err, redis.Nil) {
+		return nil, fmt.Errorf("job %s: %w", id, ErrJobNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("không đọc được job %s từ Redis: %w", id, err)
+	}
+	var job Job
+	if err := json.Unmarshal([]byte(data), &job); err != nil {
+		return nil, fmt.Errorf("trạng thái job %s trên Redis không hợp lệ: %w", id, err)
+	}
+	return &job, nil
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"tool-map/models"
+)
+
+// lookupMarginDegrees là khoảng nới bounding box (~1 km) khi tìm xã/phường, để điểm nằm ngoài mọi polygon
+// (ngoài khơi, sát biên giới) vẫn có xã gần nhất kèm khoảng cách
+const lookupMarginDegrees = 0.01
+
+// ErrInvalidCoordinate là lỗi khi vĩ độ/kinh độ nằm ngoài phạm vi hợp lệ
+var ErrInvalidCoordinate = errors.New("tọa độ không hợp lệ")
+
+// UnitRef là mã và tên một đơn vị hành chính trong kết quả tra cứu
+type UnitRef struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
+
+// LookupResult là kết quả tra cứu xã/phường chứa một tọa độ. Khi Found, DistanceToBoundaryMeters là
+// khoảng cách từ điểm tới ranh giới của xã chứa nó (nhỏ = điểm sát ranh giới). Khi không tìm thấy,
+// Nearest là xã có ranh giới gần nhất trong bán kính ~1 km, nếu có, và khoảng cách tới ranh giới đó.
+type LookupResult struct {
+	ID                       string   `json:"id,omitempty"`
+	Lat                      float64  `json:"lat"`
+	Lon                      float64  `json:"lon"`
+	Found                    bool     `json:"found"`
+	Commune                  *UnitRef `json:"commune,omitempty"`
+	Province                 *UnitRef `json:"province,omitempty"`
+	Nearest                  *UnitRef `json:"nearest,omitempty"`
+	DistanceToBoundaryMeters *float64 `json:"distanceToBoundaryMeters,omitempty"`
+	Error                    string   `json:"error,omitempty"`
+}
+
+// LookupInIndex tìm xã/phường chứa (lat, lon) trên chỉ mục polygon; maTT khác rỗng thì chỉ tìm trong tỉnh đó.
+// Đây là quy tắc tra cứu chung của REST, gRPC và lệnh lookup: xét mọi polygon (kể cả lỗ) của các xã có bbox
+// nằm trong khoảng lookupMarginDegrees quanh điểm, không có xã chứa điểm thì trả xã gần nhất.
+// Tên tỉnh lấy từ polygon tỉnh trong chỉ mục, rỗng nếu chỉ mục không có tỉnh.
+func LookupInIndex(index *PolygonIndex, maTT string, lat, lon float64) (*LookupResult, error) {
+	if !(lat >= -90 && lat <= 90) || !(lon >= -180 && lon <= 180) {
+		return nil, fmt.Errorf("%w: (%g, %g)", ErrInvalidCoordinate, lat, lon)
+	}
+
+	result := &LookupResult{Lat: lat, Lon: lon}
+	var nearest *IndexedUnit
+	nearestDistance := math.Inf(1)
+	candidates := index.Intersecting(models.AdminLevelCommune,
+		lat-lookupMarginDegrees, lon-lookupMarginDegrees, lat+lookupMarginDegrees, lon+lookupMarginDegrees)
+	for _, commune := range candidates {
+		if commune.Code == "" || (maTT != "" && commune.MaTT != maTT) {
+			continue
+		}
+		distance := commune.DistanceToBoundaryMeters(lat, lon)
+		if commune.Contains(lat, lon) {
+			result.Found = true
+			result.Commune = &UnitRef{Code: commune.Code, Name: commune.Name}
+			result.Province = &UnitRef{Code: commune.MaTT}
+			if province := index.UnitAt(models.AdminLevelProvince, commune.MaTT); province != nil {
+				result.Province.Name = province.Name
+			}
+			result.DistanceToBoundaryMeters = &distance
+			return result, nil
+		}
+		if distance < nearestDistance {
+			nearest, nearestDistance = commune, distance
+		}
+	}
+
+	if nearest != nil {
+		result.Nearest = &UnitRef{Code: nearest.Code, Name: nearest.Name}
+		result.DistanceToBoundaryMeters = &nearestDistance
+	}
+	return result, nil
+}
+
+// PointLookup tra cứu tọa độ trên POLYGON_DATA (hoặc hash geo_polygon:phuong_xa) không cần nạp cả chỉ mục,
+// cho lệnh lookup; quy tắc tra cứu là LookupInIndex trên các xã ứng viên lấy từ DB.
+// Polygon và tên tỉnh đã nạp được cache trong PointLookup nên dùng một PointLookup cho cả lô điểm;
+// PointLookup không an toàn khi dùng đồng thời.
+type PointLookup struct {
+	service   *OSMService
+	communes  map[string]*UnitGeometry
+	provinces map[string]string
+}
+
+// NewPointLookup tạo bộ tra cứu tọa độ dùng repositories của service
+func (s *OSMService) NewPointLookup() *PointLookup {
+	return &PointLookup{
+		service:   s,
+		communes:  make(map[string]*UnitGeometry),
+		provinces: make(map[string]string),
+	}
+}
+
+// Lookup tìm xã/phường chứa (lat, lon); maTT khác rỗng thì chỉ tìm trong tỉnh đó.
+// Tọa độ ngoài phạm vi là lỗi; không tìm thấy không phải lỗi (Found = false).
+func (l *PointLookup) Lookup(ctx context.Context, maTT string, lat, lon float64) (*LookupResult, error) {
+	s := l.service
+	if s.dmTTRepo == nil || s.dmPhuongXaRepo == nil {
+		return nil, fmt.Errorf("database repositories not initialized, use NewOSMServiceWithDB()")
+	}
+	if !(lat >= -90 && lat <= 90) || !(lon >= -180 && lon <= 180) {
+		return nil, fmt.Errorf("%w: (%g, %g)", ErrInvalidCoordinate, lat, lon)
+	}
+
+	candidates, err := s.dmPhuongXaRepo.FindCandidatesByCoordinate(ctx, maTT, lat, lon, lookupMarginDegrees)
+	if err != nil {
+		return nil, err
+	}
+	units := make([]UnitGeometry, 0, len(candidates))
+	for i := range candidates {
+		commune, err := l.commune(ctx, candidates[i].MaPhuongXa, candidates[i].TenPhuongXa, candidates[i].TrucThuocTinh)
+		if err != nil {
+			return nil, err
+		}
+		if commune != nil {
+			units = append(units, *commune)
+		}
+	}
+
+	result, err := LookupInIndex(NewPolygonIndex(units), maTT, lat, lon)
+	if err != nil {
+		return nil, err
+	}
+	if result.Province != nil {
+		if result.Province.Name, err = l.provinceName(ctx, result.Province.Code); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// commune nạp polygon của xã/phường (Redis trước, rồi POLYGON_DATA); nil nếu polygon không đọc được
+func (l *PointLookup) commune(ctx context.Context, ma, name, maTT string) (*UnitGeometry, error) {
+	if commune, ok := l.communes[ma]; ok {
+		return commune, nil
+	}
+
+	data, ok := cachedPolygon(ctx, redisHashWardPolygon, ma)
+	if !ok {
+		px, err := l.service.dmPhuongXaRepo.GetByMaPhuongXa(ctx, ma)
+		if err != nil {
+			return nil, err
+		}
+		if px == nil || px.Polygon == nil {
+			l.communes[ma] = nil
+			return nil, nil
+		}
+		data = *px.Polygon
+	}
+
+	var commune *UnitGeometry
+	if ring, err := ParsePolygonData(data); err == nil && len(ring) >= 3 {
+		commune = &UnitGeometry{Level: models.AdminLevelCommune, Code: ma, Name: name, MaTT: maTT, Polygons: [][][2]float64{ring}}
+	}
+	l.communes[ma] = commune
+	return commune, nil
+}
+
+// provinceName lấy tên tỉnh theo mã, có cache
+func (l *PointLookup) provinceName(ctx context.Context, maTT string) (string, error) {
+	if name, ok := l.provinces[maTT]; ok {
+		return name, nil
+	}
+	tt, err := l.service.dmTTRepo.GetByMaTT(ctx, maTT)
+	if err != nil {
+		return "", err
+	}
+	var name string
+	if tt != nil {
+		name = tt.TenTT
+	}
+	l.provinces[maTT] = name
+	return name, nil
+}
@@ -3,11 +3,13 @@ package services
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
-	"os"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/minio/minio-go/v7"
@@ -18,42 +20,48 @@ var minioClient *minio.Client
 var returnURL string
 
 // ensureMinioClient ensures MinIO client is initialized
-func ensureMinioClient() error {
+func ensureMinioClient(ctx context.Context) error {
 	if minioClient != nil {
 		return nil
 	}
-	return InitMinioClient()
+	return InitMinioClient(ctx)
 }
 
 // InitMinioClient initializes MinIO client
-func InitMinioClient() error {
+func InitMinioClient(ctx context.Context) error {
 	var err error
 
-	// Get configuration from environment
-	endpoint := os.Getenv("MINIO_ENDPOINT")
-	accessKey := os.Getenv("MINIO_ACCESS_KEY_ID")
-	secretKey := os.Getenv("MINIO_SECRET_ACCESS_KEY")
-	useSSL := os.Getenv("MINIO_USE_SSL") == "true"
-	returnURL = os.Getenv("MINIO_RETURN_URL")
+	cfg := appConfig.MinIO
+	endpoint := cfg.Endpoint
+	returnURL = cfg.ReturnURL
 
-	if endpoint == "" || accessKey == "" || secretKey == "" {
-		return fmt.Errorf("MinIO configuration missing in environment variables")
+	if endpoint == "" || cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return fmt.Errorf("MinIO configuration missing (minio.endpoint, minio.access_key_id, minio.secret_access_key)")
 	}
 
 	if returnURL == "" {
 		returnURL = fmt.Sprintf("http://%s", endpoint)
 	}
 
+	transport, err := minio.DefaultTransport(cfg.UseSSL)
+	if err != nil {
+		return fmt.Errorf("failed to create MinIO transport: %w", err)
+	}
+	if cfg.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+	}
+
 	minioClient, err = minio.New(endpoint, &minio.Options{
-		Creds:  credentials.NewStaticV4(accessKey, secretKey, ""),
-		Secure: useSSL,
+		Creds:     credentials.NewStaticV4(cfg.AccessKeyID, cfg.SecretAccessKey, ""),
+		Secure:    cfg.UseSSL,
+		Transport: transport,
 	})
 	if err != nil {
 		return fmt.Errorf("failed to create MinIO client: %w", err)
 	}
 
 	// Test connection
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
 	_, err = minioClient.ListBuckets(ctx)
@@ -61,24 +69,19 @@ func InitMinioClient() error {
 		return fmt.Errorf("failed to connect to MinIO: %w", err)
 	}
 
-	log.Printf("Successfully initialized MinIO client at %s", endpoint)
+	slog.Info("Successfully initialized MinIO client", "endpoint", endpoint)
 	return nil
 }
 
 // UploadFile uploads a file to MinIO (similar to your UploadFile function)
-func UploadFile(fileBytes []byte, fileName, bucket, rootPath string) (string, error) {
-	if err := ensureMinioClient(); err != nil {
+func UploadFile(ctx context.Context, fileBytes []byte, fileName, bucket, rootPath string) (string, error) {
+	if err := ensureMinioClient(ctx); err != nil {
 		return "", fmt.Errorf("failed to initialize MinIO client: %w", err)
 	}
 
-	ctx := context.Background()
-
 	// Use default bucket if not specified
 	if bucket == "" {
-		bucket = os.Getenv("MINIO_BUCKET_NAME")
-		if bucket == "" {
-			bucket = "osm-data"
-		}
+		bucket = polygonBucket()
 	}
 
 	// Create bucket if it doesn't exist
@@ -107,7 +110,7 @@ func UploadFile(fileBytes []byte, fileName, bucket, rootPath string) (string, er
 	// Detect content type
 	contentType := http.DetectContentType(fileBytes)
 
-	log.Printf("Starting upload file %s to bucket %s", objectName, bucket)
+	slog.Debug("Starting upload file", "object", objectName, "bucket", bucket)
 
 	// Upload the file
 	info, err := minioClient.PutObject(ctx, bucket, objectName, bytes.NewReader(fileBytes), int64(len(fileBytes)), minio.PutObjectOptions{
@@ -120,7 +123,7 @@ func UploadFile(fileBytes []byte, fileName, bucket, rootPath string) (string, er
 		return "", fmt.Errorf("failed to upload file: %w", err)
 	}
 
-	log.Printf("Successfully uploaded %s of size %d", objectName, info.Size)
+	slog.Debug("Successfully uploaded file", "object", objectName, "size", info.Size)
 
 	// Return the URL
 	uploadURL := fmt.Sprintf("%s/%s/%s", returnURL, bucket, objectName)
@@ -128,17 +131,12 @@ func UploadFile(fileBytes []byte, fileName, bucket, rootPath string) (string, er
 }
 
 // UploadPolygonData uploads polygon data specifically for OSM data
-func UploadPolygonData(polygonData []byte, objectName string) (string, error) {
-	if err := ensureMinioClient(); err != nil {
+func UploadPolygonData(ctx context.Context, polygonData []byte, objectName string) (string, error) {
+	if err := ensureMinioClient(ctx); err != nil {
 		return "", fmt.Errorf("failed to initialize MinIO client: %w", err)
 	}
 
-	ctx := context.Background()
-
-	bucket := os.Getenv("MINIO_BUCKET_NAME")
-	if bucket == "" {
-		bucket = "osm-data"
-	}
+	bucket := polygonBucket()
 
 	// Create bucket if it doesn't exist
 	err := minioClient.MakeBucket(ctx, bucket, minio.MakeBucketOptions{Region: "us-east-1"})
@@ -153,7 +151,7 @@ func UploadPolygonData(polygonData []byte, objectName string) (string, error) {
 		}
 	}
 
-	log.Printf("Starting upload polygon data %s to bucket %s", objectName, bucket)
+	slog.Debug("Starting upload polygon data", "object", objectName, "bucket", bucket)
 
 	// Upload the polygon data
 	info, err := minioClient.PutObject(ctx, bucket, objectName, bytes.NewReader(polygonData), int64(len(polygonData)), minio.PutObjectOptions{
@@ -166,7 +164,7 @@ func UploadPolygonData(polygonData []byte, objectName string) (string, error) {
 		return "", fmt.Errorf("failed to upload polygon data: %w", err)
 	}
 
-	log.Printf("Successfully uploaded polygon data %s of size %d", objectName, info.Size)
+	slog.Debug("Successfully uploaded polygon data", "object", objectName, "size", info.Size)
 
 	// Return the URL
 	uploadURL := fmt.Sprintf("%s/%s/%s", returnURL, bucket, objectName)
@@ -174,12 +172,12 @@ func UploadPolygonData(polygonData []byte, objectName string) (string, error) {
 }
 
 // DownloadFile downloads a file from MinIO
-func DownloadFile(bucket, objectName string) ([]byte, error) {
-	if err := ensureMinioClient(); err != nil {
+func DownloadFile(ctx context.Context, bucket, objectName string) ([]byte, error) {
+	if err := ensureMinioClient(ctx); err != nil {
 		return nil, fmt.Errorf("failed to initialize MinIO client: %w", err)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
 	object, err := minioClient.GetObject(ctx, bucket, objectName, minio.GetObjectOptions{})
@@ -197,19 +195,52 @@ func DownloadFile(bucket, objectName string) ([]byte, error) {
 	return data, nil
 }
 
+// DownloadFileIfExists tải object từ MinIO; found = false (không lỗi) khi object không tồn tại
+func DownloadFileIfExists(ctx context.Context, bucket, objectName string) (data []byte, found bool, err error) {
+	data, err = DownloadFile(ctx, bucket, objectName)
+	var response minio.ErrorResponse
+	if errors.As(err, &response) && response.Code == "NoSuchKey" {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
 // GetPresignedURL generates a presigned URL for object access
-func GetPresignedURL(bucket, objectName string, expiry time.Duration) (string, error) {
-	if err := ensureMinioClient(); err != nil {
+func GetPresignedURL(ctx context.Context, bucket, objectName string, expiry time.Duration) (string, error) {
+	if err := ensureMinioClient(ctx); err != nil {
 		return "", fmt.Errorf("failed to initialize MinIO client: %w", err)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
-	url, err := minioClient.PresignedGetObject(ctx, bucket, objectName, expiry, nil)
+	presigned, err := minioClient.PresignedGetObject(ctx, bucket, objectName, expiry, nil)
 	if err != nil {
 		return "", fmt.Errorf("failed to generate presigned URL: %w", err)
 	}
 
-	return url.String(), nil
+	return presigned.String(), nil
+}
+
+// DownloadPolygonData tải polygon từ URL đã trả về bởi UploadPolygonData ({returnURL}/{bucket}/{object})
+func DownloadPolygonData(ctx context.Context, objectURL string) ([]byte, error) {
+	if err := ensureMinioClient(ctx); err != nil {
+		return nil, fmt.Errorf("failed to initialize MinIO client: %w", err)
+	}
+
+	path := objectURL
+	if returnURL != "" && strings.HasPrefix(path, returnURL) {
+		path = strings.TrimPrefix(path, returnURL)
+	} else if u, err := url.Parse(objectURL); err == nil {
+		path = u.Path
+	}
+
+	bucket, objectName, found := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	if !found || bucket == "" || objectName == "" {
+		return nil, fmt.Errorf("invalid polygon URL %q", objectURL)
+	}
+	return DownloadFile(ctx, bucket, objectName)
 }
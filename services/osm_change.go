@@ -0,0 +1,74 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"tool-map/models"
+)
+
+// ImportedUnit là đơn vị hành chính trong DM đã được import từ một relation OSM
+type ImportedUnit struct {
+	RelationID int64
+	Level      int    // 4 = tỉnh/thành phố, 6 = xã/phường
+	Code       string // MATT hoặc MA_PHUONG_XA
+	Name       string
+	MaTT       string // tỉnh chứa xã/phường, rỗng với cấp tỉnh
+}
+
+// SetBoundaryStore gắn store cục bộ; mọi relation fetch qua FetchAndProcessRelation sẽ được lưu vào store
+func (s *OSMService) SetBoundaryStore(store *BoundaryStore) {
+	s.store = store
+}
+
+// BoundaryStore trả về store đang gắn (có thể nil)
+func (s *OSMService) BoundaryStore() *BoundaryStore {
+	return s.store
+}
+
+// ApplyOsmChange áp dụng một OsmChange vào boundary store trong bộ nhớ và trả về các relation cần dựng lại.
+// Store không được lưu ở đây: người gọi chỉ lưu sau khi đã publish lại mọi relation bị ảnh hưởng, để lần chạy
+// lại cùng diff vẫn thấy các relation đó khi publish lỗi giữa chừng.
+func (s *OSMService) ApplyOsmChange(change *models.OsmChange) (*BoundaryStoreChange, error) {
+	if s.store == nil {
+		return nil, fmt.Errorf("chưa cấu hình boundary store")
+	}
+	return s.store.Apply(change), nil
+}
+
+// ProcessStoredRelation dựng kết quả xử lý cho relation từ boundary store, không gọi OSM API
+func (s *OSMService) ProcessStoredRelation(relationID int64) (*models.OSMProcessingResult, error) {
+	if s.store == nil {
+		return nil, fmt.Errorf("chưa cấu hình boundary store")
+	}
+
+	osm, ok := s.store.RelationOSM(relationID)
+	if !ok {
+		return nil, fmt.Errorf("relation %d không có trong boundary store", relationID)
+	}
+
+	return s.ProcessOSM(osm, relationID)
+}
+
+// FindImportedUnit tìm dòng DM_PHUONG_XA hoặc DMTT đã import từ relation; trả về nil nếu chưa import
+func (s *OSMService) FindImportedUnit(ctx context.Context, relationID int64) (*ImportedUnit, error) {
+	if s.dmTTRepo == nil || s.dmPhuongXaRepo == nil {
+		return nil, fmt.Errorf("database repositories not initialized, use NewOSMServiceWithDB()")
+	}
+
+	px, err := s.dmPhuongXaRepo.GetByOsmRelationID(ctx, relationID)
+	if err != nil {
+		return nil, fmt.Errorf("không thể lấy dữ liệu xã/phường từ database: %w", err)
+	}
+	if px != nil {
+		return &ImportedUnit{RelationID: relationID, Level: 6, Code: px.MaPhuongXa, Name: px.TenPhuongXa, MaTT: px.TrucThuocTinh}, nil
+	}
+
+	tt, err := s.dmTTRepo.GetByOsmRelationID(ctx, relationID)
+	if err != nil {
+		return nil, fmt.Errorf("không thể lấy dữ liệu tỉnh/thành phố từ database: %w", err)
+	}
+	if tt != nil {
+		return &ImportedUnit{RelationID: relationID, Level: 4, Code: tt.MaTT, Name: tt.TenTT}, nil
+	}
+	return nil, nil
+}
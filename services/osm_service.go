@@ -4,7 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"math"
 	"os"
 	"path/filepath"
@@ -13,6 +13,7 @@ import (
 	"strings"
 	"time"
 	"tool-map/entities"
+	"tool-map/metrics"
 	"tool-map/models"
 	"tool-map/repositories"
 	"tool-map/util"
@@ -22,19 +23,32 @@ import (
 )
 
 type OSMServiceInterface interface {
-	FetchAndProcessRelation(relationID int64) (*models.OSMProcessingResult, error)
+	FetchAndProcessRelation(ctx context.Context, relationID int64) (*models.OSMProcessingResult, error)
 	GetBoundaryStringFromResult(result *models.OSMProcessingResult) string
-	UpdateStringBoundaryToDatabase(id string, level int, boundaryString, wayAddress string, lonCenter, latCenter float64, maTT string) error
-	CreatePolygonFromWaysAndNodes(osm *models.OSM) ([][]float64, error)
-	UpdatePolygonToDatabase(id string, level int, polygonData [][][]float64, maTT string) error
-	FindCommuneByCoordinate(provinceCode string, lat, lon float64) (*entities.DmPhuongXa, error)
-	UpdateLatLonCenterForPhuongXa() error
-	DownloadAllPolygonFiles() (int, error)
+	UpdateStringBoundaryToDatabase(ctx context.Context, name string, level int, maxLat, minLat, maxLon, minLon, lonCenter, latCenter float64, maTT string) error
+	CreatePolygonFromWaysAndNodes(ways []models.WayAddress, nodes []models.Address) ([][][]float64, error)
+	UpdatePolygonToDatabase(ctx context.Context, name string, level int, polygonData string, maTT string) error
+	FindCommuneByCoordinate(ctx context.Context, provinceCode string, lat, lon float64) (*entities.DmPhuongXa, error)
+	UpdateLatLonCenterForPhuongXa(ctx context.Context) error
+	DownloadAllPolygonFiles(ctx context.Context) (int, error)
+	DownloadPolygonFilesTo(ctx context.Context, polygonDir string) (int, error)
+	DiscoverRelationIDs(ctx context.Context, areaRelationID int64, adminLevel int) ([]int64, error)
 }
 type OSMService struct {
 	client         *models.OSMApiClient
+	overpass       *models.OverpassClient
 	dmTTRepo       repositories.DmTTRepositoryInterface
 	dmPhuongXaRepo repositories.DmPhuongXaRepositoryInterface
+	apiKeyRepo     repositories.ApiKeyRepositoryInterface
+
+	// store (nếu có) giữ bản sao node/way/relation đã fetch để áp dụng OsmChange
+	store *BoundaryStore
+
+	// plan (nếu có) bật chế độ dry-run: mọi thao tác ghi chỉ được ghi nhận vào plan
+	plan *ChangePlan
+
+	// report (nếu có) ghi nhận polygon dựng gần đúng cho báo cáo lần chạy
+	report *RunReport
 }
 
 // WayCoordinates represents a way with its coordinates
@@ -51,36 +65,102 @@ type Connection struct {
 	Coords  [][]float64
 }
 
+// NewOSMService creates an OSM service without database access (fetch/build only).
+// osm.api_url / osm.overpass_url override the API endpoints (e.g. a local stub server),
+// osm.rate_limit the number of OSM API requests per second shared by all workers.
+func NewOSMService() *OSMService {
+	cfg := appConfig.OSM
+	client := models.NewOSMApiClient()
+	if cfg.APIURL != "" {
+		client.BaseURL = cfg.APIURL
+	}
+	// rate_limit <= 0 để tắt giới hạn
+	if cfg.RateLimit > 0 {
+		client.Limiter = models.NewRateLimiter(cfg.RateLimit)
+	} else {
+		client.Limiter = nil
+	}
+	overpass := models.NewOverpassClient()
+	if cfg.OverpassURL != "" {
+		overpass.BaseURL = cfg.OverpassURL
+	}
+
+	return &OSMService{
+		client:   client,
+		overpass: overpass,
+	}
+}
+
 // NewOSMServiceWithDB creates a new OSM service with database repositories
 func NewOSMServiceWithDB(db *gorm.DB) *OSMService {
-	return &OSMService{
-		client:         models.NewOSMApiClient(),
-		dmTTRepo:       repositories.NewDmTTRepository(db),
-		dmPhuongXaRepo: repositories.NewDmPhuongXaRepository(db),
+	s := NewOSMService()
+	s.dmTTRepo = repositories.NewDmTTRepository(db)
+	s.dmPhuongXaRepo = repositories.NewDmPhuongXaRepository(db)
+	s.apiKeyRepo = repositories.NewApiKeyRepository(db)
+	return s
+}
+
+// DiscoverRelationIDs dùng Overpass để tìm các relation boundary=administrative có admin_level
+// nằm trong vùng areaRelationID (ví dụ: tất cả tỉnh admin_level=4 trong Việt Nam)
+func (s *OSMService) DiscoverRelationIDs(ctx context.Context, areaRelationID int64, adminLevel int) ([]int64, error) {
+	slog.Info("Gọi Overpass tìm relation", "admin_level", adminLevel, "area", areaRelationID)
+
+	ids, err := s.overpass.FetchAdminRelationIDsInArea(ctx, areaRelationID, adminLevel)
+	if err != nil {
+		return nil, fmt.Errorf("không thể lấy danh sách relation từ Overpass: %w", err)
 	}
+
+	slog.Info("Tìm thấy relation từ Overpass", "admin_level", adminLevel, "count", len(ids))
+	return ids, nil
 }
 
 // FetchAndProcessRelation fetches OSM relation data and processes it
-func (s *OSMService) FetchAndProcessRelation(relationID int64) (*models.OSMProcessingResult, error) {
-	fmt.Printf("Gọi OSM API để lấy dữ liệu cho relation %d...\n", relationID)
+func (s *OSMService) FetchAndProcessRelation(ctx context.Context, relationID int64) (*models.OSMProcessingResult, error) {
+	osm, err := s.FetchRelationOSM(ctx, relationID)
+	if err != nil {
+		return nil, err
+	}
+	return s.ProcessOSM(osm, relationID)
+}
+
+// FetchRelationOSM lấy /relation/{id}/full và lưu vào boundary store (nếu có)
+func (s *OSMService) FetchRelationOSM(ctx context.Context, relationID int64) (*models.OSM, error) {
+	logger := slog.With("relation", relationID)
+	logger.Info("Gọi OSM API lấy relation full")
 
-	osm, err := s.client.FetchRelationFull(relationID)
+	osm, err := s.client.FetchRelationFull(ctx, relationID)
 	if err != nil {
 		return nil, fmt.Errorf("không thể lấy dữ liệu từ OSM API: %w", err)
 	}
 
-	fmt.Printf("OSM Data Information (from API):\n")
-	fmt.Printf("Version: %s\n", osm.Version)
-	fmt.Printf("Generator: %s\n", osm.Generator)
-	fmt.Printf("Total Nodes: %d\n", len(osm.Nodes))
-	fmt.Printf("Total Ways: %d\n", len(osm.Ways))
-	fmt.Printf("Total Relations: %d\n", len(osm.Relations))
+	logger.Debug("Đã nhận dữ liệu OSM",
+		"version", osm.Version,
+		"generator", osm.Generator,
+		"nodes", len(osm.Nodes),
+		"ways", len(osm.Ways),
+		"relations", len(osm.Relations))
 
-	return s.processOSMData(osm)
+	if s.store != nil {
+		s.store.Merge(osm)
+	}
+	return osm, nil
+}
+
+// ProcessOSM xử lý tài liệu OSM (từ API, file hoặc boundary store) cho relation gốc relationID
+func (s *OSMService) ProcessOSM(osm *models.OSM, relationID int64) (*models.OSMProcessingResult, error) {
+	result, err := s.processOSMData(osm, slog.With("relation", relationID))
+	if err != nil {
+		return nil, err
+	}
+	if relation, found := osm.FindRelationByID(relationID); found {
+		info := relation.ToRelationInfo()
+		result.Relation = &info
+	}
+	return result, nil
 }
 
 // processOSMData processes OSM data and returns structured result
-func (s *OSMService) processOSMData(osm *models.OSM) (*models.OSMProcessingResult, error) {
+func (s *OSMService) processOSMData(osm *models.OSM, logger *slog.Logger) (*models.OSMProcessingResult, error) {
 	// Basic info
 	basicInfo := &models.BasicOSMInfo{
 		Version:    osm.Version,
@@ -103,19 +183,19 @@ func (s *OSMService) processOSMData(osm *models.OSM) (*models.OSMProcessingResul
 	}
 
 	// Process boundary coordinates
-	boundaryData, err := s.processBoundaryData(osm)
+	boundaryData, err := s.processBoundaryData(osm, logger)
 	if err != nil {
 		return nil, fmt.Errorf("lỗi khi xử lý boundary data: %w", err)
 	}
 
 	// Process administrative entities
-	administrativeData, err := s.processAdministrativeEntities(osm)
+	administrativeData, err := s.processAdministrativeEntities(osm, logger)
 	if err != nil {
 		return nil, fmt.Errorf("lỗi khi xử lý administrative entities: %w", err)
 	}
 
 	// Get capital level statistics
-	capitalStats := s.getCapitalLevelStats(osm)
+	capitalStats := s.getCapitalLevelStats(osm, logger)
 
 	// Convert OSM Ways to WayAddress format
 	var ways []models.WayAddress
@@ -142,6 +222,7 @@ func (s *OSMService) processOSMData(osm *models.OSM) (*models.OSMProcessingResul
 	}
 
 	return &models.OSMProcessingResult{
+		GeometryHash:    osm.GeometryFingerprint(),
 		BasicInfo:       basicInfo,
 		Boundaries:      boundaryData,
 		Administrative:  administrativeData,
@@ -155,32 +236,26 @@ func (s *OSMService) processOSMData(osm *models.OSM) (*models.OSMProcessingResul
 }
 
 // processBoundaryData processes boundary coordinate data
-func (s *OSMService) processBoundaryData(osm *models.OSM) (*models.BoundaryData, error) {
-	fmt.Printf("\nProcessing boundary coordinates...\n")
-
+func (s *OSMService) processBoundaryData(osm *models.OSM, logger *slog.Logger) (*models.BoundaryData, error) {
 	coordinates, err := osm.GetBoundaryCoordinates()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get boundary coordinates: %w", err)
 	}
 
-	fmt.Printf("Found %d boundary coordinates\n", len(coordinates))
-
 	// Encode coordinates to JSON string
 	jsonString, err := models.EncodeCoordinatesToJSON(coordinates)
 	if err != nil {
 		return nil, fmt.Errorf("failed to encode coordinates to JSON: %w", err)
 	}
 
-	fmt.Printf("JSON string length: %d characters\n", len(jsonString))
-
 	// Decode back to verify
 	decodedCoords, err := models.DecodeCoordinatesFromJSON(jsonString)
 	if err != nil {
-		log.Printf("Failed to decode coordinates from JSON: %v", err)
+		logger.Warn("Failed to decode coordinates from JSON", "error", err)
+	} else if len(decodedCoords) != len(coordinates) {
+		logger.Warn("Decoded coordinates count mismatch", "decoded", len(decodedCoords), "original", len(coordinates))
 	}
-
-	fmt.Printf("Decoded coordinates count: %d (matches original: %t)\n",
-		len(decodedCoords), len(decodedCoords) == len(coordinates))
+	logger.Debug("Boundary coordinates", "count", len(coordinates), "json_length", len(jsonString))
 
 	// Get first 5 coordinates for display
 	var firstFiveCoords []models.Coordinate
@@ -190,11 +265,6 @@ func (s *OSMService) processBoundaryData(osm *models.OSM) (*models.BoundaryData,
 			maxCoords = len(coordinates)
 		}
 		firstFiveCoords = coordinates[:maxCoords]
-
-		fmt.Printf("\nFirst %d coordinates:\n", maxCoords)
-		for i, coord := range firstFiveCoords {
-			fmt.Printf("  %d: [%.6f, %.6f]\n", i+1, coord.Lon, coord.Lat)
-		}
 	}
 
 	// Get places data
@@ -204,15 +274,7 @@ func (s *OSMService) processBoundaryData(osm *models.OSM) (*models.BoundaryData,
 		PlaceRelations: len(placeRelations),
 	}
 
-	fmt.Printf("\nPlaces found:\n")
-	fmt.Printf("Place Nodes: %d\n", len(placeNodes))
-	for _, node := range placeNodes {
-		fmt.Printf("  - Node %d: %s (place=%s)\n", node.ID, node.GetName(), node.GetTagValue("place"))
-	}
-	fmt.Printf("Place Relations: %d\n", len(placeRelations))
-	for _, relation := range placeRelations {
-		fmt.Printf("  - Relation %d: %s (place=%s)\n", relation.ID, relation.GetName(), relation.GetTagValue("place"))
-	}
+	logger.Debug("Places found", "place_nodes", len(placeNodes), "place_relations", len(placeRelations))
 
 	// Get administrative boundaries
 	boundaryWays, boundaryRelations := osm.GetAdministrativeBoundaries()
@@ -221,15 +283,7 @@ func (s *OSMService) processBoundaryData(osm *models.OSM) (*models.BoundaryData,
 		BoundaryRelations: len(boundaryRelations),
 	}
 
-	fmt.Printf("\nAdministrative Boundaries:\n")
-	fmt.Printf("Boundary Ways: %d\n", len(boundaryWays))
-	for _, way := range boundaryWays {
-		fmt.Printf("  - Way %d: admin_level=%s\n", way.ID, way.GetTagValue("admin_level"))
-	}
-	fmt.Printf("Boundary Relations: %d\n", len(boundaryRelations))
-	for _, relation := range boundaryRelations {
-		fmt.Printf("  - Relation %d: %s (admin_level=%s)\n", relation.ID, relation.GetName(), relation.GetTagValue("admin_level"))
-	}
+	logger.Debug("Administrative boundaries", "boundary_ways", len(boundaryWays), "boundary_relations", len(boundaryRelations))
 
 	return &models.BoundaryData{
 		TotalCoordinates: len(coordinates),
@@ -241,13 +295,12 @@ func (s *OSMService) processBoundaryData(osm *models.OSM) (*models.BoundaryData,
 }
 
 // getCapitalLevelStats gets capital level statistics
-func (s *OSMService) getCapitalLevelStats(osm *models.OSM) map[int]int {
-	fmt.Printf("\n=== CAPITAL LEVEL STATISTICS ===\n")
-
+func (s *OSMService) getCapitalLevelStats(osm *models.OSM, logger *slog.Logger) map[int]int {
 	capitalStats := make(map[int]int)
-	for _, relation := range osm.Relations {
-		if relation.IsAdministrativeBoundary() {
-			capitalLevel := relation.GetCapitalLevel()
+	ix := osm.Index()
+	for i := range osm.Relations {
+		if tags := ix.RelationTags(i); tags.IsAdministrativeBoundary() {
+			capitalLevel := tags.CapitalLevel()
 			if capitalLevel > 0 {
 				capitalStats[capitalLevel]++
 			}
@@ -255,121 +308,102 @@ func (s *OSMService) getCapitalLevelStats(osm *models.OSM) map[int]int {
 	}
 
 	for level, count := range capitalStats {
-		var levelName string
-		switch level {
-		case 4:
-			levelName = "Tỉnh/Thành phố"
-		case 6:
-			levelName = "Xã/Phường"
-		default:
-			levelName = fmt.Sprintf("Cấp %d", level)
-		}
-		fmt.Printf("Capital level %d (%s): %d entities\n", level, levelName, count)
+		logger.Debug("Capital level statistics", "capital_level", level, "entities", count)
 	}
 
 	return capitalStats
 }
 
 // processAdministrativeEntities processes administrative entities and categorizes them by level
-func (s *OSMService) processAdministrativeEntities(osm *models.OSM) (map[string][]models.AdminEntity, error) {
-	fmt.Printf("\nProcessing administrative entities...\n")
-
+func (s *OSMService) processAdministrativeEntities(osm *models.OSM, logger *slog.Logger) (map[string][]models.AdminEntity, error) {
 	administrativeData := map[string][]models.AdminEntity{
 		"provinces": []models.AdminEntity{},
 		"communes":  []models.AdminEntity{},
 	}
 
 	// Process relations (boundaries)
-	for _, relation := range osm.Relations {
-		if !relation.IsAdministrativeBoundary() {
+	ix := osm.Index()
+	for i := range osm.Relations {
+		relation := &osm.Relations[i]
+		tags := ix.RelationTags(i)
+		if !tags.IsAdministrativeBoundary() {
 			continue
 		}
 
-		adminLevel := relation.GetAdminLevel()
-		capitalLevel := relation.GetCapitalLevel()
-
-		fmt.Printf("Processing relation %d: %s (admin_level=%d, capital=%d)\n",
-			relation.ID, relation.GetName(), adminLevel, capitalLevel)
+		adminLevel := tags.AdminLevel()
+		capitalLevel := tags.CapitalLevel()
 
 		// Get boundary coordinates for this relation
-		coordinates, err := osm.GetBoundaryCoordinatesFromRelation(&relation)
+		coordinates, err := osm.GetBoundaryCoordinatesFromRelation(relation)
 		if err != nil {
-			fmt.Printf("Warning: Could not get boundary coordinates for relation %d: %v\n", relation.ID, err)
+			logger.Warn("Could not get boundary coordinates", "member_relation", relation.ID, "name", tags.Name(), "error", err)
 			continue
 		}
 
 		// Encode coordinates to JSON
 		boundaryJSON, err := models.EncodeCoordinatesToJSON(coordinates)
 		if err != nil {
-			fmt.Printf("Warning: Could not encode coordinates for relation %d: %v\n", relation.ID, err)
+			logger.Warn("Could not encode coordinates", "member_relation", relation.ID, "name", tags.Name(), "error", err)
 			continue
 		}
 
 		// Create AdminEntity
 		entity := models.AdminEntity{
 			ID:           relation.ID,
-			Name:         relation.GetTagValue("name"),
-			NameEn:       relation.GetTagValue("name:en"),
-			NameVi:       relation.GetTagValue("name:vi"),
+			Name:         tags.Get("name"),
+			NameEn:       tags.Get("name:en"),
+			NameVi:       tags.Get("name:vi"),
 			AdminLevel:   adminLevel,
 			CapitalLevel: capitalLevel,
-			Place:        relation.GetTagValue("place"),
+			Place:        tags.Get("place"),
 			Boundary:     boundaryJSON,
 		}
 
 		// Classify by level - Relation thường dùng admin_level
-		if adminLevel == 4 {
+		if adminLevel == models.OSMProvinceLevel {
 			entity.Type = "province"
 			administrativeData["provinces"] = append(administrativeData["provinces"], entity)
-			fmt.Printf("  -> Added as PROVINCE (admin_level=4)\n")
-		} else if adminLevel == 6 {
+		} else if adminLevel == models.OSMCommuneLevel {
 			entity.Type = "commune"
 			administrativeData["communes"] = append(administrativeData["communes"], entity)
-			fmt.Printf("  -> Added as COMMUNE (admin_level=6)\n")
 		} else {
-			fmt.Printf("  -> Skipped (admin_level=%d not recognized)\n", adminLevel)
+			logger.Debug("Skipped relation, admin_level not recognized", "member_relation", relation.ID, "admin_level", adminLevel)
 		}
 	}
 
 	// Process nodes (places with capital level)
-	for _, node := range osm.Nodes {
-		capitalLevel := node.GetCapitalLevel()
+	for i := range osm.Nodes {
+		tags := ix.NodeTags(i)
+		capitalLevel := tags.CapitalLevel()
 		if capitalLevel <= 0 {
 			continue
 		}
 
-		fmt.Printf("Processing node %d: %s (capital=%d)\n",
-			node.ID, node.GetName(), capitalLevel)
-
 		// Create AdminEntity for node
 		entity := models.AdminEntity{
-			ID:           node.ID,
-			Name:         node.GetTagValue("name"),
-			NameEn:       node.GetTagValue("name:en"),
-			NameVi:       node.GetTagValue("name:vi"),
+			ID:           osm.Nodes[i].ID,
+			Name:         tags.Get("name"),
+			NameEn:       tags.Get("name:en"),
+			NameVi:       tags.Get("name:vi"),
 			AdminLevel:   -1, // Nodes don't have admin_level
 			CapitalLevel: capitalLevel,
-			Place:        node.GetTagValue("place"),
+			Place:        tags.Get("place"),
 			Boundary:     "", // Nodes don't have boundary coordinates
 		}
 
 		// Classify by capital level - Node thường dùng capital level
-		if capitalLevel == 4 {
+		if capitalLevel == models.OSMProvinceLevel {
 			entity.Type = "province"
 			administrativeData["provinces"] = append(administrativeData["provinces"], entity)
-			fmt.Printf("  -> Added as PROVINCE (capital=4)\n")
-		} else if capitalLevel == 6 {
+		} else if capitalLevel == models.OSMCommuneLevel {
 			entity.Type = "commune"
 			administrativeData["communes"] = append(administrativeData["communes"], entity)
-			fmt.Printf("  -> Added as COMMUNE (capital=6)\n")
-		} else {
-			fmt.Printf("  -> Skipped (capital level %d not recognized)\n", capitalLevel)
 		}
 	}
 
-	fmt.Printf("Administrative entities processed:\n")
-	fmt.Printf("- Provinces: %d\n", len(administrativeData["provinces"]))
-	fmt.Printf("- Communes: %d\n", len(administrativeData["communes"]))
+	logger.Debug("Administrative entities processed",
+		"provinces", len(administrativeData["provinces"]),
+		"communes", len(administrativeData["communes"]))
 
 	return administrativeData, nil
 }
@@ -382,23 +416,16 @@ func (s *OSMService) GetBoundaryStringFromResult(result *models.OSMProcessingRes
 
 	// Lấy boundary string từ tổng quát (nếu có)
 	if result.Boundaries != nil {
-		boundaryString := result.Boundaries.JSONString
-		fmt.Printf("Lấy boundary string từ kết quả đã xử lý:\n")
-		fmt.Printf("- Số coordinates: %d\n", result.Boundaries.TotalCoordinates)
-		fmt.Printf("- JSON string length: %d characters\n", len(boundaryString))
-		return boundaryString
+		return result.Boundaries.JSONString
 	}
 
 	// Nếu không có tổng quát, lấy từ Administrative entities
 	if result.Administrative != nil {
-		fmt.Printf("Lấy boundary string từ Administrative entities:\n")
-
 		// Lấy từ communes trước (thường có boundary chi tiết hơn)
 		if communes, exists := result.Administrative["communes"]; exists && len(communes) > 0 {
 			for _, commune := range communes {
 				if commune.Boundary != "" {
-					fmt.Printf("- Từ commune '%s' (ID: %d): %d characters\n",
-						commune.Name, commune.ID, len(commune.Boundary))
+					slog.Debug("Lấy boundary string từ commune", "member_relation", commune.ID, "name", commune.Name)
 					return commune.Boundary
 				}
 			}
@@ -408,42 +435,38 @@ func (s *OSMService) GetBoundaryStringFromResult(result *models.OSMProcessingRes
 		if provinces, exists := result.Administrative["provinces"]; exists && len(provinces) > 0 {
 			for _, province := range provinces {
 				if province.Boundary != "" {
-					fmt.Printf("- Từ province '%s' (ID: %d): %d characters\n",
-						province.Name, province.ID, len(province.Boundary))
+					slog.Debug("Lấy boundary string từ province", "member_relation", province.ID, "name", province.Name)
 					return province.Boundary
 				}
 			}
 		}
 	}
 
-	fmt.Printf("Không tìm thấy boundary string trong kết quả\n")
+	slog.Warn("Không tìm thấy boundary string trong kết quả")
 	return ""
 }
 
-func (s *OSMService) UpdateStringBoundaryToDatabase(name string, level int, maxLat, minLat, maxLon, minLon, lonCenter, latCenter float64, maTT string) error {
+func (s *OSMService) UpdateStringBoundaryToDatabase(ctx context.Context, name string, level int, maxLat, minLat, maxLon, minLon, lonCenter, latCenter float64, maTT string) error {
 	if s.dmTTRepo == nil || s.dmPhuongXaRepo == nil {
 		return fmt.Errorf("database repositories not initialized, use NewOSMServiceWithDB()")
 	}
 
 	switch level {
 	case 4: // Tỉnh/thành phố
-		tt, err := s.dmTTRepo.GetByName(name)
+		maTT, err := s.resolveProvinceCode(ctx, "", name)
 		if err != nil {
-			return fmt.Errorf("không thể lấy dữ liệu tỉnh/thành phố từ database: %w", err)
-		}
-		if tt == nil {
-			return fmt.Errorf("không tìm thấy tỉnh/thành phố '%s' trong database", name)
+			return err
 		}
-		return s.dmTTRepo.UpdateDataAddressByMaTT(tt.MaTT, &maxLat, &minLat, &maxLon, &minLon, &lonCenter, &latCenter)
+		return s.updateProvinceBoundary(ctx, maTT, maxLat, minLat, maxLon, minLon, lonCenter, latCenter)
 	case 6: // Xã/phường
-		px, err := s.dmPhuongXaRepo.GetByName(name, maTT)
+		px, err := s.dmPhuongXaRepo.GetByName(ctx, name, maTT)
 		if err != nil {
 			return fmt.Errorf("không thể lấy dữ liệu xã/phường từ database: %w", err)
 		}
-		if px == nil {
+		if px == nil || px.MaPhuongXa == "" {
 			return fmt.Errorf("không tìm thấy xã/phường '%s' trong database", name)
 		}
-		return s.dmPhuongXaRepo.UpdateDataAddressByMaPhuongXa(px.MaPhuongXa, &maxLat, &minLat, &maxLon, &minLon, &lonCenter, &latCenter)
+		return s.updateCommuneBoundary(ctx, px.MaPhuongXa, maxLat, minLat, maxLon, minLon, lonCenter, latCenter)
 	default:
 		return fmt.Errorf("level '%d' không được hỗ trợ", level)
 	}
@@ -451,16 +474,19 @@ func (s *OSMService) UpdateStringBoundaryToDatabase(name string, level int, maxL
 
 // CreatePolygonFromWaysAndNodes tạo polygon từ ways và nodes
 func (s *OSMService) CreatePolygonFromWaysAndNodes(ways []models.WayAddress, nodes []models.Address) ([][][]float64, error) {
-	fmt.Printf("\n=== TẠO POLYGON TỪ WAYS VÀ NOTES ===\n")
+	polygons, _, err := s.buildPolygons(ways, nodes)
+	return polygons, err
+}
 
+// buildPolygons tạo polygon từ ways và nodes; approximated = true khi các way không nối được
+// thành vòng khép kín và polygon được thay bằng convex hull
+func (s *OSMService) buildPolygons(ways []models.WayAddress, nodes []models.Address) (polygons [][][]float64, approximated bool, err error) {
 	// Xây dựng map cho nodes với ID là key, value là [lat, lon]
 	nodeMap := make(map[int64][]float64)
 	for _, node := range nodes {
 		nodeMap[node.ID] = []float64{node.Lat, node.Lon}
 	}
 
-	fmt.Printf("Created node map with %d nodes\n", len(nodeMap))
-
 	// Tạo danh sách WayCoordinates từ ways và note
 	var wayCoords []WayCoordinates
 	var closedPolygons [][][]float64 // Lưu polygons đóng riêng biệt
@@ -486,7 +512,6 @@ func (s *OSMService) CreatePolygonFromWaysAndNodes(ways []models.WayAddress, nod
 				coords[0][1] == coords[len(coords)-1][1]
 
 			if isClosed {
-				fmt.Printf("Found closed polygon (way %d) with %d points\n", way.ID, len(coords))
 				closedPolygons = append(closedPolygons, coords)
 			} else {
 				wayCoords = append(wayCoords, WayCoordinates{
@@ -505,7 +530,7 @@ func (s *OSMService) CreatePolygonFromWaysAndNodes(ways []models.WayAddress, nod
 		}
 	}
 
-	fmt.Printf("Tạo %d closed polygons, %d way coordinates\n", len(closedPolygons), len(wayCoords))
+	slog.Debug("Tạo polygon từ ways", "nodes", len(nodeMap), "closed_polygons", len(closedPolygons), "open_ways", len(wayCoords))
 
 	var allPolygons [][][]float64
 
@@ -518,10 +543,10 @@ func (s *OSMService) CreatePolygonFromWaysAndNodes(ways []models.WayAddress, nod
 	if len(wayCoords) > 0 {
 		polygon, err := s.buildConnectedPath(wayCoords)
 		if err != nil {
-			fmt.Printf("Warning: buildConnectedPath failed: %v\n", err)
 			// Fallback: sử dụng convex hull từ ways & node
 			polygon = s.fallbackPolygonConstruction(wayCoords)
-			fmt.Printf("Using fallback convex hull with %d points\n", len(polygon))
+			slog.Warn("buildConnectedPath failed, dùng convex hull", "error", err, "points", len(polygon))
+			approximated = true
 		}
 
 		if len(polygon) > 0 {
@@ -530,15 +555,12 @@ func (s *OSMService) CreatePolygonFromWaysAndNodes(ways []models.WayAddress, nod
 	}
 
 	if len(allPolygons) == 0 {
-		return nil, fmt.Errorf("no valid polygons found")
+		return nil, false, fmt.Errorf("no valid polygons found")
 	}
 
-	fmt.Printf("Final result: %d polygons\n", len(allPolygons))
-	for i, polygon := range allPolygons {
-		fmt.Printf("Polygon %d: %d coordinates\n", i+1, len(polygon))
-	}
+	slog.Debug("Đã tạo polygon", "polygons", len(allPolygons))
 
-	return allPolygons, nil
+	return allPolygons, approximated, nil
 }
 
 // buildConnectedPath
@@ -722,61 +744,97 @@ func (s *OSMService) cross(p1, p2, p3 []float64) float64 {
 }
 
 // UpdatePolygonToDatabase lưu polygon data vào database
-func (s *OSMService) UpdatePolygonToDatabase(name string, level int, polygonData string, maTT string) error {
+func (s *OSMService) UpdatePolygonToDatabase(ctx context.Context, name string, level int, polygonData string, maTT string) error {
 	if s.dmTTRepo == nil || s.dmPhuongXaRepo == nil {
 		return fmt.Errorf("database repositories not initialized, use NewOSMServiceWithDB()")
 	}
 
 	switch level {
 	case 4: // Tỉnh/thành phố
-		tt, err := s.dmTTRepo.GetByName(name)
+		maTT, err := s.resolveProvinceCode(ctx, "", name)
 		if err != nil {
-			return fmt.Errorf("không thể lấy dữ liệu tỉnh/thành phố từ database: %w", err)
-		}
-		if tt == nil {
-			return fmt.Errorf("không tìm thấy tỉnh/thành phố '%s' trong database", name)
+			return err
 		}
-
-		if err = HSet(redisHashProvincePolygon, tt.MaTT, polygonData); err != nil {
-			return fmt.Errorf("không thể lưu polygon data vào redis: %w", err)
-		}
-
-		return s.dmTTRepo.UpdatePolygonDataByMaTT(tt.MaTT, &polygonData)
+		return s.updateProvincePolygon(ctx, maTT, polygonData)
 	case 6: // Xã/phường
 		// TODO: Implement for communes if needed
-		px, err := s.dmPhuongXaRepo.GetByName(name, maTT)
+		px, err := s.dmPhuongXaRepo.GetByName(ctx, name, maTT)
 		if err != nil {
 			return fmt.Errorf("không thể lấy dữ liệu xã/phường từ database: %w", err)
 		}
-		if px == nil {
+		if px == nil || px.MaPhuongXa == "" {
 			return fmt.Errorf("không tìm thấy xã/phường '%s' trong database", name)
 		}
-
-		if err = HSet(redisHashWardPolygon, px.MaPhuongXa, polygonData); err != nil {
-			return fmt.Errorf("không thể lưu polygon data vào redis: %w", err)
-		}
-
-		return s.dmPhuongXaRepo.UpdatePolygonDataByMaPhuongXa(px.MaPhuongXa, &polygonData)
+		return s.updateCommunePolygon(ctx, px.MaPhuongXa, polygonData)
 	default:
 		return fmt.Errorf("level '%d' không được hỗ trợ", level)
 	}
 }
 
-// FindCommuneByCoordinate tìm xã/phường từ tọa độ lat/lon và mã tỉnh thành
-func (s *OSMService) FindCommuneByCoordinate(provinceCode string, lat, lon float64) (*entities.DmPhuongXa, error) {
+// resolveProvinceCode trả về maTT nếu đã có, nếu không thì tra MATT của tỉnh/thành phố theo tên
+func (s *OSMService) resolveProvinceCode(ctx context.Context, maTT, name string) (string, error) {
+	if maTT != "" {
+		return maTT, nil
+	}
+	if s.dmTTRepo == nil {
+		return "", fmt.Errorf("database repositories not initialized, use NewOSMServiceWithDB()")
+	}
+	tt, err := s.dmTTRepo.GetByName(ctx, name)
+	if err != nil {
+		return "", fmt.Errorf("không thể lấy dữ liệu tỉnh/thành phố từ database: %w", err)
+	}
+	if tt == nil {
+		return "", fmt.Errorf("không tìm thấy tỉnh/thành phố '%s' trong database", name)
+	}
+	return tt.MaTT, nil
+}
+
+// updateProvinceBoundary lưu bbox và tâm của tỉnh/thành phố theo MATT
+func (s *OSMService) updateProvinceBoundary(ctx context.Context, maTT string, maxLat, minLat, maxLon, minLon, lonCenter, latCenter float64) error {
+	return metrics.CountWriteError(metrics.TargetOracle, s.dmTTRepo.UpdateDataAddressByMaTT(ctx, maTT, &maxLat, &minLat, &maxLon, &minLon, &lonCenter, &latCenter))
+}
+
+// updateProvincePolygon lưu danh sách URL polygon của tỉnh/thành phố theo MATT vào Redis và POLYGON_DATA
+func (s *OSMService) updateProvincePolygon(ctx context.Context, maTT, polygonData string) error {
+	if err := metrics.CountWriteError(metrics.TargetRedis, HSet(ctx, redisHashProvincePolygon, maTT, polygonData)); err != nil {
+		return fmt.Errorf("không thể lưu polygon data vào redis: %w", err)
+	}
+	return metrics.CountWriteError(metrics.TargetOracle, s.dmTTRepo.UpdatePolygonDataByMaTT(ctx, maTT, &polygonData))
+}
+
+// updateCommuneBoundary lưu bbox và tâm của xã/phường theo MA_PHUONG_XA
+func (s *OSMService) updateCommuneBoundary(ctx context.Context, maPhuongXa string, maxLat, minLat, maxLon, minLon, lonCenter, latCenter float64) error {
+	return metrics.CountWriteError(metrics.TargetOracle, s.dmPhuongXaRepo.UpdateDataAddressByMaPhuongXa(ctx, maPhuongXa, &maxLat, &minLat, &maxLon, &minLon, &lonCenter, &latCenter))
+}
+
+// updateCommunePolygon lưu polygon của xã/phường theo MA_PHUONG_XA vào Redis và POLYGON_DATA
+func (s *OSMService) updateCommunePolygon(ctx context.Context, maPhuongXa, polygonData string) error {
+	if err := metrics.CountWriteError(metrics.TargetRedis, HSet(ctx, redisHashWardPolygon, maPhuongXa, polygonData)); err != nil {
+		return fmt.Errorf("không thể lưu polygon data vào redis: %w", err)
+	}
+	return metrics.CountWriteError(metrics.TargetOracle, s.dmPhuongXaRepo.UpdatePolygonDataByMaPhuongXa(ctx, maPhuongXa, &polygonData))
+}
+
+// FindCommuneByCoordinate tìm xã/phường từ tọa độ lat/lon và mã tỉnh thành.
+//
+// Deprecated: service khác dùng client.Client.Lookup (GET /lookup) thay vì import package này.
+func (s *OSMService) FindCommuneByCoordinate(ctx context.Context, provinceCode string, lat, lon float64) (*entities.DmPhuongXa, error) {
 	if s.dmTTRepo == nil {
 		return nil, fmt.Errorf("database repositories not initialized, use NewOSMServiceWithDB()")
 	}
 
-	return s.dmTTRepo.FindCommuneByCoordinate(provinceCode, lat, lon)
+	return s.dmTTRepo.FindCommuneByCoordinate(ctx, provinceCode, lat, lon)
 }
 
-func (s *OSMService) UpdateLatLonCenterForPhuongXa() error {
-	PhuongXaUpdate, err := s.dmPhuongXaRepo.GetWhenHavePolygonAndCenterNull()
+func (s *OSMService) UpdateLatLonCenterForPhuongXa(ctx context.Context) error {
+	PhuongXaUpdate, err := s.dmPhuongXaRepo.GetWhenHavePolygonAndCenterNull(ctx)
 	if err != nil {
 		return fmt.Errorf("không thể lấy dữ liệu xã/phường từ database: %w", err)
 	}
 	for _, phuongXa := range PhuongXaUpdate {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		polygonData := phuongXa.Polygon
 		if polygonData == nil {
 			continue
@@ -787,39 +845,40 @@ func (s *OSMService) UpdateLatLonCenterForPhuongXa() error {
 		var polygons [][2]float64
 		err := json.Unmarshal([]byte(*polygonData), &polygons)
 		if err != nil || len(polygons) == 0 {
-			log.Printf("Không thể parse polygonData cho phường/xã %s: %v\n", phuongXa.MaPhuongXa, err)
+			slog.Warn("Không thể parse polygonData", "commune", phuongXa.MaPhuongXa, "error", err)
 			continue
 		}
 		// Lấy polygon đầu tiên để xử lý centroid
 		latCenter, lonCenter := util.PolygonInteriorCentroid(polygons)
 
-		err = s.dmPhuongXaRepo.UpdateLatLonCenterByMaPhuongXa(phuongXa.MaPhuongXa, &latCenter, &lonCenter)
+		err = s.dmPhuongXaRepo.UpdateLatLonCenterByMaPhuongXa(ctx, phuongXa.MaPhuongXa, &latCenter, &lonCenter)
 		if err != nil {
 			return fmt.Errorf("không thể cập nhật tọa độ trung tâm của xã/phường: %w", err)
 		}
 
-		log.Printf("Cập nhật tọa độ trung tâm của xã/phường %s: %f, %f\n", phuongXa.MaPhuongXa, latCenter, lonCenter)
+		slog.Debug("Cập nhật tọa độ trung tâm", "commune", phuongXa.MaPhuongXa, "lat", latCenter, "lon", lonCenter)
 	}
 	return nil
 }
 
 // DownloadAllPolygonFiles downloads all polygon files from MinIO and saves them to the polygon directory
-func (s *OSMService) DownloadAllPolygonFiles() (int, error) {
-	if err := ensureMinioClient(); err != nil {
+func (s *OSMService) DownloadAllPolygonFiles(ctx context.Context) (int, error) {
+	return s.DownloadPolygonFilesTo(ctx, appConfig.Storage.PolygonDir)
+}
+
+// DownloadPolygonFilesTo downloads all polygon files from MinIO into polygonDir
+func (s *OSMService) DownloadPolygonFilesTo(ctx context.Context, polygonDir string) (int, error) {
+	if err := ensureMinioClient(ctx); err != nil {
 		return 0, fmt.Errorf("failed to initialize MinIO client: %w", err)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
 	defer cancel()
 
-	// Get bucket name from env or use default
-	bucket := os.Getenv("MINIO_BUCKET_NAME")
-	if bucket == "" {
-		bucket = "kbtt-htn-media"
-	}
+	// Cùng bucket với UploadPolygonData
+	bucket := polygonBucket()
 
 	// Create polygon directory if it doesn't exist
-	polygonDir := "polygon"
 	if err := os.MkdirAll(polygonDir, 0755); err != nil {
 		return 0, fmt.Errorf("failed to create polygon directory: %w", err)
 	}
@@ -841,9 +900,9 @@ func (s *OSMService) DownloadAllPolygonFiles() (int, error) {
 		}
 
 		// Download the file
-		data, err := DownloadFile(bucket, object.Key)
+		data, err := DownloadFile(ctx, bucket, object.Key)
 		if err != nil {
-			log.Printf("Failed to download %s: %v", object.Key, err)
+			slog.Warn("Failed to download polygon file", "key", object.Key, "error", err)
 			continue
 		}
 
@@ -856,14 +915,14 @@ func (s *OSMService) DownloadAllPolygonFiles() (int, error) {
 		// Save to polygon directory
 		filePath := filepath.Join(polygonDir, fileName)
 		if err := os.WriteFile(filePath, data, 0644); err != nil {
-			log.Printf("Failed to save file %s: %v", filePath, err)
+			slog.Warn("Failed to save polygon file", "path", filePath, "error", err)
 			continue
 		}
 
-		log.Printf("Downloaded: %s (%d bytes)", filePath, len(data))
+		slog.Debug("Downloaded polygon file", "path", filePath, "bytes", len(data))
 		downloadedCount++
 	}
 
-	log.Printf("Downloaded %d polygon files to %s directory", downloadedCount, polygonDir)
+	slog.Info("Downloaded polygon files", "count", downloadedCount, "dir", polygonDir)
 	return downloadedCount, nil
 }
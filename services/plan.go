@@ -0,0 +1,361 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+	"tool-map/entities"
+	"tool-map/models"
+	"tool-map/util"
+)
+
+const (
+	PlanActionUpdate     = "update"      // sẽ ghi lại boundary + polygon
+	PlanActionRecordOnly = "record_only" // hình học giữ nguyên, chỉ cập nhật phiên bản OSM
+	PlanActionNotFound   = "not_found"   // không có dòng DM tương ứng, sẽ bị bỏ qua
+	PlanActionFailed     = "failed"      // fetch hoặc dựng polygon lỗi
+)
+
+// PlannedChange mô tả thay đổi dự kiến trên một dòng DMTT/DM_PHUONG_XA ở chế độ dry-run
+type PlannedChange struct {
+	Level      int    `json:"level"`
+	Table      string `json:"table"`
+	Code       string `json:"code,omitempty"` // MATT hoặc MA_PHUONG_XA
+	Name       string `json:"name"`
+	MaTT       string `json:"maTT,omitempty"`
+	RelationID int64  `json:"relationId,omitempty"`
+	Action     string `json:"action"`
+	Status     string `json:"status,omitempty"` // trạng thái từ DetectChange
+	OldVersion *int   `json:"oldVersion,omitempty"`
+	NewVersion int    `json:"newVersion,omitempty"`
+
+	OldBounds         *models.Bounds     `json:"oldBounds,omitempty"`
+	NewBounds         *models.Bounds     `json:"newBounds,omitempty"`
+	OldCenter         *models.Coordinate `json:"oldCenter,omitempty"`
+	NewCenter         *models.Coordinate `json:"newCenter,omitempty"`
+	CenterShiftMeters *float64           `json:"centerShiftMeters,omitempty"`
+	OldAreaKm2        *float64           `json:"oldAreaKm2,omitempty"`
+	NewAreaKm2        *float64           `json:"newAreaKm2,omitempty"`
+	AreaDeltaKm2      *float64           `json:"areaDeltaKm2,omitempty"`
+	OldPolygons       int                `json:"oldPolygons"`
+	NewPolygons       int                `json:"newPolygons"`
+	OldVertices       int                `json:"oldVertices"`
+	NewVertices       int                `json:"newVertices"`
+
+	Warning string `json:"warning,omitempty"`
+}
+
+// ChangePlan gom các thay đổi dự kiến của một lần chạy dry-run; an toàn khi nhiều worker cùng ghi
+type ChangePlan struct {
+	mu        sync.Mutex
+	CreatedAt string          `json:"createdAt"`
+	Units     []PlannedChange `json:"units"`
+	Unchanged int             `json:"unchanged"` // số đơn vị không có thay đổi nào
+}
+
+// NewChangePlan tạo plan rỗng
+func NewChangePlan() *ChangePlan {
+	return &ChangePlan{CreatedAt: time.Now().Format(time.RFC3339)}
+}
+
+// Add thêm một thay đổi dự kiến
+func (p *ChangePlan) Add(change PlannedChange) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.Units = append(p.Units, change)
+}
+
+// recordChange gắn kết quả DetectChange vào dòng đã lên kế hoạch, hoặc thêm dòng record_only
+func (p *ChangePlan) recordChange(change *UnitChange) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i := range p.Units {
+		unit := &p.Units[i]
+		if unit.Level == change.Level && unit.Code == change.Code {
+			unit.Status = change.Status
+			unit.OldVersion = change.OldVersion
+			unit.NewVersion = change.NewVersion
+			return
+		}
+	}
+	if change.Status == ChangeStatusUnchanged {
+		p.Unchanged++
+		return
+	}
+	p.Units = append(p.Units, PlannedChange{
+		Level:      change.Level,
+		Table:      tableForLevel(change.Level),
+		Code:       change.Code,
+		Name:       change.Name,
+		RelationID: change.RelationID,
+		Action:     PlanActionRecordOnly,
+		Status:     change.Status,
+		OldVersion: change.OldVersion,
+		NewVersion: change.NewVersion,
+	})
+}
+
+// Sorted trả về các thay đổi theo thứ tự tỉnh trước, rồi theo MATT và mã
+func (p *ChangePlan) Sorted() []PlannedChange {
+	p.mu.Lock()
+	units := append([]PlannedChange(nil), p.Units...)
+	p.mu.Unlock()
+
+	sort.SliceStable(units, func(i, j int) bool {
+		a, b := units[i], units[j]
+		if a.Level != b.Level {
+			return a.Level < b.Level
+		}
+		if a.MaTT != b.MaTT {
+			return a.MaTT < b.MaTT
+		}
+		return a.Code < b.Code
+	})
+	return units
+}
+
+// Counts đếm số dòng theo action
+func (p *ChangePlan) Counts() map[string]int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	counts := make(map[string]int)
+	for _, unit := range p.Units {
+		counts[unit.Action]++
+	}
+	return counts
+}
+
+// WriteJSON ghi plan dạng JSON
+func (p *ChangePlan) WriteJSON(w io.Writer) error {
+	out := struct {
+		CreatedAt string          `json:"createdAt"`
+		Counts    map[string]int  `json:"counts"`
+		Unchanged int             `json:"unchanged"`
+		Units     []PlannedChange `json:"units"`
+	}{p.CreatedAt, p.Counts(), p.Unchanged, p.Sorted()}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(out)
+}
+
+// WriteText ghi plan dạng văn bản để review trước khi import thật
+func (p *ChangePlan) WriteText(w io.Writer) error {
+	units := p.Sorted()
+	counts := p.Counts()
+
+	fmt.Fprintf(w, "KẾ HOẠCH THAY ĐỔI (dry-run, %s)\n", p.CreatedAt)
+	fmt.Fprintf(w, "Cập nhật: %d, chỉ phiên bản: %d, không tìm thấy: %d, lỗi: %d, không đổi: %d\n\n",
+		counts[PlanActionUpdate], counts[PlanActionRecordOnly], counts[PlanActionNotFound], counts[PlanActionFailed], p.Unchanged)
+
+	for _, u := range units {
+		fmt.Fprintf(w, "[%s] %s %s '%s'", u.Action, u.Table, u.Code, u.Name)
+		if u.RelationID != 0 {
+			fmt.Fprintf(w, " (relation %d)", u.RelationID)
+		}
+		if u.Status != "" {
+			fmt.Fprintf(w, " %s", u.Status)
+		}
+		if u.NewVersion != 0 {
+			fmt.Fprintf(w, " v%s→v%d", formatVersion(u.OldVersion), u.NewVersion)
+		}
+		fmt.Fprintln(w)
+
+		if u.Action == PlanActionUpdate {
+			fmt.Fprintf(w, "    bbox: %s → %s\n", formatBounds(u.OldBounds), formatBounds(u.NewBounds))
+			if u.CenterShiftMeters != nil {
+				fmt.Fprintf(w, "    tâm dịch chuyển: %.1f m\n", *u.CenterShiftMeters)
+			}
+			if u.AreaDeltaKm2 != nil {
+				fmt.Fprintf(w, "    diện tích: %.3f → %.3f km² (%+.3f km², %s)\n",
+					*u.OldAreaKm2, *u.NewAreaKm2, *u.AreaDeltaKm2, formatPercent(*u.AreaDeltaKm2, *u.OldAreaKm2))
+			} else if u.NewAreaKm2 != nil {
+				fmt.Fprintf(w, "    diện tích: - → %.3f km²\n", *u.NewAreaKm2)
+			}
+			fmt.Fprintf(w, "    polygon: %d → %d, đỉnh: %d → %d (%+d)\n",
+				u.OldPolygons, u.NewPolygons, u.OldVertices, u.NewVertices, u.NewVertices-u.OldVertices)
+		}
+		if u.Warning != "" {
+			fmt.Fprintf(w, "    cảnh báo: %s\n", u.Warning)
+		}
+	}
+	return nil
+}
+
+// SetDryRun bật chế độ dry-run: Publish* và RecordImport chỉ ghi vào plan,
+// không ghi Oracle, Redis, MinIO hay file cây hành chính. plan = nil tắt dry-run.
+func (s *OSMService) SetDryRun(plan *ChangePlan) {
+	s.plan = plan
+}
+
+// DryRun cho biết service đang ở chế độ dry-run
+func (s *OSMService) DryRun() bool {
+	return s.plan != nil
+}
+
+// planUnit tính thay đổi dự kiến của một tỉnh/xã thay cho PublishProvince/PublishCommune; xã/phường được
+// đọc theo maPhuongXa như PublishCommune ghi, tỉnh theo maTT (hoặc theo tên khi chưa có mã)
+func (s *OSMService) planUnit(ctx context.Context, relationID int64, maPhuongXa, name string, level int, maTT string, result *models.OSMProcessingResult) error {
+	if s.dmTTRepo == nil || s.dmPhuongXaRepo == nil {
+		return fmt.Errorf("database repositories not initialized, use NewOSMServiceWithDB()")
+	}
+	if result.BasicInfo == nil || result.BasicInfo.Bounds == nil {
+		return fmt.Errorf("'%s' không có dữ liệu bounds", name)
+	}
+
+	planned := PlannedChange{Level: level, Table: tableForLevel(level), Name: name, MaTT: maTT, RelationID: relationID, Action: PlanActionUpdate}
+
+	var stored entities.AddressBase
+	var oldRings [][][2]float64
+	switch level {
+	case 4:
+		var tt *entities.DmTT
+		var err error
+		if maTT != "" {
+			tt, err = s.dmTTRepo.GetByMaTT(ctx, maTT)
+		} else {
+			tt, err = s.dmTTRepo.GetByName(ctx, name)
+		}
+		if err != nil {
+			return fmt.Errorf("không thể lấy dữ liệu tỉnh/thành phố từ database: %w", err)
+		}
+		if tt == nil {
+			planned.Action = PlanActionNotFound
+			s.plan.Add(planned)
+			return nil
+		}
+		planned.Code, stored = tt.MaTT, tt.AddressBase
+		if tt.Polygon != nil && *tt.Polygon != "" {
+			// Polygon tỉnh nằm trên MinIO; chỉ đọc để so sánh
+			unit, err := s.provinceGeometry(ctx, tt)
+			if err != nil {
+				planned.Warning = fmt.Sprintf("không đọc được polygon hiện tại: %v", err)
+			} else {
+				oldRings = unit.Polygons
+			}
+		}
+	case 6:
+		px, err := s.dmPhuongXaRepo.GetByMaPhuongXa(ctx, maPhuongXa)
+		if err != nil {
+			return fmt.Errorf("không thể lấy dữ liệu xã/phường từ database: %w", err)
+		}
+		if px == nil {
+			planned.Action = PlanActionNotFound
+			s.plan.Add(planned)
+			return nil
+		}
+		planned.Code, stored = px.MaPhuongXa, px.AddressBase
+		if px.Polygon != nil && *px.Polygon != "" {
+			ring, err := ParsePolygonData(*px.Polygon)
+			if err != nil {
+				planned.Warning = fmt.Sprintf("không đọc được polygon hiện tại: %v", err)
+			} else {
+				oldRings = [][][2]float64{ring}
+			}
+		}
+	default:
+		return fmt.Errorf("level '%d' không được hỗ trợ", level)
+	}
+
+	polygons, approximated, err := s.buildPolygons(result.Ways, result.Nodes)
+	if err != nil {
+		return fmt.Errorf("lỗi khi tạo polygon: %w", err)
+	}
+	if approximated {
+		s.report.approximated(level, relationID, name, maTT, polygons)
+	}
+	newRings := toRings(polygons)
+	if level == 6 && len(newRings) > 1 {
+		// PublishCommune chỉ lưu polygon chính
+		newRings = newRings[:1]
+	}
+
+	// Bbox và tâm: giá trị PublishProvince/PublishCommune sẽ ghi
+	bounds := *result.BasicInfo.Bounds
+	planned.NewBounds = &bounds
+	if stored.MinLat != nil && stored.MaxLat != nil && stored.MinLon != nil && stored.MaxLon != nil {
+		planned.OldBounds = &models.Bounds{MinLat: *stored.MinLat, MaxLat: *stored.MaxLat, MinLon: *stored.MinLon, MaxLon: *stored.MaxLon, HasData: true}
+	}
+	newCenter := models.Coordinate{}
+	if len(result.CenterPoints) > 0 {
+		newCenter.Lat, newCenter.Lon = result.CenterPoints[0].Lat, result.CenterPoints[0].Lon
+	}
+	planned.NewCenter = &newCenter
+	if stored.LatCenter != nil && stored.LonCenter != nil {
+		planned.OldCenter = &models.Coordinate{Lat: *stored.LatCenter, Lon: *stored.LonCenter}
+		shift := util.HaversineMeters(planned.OldCenter.Lat, planned.OldCenter.Lon, newCenter.Lat, newCenter.Lon)
+		planned.CenterShiftMeters = &shift
+	}
+
+	planned.OldPolygons, planned.OldVertices = len(oldRings), countVertices(oldRings)
+	planned.NewPolygons, planned.NewVertices = len(newRings), countVertices(newRings)
+	newArea := ringsAreaKm2(newRings)
+	planned.NewAreaKm2 = &newArea
+	if len(oldRings) > 0 {
+		oldArea := ringsAreaKm2(oldRings)
+		delta := newArea - oldArea
+		planned.OldAreaKm2, planned.AreaDeltaKm2 = &oldArea, &delta
+	}
+
+	s.plan.Add(planned)
+	slog.Debug("Dry-run", "table", planned.Table, "name", name, "relation", relationID, "old_vertices", planned.OldVertices, "new_vertices", planned.NewVertices)
+	return nil
+}
+
+func tableForLevel(level int) string {
+	if level == 4 {
+		return "DMTT"
+	}
+	return "DM_PHUONG_XA"
+}
+
+// levelLabel là nhãn cấp đơn vị dùng cho metrics
+func levelLabel(level int) string {
+	if level == 4 {
+		return "province"
+	}
+	return "commune"
+}
+
+func countVertices(rings [][][2]float64) int {
+	count := 0
+	for _, ring := range rings {
+		count += len(ring)
+	}
+	return count
+}
+
+func ringsAreaKm2(rings [][][2]float64) float64 {
+	var area float64
+	for _, ring := range rings {
+		area += util.RingAreaKm2(ring)
+	}
+	return area
+}
+
+func formatBounds(b *models.Bounds) string {
+	if b == nil {
+		return "-"
+	}
+	return fmt.Sprintf("[%.6f, %.6f, %.6f, %.6f]", b.MinLat, b.MinLon, b.MaxLat, b.MaxLon)
+}
+
+func formatVersion(v *int) string {
+	if v == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%d", *v)
+}
+
+func formatPercent(delta, base float64) string {
+	if base == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%+.2f%%", delta/base*100)
+}
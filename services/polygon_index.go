@@ -0,0 +1,177 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+	"tool-map/pkg/admingeo"
+)
+
+// PolygonIndex là chỉ mục polygon trong bộ nhớ để tra cứu tọa độ không cần DB (vector tile, gRPC, geocode).
+// Định dạng snapshot và quy tắc point-in-polygon nằm ở pkg/admingeo, dùng chung với các service khác.
+type PolygonIndex = admingeo.Index
+
+// IndexedUnit là một đơn vị hành chính trong PolygonIndex
+type IndexedUnit = admingeo.Unit
+
+// indexRetryInterval là thời gian chờ trước khi thử dựng lại chỉ mục sau một lần dựng lại lỗi
+const indexRetryInterval = time.Minute
+
+// ErrIndexUnavailable là lỗi khi chỉ mục polygon dùng chung chưa nạp được
+var ErrIndexUnavailable = errors.New("chỉ mục polygon chưa sẵn sàng")
+
+// SharedPolygonIndex nạp PolygonIndex một lần cho mọi nơi dùng trong serve (tra cứu, vector tile, gRPC), ở lần
+// gọi Get đầu tiên: từ các file tiles.index nếu có, ngược lại từ DB (POLYGON_DATA và polygon tỉnh trên MinIO).
+// Invalidate đánh dấu chỉ mục cũ (sau khi job import publish dữ liệu mới) và dựng lại ở nền; trong lúc dựng,
+// Get vẫn trả chỉ mục cũ.
+type SharedPolygonIndex struct {
+	service *OSMService
+
+	mu         sync.Mutex
+	index      *PolygonIndex
+	stale      bool
+	generation uint64     // tăng mỗi lần Invalidate, để bỏ kết quả của lần nạp bắt đầu trước đó
+	loading    *indexLoad // lần nạp đang chạy, nil nếu không có
+	retryAt    time.Time  // dựng lại lỗi thì chờ tới thời điểm này mới thử lại
+	onReload   []func()
+}
+
+// indexLoad là một lần nạp chỉ mục; done đóng khi index, err đã có
+type indexLoad struct {
+	done  chan struct{}
+	index *PolygonIndex
+	err   error
+}
+
+// NewSharedPolygonIndex tạo bộ nạp chỉ mục polygon dùng chung
+func (s *OSMService) NewSharedPolygonIndex() *SharedPolygonIndex {
+	return &SharedPolygonIndex{service: s}
+}
+
+// Get trả về chỉ mục, nạp ở lần gọi đầu. Việc nạp không bị hủy theo ctx (các lời gọi khác có thể đang chờ
+// cùng lần nạp), ctx chỉ giới hạn thời gian chờ của lời gọi này. Lỗi không được nhớ để lần gọi sau thử lại.
+func (p *SharedPolygonIndex) Get(ctx context.Context) (*PolygonIndex, error) {
+	p.mu.Lock()
+	if p.index != nil {
+		if p.stale && time.Now().After(p.retryAt) {
+			p.startLoad(ctx)
+		}
+		index := p.index
+		p.mu.Unlock()
+		return index, nil
+	}
+	load := p.startLoad(ctx)
+	p.mu.Unlock()
+
+	select {
+	case <-load.done:
+		return load.index, load.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Invalidate đánh dấu chỉ mục đã cũ và dựng lại ở nền nếu chỉ mục đã được nạp. Chỉ mục nạp từ tiles.index
+// không đổi theo DB nên không bị ảnh hưởng.
+func (p *SharedPolygonIndex) Invalidate(ctx context.Context) {
+	if len(appConfig.Tiles.Index) > 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.generation++
+	if p.index != nil {
+		p.stale = true
+		p.startLoad(ctx)
+	}
+}
+
+// OnReload đăng ký fn được gọi mỗi khi chỉ mục mới thay chỉ mục cũ (không gọi ở lần nạp đầu),
+// ví dụ để xóa cache tile dựng từ chỉ mục cũ
+func (p *SharedPolygonIndex) OnReload(fn func()) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.onReload = append(p.onReload, fn)
+}
+
+// startLoad bắt đầu nạp chỉ mục nếu chưa có lần nạp nào đang chạy; gọi khi giữ p.mu
+func (p *SharedPolygonIndex) startLoad(ctx context.Context) *indexLoad {
+	if p.loading != nil {
+		return p.loading
+	}
+	load := &indexLoad{done: make(chan struct{})}
+	p.loading = load
+	go p.load(context.WithoutCancel(ctx), load, p.generation)
+	return load
+}
+
+func (p *SharedPolygonIndex) load(ctx context.Context, load *indexLoad, generation uint64) {
+	start := time.Now()
+	var index *PolygonIndex
+	var err error
+	if files := appConfig.Tiles.Index; len(files) > 0 {
+		index, err = LoadPolygonIndex(files...)
+	} else {
+		index, err = p.service.BuildPolygonIndex(ctx)
+	}
+
+	p.mu.Lock()
+	p.loading = nil
+	var hooks []func()
+	switch {
+	case err != nil && p.index != nil:
+		// Dựng lại ở nền: không ai chờ kết quả nên lỗi chỉ được log, Get tiếp tục trả chỉ mục cũ
+		p.retryAt = time.Now().Add(indexRetryInterval)
+		slog.Error("Không dựng lại được chỉ mục polygon, tiếp tục dùng chỉ mục cũ", "error", err)
+	case err != nil:
+		err = fmt.Errorf("%w: %w", ErrIndexUnavailable, err)
+	default:
+		slog.Info("Đã nạp chỉ mục polygon", "units", index.Len(), "duration", time.Since(start).Round(time.Millisecond))
+		if p.index != nil {
+			hooks = p.onReload
+		}
+		p.index = index
+		// Invalidate trong lúc nạp thì dữ liệu vừa đọc có thể đã cũ: lời gọi Get sau sẽ nạp lại
+		p.stale = generation != p.generation
+	}
+	p.mu.Unlock()
+
+	load.index, load.err = index, err
+	close(load.done)
+	for _, hook := range hooks {
+		hook()
+	}
+}
+
+// NewPolygonIndex tạo chỉ mục từ các đơn vị; mỗi vòng của UnitGeometry là một polygon không có lỗ
+func NewPolygonIndex(units []UnitGeometry) *PolygonIndex {
+	return admingeo.New(snapshotUnits(units))
+}
+
+// LoadPolygonIndex đọc chỉ mục từ một hoặc nhiều file GeoJSON (kết quả export -format geojson) hoặc snapshot
+// nhị phân (kết quả export -format index), ví dụ một file tỉnh và một file xã/phường
+func LoadPolygonIndex(paths ...string) (*PolygonIndex, error) {
+	index, err := admingeo.LoadFile(paths...)
+	if err != nil {
+		return nil, fmt.Errorf("không nạp được chỉ mục polygon: %w", err)
+	}
+	return index, nil
+}
+
+// writeIndexSnapshot ghi snapshot nhị phân cho LoadPolygonIndex và pkg/admingeo
+func writeIndexSnapshot(w io.Writer, units []UnitGeometry) error {
+	return admingeo.WriteSnapshot(w, snapshotUnits(units))
+}
+
+func snapshotUnits(units []UnitGeometry) []admingeo.SnapshotUnit {
+	result := make([]admingeo.SnapshotUnit, len(units))
+	for i := range units {
+		unit := &units[i]
+		result[i] = admingeo.SnapshotUnit{Level: unit.Level, Code: unit.Code, Name: unit.Name, MaTT: unit.MaTT, Polygons: unit.Polygons}
+	}
+	return result
+}
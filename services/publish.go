@@ -0,0 +1,181 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"tool-map/metrics"
+	"tool-map/models"
+)
+
+// PublishProvince tạo polygon tỉnh/thành phố, upload lên MinIO rồi lưu danh sách URL và boundary vào DB
+// theo maTT. maTT rỗng thì tra một lần theo tên rồi ghi theo mã tìm được.
+// Lỗi tạo/upload polygon chỉ được in ra; lỗi trả về là lỗi lưu boundary.
+// Ở chế độ dry-run chỉ ghi thay đổi dự kiến vào plan.
+func (s *OSMService) PublishProvince(ctx context.Context, relationID int64, maTT, name string, adminLevel int, result *models.OSMProcessingResult) error {
+	if s.plan != nil {
+		return s.planUnit(ctx, relationID, "", name, adminLevel, maTT, result)
+	}
+	if result.BasicInfo == nil || result.BasicInfo.Bounds == nil {
+		return fmt.Errorf("relation %d không có dữ liệu bounds", relationID)
+	}
+	maTT, err := s.resolveProvinceCode(ctx, maTT, name)
+	if err != nil {
+		return err
+	}
+
+	logger := slog.With("relation", relationID, "province", name, "ma_tt", maTT)
+
+	// Lấy boundary từ province
+	var lonCenter, latCenter float64
+	if len(result.CenterPoints) > 0 {
+		lonCenter = result.CenterPoints[0].Lon
+		latCenter = result.CenterPoints[0].Lat
+	}
+	maxLat := result.BasicInfo.Bounds.MaxLat
+	minLat := result.BasicInfo.Bounds.MinLat
+	maxLon := result.BasicInfo.Bounds.MaxLon
+	minLon := result.BasicInfo.Bounds.MinLon
+
+	// Tạo polygon từ ways và nodes
+	polygons, approximated, err := s.buildPolygons(result.Ways, result.Nodes)
+	if err != nil {
+		logger.Error("Lỗi khi tạo polygon", "error", err)
+	} else {
+		if approximated {
+			s.report.approximated(adminLevel, relationID, name, "", polygons)
+		}
+		metrics.PolygonsBuilt(levelLabel(adminLevel), len(polygons), approximated)
+		logger.Debug("Tạo thành công polygon", "polygons", len(polygons))
+		// Tạo mảng để lưu các URL MinIO sau khi upload polygons
+		var polygonUrls []string
+
+		// Xử lý từng polygon
+		for i, polygon := range polygons {
+			// Upload polygon data lên MinIO
+			polygonJSON, err := json.Marshal(polygon)
+			if err != nil {
+				logger.Error("Lỗi khi marshal polygon JSON", "polygon", i+1, "error", err)
+				continue
+			}
+
+			// Tạo tên file khác nhau cho mỗi polygon
+			var objectName string
+			if len(polygons) == 1 {
+				objectName = fmt.Sprintf("provinces_%d_polygon.txt", relationID)
+			} else {
+				objectName = fmt.Sprintf("provinces_%d_polygon_%d.txt", relationID, i+1)
+			}
+
+			uploadPolygonURL, err := UploadPolygonData(ctx, polygonJSON, objectName)
+			if metrics.CountWriteError(metrics.TargetMinIO, err) != nil {
+				logger.Error("Lỗi khi upload polygon lên MinIO", "object", objectName, "error", err)
+				continue
+			}
+
+			// Thêm url vào mảng lưu trữ
+			polygonUrls = append(polygonUrls, uploadPolygonURL)
+		}
+
+		// Convert mảng các url thành string dạng JSON
+		polygonUrlsJSON, err := json.Marshal(polygonUrls)
+		if err != nil {
+			logger.Error("Lỗi khi convert polygon URLs array sang string", "error", err)
+		} else {
+			// Lưu string mảng các url vào Redis và database
+			err = s.updateProvincePolygon(ctx, maTT, string(polygonUrlsJSON))
+			if err != nil {
+				logger.Error("Lỗi khi lưu mảng polygon URLs vào database", "error", err)
+			} else {
+				logger.Debug("Đã lưu mảng polygon URLs", "urls", len(polygonUrls))
+			}
+		}
+	}
+
+	// Lưu province vào database
+	err = s.updateProvinceBoundary(ctx, maTT, maxLat, minLat, maxLon, minLon, lonCenter, latCenter)
+	if err != nil {
+		return fmt.Errorf("lỗi khi lưu province vào database: %w", err)
+	}
+	logger.Info("Đã publish tỉnh/thành phố", "polygons", len(polygons))
+	return nil
+}
+
+// PublishCommune lưu boundary và polygon chính của xã/phường maPhuongXa (đã tra từ trước, ghi theo mã chứ không
+// tra lại theo tên) vào DB và Redis. Chỉ trả về nil khi cả boundary lẫn polygon đều đã được lưu.
+// Ở chế độ dry-run chỉ ghi vào plan.
+func (s *OSMService) PublishCommune(ctx context.Context, maPhuongXa, name string, adminLevel int, maTT string, result *models.OSMProcessingResult) error {
+	if s.plan != nil {
+		var relationID int64
+		if result.Relation != nil {
+			relationID = result.Relation.ID
+		}
+		return s.planUnit(ctx, relationID, maPhuongXa, name, adminLevel, maTT, result)
+	}
+	if s.dmPhuongXaRepo == nil {
+		return fmt.Errorf("database repositories not initialized, use NewOSMServiceWithDB()")
+	}
+	if result.BasicInfo == nil || result.BasicInfo.Bounds == nil {
+		return fmt.Errorf("xã/phường '%s' không có dữ liệu bounds", name)
+	}
+
+	logger := slog.With("commune", name, "ma_phuong_xa", maPhuongXa, "ma_tt", maTT)
+	if result.Relation != nil {
+		logger = logger.With("relation", result.Relation.ID)
+	}
+
+	// Lấy boundary từ commune
+	maxLat := result.BasicInfo.Bounds.MaxLat
+	minLat := result.BasicInfo.Bounds.MinLat
+	maxLon := result.BasicInfo.Bounds.MaxLon
+	minLon := result.BasicInfo.Bounds.MinLon
+
+	var lonCenter, latCenter float64
+	if len(result.CenterPoints) > 0 {
+		lonCenter = result.CenterPoints[0].Lon
+		latCenter = result.CenterPoints[0].Lat
+	}
+
+	// Lưu commune
+	boundaryErr := s.updateCommuneBoundary(ctx, maPhuongXa, maxLat, minLat, maxLon, minLon, lonCenter, latCenter)
+	if boundaryErr != nil {
+		logger.Error("Lỗi khi lưu commune vào database", "error", boundaryErr)
+	}
+
+	// Tạo polygon từ ways và nodes
+	polygons, approximated, err := s.buildPolygons(result.Ways, result.Nodes)
+	if err != nil {
+		return fmt.Errorf("lỗi khi tạo polygon: %w", err)
+	}
+	if len(polygons) == 0 {
+		return fmt.Errorf("không tạo được polygon nào cho '%s'", name)
+	}
+	if approximated {
+		var relationID int64
+		if result.Relation != nil {
+			relationID = result.Relation.ID
+		}
+		s.report.approximated(adminLevel, relationID, name, maTT, polygons)
+	}
+	metrics.PolygonsBuilt(levelLabel(adminLevel), len(polygons), approximated)
+	if len(polygons) > 1 {
+		logger.Debug("Chỉ lưu polygon chính, bỏ qua các polygon phụ", "polygons", len(polygons))
+	}
+
+	// Lưu polygon vào database (chỉ polygon đầu tiên)
+	polygonJSON, err := json.Marshal(polygons[0])
+	if err != nil {
+		return fmt.Errorf("lỗi khi marshal polygon JSON: %w", err)
+	}
+	// lưu polygon vào database là data cho phường xã
+	if err := s.updateCommunePolygon(ctx, maPhuongXa, string(polygonJSON)); err != nil {
+		return fmt.Errorf("lỗi khi lưu polygon vào database: %w", err)
+	}
+
+	if boundaryErr != nil {
+		return fmt.Errorf("lỗi khi lưu commune vào database: %w", boundaryErr)
+	}
+	logger.Debug("Đã publish xã/phường", "points", len(polygons[0]))
+	return nil
+}
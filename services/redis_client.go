@@ -3,9 +3,9 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
-	"os"
+	"log/slog"
 	"strings"
 	"sync"
 	"time"
@@ -16,7 +16,6 @@ import (
 
 var rdCluster *redis.ClusterClient
 var rd *redis.Client
-var ctx = context.Background()
 var prefix = ""
 
 const (
@@ -24,42 +23,46 @@ const (
 	redisHashWardPolygon     = "geo_polygon:phuong_xa"
 )
 
+// InitRedis tạo client Redis theo cấu hình; redis.cluster có giá trị thì dùng cluster,
+// ngược lại dùng redis.address. Không cấu hình gì thì Redis bị tắt.
 func InitRedis() {
-	clusterEnv := os.Getenv("REDIS_CLUSTER")
-	password := os.Getenv("REDIS_PASSWORD")
-
-	if strings.TrimSpace(clusterEnv) != "" {
-		addrs := []string{}
-		for _, p := range strings.Split(clusterEnv, ",") {
-			p = strings.TrimSpace(p)
-			if p != "" {
-				addrs = append(addrs, p)
-			}
-		}
-		if len(addrs) > 0 {
-			rdCluster = redis.NewClusterClient(&redis.ClusterOptions{
-				Addrs:    addrs,
-				Password: password,
-			})
-		}
+	cfg := appConfig.Redis
+
+	if len(cfg.Cluster) > 0 {
+		rdCluster = redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:           cfg.Cluster,
+			Password:        cfg.Password,
+			PoolSize:        cfg.PoolSize,
+			MinIdleConns:    cfg.MinIdleConns,
+			ConnMaxIdleTime: cfg.ConnMaxIdleTime,
+			DialTimeout:     cfg.DialTimeout,
+		})
 	}
 
 	if rdCluster == nil { // fallback to single instance
-		addr := os.Getenv("REDIS_ADDRESS") // e.g., localhost:6379
-		if strings.TrimSpace(addr) != "" {
+		if strings.TrimSpace(cfg.Address) != "" { // e.g., localhost:6379
 			rd = redis.NewClient(&redis.Options{
-				Addr:     addr,
-				Password: password,
+				Addr:            cfg.Address,
+				Password:        cfg.Password,
+				PoolSize:        cfg.PoolSize,
+				MinIdleConns:    cfg.MinIdleConns,
+				ConnMaxIdleTime: cfg.ConnMaxIdleTime,
+				DialTimeout:     cfg.DialTimeout,
 			})
 		}
 	}
 
-	prefix = os.Getenv("REDIS_PREFIX")
+	prefix = cfg.Prefix
 	if len(prefix) > 0 {
 		prefix = prefix + ":"
 	}
 }
 
+// RedisEnabled cho biết Redis đã được cấu hình qua InitRedis
+func RedisEnabled() bool {
+	return rdCluster != nil || rd != nil
+}
+
 func Close() {
 	if rdCluster != nil {
 		_ = rdCluster.Close()
@@ -70,21 +73,21 @@ func Close() {
 
 }
 
-func Set(key string, val any, exp time.Duration) error {
+func Set(ctx context.Context, key string, val any, exp time.Duration) error {
 	if rdCluster != nil {
 		return rdCluster.Set(ctx, prefix+key, val, exp).Err()
 	}
 	return rd.Set(ctx, prefix+key, val, exp).Err()
 }
 
-func Get(key string) (string, error) {
+func Get(ctx context.Context, key string) (string, error) {
 	if rdCluster != nil {
 		return rdCluster.Get(ctx, prefix+key).Result()
 	}
 	return rd.Get(ctx, prefix+key).Result()
 }
 
-func GetDel(key string) (string, error) {
+func GetDel(ctx context.Context, key string) (string, error) {
 	if rdCluster != nil {
 		val, err := rdCluster.Get(ctx, prefix+key).Result()
 		if err == nil {
@@ -96,21 +99,21 @@ func GetDel(key string) (string, error) {
 	return rd.GetDel(ctx, prefix+key).Result()
 }
 
-func Del(key string) error {
+func Del(ctx context.Context, key string) error {
 	if rdCluster != nil {
 		return rdCluster.Del(ctx, prefix+key).Err()
 	}
 	return rd.Del(ctx, prefix+key).Err()
 }
 
-func HMSet(key string, val any) error {
+func HMSet(ctx context.Context, key string, val any) error {
 	if rdCluster != nil {
 		return rdCluster.HMSet(ctx, prefix+key, val).Err()
 	}
 	return rd.HMSet(ctx, prefix+key, val).Err()
 }
 
-func HMGet[E any](key string, field string) (*E, error) {
+func HMGet[E any](ctx context.Context, key string, field string) (*E, error) {
 	var values []interface{}
 	var err error
 	if rdCluster != nil {
@@ -126,14 +129,14 @@ func HMGet[E any](key string, field string) (*E, error) {
 	return &e, err
 }
 
-func HSet(key string, hKey any, val any) error {
+func HSet(ctx context.Context, key string, hKey any, val any) error {
 	if rdCluster != nil {
 		return rdCluster.HSet(ctx, prefix+key, hKey, val).Err()
 	}
 	return rd.HSet(ctx, prefix+key, hKey, val).Err()
 }
 
-func HGet[E any](key string, hkey string) (*E, error) {
+func HGet[E any](ctx context.Context, key string, hkey string) (*E, error) {
 	var value string
 	if rdCluster != nil {
 		value = rdCluster.HGet(ctx, prefix+key, hkey).Val()
@@ -145,7 +148,23 @@ func HGet[E any](key string, hkey string) (*E, error) {
 	return &e, err
 }
 
-func HGetAll[E any](key string) (map[string]E, error) {
+// HGetString đọc nguyên giá trị một field của hash; found = false khi key hoặc field không tồn tại
+func HGetString(ctx context.Context, key string, hKey string) (value string, found bool, err error) {
+	if rdCluster != nil {
+		value, err = rdCluster.HGet(ctx, prefix+key, hKey).Result()
+	} else {
+		value, err = rd.HGet(ctx, prefix+key, hKey).Result()
+	}
+	if errors.Is(err, redis.Nil) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return value, true, nil
+}
+
+func HGetAll[E any](ctx context.Context, key string) (map[string]E, error) {
 	var value map[string]string
 	if rdCluster != nil {
 		value = rdCluster.HGetAll(ctx, prefix+key).Val()
@@ -157,7 +176,7 @@ func HGetAll[E any](key string) (map[string]E, error) {
 		var e E
 		err := json.Unmarshal([]byte(v), &e)
 		if err != nil {
-			log.Printf("Loi khi parse value tu redis: %s", err.Error())
+			slog.Error("Loi khi parse value tu redis", "key", key, "field", k, "error", err)
 			return nil, err
 		}
 		m[k] = e
@@ -165,84 +184,84 @@ func HGetAll[E any](key string) (map[string]E, error) {
 	return m, nil
 }
 
-func HDel(key string, hKey string) error {
+func HDel(ctx context.Context, key string, hKey string) error {
 	if rdCluster != nil {
 		return rdCluster.HDel(ctx, prefix+key, hKey).Err()
 	}
 	return rd.HDel(ctx, prefix+key, hKey).Err()
 }
 
-func Incr(key string) (int64, error) {
+func Incr(ctx context.Context, key string) (int64, error) {
 	if rdCluster != nil {
 		return rdCluster.Incr(ctx, prefix+key).Result()
 	}
 	return rd.Incr(ctx, prefix+key).Result()
 }
 
-func SetNX(key string, val any, exp time.Duration) (bool, error) {
+func SetNX(ctx context.Context, key string, val any, exp time.Duration) (bool, error) {
 	if rdCluster != nil {
 		return rdCluster.SetNX(ctx, prefix+key, val, exp).Result()
 	}
 	return rd.SetNX(ctx, prefix+key, val, exp).Result()
 }
 
-func Expire(key string, exp time.Duration) error {
+func Expire(ctx context.Context, key string, exp time.Duration) error {
 	if rdCluster != nil {
 		return rdCluster.Expire(ctx, prefix+key, exp).Err()
 	}
 	return rd.Expire(ctx, prefix+key, exp).Err()
 }
 
-func ExpireNX(key string, exp time.Duration) (bool, error) {
+func ExpireNX(ctx context.Context, key string, exp time.Duration) (bool, error) {
 	if rdCluster != nil {
 		return rdCluster.Expire(ctx, prefix+key, exp).Result()
 	}
 	return rd.Expire(ctx, prefix+key, exp).Result()
 }
 
-func HExists(key string, hkey string) (bool, error) {
+func HExists(ctx context.Context, key string, hkey string) (bool, error) {
 	if rdCluster != nil {
 		return rdCluster.HExists(ctx, prefix+key, hkey).Result()
 	}
 	return rd.HExists(ctx, prefix+key, hkey).Result()
 }
 
-func HKeys(key string) ([]string, error) {
+func HKeys(ctx context.Context, key string) ([]string, error) {
 	if rdCluster != nil {
 		return rdCluster.HKeys(ctx, prefix+key).Result()
 	}
 	return rd.HKeys(ctx, prefix+key).Result()
 }
 
-func HValues(key string) ([]string, error) {
+func HValues(ctx context.Context, key string) ([]string, error) {
 	if rdCluster != nil {
 		return rdCluster.HVals(ctx, prefix+key).Result()
 	}
 	return rd.HVals(ctx, prefix+key).Result()
 }
 
-func HLen(key string) (int64, error) {
+func HLen(ctx context.Context, key string) (int64, error) {
 	if rdCluster != nil {
 		return rdCluster.HLen(ctx, prefix+key).Result()
 	}
 	return rd.HLen(ctx, prefix+key).Result()
 }
 
-func HSetNX(key string, hKey string, val any) (bool, error) {
+func HSetNX(ctx context.Context, key string, hKey string, val any) (bool, error) {
 	if rdCluster != nil {
 		return rdCluster.HSetNX(ctx, prefix+key, hKey, val).Result()
 	}
 	return rd.HSetNX(ctx, prefix+key, hKey, val).Result()
 }
 
-func HIncrBy(key string, hKey string, incr int64) (int64, error) {
+func HIncrBy(ctx context.Context, key string, hKey string, incr int64) (int64, error) {
 	if rdCluster != nil {
 		return rdCluster.HIncrBy(ctx, prefix+key, hKey, incr).Result()
 	}
 	return rd.HIncrBy(ctx, prefix+key, hKey, incr).Result()
 }
 
-func HIncrByFloat(key string, hKey string, incr float64) (float64, error) {
+func HIncrByFloat(ctx context.Context, key string, hKey string, incr float64) (float64, error) {
 	if rdCluster != nil {
 		return rdCluster.HIncrByFloat(ctx, prefix+key, hKey, incr).Result()
 	}
@@ -250,7 +269,7 @@ func HIncrByFloat(key string, hKey string, incr float64) (float64, error) {
 }
 
 // Helper function để lưu struct vào HSET
-func HSetStruct(key string, hKey string, data interface{}) error {
+func HSetStruct(ctx context.Context, key string, hKey string, data interface{}) error {
 	// Convert struct thành JSON
 	jsonData, err := json.Marshal(data)
 	if err != nil {
@@ -258,13 +277,13 @@ func HSetStruct(key string, hKey string, data interface{}) error {
 	}
 
 	// Lưu JSON string vào HSET
-	return HSet(key, hKey, string(jsonData))
+	return HSet(ctx, key, hKey, string(jsonData))
 }
 
 // Helper function để lấy struct từ HSET
-func HGetStruct[T any](key string, hKey string) (*T, error) {
+func HGetStruct[T any](ctx context.Context, key string, hKey string) (*T, error) {
 	// Lấy JSON string từ HSET
-	jsonStr, err := HGet[string](key, hKey)
+	jsonStr, err := HGet[string](ctx, key, hKey)
 	if err != nil {
 		return nil, err
 	}
@@ -280,7 +299,7 @@ func HGetStruct[T any](key string, hKey string) (*T, error) {
 }
 
 // Helper function để lưu nhiều struct vào HSET
-func HMSetStruct(key string, data map[string]interface{}) error {
+func HMSetStruct(ctx context.Context, key string, data map[string]interface{}) error {
 	// Convert tất cả struct thành JSON string
 	jsonData := make(map[string]interface{})
 	for k, v := range data {
@@ -291,13 +310,13 @@ func HMSetStruct(key string, data map[string]interface{}) error {
 		}
 	}
 
-	return HMSet(key, jsonData)
+	return HMSet(ctx, key, jsonData)
 }
 
 // Helper function để lấy tất cả struct từ HSET
-func HGetAllStruct[T any](key string) (map[string]T, error) {
+func HGetAllStruct[T any](ctx context.Context, key string) (map[string]T, error) {
 	// Lấy tất cả data dưới dạng string
-	allData, err := HGetAll[string](key)
+	allData, err := HGetAll[string](ctx, key)
 	if err != nil {
 		return nil, err
 	}
@@ -315,12 +334,12 @@ func HGetAllStruct[T any](key string) (map[string]T, error) {
 	return result, nil
 }
 
-func GetAllKeyByPrefix(prefix string) ([]string, error) {
+func GetAllKeyByPrefix(ctx context.Context, prefix string) ([]string, error) {
 	var keys []string
 	var mu sync.Mutex
 	match := fmt.Sprintf("%s:*", prefix)
 
-	_, ctx := errgroup.WithContext(ctx)
+	_, ctx = errgroup.WithContext(ctx)
 
 	if rdCluster != nil {
 		err := rdCluster.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
@@ -355,7 +374,7 @@ func GetAllKeyByPrefix(prefix string) ([]string, error) {
 	}
 	return keys, nil
 }
-func Exists(key string) (bool, error) {
+func Exists(ctx context.Context, key string) (bool, error) {
 	if rdCluster != nil {
 		exists, err := rdCluster.Exists(ctx, prefix+key).Result()
 		return exists > 0, err
@@ -363,3 +382,26 @@ func Exists(key string) (bool, error) {
 	exists, err := rd.Exists(ctx, prefix+key).Result()
 	return exists > 0, err
 }
+
+var (
+	delIfEqualScript    = redis.NewScript(`if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) end return 0`)
+	expireIfEqualScript = redis.NewScript(`if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("PEXPIRE", KEYS[1], ARGV[2]) end return 0`)
+)
+
+// DelIfEqual xóa key chỉ khi giá trị của nó là val, nguyên tử bằng Lua; false nếu key không còn hoặc đã đổi giá trị
+func DelIfEqual(ctx context.Context, key string, val string) (bool, error) {
+	return runScript(ctx, delIfEqualScript, []string{prefix + key}, val).Bool()
+}
+
+// ExpireIfEqual đặt lại TTL của key chỉ khi giá trị của nó là val, nguyên tử bằng Lua; false nếu key không còn
+// hoặc đã đổi giá trị
+func ExpireIfEqual(ctx context.Context, key string, val string, exp time.Duration) (bool, error) {
+	return runScript(ctx, expireIfEqualScript, []string{prefix + key}, val, exp.Milliseconds()).Bool()
+}
+
+func runScript(ctx context.Context, script *redis.Script, keys []string, args ...any) *redis.Cmd {
+	if rdCluster != nil {
+		return script.Run(ctx, rdCluster, keys, args...)
+	}
+	return script.Run(ctx, rd, keys, args...)
+}
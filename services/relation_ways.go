@@ -0,0 +1,150 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"tool-map/models"
+)
+
+// RelationWays là các way thành viên và node đầu mút của một relation, dùng để debug việc nối vòng polygon
+type RelationWays struct {
+	RelationID int64
+	Name       string
+	Source     string // "store" (boundary store) hoặc "api" (OSM API)
+	Ways       []MemberWay
+	Endpoints  []WayEndpoint
+}
+
+// MemberWay là một way thành viên của relation, tọa độ [lat, lon]
+type MemberWay struct {
+	ID          int64
+	Role        string
+	Coordinates [][2]float64
+	Missing     int // số node của way không có trong dữ liệu
+}
+
+// WayEndpoint là node đầu/cuối của một hoặc nhiều way. Node chỉ thuộc một way (Dangling) là chỗ vòng
+// polygon bị hở; node thuộc nhiều hơn hai way là chỗ nối có nhánh.
+type WayEndpoint struct {
+	ID       int64
+	Lat, Lon float64
+	Ways     []int64
+	Dangling bool
+}
+
+// RelationWays đọc các way thành viên của relation từ boundary store nếu có, ngược lại gọi OSM API
+func (s *OSMService) RelationWays(ctx context.Context, relationID int64) (*RelationWays, error) {
+	source := "store"
+	var osm *models.OSM
+	if s.store != nil {
+		osm, _ = s.store.RelationOSM(relationID)
+	}
+	if osm == nil {
+		source = "api"
+		fetched, err := s.FetchRelationOSM(ctx, relationID)
+		if err != nil {
+			return nil, err
+		}
+		osm = fetched
+	}
+
+	index := osm.Index()
+	relation, found := index.Relation(relationID)
+	if !found {
+		return nil, fmt.Errorf("relation %d: %w", relationID, ErrUnitNotFound)
+	}
+
+	result := &RelationWays{RelationID: relationID, Name: relation.GetName(), Source: source}
+	endpoints := make(map[int64]*WayEndpoint)
+	var order []int64
+	for _, member := range relation.Members {
+		if member.Type != "way" {
+			continue
+		}
+		way, ok := index.Way(member.Ref)
+		if !ok {
+			result.Ways = append(result.Ways, MemberWay{ID: member.Ref, Role: member.Role})
+			continue
+		}
+		coordinates := index.WayCoordinates(way)
+		memberWay := MemberWay{
+			ID:          way.ID,
+			Role:        member.Role,
+			Coordinates: make([][2]float64, 0, len(coordinates)),
+			Missing:     len(way.Nodes) - len(coordinates),
+		}
+		for _, c := range coordinates {
+			memberWay.Coordinates = append(memberWay.Coordinates, [2]float64{c.Lat, c.Lon})
+		}
+		result.Ways = append(result.Ways, memberWay)
+
+		if len(way.Nodes) == 0 {
+			continue
+		}
+		ends := []int64{way.Nodes[0].Ref, way.Nodes[len(way.Nodes)-1].Ref}
+		if ends[0] == ends[1] {
+			continue // way tự đóng vòng
+		}
+		for _, id := range ends {
+			endpoint, ok := endpoints[id]
+			if !ok {
+				lat, lon, found := index.Coord(id)
+				if !found {
+					continue
+				}
+				endpoint = &WayEndpoint{ID: id, Lat: lat, Lon: lon}
+				endpoints[id] = endpoint
+				order = append(order, id)
+			}
+			endpoint.Ways = append(endpoint.Ways, way.ID)
+		}
+	}
+
+	for _, id := range order {
+		endpoint := endpoints[id]
+		endpoint.Dangling = len(endpoint.Ways) == 1
+		result.Endpoints = append(result.Endpoints, *endpoint)
+	}
+	return result, nil
+}
+
+// ToGeoJSON chuyển kết quả sang FeatureCollection: LineString cho mỗi way và Point cho mỗi node đầu mút,
+// tọa độ [lon, lat]
+func (r *RelationWays) ToGeoJSON() map[string]any {
+	features := make([]map[string]any, 0, len(r.Ways)+len(r.Endpoints))
+	for _, way := range r.Ways {
+		coordinates := make([][2]float64, 0, len(way.Coordinates))
+		for _, point := range way.Coordinates {
+			coordinates = append(coordinates, [2]float64{point[1], point[0]})
+		}
+		features = append(features, map[string]any{
+			"type": "Feature",
+			"properties": map[string]any{
+				"kind":    "way",
+				"id":      way.ID,
+				"role":    way.Role,
+				"missing": way.Missing,
+			},
+			"geometry": map[string]any{"type": "LineString", "coordinates": coordinates},
+		})
+	}
+	for _, endpoint := range r.Endpoints {
+		features = append(features, map[string]any{
+			"type": "Feature",
+			"properties": map[string]any{
+				"kind":     "node",
+				"id":       endpoint.ID,
+				"ways":     endpoint.Ways,
+				"dangling": endpoint.Dangling,
+			},
+			"geometry": map[string]any{"type": "Point", "coordinates": [2]float64{endpoint.Lon, endpoint.Lat}},
+		})
+	}
+	return map[string]any{
+		"type":          "FeatureCollection",
+		"osmRelationId": r.RelationID,
+		"name":          r.Name,
+		"source":        r.Source,
+		"features":      features,
+	}
+}
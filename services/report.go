@@ -0,0 +1,420 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	ReportStatusPublished   = "published"
+	ReportStatusFailed      = "failed"
+	ReportStatusSkipped     = "skipped"     // đã xong theo checkpoint hoặc đang được tiến trình khác xử lý
+	ReportStatusInterrupted = "interrupted" // lần chạy bị hủy giữa chừng
+)
+
+const (
+	StageFetch        = "fetch"         // fetch + xử lý relation tỉnh
+	StageProvince     = "province"      // đối chiếu thay đổi + publish tỉnh
+	StageAdminTree    = "admin_tree"    // dựng cây hành chính + đối chiếu DM_PHUONG_XA
+	StageCommunes     = "communes"      // toàn bộ pipeline xã/phường (thời gian thực)
+	StageCommuneFetch = "commune_fetch" // tổng thời gian fetch của các xã/phường (cộng dồn các worker)
+	StageCommuneWrite = "commune_write" // tổng thời gian ghi DB/Redis của các xã/phường
+)
+
+// StageTiming là thời gian của một stage
+type StageTiming struct {
+	Stage      string `json:"stage"`
+	DurationMs int64  `json:"durationMs"`
+	Count      int    `json:"count"`
+}
+
+// ReportCommune là kết quả một xã/phường trong báo cáo
+type ReportCommune struct {
+	RelationID int64  `json:"relationId"`
+	Name       string `json:"name"`
+	MaPhuongXa string `json:"maPhuongXa,omitempty"`
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	FetchMs    int64  `json:"fetchMs,omitempty"`
+	WriteMs    int64  `json:"writeMs,omitempty"`
+}
+
+// ReportApproximation là đơn vị có polygon dựng gần đúng (convex hull) do các way không khép kín
+type ReportApproximation struct {
+	Level      int    `json:"level"`
+	RelationID int64  `json:"relationId,omitempty"`
+	Name       string `json:"name"`
+	MaTT       string `json:"maTT,omitempty"`
+	Polygons   int    `json:"polygons"`
+	Vertices   int    `json:"vertices"`
+}
+
+// ProvinceReport là báo cáo của một relation tỉnh
+type ProvinceReport struct {
+	RelationID int64  `json:"relationId"`
+	Name       string `json:"name,omitempty"`
+	MaTT       string `json:"maTT,omitempty"`
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"durationMs"`
+
+	Matched      []ReportCommune       `json:"matched"`
+	Unmatched    []ReportCommune       `json:"unmatched"` // không tìm thấy trong DM_PHUONG_XA theo tên
+	Skipped      []ReportCommune       `json:"skipped"`
+	Failed       []ReportCommune       `json:"failed"`
+	Approximated []ReportApproximation `json:"approximated"`
+	Stages       []StageTiming         `json:"stages"`
+
+	started time.Time
+}
+
+// ReportTotals là số liệu tổng hợp của lần chạy
+type ReportTotals struct {
+	Provinces          int `json:"provinces"`
+	ProvincesPublished int `json:"provincesPublished"`
+	ProvincesFailed    int `json:"provincesFailed"`
+	ProvincesSkipped   int `json:"provincesSkipped"`
+	Matched            int `json:"matched"`
+	Unmatched          int `json:"unmatched"`
+	Skipped            int `json:"skipped"`
+	Failed             int `json:"failed"`
+	Approximated       int `json:"approximated"`
+}
+
+// RunReport gom kết quả một lần chạy publish theo từng tỉnh; an toàn khi nhiều worker cùng ghi.
+// Mọi method đều chấp nhận receiver nil (không ghi báo cáo).
+type RunReport struct {
+	mu         sync.Mutex
+	StartedAt  string            `json:"startedAt"`
+	FinishedAt string            `json:"finishedAt,omitempty"`
+	DurationMs int64             `json:"durationMs"`
+	DryRun     bool              `json:"dryRun"`
+	Totals     ReportTotals      `json:"totals"`
+	Stages     []StageTiming     `json:"stages"` // cộng dồn các stage của mọi tỉnh
+	Provinces  []*ProvinceReport `json:"provinces"`
+
+	// OtherApproximated là polygon gần đúng không gắn được vào tỉnh nào trong lần chạy
+	OtherApproximated []ReportApproximation `json:"otherApproximated,omitempty"`
+
+	started time.Time
+}
+
+// NewRunReport tạo báo cáo rỗng, bắt đầu tính giờ từ lúc gọi
+func NewRunReport(dryRun bool) *RunReport {
+	now := time.Now()
+	return &RunReport{StartedAt: now.Format(time.RFC3339), DryRun: dryRun, started: now}
+}
+
+// SetRunReport gắn báo cáo vào service để ghi nhận polygon dựng gần đúng
+func (s *OSMService) SetRunReport(report *RunReport) {
+	s.report = report
+}
+
+// Report trả về báo cáo đang gắn với service (nil nếu không có)
+func (s *OSMService) Report() *RunReport {
+	return s.report
+}
+
+// province trả về báo cáo của relation, tạo mới nếu chưa có; gọi khi đang giữ r.mu
+func (r *RunReport) province(relationID int64) *ProvinceReport {
+	for _, p := range r.Provinces {
+		if p.RelationID == relationID {
+			return p
+		}
+	}
+	p := &ProvinceReport{
+		RelationID:   relationID,
+		Matched:      []ReportCommune{},
+		Unmatched:    []ReportCommune{},
+		Skipped:      []ReportCommune{},
+		Failed:       []ReportCommune{},
+		Approximated: []ReportApproximation{},
+		Stages:       []StageTiming{},
+	}
+	r.Provinces = append(r.Provinces, p)
+	return p
+}
+
+// StartProvince bắt đầu tính giờ cho relation tỉnh
+func (r *RunReport) StartProvince(relationID int64) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.province(relationID).started = time.Now()
+}
+
+// SetProvince gắn tên và MATT cho relation tỉnh; name/maTT rỗng giữ nguyên giá trị cũ
+func (r *RunReport) SetProvince(relationID int64, name, maTT string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p := r.province(relationID)
+	if name != "" {
+		p.Name = name
+	}
+	if maTT != "" {
+		p.MaTT = maTT
+	}
+}
+
+// Stage cộng thời gian từ started tới hiện tại vào stage của relation tỉnh
+func (r *RunReport) Stage(relationID int64, stage string, started time.Time) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	addStage(&r.province(relationID).Stages, stage, time.Since(started))
+}
+
+// AddCommune phân loại kết quả một xã/phường của relation tỉnh
+func (r *RunReport) AddCommune(relationID int64, result CommuneResult) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p := r.province(relationID)
+	commune := ReportCommune{
+		RelationID: result.Job.RelationID,
+		Name:       result.Job.Name,
+		MaPhuongXa: result.MaPhuongXa,
+		Status:     result.Status,
+		FetchMs:    result.FetchDuration.Milliseconds(),
+		WriteMs:    result.WriteDuration.Milliseconds(),
+	}
+	if result.Err != nil {
+		commune.Error = result.Err.Error()
+	}
+	if p.MaTT == "" {
+		p.MaTT = result.Job.MaTT
+	}
+
+	switch result.Status {
+	case CommuneStatusWritten, CommuneStatusUnchanged:
+		p.Matched = append(p.Matched, commune)
+	case CommuneStatusNotFound:
+		p.Unmatched = append(p.Unmatched, commune)
+	case CommuneStatusSkipped:
+		p.Skipped = append(p.Skipped, commune)
+	default:
+		p.Failed = append(p.Failed, commune)
+	}
+	if result.FetchDuration > 0 {
+		addStage(&p.Stages, StageCommuneFetch, result.FetchDuration)
+	}
+	if result.WriteDuration > 0 {
+		addStage(&p.Stages, StageCommuneWrite, result.WriteDuration)
+	}
+}
+
+// FinishProvince ghi trạng thái cuối của relation tỉnh
+func (r *RunReport) FinishProvince(relationID int64, status string, err error) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p := r.province(relationID)
+	p.Status = status
+	if err != nil {
+		p.Error = err.Error()
+	}
+	if !p.started.IsZero() {
+		p.DurationMs = time.Since(p.started).Milliseconds()
+	}
+}
+
+// approximated ghi nhận polygon dựng gần đúng: tỉnh gắn theo relation, xã/phường theo MATT
+func (r *RunReport) approximated(level int, relationID int64, name, maTT string, polygons [][][]float64) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry := ReportApproximation{Level: level, RelationID: relationID, Name: name, MaTT: maTT, Polygons: len(polygons)}
+	for _, polygon := range polygons {
+		entry.Vertices += len(polygon)
+	}
+
+	for _, p := range r.Provinces {
+		if (level == 4 && p.RelationID == relationID) || (maTT != "" && p.MaTT == maTT) {
+			p.Approximated = append(p.Approximated, entry)
+			return
+		}
+	}
+	r.OtherApproximated = append(r.OtherApproximated, entry)
+}
+
+// Finish chốt thời gian và tính số liệu tổng hợp
+func (r *RunReport) Finish() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.FinishedAt = time.Now().Format(time.RFC3339)
+	r.DurationMs = time.Since(r.started).Milliseconds()
+
+	totals := ReportTotals{Provinces: len(r.Provinces), Approximated: len(r.OtherApproximated)}
+	stages := []StageTiming{}
+	for _, p := range r.Provinces {
+		switch p.Status {
+		case ReportStatusPublished:
+			totals.ProvincesPublished++
+		case ReportStatusSkipped:
+			totals.ProvincesSkipped++
+		case ReportStatusFailed:
+			totals.ProvincesFailed++
+		}
+		totals.Matched += len(p.Matched)
+		totals.Unmatched += len(p.Unmatched)
+		totals.Skipped += len(p.Skipped)
+		totals.Failed += len(p.Failed)
+		totals.Approximated += len(p.Approximated)
+		for _, stage := range p.Stages {
+			addStageTiming(&stages, stage)
+		}
+	}
+	r.Totals = totals
+	r.Stages = stages
+}
+
+func addStage(stages *[]StageTiming, stage string, d time.Duration) {
+	addStageTiming(stages, StageTiming{Stage: stage, DurationMs: d.Milliseconds(), Count: 1})
+}
+
+func addStageTiming(stages *[]StageTiming, timing StageTiming) {
+	for i := range *stages {
+		if (*stages)[i].Stage == timing.Stage {
+			(*stages)[i].DurationMs += timing.DurationMs
+			(*stages)[i].Count += timing.Count
+			return
+		}
+	}
+	*stages = append(*stages, timing)
+}
+
+// WriteJSON ghi báo cáo dạng JSON
+func (r *RunReport) WriteJSON(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// WriteHTML ghi báo cáo dạng trang HTML tĩnh
+func (r *RunReport) WriteHTML(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return reportTemplate.Execute(w, r)
+}
+
+// Save ghi báo cáo ra <base>.json và <base>.html
+func (r *RunReport) Save(base string) (jsonPath, htmlPath string, err error) {
+	if r == nil {
+		return "", "", nil
+	}
+	jsonPath, htmlPath = base+".json", base+".html"
+	if err := writeReportFile(jsonPath, r.WriteJSON); err != nil {
+		return "", "", err
+	}
+	if err := writeReportFile(htmlPath, r.WriteHTML); err != nil {
+		return "", "", err
+	}
+	return jsonPath, htmlPath, nil
+}
+
+func writeReportFile(path string, write func(io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("không thể tạo file báo cáo %s: %w", path, err)
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return fmt.Errorf("lỗi khi ghi báo cáo %s: %w", path, err)
+	}
+	return f.Close()
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"seconds": func(ms int64) string { return fmt.Sprintf("%.1fs", float64(ms)/1000) },
+}).Parse(`<!DOCTYPE html>
+<html lang="vi">
+<head>
+<meta charset="utf-8">
+<title>Báo cáo import {{.StartedAt}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin: 0.5em 0 1.5em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+th { background: #f3f3f3; }
+.published { color: #1a7f37; } .failed { color: #cf222e; } .skipped, .interrupted { color: #9a6700; }
+details { margin: 0.3em 0; }
+</style>
+</head>
+<body>
+<h1>Báo cáo import{{if .DryRun}} (dry-run){{end}}</h1>
+<p>Bắt đầu {{.StartedAt}}, kết thúc {{.FinishedAt}}, tổng {{seconds .DurationMs}}</p>
+
+<table>
+<tr><th>Tỉnh</th><th>Publish</th><th>Lỗi</th><th>Bỏ qua</th><th>Xã/phường khớp</th><th>Không khớp</th><th>Bỏ qua</th><th>Lỗi</th><th>Polygon gần đúng</th></tr>
+<tr><td>{{.Totals.Provinces}}</td><td>{{.Totals.ProvincesPublished}}</td><td>{{.Totals.ProvincesFailed}}</td><td>{{.Totals.ProvincesSkipped}}</td>
+<td>{{.Totals.Matched}}</td><td>{{.Totals.Unmatched}}</td><td>{{.Totals.Skipped}}</td><td>{{.Totals.Failed}}</td><td>{{.Totals.Approximated}}</td></tr>
+</table>
+
+<h2>Thời gian theo stage</h2>
+<table>
+<tr><th>Stage</th><th>Thời gian</th><th>Số lần</th></tr>
+{{range .Stages}}<tr><td>{{.Stage}}</td><td>{{seconds .DurationMs}}</td><td>{{.Count}}</td></tr>
+{{end}}</table>
+
+<h2>Theo tỉnh</h2>
+<table>
+<tr><th>Relation</th><th>Tên</th><th>MATT</th><th>Trạng thái</th><th>Thời gian</th><th>Khớp</th><th>Không khớp</th><th>Bỏ qua</th><th>Lỗi</th><th>Gần đúng</th></tr>
+{{range .Provinces}}<tr><td>{{.RelationID}}</td><td>{{.Name}}</td><td>{{.MaTT}}</td><td class="{{.Status}}">{{.Status}}{{if .Error}}: {{.Error}}{{end}}</td>
+<td>{{seconds .DurationMs}}</td><td>{{len .Matched}}</td><td>{{len .Unmatched}}</td><td>{{len .Skipped}}</td><td>{{len .Failed}}</td><td>{{len .Approximated}}</td></tr>
+{{end}}</table>
+
+{{range .Provinces}}{{if or .Unmatched .Failed .Approximated .Skipped}}
+<h3>{{if .Name}}{{.Name}}{{else}}Relation {{.RelationID}}{{end}}</h3>
+{{if .Stages}}<p>{{range .Stages}}{{.Stage}}: {{seconds .DurationMs}} &nbsp; {{end}}</p>{{end}}
+{{if .Unmatched}}<details open><summary>Không tìm thấy trong DM_PHUONG_XA ({{len .Unmatched}})</summary>
+<table><tr><th>Relation</th><th>Tên OSM</th></tr>
+{{range .Unmatched}}<tr><td>{{.RelationID}}</td><td>{{.Name}}</td></tr>
+{{end}}</table></details>{{end}}
+{{if .Failed}}<details open><summary>Lỗi ({{len .Failed}})</summary>
+<table><tr><th>Relation</th><th>Tên</th><th>MA_PHUONG_XA</th><th>Lỗi</th></tr>
+{{range .Failed}}<tr><td>{{.RelationID}}</td><td>{{.Name}}</td><td>{{.MaPhuongXa}}</td><td>{{.Error}}</td></tr>
+{{end}}</table></details>{{end}}
+{{if .Approximated}}<details open><summary>Polygon gần đúng ({{len .Approximated}})</summary>
+<table><tr><th>Cấp</th><th>Relation</th><th>Tên</th><th>Polygon</th><th>Đỉnh</th></tr>
+{{range .Approximated}}<tr><td>{{.Level}}</td><td>{{.RelationID}}</td><td>{{.Name}}</td><td>{{.Polygons}}</td><td>{{.Vertices}}</td></tr>
+{{end}}</table></details>{{end}}
+{{if .Skipped}}<details><summary>Bỏ qua theo checkpoint ({{len .Skipped}})</summary>
+<table><tr><th>Relation</th><th>Tên</th></tr>
+{{range .Skipped}}<tr><td>{{.RelationID}}</td><td>{{.Name}}</td></tr>
+{{end}}</table></details>{{end}}
+{{end}}{{end}}
+
+{{if .OtherApproximated}}<h3>Polygon gần đúng khác</h3>
+<table><tr><th>Cấp</th><th>Relation</th><th>Tên</th><th>MATT</th><th>Polygon</th><th>Đỉnh</th></tr>
+{{range .OtherApproximated}}<tr><td>{{.Level}}</td><td>{{.RelationID}}</td><td>{{.Name}}</td><td>{{.MaTT}}</td><td>{{.Polygons}}</td><td>{{.Vertices}}</td></tr>
+{{end}}</table>{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"container/list"
+	"sync"
+)
+
+// tileCache là cache LRU các tile đã mã hóa, giới hạn theo số tile; an toàn khi dùng đồng thời
+type tileCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List // phần tử đầu là tile dùng gần nhất
+	entries  map[string]*list.Element
+	purges   uint64 // số lần purge, để không cache tile dựng từ chỉ mục đã bị thay trong lúc dựng
+}
+
+type tileCacheEntry struct {
+	key  string
+	tile *Tile
+}
+
+// newTileCache tạo cache giữ tối đa capacity tile; capacity <= 0 thì cache luôn rỗng
+func newTileCache(capacity int) *tileCache {
+	return &tileCache{capacity: capacity, order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+func (c *tileCache) get(key string) (*Tile, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*tileCacheEntry).tile, true
+}
+
+// generation trả về số lần purge, đọc trước khi dựng tile để truyền cho put
+func (c *tileCache) generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.purges
+}
+
+// put thêm tile dựng khi cache ở generation; bỏ qua nếu cache đã bị purge từ đó
+func (c *tileCache) put(key string, tile *Tile, generation uint64) {
+	if c.capacity <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if generation != c.purges {
+		return
+	}
+	if element, ok := c.entries[key]; ok {
+		element.Value.(*tileCacheEntry).tile = tile
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&tileCacheEntry{key: key, tile: tile})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*tileCacheEntry).key)
+	}
+}
+
+// purge xóa mọi tile trong cache
+func (c *tileCache) purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.purges++
+	c.order.Init()
+	clear(c.entries)
+}
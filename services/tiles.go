@@ -0,0 +1,159 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"time"
+	"tool-map/models"
+	"tool-map/util"
+)
+
+const (
+	TileLayerProvinces = "provinces"
+	TileLayerCommunes  = "communes"
+
+	// tileBuffer là số đơn vị tile (trên 4096) nới ra mỗi phía khi cắt polygon, để nét viền không bị đứt ở mép tile
+	tileBuffer = 64
+)
+
+// ErrInvalidTile là lỗi khi layer hoặc tọa độ z/x/y của tile không hợp lệ
+var ErrInvalidTile = errors.New("tile không hợp lệ")
+
+// Tile là một vector tile đã mã hóa (Mapbox Vector Tile); Data rỗng là tile không có feature nào
+type Tile struct {
+	Data []byte
+	ETag string // ETag HTTP (có dấu nháy), tính từ nội dung
+}
+
+// TileServer dựng vector tile cho layer tỉnh và xã/phường từ PolygonIndex, hoặc đọc tile dựng sẵn trên MinIO.
+// Tile được giữ trong cache LRU, xóa mỗi khi chỉ mục được dựng lại.
+type TileServer struct {
+	index *SharedPolygonIndex
+	cache *tileCache
+}
+
+// NewTileServer tạo TileServer theo cấu hình tiles, dựng tile từ chỉ mục index
+func NewTileServer(index *SharedPolygonIndex) *TileServer {
+	t := &TileServer{index: index, cache: newTileCache(appConfig.Tiles.CacheSize)}
+	index.OnReload(t.cache.purge)
+	return t
+}
+
+// MaxAge là thời gian client được cache tile (tiles.max_age)
+func (t *TileServer) MaxAge() time.Duration {
+	return appConfig.Tiles.MaxAge
+}
+
+// Tile trả về tile z/x/y của layer (provinces hoặc communes)
+func (t *TileServer) Tile(ctx context.Context, layer string, z, x, y int) (*Tile, error) {
+	cfg := appConfig.Tiles
+	if layer != TileLayerProvinces && layer != TileLayerCommunes {
+		return nil, fmt.Errorf("%w: layer '%s' (provinces, communes)", ErrInvalidTile, layer)
+	}
+	if z < 0 || z > cfg.MaxZoom || x < 0 || y < 0 || x >= 1<<z || y >= 1<<z {
+		return nil, fmt.Errorf("%w: %d/%d/%d (zoom tối đa %d)", ErrInvalidTile, z, x, y, cfg.MaxZoom)
+	}
+
+	key := fmt.Sprintf("%s/%d/%d/%d", layer, z, x, y)
+	if tile, ok := t.cache.get(key); ok {
+		return tile, nil
+	}
+	generation := t.cache.generation()
+
+	var data []byte
+	found := false
+	if cfg.MinIOPrefix != "" {
+		object := path.Join(cfg.MinIOPrefix, key+".mvt")
+		var err error
+		data, found, err = DownloadFileIfExists(ctx, polygonBucket(), object)
+		if err != nil {
+			return nil, fmt.Errorf("không đọc được tile %s từ MinIO: %w", object, err)
+		}
+	}
+	if !found {
+		index, err := t.index.Get(ctx)
+		if err != nil {
+			return nil, err
+		}
+		data = renderTile(index, layer, z, x, y)
+	}
+
+	sum := sha256.Sum256(data)
+	tile := &Tile{Data: data, ETag: `"` + hex.EncodeToString(sum[:8]) + `"`}
+	t.cache.put(key, tile, generation)
+	return tile, nil
+}
+
+// BuildPolygonIndex dựng PolygonIndex của mọi tỉnh và xã/phường đã có polygon trong DB
+func (s *OSMService) BuildPolygonIndex(ctx context.Context) (*PolygonIndex, error) {
+	units, err := s.ProvinceGeometries(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	provinces := len(units)
+	for i := 0; i < provinces; i++ {
+		communes, err := s.CommuneGeometries(ctx, units[i].Code)
+		if err != nil {
+			return nil, err
+		}
+		units = append(units, communes...)
+	}
+	return NewPolygonIndex(units), nil
+}
+
+// renderTile dựng tile của layer: chọn các đơn vị có bbox giao tile (kể cả buffer), cắt, đơn giản hóa
+// và mã hóa MVT. Layer xã/phường ở zoom nhỏ hơn tiles.commune_min_zoom luôn rỗng.
+func renderTile(index *PolygonIndex, layer string, z, x, y int) []byte {
+	level := models.AdminLevelProvince
+	if layer == TileLayerCommunes {
+		if z < appConfig.Tiles.CommuneMinZoom {
+			return nil
+		}
+		level = models.AdminLevelCommune
+	}
+
+	minLat, minLon, maxLat, maxLon := util.TileBounds(z, x, y)
+	padLat := (maxLat - minLat) * tileBuffer / util.MVTExtent
+	padLon := (maxLon - minLon) * tileBuffer / util.MVTExtent
+	projector := util.NewTileProjector(z, x, y)
+
+	mvt := util.NewMVTLayer(layer)
+	for i, unit := range index.Intersecting(level, minLat-padLat, minLon-padLon, maxLat+padLat, maxLon+padLon) {
+		var polygons [][][][2]int32
+		for _, polygon := range unit.Polygons() {
+			var rings [][][2]int32
+			for j, ring := range polygon {
+				tileRing := projector.TileRing(ring, tileBuffer)
+				if tileRing == nil {
+					if j == 0 {
+						break // vòng ngoài nằm ngoài tile thì bỏ cả polygon
+					}
+					continue
+				}
+				rings = append(rings, tileRing)
+			}
+			if len(rings) > 0 {
+				polygons = append(polygons, rings)
+			}
+		}
+		if len(polygons) == 0 {
+			continue
+		}
+
+		properties := map[string]any{"code": unit.Code, "name": unit.Name, "level": unit.Level}
+		if level == models.AdminLevelCommune {
+			properties["maTT"] = unit.MaTT
+		}
+		id, err := strconv.ParseUint(unit.Code, 10, 64)
+		if err != nil {
+			id = uint64(i + 1)
+		}
+		mvt.AddPolygon(id, properties, polygons)
+	}
+	return util.EncodeMVT(mvt)
+}
@@ -0,0 +1,197 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"tool-map/entities"
+	"tool-map/models"
+	"tool-map/util"
+)
+
+// ErrUnitNotFound là lỗi khi mã tỉnh/xã không có trong DB hoặc đơn vị chưa có polygon
+var ErrUnitNotFound = errors.New("không tìm thấy đơn vị hành chính")
+
+// UnitSummary là thông tin một đơn vị hành chính không kèm hình học, dùng cho các API danh sách
+type UnitSummary struct {
+	Level      int       `json:"level"` // 4 = tỉnh/thành phố, 6 = xã/phường
+	Code       string    `json:"code"`  // MATT hoặc MA_PHUONG_XA
+	Name       string    `json:"name"`
+	NameEn     string    `json:"nameEn,omitempty"`
+	MaTT       string    `json:"maTT,omitempty"`
+	RelationID *int64    `json:"osmRelationId,omitempty"`
+	LatCenter  *float64  `json:"latCenter,omitempty"`
+	LonCenter  *float64  `json:"lonCenter,omitempty"`
+	BBox       []float64 `json:"bbox,omitempty"` // [minLon, minLat, maxLon, maxLat] như GeoJSON
+}
+
+func bbox(base *entities.AddressBase) []float64 {
+	if base.MinLon == nil || base.MinLat == nil || base.MaxLon == nil || base.MaxLat == nil {
+		return nil
+	}
+	return []float64{*base.MinLon, *base.MinLat, *base.MaxLon, *base.MaxLat}
+}
+
+// ListProvinces liệt kê các tỉnh/thành phố trong DMTT theo mã
+func (s *OSMService) ListProvinces(ctx context.Context) ([]UnitSummary, error) {
+	if s.dmTTRepo == nil {
+		return nil, fmt.Errorf("database repositories not initialized, use NewOSMServiceWithDB()")
+	}
+	rows, err := s.dmTTRepo.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	units := make([]UnitSummary, 0, len(rows))
+	for i := range rows {
+		row := &rows[i]
+		units = append(units, UnitSummary{
+			Level:      models.AdminLevelProvince,
+			Code:       row.MaTT,
+			Name:       row.TenTT,
+			NameEn:     row.TenTTEn,
+			RelationID: row.OsmRelationID,
+			LatCenter:  row.LatCenter,
+			LonCenter:  row.LonCenter,
+			BBox:       bbox(&row.AddressBase),
+		})
+	}
+	return units, nil
+}
+
+// ListCommunes liệt kê các xã/phường thuộc tỉnh maTT; trả về ErrUnitNotFound nếu tỉnh không tồn tại
+func (s *OSMService) ListCommunes(ctx context.Context, maTT string) ([]UnitSummary, error) {
+	if s.dmTTRepo == nil || s.dmPhuongXaRepo == nil {
+		return nil, fmt.Errorf("database repositories not initialized, use NewOSMServiceWithDB()")
+	}
+	tt, err := s.dmTTRepo.GetByMaTT(ctx, maTT)
+	if err != nil {
+		return nil, err
+	}
+	if tt == nil {
+		return nil, fmt.Errorf("tỉnh/thành phố %s: %w", maTT, ErrUnitNotFound)
+	}
+
+	rows, err := s.dmPhuongXaRepo.ListByMaTT(ctx, maTT)
+	if err != nil {
+		return nil, err
+	}
+	units := make([]UnitSummary, 0, len(rows))
+	for i := range rows {
+		row := &rows[i]
+		units = append(units, UnitSummary{
+			Level:      models.AdminLevelCommune,
+			Code:       row.MaPhuongXa,
+			Name:       row.TenPhuongXa,
+			NameEn:     row.TenPhuongXaEn,
+			MaTT:       row.TrucThuocTinh,
+			RelationID: row.OsmRelationID,
+			LatCenter:  row.LatCenter,
+			LonCenter:  row.LonCenter,
+			BBox:       bbox(&row.AddressBase),
+		})
+	}
+	return units, nil
+}
+
+// ProvinceGeometry lấy hình học của tỉnh maTT. Danh sách URL polygon đọc từ hash geo_polygon:tinh_tp
+// nếu Redis được bật, ngược lại từ POLYGON_DATA; các polygon được tải từ MinIO.
+func (s *OSMService) ProvinceGeometry(ctx context.Context, maTT string) (*UnitGeometry, error) {
+	if s.dmTTRepo == nil {
+		return nil, fmt.Errorf("database repositories not initialized, use NewOSMServiceWithDB()")
+	}
+	tt, err := s.dmTTRepo.GetByMaTT(ctx, maTT)
+	if err != nil {
+		return nil, err
+	}
+	if tt == nil {
+		return nil, fmt.Errorf("tỉnh/thành phố %s: %w", maTT, ErrUnitNotFound)
+	}
+	if cached, ok := cachedPolygon(ctx, redisHashProvincePolygon, maTT); ok {
+		tt.Polygon = &cached
+	}
+	if tt.Polygon == nil || *tt.Polygon == "" {
+		return nil, fmt.Errorf("tỉnh/thành phố %s chưa có polygon: %w", maTT, ErrUnitNotFound)
+	}
+	return s.provinceGeometry(ctx, tt)
+}
+
+// CommuneGeometry lấy hình học của xã/phường ma. Polygon đọc từ hash geo_polygon:phuong_xa nếu Redis
+// được bật, ngược lại từ POLYGON_DATA.
+func (s *OSMService) CommuneGeometry(ctx context.Context, ma string) (*UnitGeometry, error) {
+	if s.dmPhuongXaRepo == nil {
+		return nil, fmt.Errorf("database repositories not initialized, use NewOSMServiceWithDB()")
+	}
+	px, err := s.dmPhuongXaRepo.GetByMaPhuongXa(ctx, ma)
+	if err != nil {
+		return nil, err
+	}
+	if px == nil {
+		return nil, fmt.Errorf("xã/phường %s: %w", ma, ErrUnitNotFound)
+	}
+	if cached, ok := cachedPolygon(ctx, redisHashWardPolygon, ma); ok {
+		px.Polygon = &cached
+	}
+	if px.Polygon == nil || *px.Polygon == "" {
+		return nil, fmt.Errorf("xã/phường %s chưa có polygon: %w", ma, ErrUnitNotFound)
+	}
+
+	ring, err := ParsePolygonData(*px.Polygon)
+	if err != nil {
+		return nil, fmt.Errorf("polygon của xã/phường %s không hợp lệ: %w", ma, err)
+	}
+	return &UnitGeometry{
+		Level:      models.AdminLevelCommune,
+		Code:       px.MaPhuongXa,
+		Name:       px.TenPhuongXa,
+		MaTT:       px.TrucThuocTinh,
+		RelationID: px.OsmRelationID,
+		LatCenter:  px.LatCenter,
+		LonCenter:  px.LonCenter,
+		Polygons:   [][][2]float64{ring},
+	}, nil
+}
+
+// cachedPolygon đọc polygon của đơn vị từ hash Redis; lỗi Redis chỉ được log để đọc tiếp từ DB
+func cachedPolygon(ctx context.Context, hash, code string) (string, bool) {
+	if !RedisEnabled() {
+		return "", false
+	}
+	value, found, err := HGetString(ctx, hash, code)
+	if err != nil {
+		slog.Warn("Không đọc được polygon từ Redis, dùng POLYGON_DATA", "hash", hash, "code", code, "error", err)
+		return "", false
+	}
+	return value, found && value != ""
+}
+
+// Simplify trả về bản sao của đơn vị với mọi vòng polygon được đơn giản hóa theo dung sai (mét)
+func (u *UnitGeometry) Simplify(toleranceMeters float64) *UnitGeometry {
+	simplified := *u
+	if toleranceMeters <= 0 {
+		return &simplified
+	}
+	simplified.Polygons = make([][][2]float64, len(u.Polygons))
+	for i, ring := range u.Polygons {
+		simplified.Polygons[i] = util.SimplifyRing(ring, toleranceMeters)
+	}
+	return &simplified
+}
+
+// SimplifyTolerance đọc dung sai đơn giản hóa (mét) từ tham số request; rỗng dùng simplify.default_tolerance_m,
+// giá trị âm hoặc lớn hơn simplify.max_tolerance_m là lỗi
+func SimplifyTolerance(raw string) (float64, error) {
+	cfg := appConfig.Simplify
+	if raw == "" {
+		return cfg.DefaultToleranceMeters, nil
+	}
+	tolerance, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return 0, fmt.Errorf("simplify '%s' không phải số mét", raw)
+	}
+	if !(tolerance >= 0 && tolerance <= cfg.MaxToleranceMeters) {
+		return 0, fmt.Errorf("simplify phải trong khoảng 0..%g mét", cfg.MaxToleranceMeters)
+	}
+	return tolerance, nil
+}
@@ -24,3 +24,15 @@ ALTER TABLE DMTT DROP COLUMN WAY_ADDRESS;
 ALTER TABLE DMTT ADD POLYGON_DATA CLOB;
 
 
+-- Phiên bản relation OSM của lần import gần nhất (phát hiện thay đổi biên giới)
+ALTER TABLE DM_PHUONG_XA ADD OSM_RELATION_ID NUMBER(19);
+ALTER TABLE DM_PHUONG_XA ADD OSM_VERSION NUMBER(10);
+ALTER TABLE DM_PHUONG_XA ADD OSM_CHANGESET NUMBER(19);
+ALTER TABLE DM_PHUONG_XA ADD OSM_TIMESTAMP VARCHAR2(32);
+ALTER TABLE DM_PHUONG_XA ADD GEOMETRY_HASH VARCHAR2(64);
+
+ALTER TABLE DMTT ADD OSM_RELATION_ID NUMBER(19);
+ALTER TABLE DMTT ADD OSM_VERSION NUMBER(10);
+ALTER TABLE DMTT ADD OSM_CHANGESET NUMBER(19);
+ALTER TABLE DMTT ADD OSM_TIMESTAMP VARCHAR2(32);
+ALTER TABLE DMTT ADD GEOMETRY_HASH VARCHAR2(64);
@@ -0,0 +1,140 @@
+package util
+
+import "math"
+
+// earthRadiusMeters là bán kính trung bình của Trái Đất (WGS84)
+const earthRadiusMeters = 6371008.8
+
+// HaversineMeters trả về khoảng cách (mét) giữa hai điểm lat/lon theo công thức haversine
+func HaversineMeters(lat1, lon1, lat2, lon2 float64) float64 {
+	phi1 := lat1 * math.Pi / 180
+	phi2 := lat2 * math.Pi / 180
+	dPhi := (lat2 - lat1) * math.Pi / 180
+	dLambda := (lon2 - lon1) * math.Pi / 180
+
+	a := math.Sin(dPhi/2)*math.Sin(dPhi/2) + math.Cos(phi1)*math.Cos(phi2)*math.Sin(dLambda/2)*math.Sin(dLambda/2)
+	return 2 * earthRadiusMeters * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// RingAreaKm2 trả về diện tích (km²) của một vòng polygon [lat, lon] trên mặt cầu.
+// Vòng có thể đóng hoặc mở; chiều của vòng không ảnh hưởng kết quả.
+func RingAreaKm2(ring [][2]float64) float64 {
+	n := len(ring)
+	if n < 3 {
+		return 0
+	}
+
+	var sum float64
+	for i := 0; i < n; i++ {
+		p1 := ring[i]
+		p2 := ring[(i+1)%n]
+		lambda1 := p1[1] * math.Pi / 180
+		lambda2 := p2[1] * math.Pi / 180
+		phi1 := p1[0] * math.Pi / 180
+		phi2 := p2[0] * math.Pi / 180
+		sum += (lambda2 - lambda1) * (2 + math.Sin(phi1) + math.Sin(phi2))
+	}
+	return math.Abs(sum*earthRadiusMeters*earthRadiusMeters/2) / 1e6
+}
+
+// SimplifyRing đơn giản hóa một vòng polygon [lat, lon] bằng Douglas-Peucker với dung sai toleranceMeters.
+// Khoảng cách tính trên phép chiếu equirectangular quanh vĩ độ trung bình của vòng, đủ chính xác ở cỡ một tỉnh.
+// Vòng giữ nguyên nếu dung sai <= 0 hoặc kết quả còn dưới 3 đỉnh phân biệt.
+func SimplifyRing(ring [][2]float64, toleranceMeters float64) [][2]float64 {
+	if toleranceMeters <= 0 || len(ring) < 4 {
+		return ring
+	}
+
+	var latSum float64
+	for _, point := range ring {
+		latSum += point[0]
+	}
+	metersPerDegree := earthRadiusMeters * math.Pi / 180
+	cosLat := math.Cos(latSum / float64(len(ring)) * math.Pi / 180)
+	project := func(p [2]float64) (float64, float64) {
+		return p[1] * metersPerDegree * cosLat, p[0] * metersPerDegree
+	}
+
+	simplified := douglasPeucker(ring, toleranceMeters, project)
+	distinct := len(simplified)
+	if simplified[0] == simplified[len(simplified)-1] {
+		distinct--
+	}
+	if distinct < 3 {
+		return ring
+	}
+	return simplified
+}
+
+// douglasPeucker giữ lại các điểm của ring cách đoạn nối hai điểm giữ lại kề nó quá tolerance,
+// khoảng cách đo trên mặt phẳng sau phép chiếu project. Điểm đầu và cuối luôn được giữ.
+func douglasPeucker(ring [][2]float64, tolerance float64, project func([2]float64) (float64, float64)) [][2]float64 {
+	keep := make([]bool, len(ring))
+	keep[0], keep[len(ring)-1] = true, true
+	stack := [][2]int{{0, len(ring) - 1}}
+	for len(stack) > 0 {
+		segment := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		first, last := segment[0], segment[1]
+		ax, ay := project(ring[first])
+		bx, by := project(ring[last])
+
+		farthest, maxDistance := -1, tolerance
+		for i := first + 1; i < last; i++ {
+			px, py := project(ring[i])
+			if d := segmentDistance(px, py, ax, ay, bx, by); d > maxDistance {
+				farthest, maxDistance = i, d
+			}
+		}
+		if farthest >= 0 {
+			keep[farthest] = true
+			stack = append(stack, [2]int{first, farthest}, [2]int{farthest, last})
+		}
+	}
+
+	simplified := make([][2]float64, 0, len(ring))
+	for i, point := range ring {
+		if keep[i] {
+			simplified = append(simplified, point)
+		}
+	}
+	return simplified
+}
+
+// segmentDistance là khoảng cách từ điểm p tới đoạn thẳng ab trên mặt phẳng
+func segmentDistance(px, py, ax, ay, bx, by float64) float64 {
+	dx, dy := bx-ax, by-ay
+	if dx == 0 && dy == 0 {
+		return math.Hypot(px-ax, py-ay)
+	}
+	t := ((px-ax)*dx + (py-ay)*dy) / (dx*dx + dy*dy)
+	t = math.Max(0, math.Min(1, t))
+	return math.Hypot(px-(ax+t*dx), py-(ay+t*dy))
+}
+
+// PointInRing cho biết điểm (lat, lon) có nằm trong vòng polygon [lat, lon] không (ray casting)
+func PointInRing(lat, lon float64, ring [][2]float64) bool {
+	return pointInPolygon(lat, lon, ring)
+}
+
+// DistanceToRingMeters trả về khoảng cách (mét) từ điểm (lat, lon) tới cạnh gần nhất của vòng polygon,
+// tính trên phép chiếu equirectangular quanh điểm. Vòng được coi là khép kín.
+func DistanceToRingMeters(lat, lon float64, ring [][2]float64) float64 {
+	if len(ring) == 0 {
+		return math.Inf(1)
+	}
+	metersPerDegree := earthRadiusMeters * math.Pi / 180
+	cosLat := math.Cos(lat * math.Pi / 180)
+	project := func(p [2]float64) (float64, float64) {
+		return (p[1] - lon) * metersPerDegree * cosLat, (p[0] - lat) * metersPerDegree
+	}
+
+	minDistance := math.Inf(1)
+	prevX, prevY := project(ring[len(ring)-1])
+	for _, point := range ring {
+		x, y := project(point)
+		minDistance = math.Min(minDistance, segmentDistance(0, 0, prevX, prevY, x, y))
+		prevX, prevY = x, y
+	}
+	return minDistance
+}
@@ -0,0 +1,335 @@
+package util
+
+import (
+	"math"
+	"sort"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// MVTExtent là số đơn vị tọa độ trên mỗi cạnh tile (giá trị mặc định của Mapbox Vector Tile 2.1)
+const MVTExtent = 4096
+
+// Các số hiệu field và lệnh hình học theo vector_tile.proto (Mapbox Vector Tile 2.1)
+const (
+	mvtTileLayers = 3
+
+	mvtLayerName     = 1
+	mvtLayerFeatures = 2
+	mvtLayerKeys     = 3
+	mvtLayerValues   = 4
+	mvtLayerExtent   = 5
+	mvtLayerVersion  = 15
+
+	mvtFeatureID       = 1
+	mvtFeatureTags     = 2
+	mvtFeatureType     = 3
+	mvtFeatureGeometry = 4
+
+	mvtValueString = 1
+	mvtValueDouble = 3
+	mvtValueInt    = 4
+
+	mvtGeomPolygon = 3
+
+	mvtCmdMoveTo    = 1
+	mvtCmdLineTo    = 2
+	mvtCmdClosePath = 7
+)
+
+// TileBounds trả về khung (lat, lon) của tile z/x/y theo lưới Web Mercator (XYZ, y tính từ phía bắc)
+func TileBounds(z, x, y int) (minLat, minLon, maxLat, maxLon float64) {
+	n := math.Exp2(float64(z))
+	minLon = float64(x)/n*360 - 180
+	maxLon = float64(x+1)/n*360 - 180
+	maxLat = mercatorLat(float64(y) / n)
+	minLat = mercatorLat(float64(y+1) / n)
+	return minLat, minLon, maxLat, maxLon
+}
+
+func mercatorLat(v float64) float64 {
+	return math.Atan(math.Sinh(math.Pi*(1-2*v))) * 180 / math.Pi
+}
+
+// TileProjector chiếu tọa độ [lat, lon] sang tọa độ trong tile z/x/y (0..MVTExtent, trục y hướng xuống)
+type TileProjector struct {
+	scale  float64
+	tx, ty float64
+}
+
+// NewTileProjector tạo phép chiếu cho tile z/x/y
+func NewTileProjector(z, x, y int) TileProjector {
+	return TileProjector{scale: math.Exp2(float64(z)), tx: float64(x), ty: float64(y)}
+}
+
+// Project chiếu một điểm [lat, lon] sang tọa độ tile
+func (p TileProjector) Project(point [2]float64) [2]float64 {
+	lat := math.Max(-85.0511287798, math.Min(85.0511287798, point[0]))
+	sinLat := math.Sin(lat * math.Pi / 180)
+	wx := (point[1] + 180) / 360
+	wy := 0.5 - math.Log((1+sinLat)/(1-sinLat))/(4*math.Pi)
+	return [2]float64{(wx*p.scale - p.tx) * MVTExtent, (wy*p.scale - p.ty) * MVTExtent}
+}
+
+// TileRing chiếu vòng polygon [lat, lon] vào tile, cắt theo khung tile nới thêm buffer đơn vị mỗi phía,
+// đơn giản hóa với dung sai một đơn vị tile rồi làm tròn về số nguyên. Trả về nil khi vòng không còn diện tích.
+func (p TileProjector) TileRing(ring [][2]float64, buffer float64) [][2]int32 {
+	projected := make([][2]float64, len(ring))
+	for i, point := range ring {
+		projected[i] = p.Project(point)
+	}
+	clipped := clipRing(projected, -buffer, MVTExtent+buffer)
+	if len(clipped) < 3 {
+		return nil
+	}
+	clipped = append(clipped, clipped[0])
+	simplified := douglasPeucker(clipped, 1, func(q [2]float64) (float64, float64) { return q[0], q[1] })
+
+	result := make([][2]int32, 0, len(simplified))
+	for _, point := range simplified[:len(simplified)-1] {
+		q := [2]int32{int32(math.Round(point[0])), int32(math.Round(point[1]))}
+		if len(result) > 0 && result[len(result)-1] == q {
+			continue
+		}
+		result = append(result, q)
+	}
+	for len(result) > 1 && result[0] == result[len(result)-1] {
+		result = result[:len(result)-1]
+	}
+	if len(result) < 3 || ringArea(result) == 0 {
+		return nil
+	}
+	return result
+}
+
+// clipRing cắt vòng (không lặp điểm đầu ở cuối) theo hình vuông [min, max]² bằng Sutherland-Hodgman
+func clipRing(ring [][2]float64, min, max float64) [][2]float64 {
+	edges := []struct {
+		axis  int
+		value float64
+		keep  func(v, bound float64) bool
+	}{
+		{0, min, func(v, bound float64) bool { return v >= bound }},
+		{0, max, func(v, bound float64) bool { return v <= bound }},
+		{1, min, func(v, bound float64) bool { return v >= bound }},
+		{1, max, func(v, bound float64) bool { return v <= bound }},
+	}
+
+	output := ring
+	for _, edge := range edges {
+		if len(output) == 0 {
+			break
+		}
+		input := output
+		output = make([][2]float64, 0, len(input))
+		prev := input[len(input)-1]
+		prevIn := edge.keep(prev[edge.axis], edge.value)
+		for _, point := range input {
+			in := edge.keep(point[edge.axis], edge.value)
+			if in != prevIn {
+				t := (edge.value - prev[edge.axis]) / (point[edge.axis] - prev[edge.axis])
+				output = append(output, [2]float64{prev[0] + t*(point[0]-prev[0]), prev[1] + t*(point[1]-prev[1])})
+			}
+			if in {
+				output = append(output, point)
+			}
+			prev, prevIn = point, in
+		}
+	}
+	return output
+}
+
+// ringArea là hai lần diện tích có dấu của vòng trong tọa độ tile; dương khi vòng theo chiều kim đồng hồ
+// trên màn hình (trục y hướng xuống)
+func ringArea(ring [][2]int32) int64 {
+	var sum int64
+	for i := range ring {
+		a, b := ring[i], ring[(i+1)%len(ring)]
+		sum += int64(a[0])*int64(b[1]) - int64(b[0])*int64(a[1])
+	}
+	return sum
+}
+
+// MVTLayer là một layer của vector tile đang được dựng
+type MVTLayer struct {
+	name     string
+	features [][]byte
+	keys     []string
+	keyIndex map[string]uint64
+	values   [][]byte
+	valIndex map[string]uint64
+}
+
+// NewMVTLayer tạo layer rỗng tên name
+func NewMVTLayer(name string) *MVTLayer {
+	return &MVTLayer{name: name, keyIndex: make(map[string]uint64), valIndex: make(map[string]uint64)}
+}
+
+// Len trả về số feature của layer
+func (l *MVTLayer) Len() int {
+	return len(l.features)
+}
+
+// AddPolygon thêm một feature polygon. Mỗi phần tử của polygons là [vòng ngoài, các lỗ...] đã ở tọa độ tile;
+// chiều vòng được chỉnh theo spec (vòng ngoài thuận, lỗ ngược chiều kim đồng hồ). Thuộc tính nhận string,
+// số nguyên và số thực; giá trị kiểu khác bị bỏ qua.
+func (l *MVTLayer) AddPolygon(id uint64, properties map[string]any, polygons [][][][2]int32) {
+	var geometry []uint32
+	var cursor [2]int32
+	for _, polygon := range polygons {
+		for i, ring := range polygon {
+			if len(ring) < 3 {
+				continue
+			}
+			area := ringArea(ring)
+			if (i == 0 && area < 0) || (i > 0 && area > 0) {
+				ring = reversedRing(ring)
+			}
+			geometry = appendRingCommands(geometry, &cursor, ring)
+		}
+	}
+	if len(geometry) == 0 {
+		return
+	}
+
+	keys := make([]string, 0, len(properties))
+	for key := range properties {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var tags []uint32
+	for _, key := range keys {
+		value, ok := encodeMVTValue(properties[key])
+		if !ok {
+			continue
+		}
+		tags = append(tags, uint32(l.key(key)), uint32(l.value(value)))
+	}
+
+	var feature []byte
+	feature = protowire.AppendTag(feature, mvtFeatureID, protowire.VarintType)
+	feature = protowire.AppendVarint(feature, id)
+	if len(tags) > 0 {
+		feature = appendPacked(feature, mvtFeatureTags, tags)
+	}
+	feature = protowire.AppendTag(feature, mvtFeatureType, protowire.VarintType)
+	feature = protowire.AppendVarint(feature, mvtGeomPolygon)
+	feature = appendPacked(feature, mvtFeatureGeometry, geometry)
+	l.features = append(l.features, feature)
+}
+
+func (l *MVTLayer) key(key string) uint64 {
+	if i, ok := l.keyIndex[key]; ok {
+		return i
+	}
+	i := uint64(len(l.keys))
+	l.keys = append(l.keys, key)
+	l.keyIndex[key] = i
+	return i
+}
+
+func (l *MVTLayer) value(value []byte) uint64 {
+	if i, ok := l.valIndex[string(value)]; ok {
+		return i
+	}
+	i := uint64(len(l.values))
+	l.values = append(l.values, value)
+	l.valIndex[string(value)] = i
+	return i
+}
+
+// encodeMVTValue mã hóa một thuộc tính thành message Value
+func encodeMVTValue(v any) ([]byte, bool) {
+	var b []byte
+	switch value := v.(type) {
+	case string:
+		b = protowire.AppendTag(b, mvtValueString, protowire.BytesType)
+		b = protowire.AppendString(b, value)
+	case int:
+		b = protowire.AppendTag(b, mvtValueInt, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(int64(value)))
+	case int64:
+		b = protowire.AppendTag(b, mvtValueInt, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(value))
+	case float64:
+		b = protowire.AppendTag(b, mvtValueDouble, protowire.Fixed64Type)
+		b = protowire.AppendFixed64(b, math.Float64bits(value))
+	default:
+		return nil, false
+	}
+	return b, true
+}
+
+// appendRingCommands ghi MoveTo, LineTo và ClosePath cho một vòng; tọa độ là độ lệch zigzag so với
+// vị trí con trỏ, con trỏ được cập nhật tới điểm cuối của vòng
+func appendRingCommands(geometry []uint32, cursor *[2]int32, ring [][2]int32) []uint32 {
+	delta := func(point [2]int32) {
+		geometry = append(geometry,
+			uint32(protowire.EncodeZigZag(int64(point[0]-cursor[0]))),
+			uint32(protowire.EncodeZigZag(int64(point[1]-cursor[1]))))
+		*cursor = point
+	}
+
+	geometry = append(geometry, command(mvtCmdMoveTo, 1))
+	delta(ring[0])
+	geometry = append(geometry, command(mvtCmdLineTo, len(ring)-1))
+	for _, point := range ring[1:] {
+		delta(point)
+	}
+	return append(geometry, command(mvtCmdClosePath, 1))
+}
+
+func command(id uint32, count int) uint32 {
+	return id&0x7 | uint32(count)<<3
+}
+
+func reversedRing(ring [][2]int32) [][2]int32 {
+	reversed := make([][2]int32, len(ring))
+	for i, point := range ring {
+		reversed[len(ring)-1-i] = point
+	}
+	return reversed
+}
+
+func appendPacked(b []byte, field protowire.Number, values []uint32) []byte {
+	var packed []byte
+	for _, v := range values {
+		packed = protowire.AppendVarint(packed, uint64(v))
+	}
+	b = protowire.AppendTag(b, field, protowire.BytesType)
+	return protowire.AppendBytes(b, packed)
+}
+
+// EncodeMVT mã hóa các layer thành một vector tile; layer không có feature bị bỏ qua.
+// Tile không có layer nào là chuỗi rỗng.
+func EncodeMVT(layers ...*MVTLayer) []byte {
+	var tile []byte
+	for _, l := range layers {
+		if len(l.features) == 0 {
+			continue
+		}
+		var layer []byte
+		layer = protowire.AppendTag(layer, mvtLayerVersion, protowire.VarintType)
+		layer = protowire.AppendVarint(layer, 2)
+		layer = protowire.AppendTag(layer, mvtLayerName, protowire.BytesType)
+		layer = protowire.AppendString(layer, l.name)
+		for _, feature := range l.features {
+			layer = protowire.AppendTag(layer, mvtLayerFeatures, protowire.BytesType)
+			layer = protowire.AppendBytes(layer, feature)
+		}
+		for _, key := range l.keys {
+			layer = protowire.AppendTag(layer, mvtLayerKeys, protowire.BytesType)
+			layer = protowire.AppendString(layer, key)
+		}
+		for _, value := range l.values {
+			layer = protowire.AppendTag(layer, mvtLayerValues, protowire.BytesType)
+			layer = protowire.AppendBytes(layer, value)
+		}
+		layer = protowire.AppendTag(layer, mvtLayerExtent, protowire.VarintType)
+		layer = protowire.AppendVarint(layer, MVTExtent)
+
+		tile = protowire.AppendTag(tile, mvtTileLayers, protowire.BytesType)
+		tile = protowire.AppendBytes(tile, layer)
+	}
+	return tile
+}
@@ -0,0 +1,327 @@
+package util
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// decodedLayer và decodedFeature là kết quả giải mã một tile để so sánh với đầu vào của EncodeMVT
+type decodedLayer struct {
+	version  uint64
+	name     string
+	extent   uint64
+	features []decodedFeature
+}
+
+type decodedFeature struct {
+	id         uint64
+	geomType   uint64
+	properties map[string]any
+	rings      [][][2]int32
+}
+
+// decodeMVT giải mã tile theo vector_tile.proto, đủ cho các field mà EncodeMVT ghi
+func decodeMVT(t *testing.T, tile []byte) []decodedLayer {
+	t.Helper()
+	var layers []decodedLayer
+	forEachField(t, tile, func(num protowire.Number, typ protowire.Type, b []byte, v uint64) {
+		if num != mvtTileLayers {
+			t.Fatalf("unexpected tile field %d", num)
+		}
+		layers = append(layers, decodeLayer(t, b))
+	})
+	return layers
+}
+
+func decodeLayer(t *testing.T, data []byte) decodedLayer {
+	var layer decodedLayer
+	var keys []string
+	var values []any
+	var rawFeatures [][]byte
+	forEachField(t, data, func(num protowire.Number, typ protowire.Type, b []byte, v uint64) {
+		switch num {
+		case mvtLayerVersion:
+			layer.version = v
+		case mvtLayerName:
+			layer.name = string(b)
+		case mvtLayerFeatures:
+			rawFeatures = append(rawFeatures, b)
+		case mvtLayerKeys:
+			keys = append(keys, string(b))
+		case mvtLayerValues:
+			values = append(values, decodeValue(t, b))
+		case mvtLayerExtent:
+			layer.extent = v
+		default:
+			t.Fatalf("unexpected layer field %d", num)
+		}
+	})
+	// Feature tham chiếu keys/values của layer nên giải mã sau khi đã đọc hết layer
+	for _, raw := range rawFeatures {
+		layer.features = append(layer.features, decodeFeature(t, raw, keys, values))
+	}
+	return layer
+}
+
+func decodeValue(t *testing.T, data []byte) any {
+	var value any
+	forEachField(t, data, func(num protowire.Number, typ protowire.Type, b []byte, v uint64) {
+		switch num {
+		case mvtValueString:
+			value = string(b)
+		case mvtValueDouble:
+			value = math.Float64frombits(v)
+		case mvtValueInt:
+			value = int64(v)
+		default:
+			t.Fatalf("unexpected value field %d", num)
+		}
+	})
+	return value
+}
+
+func decodeFeature(t *testing.T, data []byte, keys []string, values []any) decodedFeature {
+	feature := decodedFeature{properties: make(map[string]any)}
+	forEachField(t, data, func(num protowire.Number, typ protowire.Type, b []byte, v uint64) {
+		switch num {
+		case mvtFeatureID:
+			feature.id = v
+		case mvtFeatureType:
+			feature.geomType = v
+		case mvtFeatureTags:
+			tags := unpack(t, b)
+			if len(tags)%2 != 0 {
+				t.Fatalf("odd tag count %d", len(tags))
+			}
+			for i := 0; i < len(tags); i += 2 {
+				feature.properties[keys[tags[i]]] = values[tags[i+1]]
+			}
+		case mvtFeatureGeometry:
+			feature.rings = decodeGeometry(t, unpack(t, b))
+		default:
+			t.Fatalf("unexpected feature field %d", num)
+		}
+	})
+	return feature
+}
+
+// decodeGeometry đọc lại các vòng từ chuỗi lệnh MoveTo/LineTo/ClosePath với tọa độ zigzag tương đối
+func decodeGeometry(t *testing.T, commands []uint64) [][][2]int32 {
+	var rings [][][2]int32
+	var cursor [2]int32
+	var ring [][2]int32
+	for i := 0; i < len(commands); {
+		id, count := commands[i]&0x7, int(commands[i]>>3)
+		i++
+		switch id {
+		case mvtCmdMoveTo, mvtCmdLineTo:
+			if id == mvtCmdMoveTo {
+				ring = nil
+			}
+			for j := 0; j < count; j++ {
+				cursor[0] += int32(protowire.DecodeZigZag(commands[i]))
+				cursor[1] += int32(protowire.DecodeZigZag(commands[i+1]))
+				ring = append(ring, cursor)
+				i += 2
+			}
+		case mvtCmdClosePath:
+			rings = append(rings, ring)
+		default:
+			t.Fatalf("unknown command %d", id)
+		}
+	}
+	return rings
+}
+
+func unpack(t *testing.T, b []byte) []uint64 {
+	var values []uint64
+	for len(b) > 0 {
+		v, n := protowire.ConsumeVarint(b)
+		if n < 0 {
+			t.Fatalf("bad packed varint: %v", protowire.ParseError(n))
+		}
+		values = append(values, v)
+		b = b[n:]
+	}
+	return values
+}
+
+func forEachField(t *testing.T, b []byte, fn func(num protowire.Number, typ protowire.Type, b []byte, v uint64)) {
+	t.Helper()
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			t.Fatalf("bad tag: %v", protowire.ParseError(n))
+		}
+		b = b[n:]
+		var bytes []byte
+		var v uint64
+		switch typ {
+		case protowire.VarintType:
+			v, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed64Type:
+			v, n = protowire.ConsumeFixed64(b)
+		case protowire.BytesType:
+			bytes, n = protowire.ConsumeBytes(b)
+		default:
+			t.Fatalf("unexpected wire type %d", typ)
+		}
+		if n < 0 {
+			t.Fatalf("bad field %d: %v", num, protowire.ParseError(n))
+		}
+		b = b[n:]
+		fn(num, typ, bytes, v)
+	}
+}
+
+func TestEncodeMVTRoundTrip(t *testing.T) {
+	// Vòng ngoài thuận chiều kim đồng hồ trên màn hình, lỗ ngược chiều
+	outer := [][2]int32{{100, 100}, {900, 100}, {900, 900}, {100, 900}}
+	hole := [][2]int32{{300, 300}, {300, 600}, {600, 600}, {600, 300}}
+	// Vòng ngoài sai chiều: encoder phải đảo lại
+	reversedOuter := [][2]int32{{2000, 2000}, {2000, 2500}, {2500, 2500}, {2500, 2000}}
+
+	communes := NewMVTLayer("communes")
+	communes.AddPolygon(1, map[string]any{"code": "00001", "name": "Xã A", "level": 6, "area": 12.5, "ignored": true},
+		[][][][2]int32{{outer, hole}})
+	communes.AddPolygon(2, map[string]any{"code": "00002", "level": int64(6)},
+		[][][][2]int32{{reversedOuter}, {outer}})
+	// Polygon không có vòng hợp lệ không tạo feature
+	communes.AddPolygon(3, map[string]any{"code": "00003"}, [][][][2]int32{{{{0, 0}, {1, 1}}}})
+	empty := NewMVTLayer("provinces")
+
+	if communes.Len() != 2 {
+		t.Fatalf("Len = %d, want 2", communes.Len())
+	}
+
+	layers := decodeMVT(t, EncodeMVT(empty, communes))
+	if len(layers) != 1 {
+		t.Fatalf("got %d layers, want 1 (empty layer skipped)", len(layers))
+	}
+	layer := layers[0]
+	if layer.name != "communes" || layer.version != 2 || layer.extent != MVTExtent {
+		t.Errorf("layer = name %q version %d extent %d", layer.name, layer.version, layer.extent)
+	}
+	if len(layer.features) != 2 {
+		t.Fatalf("got %d features, want 2", len(layer.features))
+	}
+
+	first := layer.features[0]
+	if first.id != 1 || first.geomType != mvtGeomPolygon {
+		t.Errorf("first feature id %d type %d", first.id, first.geomType)
+	}
+	wantProps := map[string]any{"code": "00001", "name": "Xã A", "level": int64(6), "area": 12.5}
+	if !reflect.DeepEqual(first.properties, wantProps) {
+		t.Errorf("properties = %v, want %v", first.properties, wantProps)
+	}
+	if !reflect.DeepEqual(first.rings, [][][2]int32{outer, hole}) {
+		t.Errorf("rings = %v", first.rings)
+	}
+	if ringArea(first.rings[0]) <= 0 || ringArea(first.rings[1]) >= 0 {
+		t.Errorf("winding: outer %d, hole %d", ringArea(first.rings[0]), ringArea(first.rings[1]))
+	}
+
+	second := layer.features[1]
+	if !reflect.DeepEqual(second.properties, map[string]any{"code": "00002", "level": int64(6)}) {
+		t.Errorf("properties = %v", second.properties)
+	}
+	if len(second.rings) != 2 {
+		t.Fatalf("got %d rings, want 2", len(second.rings))
+	}
+	if !reflect.DeepEqual(second.rings[0], reversedRing(reversedOuter)) {
+		t.Errorf("reversed outer ring = %v", second.rings[0])
+	}
+	if !reflect.DeepEqual(second.rings[1], outer) {
+		t.Errorf("second polygon ring = %v (cursor must carry across polygons)", second.rings[1])
+	}
+}
+
+func TestEncodeMVTEmpty(t *testing.T) {
+	if tile := EncodeMVT(); len(tile) != 0 {
+		t.Errorf("EncodeMVT() = %d bytes, want 0", len(tile))
+	}
+	if tile := EncodeMVT(NewMVTLayer("communes")); len(tile) != 0 {
+		t.Errorf("EncodeMVT(empty layer) = %d bytes, want 0", len(tile))
+	}
+}
+
+func TestTileBoundsProject(t *testing.T) {
+	minLat, minLon, maxLat, maxLon := TileBounds(0, 0, 0)
+	if minLon != -180 || maxLon != 180 || math.Abs(maxLat-85.0511287798) > 1e-6 || math.Abs(minLat+85.0511287798) > 1e-6 {
+		t.Errorf("TileBounds(0,0,0) = %v %v %v %v", minLat, minLon, maxLat, maxLon)
+	}
+
+	// Góc của tile chiếu về đúng góc khung tọa độ tile
+	z, x, y := 10, 812, 451
+	minLat, minLon, maxLat, maxLon = TileBounds(z, x, y)
+	p := NewTileProjector(z, x, y)
+	for _, tc := range []struct {
+		point [2]float64
+		want  [2]float64
+	}{
+		{[2]float64{maxLat, minLon}, [2]float64{0, 0}},
+		{[2]float64{minLat, maxLon}, [2]float64{MVTExtent, MVTExtent}},
+	} {
+		got := p.Project(tc.point)
+		if math.Abs(got[0]-tc.want[0]) > 1e-6 || math.Abs(got[1]-tc.want[1]) > 1e-6 {
+			t.Errorf("Project(%v) = %v, want %v", tc.point, got, tc.want)
+		}
+	}
+}
+
+func TestTileRing(t *testing.T) {
+	z, x, y := 10, 812, 451
+	minLat, minLon, maxLat, maxLon := TileBounds(z, x, y)
+	p := NewTileProjector(z, x, y)
+	lat := func(f float64) float64 { return maxLat - f*(maxLat-minLat) }
+	lon := func(f float64) float64 { return minLon + f*(maxLon-minLon) }
+
+	t.Run("inside", func(t *testing.T) {
+		ring := [][2]float64{{lat(0.25), lon(0.25)}, {lat(0.25), lon(0.75)}, {lat(0.75), lon(0.75)}, {lat(0.75), lon(0.25)}, {lat(0.25), lon(0.25)}}
+		got := p.TileRing(ring, 64)
+		if len(got) != 4 {
+			t.Fatalf("got %v, want 4 corners", got)
+		}
+		for i, point := range got {
+			want := p.Project(ring[i])
+			if math.Abs(float64(point[0])-want[0]) > 1 || math.Abs(float64(point[1])-want[1]) > 1 {
+				t.Errorf("point %d = %v, want ~%v", i, point, want)
+			}
+		}
+	})
+
+	t.Run("clipped to buffer", func(t *testing.T) {
+		// Vòng phủ kín tile và lân cận bị cắt về khung [-buffer, extent+buffer]
+		ring := [][2]float64{{lat(-1), lon(-1)}, {lat(-1), lon(2)}, {lat(2), lon(2)}, {lat(2), lon(-1)}}
+		got := p.TileRing(ring, 64)
+		if len(got) != 4 {
+			t.Fatalf("got %v, want 4 corners", got)
+		}
+		for _, point := range got {
+			for _, v := range point {
+				if v != -64 && v != MVTExtent+64 {
+					t.Errorf("point %v not on the buffer edge", point)
+				}
+			}
+		}
+	})
+
+	t.Run("outside", func(t *testing.T) {
+		ring := [][2]float64{{lat(2), lon(2)}, {lat(2), lon(3)}, {lat(3), lon(3)}}
+		if got := p.TileRing(ring, 64); got != nil {
+			t.Errorf("got %v, want nil", got)
+		}
+	})
+
+	t.Run("degenerate", func(t *testing.T) {
+		// Nhỏ hơn một đơn vị tile: làm tròn hết về một điểm
+		d := 0.0001
+		ring := [][2]float64{{lat(0.5), lon(0.5)}, {lat(0.5), lon(0.5 + d)}, {lat(0.5 + d), lon(0.5 + d)}}
+		if got := p.TileRing(ring, 64); got != nil {
+			t.Errorf("got %v, want nil", got)
+		}
+	})
+}